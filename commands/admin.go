@@ -0,0 +1,536 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+const (
+	adminAPIPath       = "/admin/"
+	adminClientTimeout = 10 * time.Second
+)
+
+// runnerPauses is the set of runners whose job requests were paused through
+// the admin API, by runner identity so that the pause outlives configuration
+// reloads and token resets
+type runnerPauses struct {
+	lock    sync.RWMutex
+	runners map[string]bool
+}
+
+// runnerIdentityKey identifies the runner by its ID on the GitLab instance, by
+// its name when its ID is unknown, and by its token as a last resort, so that
+// the state kept for the runner, like its pause or its drain, outlives
+// configuration reloads and token resets
+func runnerIdentityKey(runner *common.RunnerConfig) string {
+	switch {
+	case runner.ID != 0:
+		return fmt.Sprintf("id:%s#%d", runner.URL, runner.ID)
+	case runner.Name != "":
+		return "name:" + runner.Name
+	}
+
+	return "token:" + runner.Token
+}
+
+func (p *runnerPauses) set(runner *common.RunnerConfig, paused bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if !paused {
+		delete(p.runners, runnerIdentityKey(runner))
+		return
+	}
+
+	if p.runners == nil {
+		p.runners = make(map[string]bool)
+	}
+	p.runners[runnerIdentityKey(runner)] = true
+}
+
+func (p *runnerPauses) isPaused(runner *common.RunnerConfig) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.runners[runnerIdentityKey(runner)]
+}
+
+// adminRunner describes a runner of the configuration, as listed by the
+// admin API
+type adminRunner struct {
+	Runner   string `json:"runner"`
+	Name     string `json:"name"`
+	Executor string `json:"executor"`
+	Paused   bool   `json:"paused"`
+}
+
+type adminError struct {
+	Error string `json:"error"`
+}
+
+type adminStatus struct {
+	Status string `json:"status"`
+}
+
+// adminHandler handles an admin API request with the path segments matched by
+// the wildcards of its route, and returns the status and the value of the
+// JSON response
+type adminHandler func(r *http.Request, params []string) (int, interface{})
+
+// adminRoute is an endpoint of the admin API, whose pattern is relative to
+// adminAPIPath and where * matches a path segment
+type adminRoute struct {
+	method  string
+	pattern string
+	handler adminHandler
+}
+
+func (mr *RunCommand) adminRoutes() []adminRoute {
+	return []adminRoute{
+		{method: http.MethodGet, pattern: "jobs", handler: mr.adminListJobs},
+		{method: http.MethodPost, pattern: "jobs/*/cancel", handler: mr.adminCancelJob},
+		{method: http.MethodGet, pattern: "runners", handler: mr.adminListRunners},
+		{method: http.MethodPost, pattern: "runners/*/pause", handler: mr.adminPauseRunner(true)},
+		{method: http.MethodPost, pattern: "runners/*/resume", handler: mr.adminPauseRunner(false)},
+		{method: http.MethodGet, pattern: "drain", handler: mr.adminDrainStatus},
+		{method: http.MethodPost, pattern: "drain", handler: mr.adminDrain},
+		{method: http.MethodDelete, pattern: "drain", handler: mr.adminStopDrain},
+		{method: http.MethodGet, pattern: "config", handler: mr.adminConfig},
+	}
+}
+
+// serveAdminAPI serves the admin API on the metrics server. As the server
+// doesn't support TLS, the admin token would be sent in clear text over the
+// network, so only the requests from the loopback interface are served.
+func (mr *RunCommand) serveAdminAPI(mux *http.ServeMux, listenAddress string) {
+	if config := mr.getConfig(); config != nil && config.AdminToken != "" && !isLoopbackAddress(listenAddress) {
+		mr.log().
+			WithField("address", listenAddress).
+			Warningln("The metrics server listens on a non-loopback address without TLS, " +
+				"the admin API only accepts the requests from the loopback interface")
+	}
+
+	mux.Handle(adminAPIPath, mr.authenticateAdminRequest(http.HandlerFunc(mr.routeAdminRequest)))
+}
+
+// isLoopbackAddress returns whether the host of the address is a loopback
+// address or localhost
+func isLoopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// authenticateAdminRequest allows the requests whose bearer token is the
+// admin_token of the configuration, which is read for each request to
+// follow the configuration reloads
+func (mr *RunCommand) authenticateAdminRequest(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var token string
+		if config := mr.getConfig(); config != nil {
+			token = config.AdminToken
+		}
+
+		if token == "" {
+			writeAdminResponse(w, http.StatusNotFound, adminError{Error: "admin API disabled, admin_token isn't set"})
+			return
+		}
+
+		if !isLoopbackAddress(r.RemoteAddr) {
+			writeAdminResponse(w, http.StatusForbidden, adminError{Error: "the admin API is only served on the loopback interface"})
+			return
+		}
+
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gitlab-runner"`)
+			writeAdminResponse(w, http.StatusUnauthorized, adminError{Error: "invalid admin token"})
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func (mr *RunCommand) routeAdminRequest(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), adminAPIPath), "/"), "/")
+
+	methodNotAllowed := false
+	for _, route := range mr.adminRoutes() {
+		params, ok := matchAdminRoute(route.pattern, segments)
+		if !ok {
+			continue
+		}
+
+		if r.Method != route.method {
+			methodNotAllowed = true
+			continue
+		}
+
+		status, response := route.handler(r, params)
+		writeAdminResponse(w, status, response)
+		return
+	}
+
+	if methodNotAllowed {
+		writeAdminResponse(w, http.StatusMethodNotAllowed, adminError{Error: http.StatusText(http.StatusMethodNotAllowed)})
+		return
+	}
+
+	writeAdminResponse(w, http.StatusNotFound, adminError{Error: http.StatusText(http.StatusNotFound)})
+}
+
+// matchAdminRoute returns the unescaped path segments matched by the
+// wildcards of the pattern, and whether the path matches the pattern
+func matchAdminRoute(pattern string, segments []string) ([]string, bool) {
+	patternSegments := strings.Split(pattern, "/")
+	if len(patternSegments) != len(segments) {
+		return nil, false
+	}
+
+	var params []string
+	for i, segment := range patternSegments {
+		if segment != "*" {
+			if segment != segments[i] {
+				return nil, false
+			}
+			continue
+		}
+
+		param, err := url.PathUnescape(segments[i])
+		if err != nil || param == "" {
+			return nil, false
+		}
+		params = append(params, param)
+	}
+
+	return params, true
+}
+
+func writeAdminResponse(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set(common.ContentType, "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(response)
+}
+
+func (mr *RunCommand) adminListJobs(_ *http.Request, _ []string) (int, interface{}) {
+	return http.StatusOK, mr.buildsHelper.runningJobs()
+}
+
+func (mr *RunCommand) adminCancelJob(_ *http.Request, params []string) (int, interface{}) {
+	jobID, err := strconv.ParseInt(params[0], 10, 64)
+	if err != nil {
+		return http.StatusBadRequest, adminError{Error: fmt.Sprintf("invalid job ID %q", params[0])}
+	}
+
+	found, canceled := mr.buildsHelper.cancelBuild(jobID)
+	if !found {
+		return http.StatusNotFound, adminError{Error: fmt.Sprintf("job %d isn't running", jobID)}
+	}
+
+	if !canceled {
+		return http.StatusConflict, adminError{Error: fmt.Sprintf("job %d can't be canceled in its current state", jobID)}
+	}
+
+	mr.log().WithField("job", jobID).Warningln("Job canceled through the admin API")
+
+	return http.StatusAccepted, adminStatus{Status: "canceling"}
+}
+
+func (mr *RunCommand) adminListRunners(_ *http.Request, _ []string) (int, interface{}) {
+	config := mr.getConfig()
+
+	runners := make([]adminRunner, 0, len(config.Runners))
+	for _, runner := range config.Runners {
+		runners = append(runners, mr.newAdminRunner(runner))
+	}
+
+	return http.StatusOK, runners
+}
+
+// adminPauseRunner returns the handler pausing or resuming the job requests
+// of the runners whose short token or name is the path parameter
+func (mr *RunCommand) adminPauseRunner(paused bool) adminHandler {
+	return func(_ *http.Request, params []string) (int, interface{}) {
+		var runners []adminRunner
+		for _, runner := range mr.findRunners(params[0]) {
+			mr.runnerPauses.set(runner, paused)
+			runners = append(runners, mr.newAdminRunner(runner))
+
+			mr.log().
+				WithFields(logrus.Fields{
+					"runner":      runner.ShortDescription(),
+					"runner_name": runner.Name,
+					"paused":      paused,
+				}).
+				Warningln("Job requests of the runner changed through the admin API")
+		}
+
+		if len(runners) == 0 {
+			return http.StatusNotFound, adminError{Error: fmt.Sprintf("runner %q not found", params[0])}
+		}
+
+		return http.StatusOK, runners
+	}
+}
+
+// findRunners returns the runners of the configuration whose short token or
+// name is the reference
+func (mr *RunCommand) findRunners(reference string) []*common.RunnerConfig {
+	var runners []*common.RunnerConfig
+	for _, runner := range mr.getConfig().Runners {
+		if runner.ShortDescription() == reference || runner.Name == reference {
+			runners = append(runners, runner)
+		}
+	}
+
+	return runners
+}
+
+func (mr *RunCommand) newAdminRunner(runner *common.RunnerConfig) adminRunner {
+	return adminRunner{
+		Runner:   runner.ShortDescription(),
+		Name:     runner.Name,
+		Executor: runner.Executor,
+		Paused:   mr.runnerPauses.isPaused(runner),
+	}
+}
+
+// adminConfig returns the effective configuration, with the references of
+// the settings kept and the secrets redacted
+func (mr *RunCommand) adminConfig(_ *http.Request, _ []string) (int, interface{}) {
+	config, err := mr.getConfig().WithReferences()
+	if err != nil {
+		return http.StatusInternalServerError, adminError{Error: err.Error()}
+	}
+
+	document, err := redactedConfigDocument(config)
+	if err != nil {
+		return http.StatusInternalServerError, adminError{Error: err.Error()}
+	}
+
+	return http.StatusOK, document
+}
+
+// adminRedactedSettings are the settings, by key path where * matches any
+// key or index, whose values are redacted besides the tokens, passwords and
+// keys. The names of the variables and options they define are kept.
+var adminRedactedSettings = []string{
+	"sentry_dsn",
+	"runners.*.environment",
+	"runners.*.machine.MachineOptions",
+	"runners.*.autoscaler.plugin_config",
+}
+
+// redactedConfigDocument returns the configuration as the document of its
+// TOML encoding, with the values of the secret settings, of the settings of
+// adminRedactedSettings and the credentials of the URLs redacted
+func redactedConfigDocument(config *common.Config) (map[string]interface{}, error) {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(config); err != nil {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+	if _, err := toml.Decode(buf.String(), &document); err != nil {
+		return nil, err
+	}
+
+	redactConfigValue(document, "", false)
+
+	return document, nil
+}
+
+// redactConfigValue redacts the settings of the value, whose key path is the
+// given one, and returns the redacted value. All the strings of the value are
+// redacted when it's a redacted setting.
+func redactConfigValue(value interface{}, path string, redacted bool) interface{} {
+	redacted = redacted || isAdminRedactedSetting(path)
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			childPath := joinSettingKey(path, key)
+			if _, ok := child.(string); ok && isSecretSetting(childPath) {
+				v[key] = redactSetting(child.(string))
+				continue
+			}
+
+			v[key] = redactConfigValue(child, childPath, redacted)
+		}
+	case []map[string]interface{}:
+		for i, child := range v {
+			redactConfigValue(child, joinSettingKey(path, strconv.Itoa(i)), redacted)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactConfigValue(child, joinSettingKey(path, strconv.Itoa(i)), redacted)
+		}
+	case string:
+		if redacted {
+			return redactDefinition(v)
+		}
+
+		return redactURLCredentials(v)
+	}
+
+	return value
+}
+
+func isAdminRedactedSetting(path string) bool {
+	keys := strings.Split(path, ".")
+
+	for _, setting := range adminRedactedSettings {
+		patternKeys := strings.Split(setting, ".")
+		if len(patternKeys) != len(keys) {
+			continue
+		}
+
+		matches := true
+		for i, key := range patternKeys {
+			if key != "*" && key != keys[i] {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactDefinition redacts the value of a NAME=value definition, or the whole
+// value when it doesn't define a name
+func redactDefinition(value string) string {
+	if name, _, ok := strings.Cut(value, "="); ok {
+		return name + "=" + maskedToken
+	}
+
+	return redactSetting(value)
+}
+
+// redactURLCredentials redacts the user information of a URL
+func redactURLCredentials(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil || u.Host == "" {
+		return value
+	}
+
+	u.User = nil
+	return strings.Replace(u.String(), "://", "://"+maskedToken+"@", 1)
+}
+
+// adminClient is the client of the admin API of a running runner process
+type adminClient struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// newAdminClient returns the client of the admin API served on the listen
+// address of the configuration, authenticated with its admin token
+func (c *configOptionsWithListenAddress) newAdminClient() (*adminClient, error) {
+	address, err := c.listenAddress()
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address: %w", err)
+	}
+
+	if address == "" {
+		return nil, errors.New("listen_address isn't set, the runner process can't be reached")
+	}
+
+	token := c.getConfig().AdminToken
+	if token == "" {
+		return nil, errors.New("admin_token isn't set, the admin API of the runner process is disabled")
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address: %w", err)
+	}
+
+	// The process listening on all the interfaces is reached on the
+	// loopback interface
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+
+	return &adminClient{
+		url:    "http://" + net.JoinHostPort(host, port) + adminAPIPath,
+		token:  token,
+		client: &http.Client{Timeout: adminClientTimeout},
+	}, nil
+}
+
+// do sends the request to the endpoint of the admin API, with the request
+// value as JSON body, and decodes the JSON response into the response value
+func (a *adminClient) do(method, path string, request, response interface{}) error {
+	var body io.Reader
+	if request != nil {
+		encoded, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, a.url+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	if body != nil {
+		req.Header.Set(common.ContentType, "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr adminError
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			apiErr.Error = resp.Status
+		}
+
+		return fmt.Errorf("%s %s: %s", method, adminAPIPath+path, apiErr.Error)
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(response)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"gitlab.com/gitlab-org/gitlab-runner/common"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers"
@@ -61,9 +62,24 @@ type runnerCounter struct {
 	requestConcurrencyExceeded int
 }
 
+// runningJob describes a job being processed, as listed by the admin API
+type runningJob struct {
+	ID              int64     `json:"id"`
+	URL             string    `json:"url"`
+	ProjectID       int64     `json:"project_id"`
+	Runner          string    `json:"runner"`
+	RunnerName      string    `json:"runner_name"`
+	State           string    `json:"state"`
+	Stage           string    `json:"stage"`
+	ExecutorStage   string    `json:"executor_stage"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+}
+
 type buildsHelper struct {
 	counters map[string]*runnerCounter
 	builds   []*common.Build
+	traces   map[*common.Build]common.JobTrace
 	lock     sync.Mutex
 
 	jobsTotal                 *prometheus.CounterVec
@@ -211,6 +227,7 @@ func (b *buildsHelper) removeBuild(deleteBuild *common.Build) bool {
 	for idx, build := range b.builds {
 		if build == deleteBuild {
 			b.builds = append(b.builds[0:idx], b.builds[idx+1:]...)
+			delete(b.traces, build)
 
 			return true
 		}
@@ -219,6 +236,75 @@ func (b *buildsHelper) removeBuild(deleteBuild *common.Build) bool {
 	return false
 }
 
+// setBuildTrace records the trace of a build added to the list, used to
+// cancel the build
+func (b *buildsHelper) setBuildTrace(build *common.Build, trace common.JobTrace) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.traces == nil {
+		b.traces = make(map[*common.Build]common.JobTrace)
+	}
+	b.traces[build] = trace
+}
+
+// cancelBuild cancels the build of the job like GitLab does, running the
+// after_script, and returns whether the job was found and whether it could
+// be canceled
+func (b *buildsHelper) cancelBuild(jobID int64) (bool, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, build := range b.builds {
+		if build.ID != jobID {
+			continue
+		}
+
+		trace := b.traces[build]
+		return true, trace != nil && trace.Cancel()
+	}
+
+	return false, false
+}
+
+// runnerBuilds returns the builds of the runners matching
+func (b *buildsHelper) runnerBuilds(match func(runner *common.RunnerConfig) bool) []*common.Build {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var builds []*common.Build
+	for _, build := range b.builds {
+		if match(build.Runner) {
+			builds = append(builds, build)
+		}
+	}
+
+	return builds
+}
+
+func (b *buildsHelper) runningJobs() []runningJob {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	jobs := make([]runningJob, 0, len(b.builds))
+	for _, build := range b.builds {
+		jobs = append(jobs, runningJob{
+			ID:              build.ID,
+			URL:             build.JobURL(),
+			ProjectID:       build.JobInfo.ProjectID,
+			Runner:          build.Runner.ShortDescription(),
+			RunnerName:      build.Runner.Name,
+			State:           string(build.CurrentState()),
+			Stage:           string(build.CurrentStage()),
+			ExecutorStage:   string(build.CurrentExecutorStage()),
+			StartedAt:       build.StartedAt(),
+			DurationSeconds: build.Duration().Seconds(),
+		})
+	}
+
+	return jobs
+}
+
 func (b *buildsHelper) buildsCount() int {
 	b.lock.Lock()
 	defer b.lock.Unlock()
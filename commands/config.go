@@ -33,6 +33,8 @@ type configAccessCollector struct {
 	loaded       prometheus.Counter
 	savingError  prometheus.Counter
 	saved        prometheus.Counter
+
+	runnerChanges *prometheus.CounterVec
 }
 
 func newConfigAccessCollector() *configAccessCollector {
@@ -53,6 +55,13 @@ func newConfigAccessCollector() *configAccessCollector {
 			Name: "gitlab_runner_configuration_saved_total",
 			Help: "Total number of times the configuration file was saved by Runner process",
 		}),
+		runnerChanges: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gitlab_runner_configuration_runner_changes_total",
+				Help: "Total number of runners added, removed or modified by configuration reloads",
+			},
+			[]string{"change"},
+		),
 	}
 }
 
@@ -61,6 +70,7 @@ func (c *configAccessCollector) Describe(descs chan<- *prometheus.Desc) {
 	c.loaded.Describe(descs)
 	c.savingError.Describe(descs)
 	c.saved.Describe(descs)
+	c.runnerChanges.Describe(descs)
 }
 
 func (c *configAccessCollector) Collect(metrics chan<- prometheus.Metric) {
@@ -68,16 +78,19 @@ func (c *configAccessCollector) Collect(metrics chan<- prometheus.Metric) {
 	c.loaded.Collect(metrics)
 	c.savingError.Collect(metrics)
 	c.saved.Collect(metrics)
+	c.runnerChanges.Collect(metrics)
 }
 
 type configOptions struct {
 	configMutex         sync.Mutex
 	config              *common.Config
 	loadedSystemIDState *common.SystemIDState
+	layers              configLayers
 
 	configAccessCollector *configAccessCollector
 
 	ConfigFile string `short:"c" long:"config" env:"CONFIG_FILE" description:"Config file"`
+	ConfigDir  string `long:"config-dir" env:"CONFIG_DIR" description:"Directory of configuration fragments merged into the config file (default: conf.d in the directory of the config file)"`
 }
 
 // getConfig returns a copy of the config as it was during the function call.
@@ -100,7 +113,12 @@ func (c *configOptions) getConfig() *common.Config {
 }
 
 func (c *configOptions) saveConfig() error {
-	err := c.config.SaveConfig(c.ConfigFile)
+	var err error
+	if c.layers.layered {
+		err = c.saveLayeredConfig()
+	} else {
+		err = c.config.SaveConfig(c.ConfigFile)
+	}
 	if err != nil {
 		c.onConfigurationAccessCollector(func(m *configAccessCollector) {
 			m.savingError.Inc()
@@ -128,8 +146,7 @@ func (c *configOptions) loadConfig() error {
 	c.configMutex.Lock()
 	defer c.configMutex.Unlock()
 
-	config := common.NewConfig()
-	err := config.LoadConfig(c.ConfigFile)
+	config, layers, err := c.readConfig(c.ConfigFile)
 	if err != nil {
 		c.onConfigurationAccessCollector(func(m *configAccessCollector) {
 			m.loadingError.Inc()
@@ -158,6 +175,7 @@ func (c *configOptions) loadConfig() error {
 	}
 
 	c.config = config
+	c.layers = layers
 	for _, runnerCfg := range c.config.Runners {
 		runnerCfg.SystemIDState = systemIDState
 	}
@@ -167,6 +185,33 @@ func (c *configOptions) loadConfig() error {
 	return nil
 }
 
+// readConfig reads the configuration as it's loaded when the configuration
+// file is the given one: the fragments directory, the references and the
+// runner state file are the ones of the configuration file
+func (c *configOptions) readConfig(file string) (*common.Config, configLayers, error) {
+	config := common.NewConfig()
+	if err := config.LoadConfig(file); err != nil {
+		return nil, configLayers{}, err
+	}
+
+	layers, err := c.loadConfigLayers(config)
+	if err != nil {
+		return nil, configLayers{}, err
+	}
+
+	err = config.ResolveReferences(newConfigReferenceResolver().resolve)
+	if err != nil {
+		return nil, configLayers{}, err
+	}
+
+	err = c.applyRunnerState(config, layers)
+	if err != nil {
+		return nil, configLayers{}, err
+	}
+
+	return config, layers, nil
+}
+
 func (c *configOptions) loadSystemID(filePath string) (*common.SystemIDState, error) {
 	systemIDState := common.NewSystemIDState()
 	err := systemIDState.LoadFromFile(filePath)
@@ -0,0 +1,359 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+type runnerChangeKind string
+
+const (
+	runnerAdded    runnerChangeKind = "added"
+	runnerRemoved  runnerChangeKind = "removed"
+	runnerModified runnerChangeKind = "modified"
+)
+
+// settingChange is a setting which differs between two configurations. The
+// values are empty when the setting isn't set.
+type settingChange struct {
+	Key string `json:"key"`
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+func (c settingChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Key, settingValue(c.Old), settingValue(c.New))
+}
+
+func settingValue(value string) string {
+	if value == "" {
+		return "(unset)"
+	}
+
+	return value
+}
+
+// runnerChange is a runner added, removed or modified between two
+// configurations, with the settings which changed for a modified runner
+type runnerChange struct {
+	Kind     runnerChangeKind `json:"kind"`
+	Name     string           `json:"name"`
+	Token    string           `json:"token"`
+	Settings []settingChange  `json:"settings,omitempty"`
+
+	// Previous is the runner in the old configuration, nil when added
+	Previous *common.RunnerConfig `json:"-"`
+	// Runner is the runner in the new configuration, nil when removed
+	Runner *common.RunnerConfig `json:"-"`
+}
+
+// settingKeys returns the keys of the settings which changed
+func (c runnerChange) settingKeys() []string {
+	keys := make([]string, 0, len(c.Settings))
+	for _, setting := range c.Settings {
+		keys = append(keys, setting.Key)
+	}
+
+	return keys
+}
+
+// configDiff is the effective change between two configurations: the
+// global settings and the runners which changed
+type configDiff struct {
+	Global  []settingChange `json:"global,omitempty"`
+	Runners []runnerChange  `json:"runners,omitempty"`
+}
+
+func (d configDiff) Empty() bool {
+	return len(d.Global) == 0 && len(d.Runners) == 0
+}
+
+func (d configDiff) write(w io.Writer) {
+	if d.Empty() {
+		_, _ = fmt.Fprintln(w, "No changes")
+		return
+	}
+
+	for _, change := range d.Global {
+		_, _ = fmt.Fprintln(w, "~ global", change)
+	}
+
+	for _, change := range d.Runners {
+		var sign string
+		switch change.Kind {
+		case runnerAdded:
+			sign = "+"
+		case runnerRemoved:
+			sign = "-"
+		default:
+			sign = "~"
+		}
+
+		_, _ = fmt.Fprintf(w, "%s runner %q (%s) %s\n", sign, change.Name, change.Token, change.Kind)
+		for _, setting := range change.Settings {
+			_, _ = fmt.Fprintln(w, "    "+setting.String())
+		}
+	}
+}
+
+// diffConfigs returns the change from the old to the new configuration. The
+// runners are matched by URL and token, then by name for the runners whose
+// token changed. The values of the secret settings, and of the settings which
+// had a reference in either configuration, are redacted.
+func diffConfigs(oldConfig, newConfig *common.Config) (configDiff, error) {
+	var diff configDiff
+
+	oldGlobal := *oldConfig
+	oldGlobal.Runners = nil
+	newGlobal := *newConfig
+	newGlobal.Runners = nil
+
+	global, err := diffSettings(&oldGlobal, &newGlobal, func(key string) bool {
+		return oldConfig.HasReference(key) || newConfig.HasReference(key)
+	})
+	if err != nil {
+		return diff, err
+	}
+	diff.Global = global
+
+	matched := make(map[*common.RunnerConfig]*common.RunnerConfig)
+	oldRunners := append([]*common.RunnerConfig{}, oldConfig.Runners...)
+
+	match := func(same func(a, b *common.RunnerConfig) bool) {
+		for _, newRunner := range newConfig.Runners {
+			if _, ok := matched[newRunner]; ok {
+				continue
+			}
+
+			for i, oldRunner := range oldRunners {
+				if oldRunner != nil && same(oldRunner, newRunner) {
+					matched[newRunner] = oldRunner
+					oldRunners[i] = nil
+					break
+				}
+			}
+		}
+	}
+	match(func(a, b *common.RunnerConfig) bool { return a.SameAs(&b.RunnerCredentials) })
+	match(func(a, b *common.RunnerConfig) bool { return a.Name == b.Name })
+
+	for _, oldRunner := range oldRunners {
+		if oldRunner != nil {
+			diff.Runners = append(diff.Runners, newRunnerChange(runnerRemoved, oldRunner, nil, nil))
+		}
+	}
+
+	for _, newRunner := range newConfig.Runners {
+		oldRunner, ok := matched[newRunner]
+		if !ok {
+			diff.Runners = append(diff.Runners, newRunnerChange(runnerAdded, nil, newRunner, nil))
+			continue
+		}
+
+		settings, err := diffSettings(oldRunner, newRunner, func(key string) bool {
+			return oldConfig.RunnerHasReference(oldRunner, key) || newConfig.RunnerHasReference(newRunner, key)
+		})
+		if err != nil {
+			return diff, err
+		}
+
+		if len(settings) > 0 {
+			diff.Runners = append(diff.Runners, newRunnerChange(runnerModified, oldRunner, newRunner, settings))
+		}
+	}
+
+	return diff, nil
+}
+
+func newRunnerChange(
+	kind runnerChangeKind,
+	oldRunner, newRunner *common.RunnerConfig,
+	settings []settingChange,
+) runnerChange {
+	runner := newRunner
+	if runner == nil {
+		runner = oldRunner
+	}
+
+	return runnerChange{
+		Kind:     kind,
+		Name:     runner.Name,
+		Token:    runner.ShortDescription(),
+		Settings: settings,
+		Previous: oldRunner,
+		Runner:   newRunner,
+	}
+}
+
+// diffSettings returns the settings, as TOML keys, which differ between the
+// old and the new values. The values of the secret settings and of the
+// referenced ones are redacted.
+func diffSettings(oldValue, newValue interface{}, referenced func(key string) bool) ([]settingChange, error) {
+	oldSettings, err := flattenSettings(oldValue)
+	if err != nil {
+		return nil, err
+	}
+
+	newSettings, err := flattenSettings(newValue)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool)
+	for key := range oldSettings {
+		keys[key] = true
+	}
+	for key := range newSettings {
+		keys[key] = true
+	}
+
+	var changes []settingChange
+	for key := range keys {
+		if oldSettings[key] == newSettings[key] {
+			continue
+		}
+
+		change := settingChange{Key: key, Old: oldSettings[key], New: newSettings[key]}
+		if isSecretSetting(key) || referenced(key) {
+			change.Old = redactSetting(change.Old)
+			change.New = redactSetting(change.New)
+		}
+
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes, nil
+}
+
+// flattenSettings returns the settings of the value encoded as TOML, by the
+// path of their key, with the values encoded as JSON
+func flattenSettings(value interface{}) (map[string]string, error) {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(value); err != nil {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+	if _, err := toml.Decode(buf.String(), &document); err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]string)
+
+	var flatten func(prefix string, value interface{}) error
+	flatten = func(prefix string, value interface{}) error {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				if err := flatten(joinSettingKey(prefix, key), child); err != nil {
+					return err
+				}
+			}
+			return nil
+		case []map[string]interface{}:
+			for i, child := range v {
+				if err := flatten(joinSettingKey(prefix, strconv.Itoa(i)), child); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		settings[prefix] = string(encoded)
+
+		return nil
+	}
+
+	return settings, flatten("", document)
+}
+
+func joinSettingKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + "." + key
+}
+
+// isSecretSetting returns whether the setting is a token, a password or a
+// key whose value mustn't be shown
+func isSecretSetting(key string) bool {
+	name := strings.ToLower(key[strings.LastIndex(key, ".")+1:])
+
+	switch name {
+	case "accesskey", "secretkey", "privatekey", "accountkey":
+		return true
+	}
+
+	return strings.HasSuffix(name, "token") ||
+		strings.Contains(name, "password") ||
+		strings.Contains(name, "secret")
+}
+
+func redactSetting(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	return maskedToken
+}
+
+type ConfigDiffCommand struct {
+	configOptions
+}
+
+func (c *ConfigDiffCommand) Execute(context *cli.Context) {
+	if len(context.Args()) != 1 {
+		_ = cli.ShowSubcommandHelp(context)
+		os.Exit(1)
+	}
+
+	diff, err := c.diffConfigFiles(context.Args().Get(0))
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+
+	diff.write(os.Stdout)
+}
+
+// diffConfigFiles returns the change applied by reloading the configuration
+// when the configuration file is replaced by the new one. Both are loaded like
+// the runner loads its configuration, with the fragments directory, the
+// references and the runner state file of the configuration file.
+func (c *ConfigDiffCommand) diffConfigFiles(newFile string) (configDiff, error) {
+	current, _, err := c.readConfig(c.ConfigFile)
+	if err != nil {
+		return configDiff{}, fmt.Errorf("loading %s: %w", c.ConfigFile, err)
+	}
+
+	if _, err := os.Stat(newFile); err != nil {
+		return configDiff{}, err
+	}
+
+	config, _, err := c.readConfig(newFile)
+	if err != nil {
+		return configDiff{}, fmt.Errorf("loading %s: %w", newFile, err)
+	}
+
+	return diffConfigs(current, config)
+}
@@ -0,0 +1,227 @@
+//go:build !integration
+
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+func newDiffTestRunner(name, token string) *common.RunnerConfig {
+	return &common.RunnerConfig{
+		Name: name,
+		RunnerCredentials: common.RunnerCredentials{
+			URL:   "https://gitlab.example.com",
+			Token: token,
+		},
+		RunnerSettings: common.RunnerSettings{
+			Executor: "docker",
+			Docker:   &common.DockerConfig{Image: "alpine"},
+		},
+	}
+}
+
+func TestDiffConfigs(t *testing.T) {
+	unchanged := newDiffTestRunner("unchanged", "glrt-unchanged12345")
+	modified := newDiffTestRunner("modified", "glrt-modified123456")
+	rotated := newDiffTestRunner("rotated", "glrt-rotated1234567")
+	removed := newDiffTestRunner("removed", "glrt-removed1234567")
+
+	oldConfig := &common.Config{
+		Concurrent: 4,
+		Runners:    []*common.RunnerConfig{unchanged, modified, rotated, removed},
+	}
+
+	newModified := newDiffTestRunner("modified", "glrt-modified123456")
+	newModified.Docker.Image = "ubuntu"
+	newModified.Limit = 2
+
+	newRotated := newDiffTestRunner("rotated", "glrt-newtoken123456")
+	added := newDiffTestRunner("added", "glrt-added123456789")
+
+	newConfig := &common.Config{
+		Concurrent: 8,
+		Runners: []*common.RunnerConfig{
+			newDiffTestRunner("unchanged", "glrt-unchanged12345"),
+			newModified,
+			newRotated,
+			added,
+		},
+	}
+
+	diff, err := diffConfigs(oldConfig, newConfig)
+	require.NoError(t, err)
+
+	assert.Equal(t, configDiff{
+		Global: []settingChange{{Key: "concurrent", Old: "4", New: "8"}},
+		Runners: []runnerChange{
+			{Kind: runnerRemoved, Name: "removed", Token: "removed12", Previous: removed},
+			{
+				Kind:  runnerModified,
+				Name:  "modified",
+				Token: "modified1",
+				Settings: []settingChange{
+					{Key: "docker.image", Old: `"alpine"`, New: `"ubuntu"`},
+					{Key: "limit", New: "2"},
+				},
+				Previous: modified,
+				Runner:   newModified,
+			},
+			{
+				Kind:     runnerModified,
+				Name:     "rotated",
+				Token:    "newtoken1",
+				Settings: []settingChange{{Key: "token", Old: "[MASKED]", New: "[MASKED]"}},
+				Previous: rotated,
+				Runner:   newRotated,
+			},
+			{Kind: runnerAdded, Name: "added", Token: "added1234", Runner: added},
+		},
+	}, diff)
+
+	buf := new(bytes.Buffer)
+	diff.write(buf)
+	assert.Equal(t, `~ global concurrent: 4 -> 8
+- runner "removed" (removed12) removed
+~ runner "modified" (modified1) modified
+    docker.image: "alpine" -> "ubuntu"
+    limit: (unset) -> 2
+~ runner "rotated" (newtoken1) modified
+    token: [MASKED] -> [MASKED]
++ runner "added" (added1234) added
+`, buf.String())
+}
+
+func TestDiffConfigsRedactsReferences(t *testing.T) {
+	load := func(t *testing.T, document string, values map[string]string) *common.Config {
+		config := common.NewConfig()
+		_, err := toml.Decode(document, config)
+		require.NoError(t, err)
+		require.NoError(t, config.ResolveReferences(func(_, reference string) (string, error) {
+			return values[reference], nil
+		}))
+
+		return config
+	}
+
+	const document = `
+sentry_dsn = "${env:SENTRY_DSN}"
+
+[[runners]]
+  name = "runner"
+  url = "https://gitlab.example.com"
+  token = "glrt-token123456789"
+  executor = "shell"
+  [runners.cache.gcs]
+    AccessID = "${env:SECRET}"
+`
+
+	oldConfig := load(t, document, map[string]string{"SENTRY_DSN": "https://old@sentry", "SECRET": "old"})
+	newConfig := load(t, document, map[string]string{"SENTRY_DSN": "https://new@sentry", "SECRET": "new"})
+
+	diff, err := diffConfigs(oldConfig, newConfig)
+	require.NoError(t, err)
+
+	assert.Equal(t, []settingChange{{Key: "sentry_dsn", Old: "[MASKED]", New: "[MASKED]"}}, diff.Global)
+	require.Len(t, diff.Runners, 1)
+	assert.Equal(t, []settingChange{{Key: "cache.gcs.AccessID", Old: "[MASKED]", New: "[MASKED]"}}, diff.Runners[0].Settings)
+}
+
+func TestDiffConfigsNoChanges(t *testing.T) {
+	config := &common.Config{Runners: []*common.RunnerConfig{newDiffTestRunner("runner", "glrt-token123456789")}}
+
+	diff, err := diffConfigs(config, config)
+	require.NoError(t, err)
+	assert.True(t, diff.Empty())
+
+	buf := new(bytes.Buffer)
+	diff.write(buf)
+	assert.Equal(t, "No changes\n", buf.String())
+}
+
+func TestDiffConfigFiles(t *testing.T) {
+	t.Setenv("DIFF_TEST_RUNNER_TOKEN", "glrt-main-token")
+
+	dir := t.TempDir()
+	writeDiffTestFile(t, filepath.Join(dir, "config.toml"), `
+concurrent = 1
+
+[[runners]]
+  name = "main"
+  url = "https://gitlab.example.com"
+  token = "${env:DIFF_TEST_RUNNER_TOKEN}"
+  executor = "shell"
+`)
+	writeDiffTestFile(t, filepath.Join(dir, "new.toml"), `
+concurrent = 2
+
+[[runners]]
+  name = "main"
+  url = "https://gitlab.example.com"
+  token = "glrt-main-token"
+  executor = "shell"
+`)
+	writeDiffTestFile(t, filepath.Join(dir, "conf.d", "runner.toml"), `
+[[runners]]
+  name = "layered"
+  url = "https://gitlab.example.com"
+  token = "glrt-layered-token"
+  executor = "shell"
+`)
+
+	stateID := runnerStateID(common.RunnerCredentials{URL: "https://gitlab.example.com", Token: "glrt-layered-token"})
+	writeDiffTestFile(t, filepath.Join(dir, ".runner_state.toml"), `
+[[runners]]
+  id = "`+stateID+`"
+  token = "glrt-obtained-token"
+`)
+
+	c := &ConfigDiffCommand{configOptions: configOptions{ConfigFile: filepath.Join(dir, "config.toml")}}
+
+	// the new file gets the fragments and the runner state of the
+	// configuration file it replaces
+	config, _, err := c.readConfig(filepath.Join(dir, "new.toml"))
+	require.NoError(t, err)
+	require.Len(t, config.Runners, 2)
+	assert.Equal(t, "layered", config.Runners[1].Name)
+	assert.Equal(t, "glrt-obtained-token", config.Runners[1].Token)
+
+	diff, err := c.diffConfigFiles(filepath.Join(dir, "new.toml"))
+	require.NoError(t, err)
+
+	// the reference of the main runner resolves to the token of the new file
+	assert.Empty(t, diff.Runners)
+	assert.Equal(t, []settingChange{{Key: "concurrent", Old: "1", New: "2"}}, diff.Global)
+}
+
+func writeDiffTestFile(t *testing.T, file, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o700))
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+}
+
+func TestIsSecretSetting(t *testing.T) {
+	tests := map[string]bool{
+		"token":                   true,
+		"kubernetes.bearer_token": true,
+		"cache.s3.SecretKey":      true,
+		"cache.gcs.PrivateKey":    true,
+		"cache.azure.AccountKey":  true,
+		"token_expires_at":        false,
+		"docker.image":            false,
+		"kubernetes.bearer_token_overwrite_allowed": false,
+	}
+
+	for key, expected := range tests {
+		t.Run(key, func(t *testing.T) {
+			assert.Equal(t, expected, isSecretSetting(key))
+		})
+	}
+}
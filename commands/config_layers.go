@@ -0,0 +1,343 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers"
+)
+
+// runnerSource is the file defining a runner of a layered configuration, with
+// the credentials of the runner as they're defined in the file
+type runnerSource struct {
+	file        string
+	credentials common.RunnerCredentials
+}
+
+// configLayers describes how the configuration was loaded: whether it's
+// layered, that is whether the fragments directory exists, and the file
+// defining each runner
+type configLayers struct {
+	layered bool
+	sources map[*common.RunnerConfig]runnerSource
+}
+
+// runnerStateFile is the state the runner persists for the runners of a
+// layered configuration instead of rewriting the files of the operator
+type runnerStateFile struct {
+	Runners []runnerState `toml:"runners"`
+}
+
+// runnerState is the credentials the runner obtained for the runner whose ID
+// is the hash of its URL and of its token as defined in its file
+type runnerState struct {
+	ID              string    `toml:"id"`
+	Token           string    `toml:"token"`
+	TokenObtainedAt time.Time `toml:"token_obtained_at"`
+	TokenExpiresAt  time.Time `toml:"token_expires_at"`
+}
+
+func runnerStateID(credentials common.RunnerCredentials) string {
+	sum := sha256.Sum256([]byte(credentials.URL + "\x00" + credentials.Token))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+func sameObtainedCredentials(a, b common.RunnerCredentials) bool {
+	return a.Token == b.Token &&
+		a.TokenObtainedAt.Equal(b.TokenObtainedAt) &&
+		a.TokenExpiresAt.Equal(b.TokenExpiresAt)
+}
+
+// configDir returns the directory of the configuration fragments
+func (c *configOptions) configDir() string {
+	if c.ConfigDir != "" {
+		return c.ConfigDir
+	}
+
+	return filepath.Join(filepath.Dir(c.ConfigFile), "conf.d")
+}
+
+func (c *configOptions) runnerStateFile() string {
+	return filepath.Join(filepath.Dir(c.ConfigFile), ".runner_state.toml")
+}
+
+// configFragments returns the TOML files of the fragments directory, sorted
+// by name, and whether the directory exists
+func (c *configOptions) configFragments() ([]string, bool, error) {
+	dir := c.configDir()
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	var fragments []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".toml" {
+			continue
+		}
+
+		fragments = append(fragments, filepath.Join(dir, name))
+	}
+
+	return fragments, true, nil
+}
+
+// configModTime returns the time the configuration was last modified: the
+// time of the configuration file or, for a layered configuration, the latest
+// time of the configuration file, of the fragments directory and of the
+// fragments
+func (c *configOptions) configModTime() (time.Time, error) {
+	var modTime time.Time
+
+	info, statErr := os.Stat(c.ConfigFile)
+	if statErr == nil {
+		modTime = info.ModTime()
+	}
+
+	fragments, layered, err := c.configFragments()
+	if err != nil {
+		return modTime, err
+	}
+
+	if !layered {
+		return modTime, statErr
+	} else if statErr != nil && !os.IsNotExist(statErr) {
+		return modTime, statErr
+	}
+
+	for _, file := range append([]string{c.configDir()}, fragments...) {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTime, err
+		}
+
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	return modTime, nil
+}
+
+// loadConfigLayers merges the fragments into the loaded configuration file,
+// in the order of their names, and records the file defining each runner
+func (c *configOptions) loadConfigLayers(config *common.Config) (configLayers, error) {
+	layers := configLayers{sources: make(map[*common.RunnerConfig]runnerSource)}
+
+	addSources := func(file string) {
+		for _, runner := range config.Runners {
+			if _, ok := layers.sources[runner]; !ok {
+				layers.sources[runner] = runnerSource{file: file}
+			}
+		}
+	}
+	addSources(c.ConfigFile)
+
+	fragments, layered, err := c.configFragments()
+	if err != nil {
+		return layers, err
+	}
+	layers.layered = layered
+
+	for _, fragment := range fragments {
+		if err := config.LoadConfigFragment(fragment); err != nil {
+			return layers, err
+		}
+		addSources(fragment)
+	}
+
+	if layered {
+		info, err := os.Stat(c.configDir())
+		if err != nil {
+			return layers, err
+		}
+
+		if info.ModTime().After(config.ModTime) {
+			config.ModTime = info.ModTime()
+		}
+	}
+
+	return layers, nil
+}
+
+// applyRunnerState records the credentials of the runners as defined in
+// their files, with their references resolved, and overrides them with the
+// ones persisted in the state file
+func (c *configOptions) applyRunnerState(config *common.Config, layers configLayers) error {
+	for _, runner := range config.Runners {
+		source := layers.sources[runner]
+		source.credentials = runner.RunnerCredentials
+		layers.sources[runner] = source
+	}
+
+	var state runnerStateFile
+	_, err := toml.DecodeFile(c.runnerStateFile(), &state)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("loading runner state file: %w", err)
+	}
+
+	states := make(map[string]runnerState)
+	for _, runnerState := range state.Runners {
+		states[runnerState.ID] = runnerState
+	}
+
+	for _, runner := range config.Runners {
+		runnerState, ok := states[runnerStateID(layers.sources[runner].credentials)]
+		if !ok {
+			continue
+		}
+
+		runner.Token = runnerState.Token
+		runner.TokenObtainedAt = runnerState.TokenObtainedAt
+		runner.TokenExpiresAt = runnerState.TokenExpiresAt
+	}
+
+	return nil
+}
+
+// saveLayeredConfig persists the changes of a layered configuration without
+// rewriting the files of the operator: a new runner is written to its own
+// fragment, the fragment of a removed runner is removed and the credentials
+// obtained by the runners are written to the state file
+func (c *configOptions) saveLayeredConfig() error {
+	current := make(map[*common.RunnerConfig]bool)
+	for _, runner := range c.config.Runners {
+		current[runner] = true
+		if _, ok := c.layers.sources[runner]; ok {
+			continue
+		}
+
+		file, err := c.writeRunnerFragment(runner)
+		if err != nil {
+			return err
+		}
+
+		c.layers.sources[runner] = runnerSource{file: file, credentials: runner.RunnerCredentials}
+	}
+
+	var removed []*common.RunnerConfig
+	for runner := range c.layers.sources {
+		if !current[runner] {
+			removed = append(removed, runner)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		return c.layers.sources[removed[i]].file < c.layers.sources[removed[j]].file
+	})
+
+	for _, runner := range removed {
+		if err := c.removeRunnerFragment(runner, c.layers.sources[runner].file); err != nil {
+			return err
+		}
+
+		delete(c.layers.sources, runner)
+	}
+
+	if err := c.saveRunnerState(); err != nil {
+		return err
+	}
+
+	c.config.ModTime = time.Now()
+
+	return nil
+}
+
+// writeRunnerFragment writes the runner to a new fragment named after its
+// short token
+func (c *configOptions) writeRunnerFragment(runner *common.RunnerConfig) (string, error) {
+	file := filepath.Join(c.configDir(), "runner-"+helpers.ShortenToken(runner.Token)+".toml")
+	if _, err := os.Stat(file); err == nil {
+		return "", fmt.Errorf("writing runner %q: %s already exists", runner.Name, file)
+	}
+
+	fragment := struct {
+		Runners []*common.RunnerConfig `toml:"runners"`
+	}{
+		Runners: []*common.RunnerConfig{runner},
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(fragment); err != nil {
+		return "", err
+	}
+
+	return file, os.WriteFile(file, buf.Bytes(), 0o600)
+}
+
+// removeRunnerFragment removes the fragment defining the runner, refusing to
+// remove the configuration file or a fragment defining other settings
+func (c *configOptions) removeRunnerFragment(runner *common.RunnerConfig, file string) error {
+	refuse := fmt.Errorf(
+		"runner %q is defined in %s with other settings, remove it from the file",
+		runner.Name,
+		file,
+	)
+
+	if filepath.Dir(file) != filepath.Clean(c.configDir()) {
+		return refuse
+	}
+
+	var fragment map[string]interface{}
+	if _, err := toml.DecodeFile(file, &fragment); err != nil {
+		return err
+	}
+
+	runners, _ := fragment["runners"].([]map[string]interface{})
+	if len(fragment) != 1 || len(runners) != 1 {
+		return refuse
+	}
+
+	return os.Remove(file)
+}
+
+// saveRunnerState writes the credentials which differ from the ones defined
+// in the files of the runners to the state file, or removes the state file if
+// none does
+func (c *configOptions) saveRunnerState() error {
+	var state runnerStateFile
+	for _, runner := range c.config.Runners {
+		source := c.layers.sources[runner]
+		if sameObtainedCredentials(source.credentials, runner.RunnerCredentials) {
+			continue
+		}
+
+		state.Runners = append(state.Runners, runnerState{
+			ID:              runnerStateID(source.credentials),
+			Token:           runner.Token,
+			TokenObtainedAt: runner.TokenObtainedAt,
+			TokenExpiresAt:  runner.TokenExpiresAt,
+		})
+	}
+
+	file := c.runnerStateFile()
+	if len(state.Runners) == 0 {
+		err := os.Remove(file)
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(state); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, buf.Bytes(), 0o600)
+}
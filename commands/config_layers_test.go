@@ -0,0 +1,185 @@
+//go:build !integration
+
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+const (
+	layeredConfig = `
+concurrent = 1
+check_interval = 3
+
+[[runners]]
+  name = "main"
+  url = "https://gitlab.example.com"
+  token = "glrt-main-token"
+  executor = "shell"
+`
+
+	layeredGlobalFragment = `
+concurrent = 5
+`
+
+	layeredRunnerFragment = `
+[[runners]]
+  name = "fragment"
+  url = "https://gitlab.example.com"
+  token = "glrt-fragment-token"
+  executor = "docker"
+  [runners.docker]
+    image = "alpine"
+`
+)
+
+func writeLayeredConfig(t *testing.T) (configOptions, string) {
+	dir := t.TempDir()
+	configDir := filepath.Join(dir, "conf.d")
+	require.NoError(t, os.Mkdir(configDir, 0o700))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.toml"), []byte(layeredConfig), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "20-runner.toml"), []byte(layeredRunnerFragment), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "10-global.toml"), []byte(layeredGlobalFragment), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "notes.txt"), []byte("not a fragment"), 0o600))
+
+	return configOptions{ConfigFile: filepath.Join(dir, "config.toml")}, dir
+}
+
+func readFile(t *testing.T, file string) string {
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+func TestLoadLayeredConfig(t *testing.T) {
+	c, _ := writeLayeredConfig(t)
+	require.NoError(t, c.loadConfig())
+
+	config := c.getConfig()
+	assert.True(t, c.layers.layered)
+	assert.Equal(t, 5, config.Concurrent)
+	assert.Equal(t, 3, config.CheckInterval)
+	require.Len(t, config.Runners, 2)
+	assert.Equal(t, "main", config.Runners[0].Name)
+	assert.Equal(t, "fragment", config.Runners[1].Name)
+	assert.Equal(t, "alpine", config.Runners[1].Docker.Image)
+	assert.Equal(t, c.ConfigFile, c.layers.sources[config.Runners[0]].file)
+	assert.Equal(t, filepath.Join(c.configDir(), "20-runner.toml"), c.layers.sources[config.Runners[1]].file)
+}
+
+func TestLoadConfigWithoutConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	require.NoError(t, os.WriteFile(file, []byte(layeredConfig), 0o600))
+
+	c := configOptions{ConfigFile: file}
+	require.NoError(t, c.loadConfig())
+	assert.False(t, c.layers.layered)
+	assert.Len(t, c.getConfig().Runners, 1)
+
+	c.config.Runners[0].Token = "glrt-new-token"
+	require.NoError(t, c.saveConfig())
+	assert.Contains(t, readFile(t, file), "glrt-new-token")
+}
+
+func TestLoadConfigFromConfigDirOnly(t *testing.T) {
+	dir := t.TempDir()
+	configDir := filepath.Join(dir, "runners")
+	require.NoError(t, os.Mkdir(configDir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "runner.toml"), []byte(layeredRunnerFragment), 0o600))
+
+	c := configOptions{ConfigFile: filepath.Join(dir, "config.toml"), ConfigDir: configDir}
+	require.NoError(t, c.loadConfig())
+	assert.True(t, c.getConfig().Loaded)
+	require.Len(t, c.getConfig().Runners, 1)
+	assert.Equal(t, "fragment", c.getConfig().Runners[0].Name)
+}
+
+func TestSaveLayeredConfigPersistsRunnerState(t *testing.T) {
+	c, dir := writeLayeredConfig(t)
+	require.NoError(t, c.loadConfig())
+
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	runner := c.config.Runners[1]
+	runner.Token = "glrt-rotated-token"
+	runner.TokenExpiresAt = expiresAt
+
+	require.NoError(t, c.saveConfig())
+
+	assert.Equal(t, layeredConfig, readFile(t, c.ConfigFile))
+	assert.Equal(t, layeredRunnerFragment, readFile(t, filepath.Join(dir, "conf.d", "20-runner.toml")))
+	assert.Contains(t, readFile(t, filepath.Join(dir, ".runner_state.toml")), "glrt-rotated-token")
+
+	reloaded := configOptions{ConfigFile: c.ConfigFile}
+	require.NoError(t, reloaded.loadConfig())
+	assert.Equal(t, "glrt-main-token", reloaded.config.Runners[0].Token)
+	assert.Equal(t, "glrt-rotated-token", reloaded.config.Runners[1].Token)
+	assert.True(t, expiresAt.Equal(reloaded.config.Runners[1].TokenExpiresAt))
+
+	reloaded.config.Runners[1].RunnerCredentials = reloaded.layers.sources[reloaded.config.Runners[1]].credentials
+	require.NoError(t, reloaded.saveConfig())
+	assert.NoFileExists(t, filepath.Join(dir, ".runner_state.toml"))
+}
+
+func TestSaveLayeredConfigRunners(t *testing.T) {
+	c, dir := writeLayeredConfig(t)
+	require.NoError(t, c.loadConfig())
+
+	added := &common.RunnerConfig{
+		Name: "added",
+		RunnerCredentials: common.RunnerCredentials{
+			URL:   "https://gitlab.example.com",
+			Token: "glrt-added-token",
+		},
+		RunnerSettings: common.RunnerSettings{Executor: "shell"},
+	}
+	c.config.Runners = append(c.config.Runners, added)
+	require.NoError(t, c.saveConfig())
+
+	fragment := filepath.Join(dir, "conf.d", "runner-added-tok.toml")
+	assert.Contains(t, readFile(t, fragment), `name = "added"`)
+	assert.NotContains(t, readFile(t, fragment), "concurrent")
+	assert.Equal(t, layeredConfig, readFile(t, c.ConfigFile))
+
+	reloaded := configOptions{ConfigFile: c.ConfigFile}
+	require.NoError(t, reloaded.loadConfig())
+	require.Len(t, reloaded.config.Runners, 3)
+	assert.Equal(t, 5, reloaded.config.Concurrent)
+
+	reloaded.config.Runners = reloaded.config.Runners[:2]
+	require.NoError(t, reloaded.saveConfig())
+	assert.NoFileExists(t, fragment)
+
+	reloaded.config.Runners = reloaded.config.Runners[1:]
+	err := reloaded.saveConfig()
+	assert.ErrorContains(t, err, `runner "main" is defined in `+c.ConfigFile)
+	assert.Equal(t, layeredConfig, readFile(t, c.ConfigFile))
+}
+
+func TestConfigModTime(t *testing.T) {
+	c, dir := writeLayeredConfig(t)
+
+	fragmentModTime := time.Now().Add(time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "conf.d", "20-runner.toml"), fragmentModTime, fragmentModTime))
+
+	modTime, err := c.configModTime()
+	require.NoError(t, err)
+	assert.True(t, fragmentModTime.Equal(modTime))
+
+	require.NoError(t, c.loadConfig())
+	assert.True(t, fragmentModTime.Equal(c.getConfig().ModTime))
+
+	c = configOptions{ConfigFile: filepath.Join(t.TempDir(), "config.toml")}
+	_, err = c.configModTime()
+	assert.True(t, os.IsNotExist(err))
+}
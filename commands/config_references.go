@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/vault"
+)
+
+// configReferenceResolver resolves the references of the configuration to
+// environment variables, files and Vault secrets. It reads each Vault secret
+// once, so that a configuration load gets a consistent value for all the
+// fields of a secret.
+type configReferenceResolver struct {
+	newVaultClient func() (vault.Client, error)
+
+	vaultClient  vault.Client
+	vaultSecrets map[string]map[string]interface{}
+}
+
+func newConfigReferenceResolver() *configReferenceResolver {
+	return &configReferenceResolver{
+		newVaultClient: newConfigVaultClient,
+		vaultSecrets:   make(map[string]map[string]interface{}),
+	}
+}
+
+func (r *configReferenceResolver) resolve(kind, reference string) (string, error) {
+	switch kind {
+	case "env":
+		value, ok := os.LookupEnv(reference)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s isn't set", reference)
+		}
+
+		return value, nil
+	case "file":
+		data, err := os.ReadFile(reference)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case "vault":
+		return r.resolveVault(reference)
+	}
+
+	return "", fmt.Errorf("unsupported reference kind %q", kind)
+}
+
+// resolveVault returns the field of the secret of a path#field reference. The
+// path is the path of the secret in the Vault API, like secret/data/runner
+// for a KV v2 secret.
+func (r *configReferenceResolver) resolveVault(reference string) (string, error) {
+	path, field, _ := strings.Cut(reference, "#")
+	if path == "" || field == "" {
+		return "", fmt.Errorf("invalid Vault reference %q, expected path#field", reference)
+	}
+
+	data, ok := r.vaultSecrets[path]
+	if !ok {
+		if r.vaultClient == nil {
+			client, err := r.newVaultClient()
+			if err != nil {
+				return "", err
+			}
+			r.vaultClient = client
+		}
+
+		result, err := r.vaultClient.Read(path)
+		if err != nil {
+			return "", fmt.Errorf("reading %s from Vault: %w", path, err)
+		}
+
+		if result != nil {
+			data = result.Data()
+		}
+
+		// the data of the KV v2 secrets is nested in a data field
+		if nested, ok := data["data"].(map[string]interface{}); ok {
+			data = nested
+		}
+
+		r.vaultSecrets[path] = data
+	}
+
+	value, ok := data[field]
+	if !ok || value == nil {
+		return "", fmt.Errorf("field %s of Vault secret %s not found", field, path)
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+// newConfigVaultClient returns a client of the Vault server of VAULT_ADDR,
+// authenticated with the token of VAULT_TOKEN in the namespace of
+// VAULT_NAMESPACE
+func newConfigVaultClient() (vault.Client, error) {
+	address := os.Getenv("VAULT_ADDR")
+	if address == "" {
+		return nil, errors.New("VAULT_ADDR isn't set")
+	}
+
+	client, err := vault.NewClient(address, os.Getenv("VAULT_NAMESPACE"))
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.Authenticate(vaultTokenAuth(os.Getenv("VAULT_TOKEN")))
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// vaultTokenAuth authenticates the Vault client with a token
+type vaultTokenAuth string
+
+func (a vaultTokenAuth) Name() string {
+	return "token"
+}
+
+func (a vaultTokenAuth) Authenticate(vault.Client) error {
+	if a == "" {
+		return errors.New("VAULT_TOKEN isn't set")
+	}
+
+	return nil
+}
+
+func (a vaultTokenAuth) Token() string {
+	return string(a)
+}
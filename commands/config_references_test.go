@@ -0,0 +1,165 @@
+//go:build !integration
+
+package commands
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/vault"
+)
+
+func TestConfigReferenceResolver(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("file-secret\n"), 0o600))
+
+	t.Setenv("CONFIG_REFERENCE_TEST", "env-secret")
+
+	tests := map[string]struct {
+		kind          string
+		reference     string
+		expectedValue string
+		expectedError string
+	}{
+		"environment variable": {
+			kind:          "env",
+			reference:     "CONFIG_REFERENCE_TEST",
+			expectedValue: "env-secret",
+		},
+		"missing environment variable": {
+			kind:          "env",
+			reference:     "CONFIG_REFERENCE_TEST_MISSING",
+			expectedError: "environment variable CONFIG_REFERENCE_TEST_MISSING isn't set",
+		},
+		"file": {
+			kind:          "file",
+			reference:     secretFile,
+			expectedValue: "file-secret",
+		},
+		"missing file": {
+			kind:          "file",
+			reference:     filepath.Join(dir, "missing"),
+			expectedError: "no such file or directory",
+		},
+		"KV v1 secret": {
+			kind:          "vault",
+			reference:     "kv/runner#password",
+			expectedValue: "kv-password",
+		},
+		"KV v2 secret": {
+			kind:          "vault",
+			reference:     "secret/data/runner#token",
+			expectedValue: "kv2-token",
+		},
+		"missing Vault field": {
+			kind:          "vault",
+			reference:     "secret/data/runner#missing",
+			expectedError: "field missing of Vault secret secret/data/runner not found",
+		},
+		"invalid Vault reference": {
+			kind:          "vault",
+			reference:     "secret/data/runner",
+			expectedError: `invalid Vault reference "secret/data/runner", expected path#field`,
+		},
+		"Vault read error": {
+			kind:          "vault",
+			reference:     "denied#field",
+			expectedError: "reading denied from Vault: permission denied",
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			kv1 := vault.NewMockResult(t)
+			kv1.On("Data").Return(map[string]interface{}{"password": "kv-password"}).Maybe()
+			kv2 := vault.NewMockResult(t)
+			kv2.On("Data").Return(map[string]interface{}{
+				"data":     map[string]interface{}{"token": "kv2-token"},
+				"metadata": map[string]interface{}{"version": 1},
+			}).Maybe()
+
+			client := vault.NewMockClient(t)
+			client.On("Read", "kv/runner").Return(kv1, nil).Maybe()
+			client.On("Read", "secret/data/runner").Return(kv2, nil).Maybe()
+			client.On("Read", "denied").Return(nil, errors.New("permission denied")).Maybe()
+
+			resolver := newConfigReferenceResolver()
+			resolver.newVaultClient = func() (vault.Client, error) {
+				return client, nil
+			}
+
+			value, err := resolver.resolve(tt.kind, tt.reference)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedValue, value)
+		})
+	}
+}
+
+func TestConfigReferenceResolverReadsVaultSecretsOnce(t *testing.T) {
+	result := vault.NewMockResult(t)
+	result.On("Data").Return(map[string]interface{}{"access": "a", "secret": "s"}).Once()
+
+	client := vault.NewMockClient(t)
+	client.On("Read", "kv/cache").Return(result, nil).Once()
+
+	clients := 0
+	resolver := newConfigReferenceResolver()
+	resolver.newVaultClient = func() (vault.Client, error) {
+		clients++
+		return client, nil
+	}
+
+	access, err := resolver.resolve("vault", "kv/cache#access")
+	require.NoError(t, err)
+	secret, err := resolver.resolve("vault", "kv/cache#secret")
+	require.NoError(t, err)
+
+	assert.Equal(t, "a", access)
+	assert.Equal(t, "s", secret)
+	assert.Equal(t, 1, clients)
+}
+
+func TestLoadConfigResolvesReferences(t *testing.T) {
+	t.Setenv("CONFIG_REFERENCE_TOKEN", "glrt-main-token")
+
+	c, dir := writeLayeredConfig(t)
+	config := `
+[[runners]]
+  name = "main"
+  url = "https://gitlab.example.com"
+  token = "${env:CONFIG_REFERENCE_TOKEN}"
+  executor = "shell"
+`
+	require.NoError(t, os.WriteFile(c.ConfigFile, []byte(config), 0o600))
+
+	require.NoError(t, c.loadConfig())
+	assert.Equal(t, "glrt-main-token", c.config.Runners[0].Token)
+
+	require.NoError(t, c.saveConfig())
+	assert.NoFileExists(t, filepath.Join(dir, ".runner_state.toml"))
+
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "conf.d")))
+	require.NoError(t, c.loadConfig())
+	require.NoError(t, c.saveConfig())
+	assert.Contains(t, readFile(t, c.ConfigFile), `token = "${env:CONFIG_REFERENCE_TOKEN}"`)
+
+	t.Setenv("CONFIG_REFERENCE_TOKEN", "glrt-reloaded-token")
+	require.NoError(t, c.loadConfig())
+	assert.Equal(t, "glrt-reloaded-token", c.config.Runners[0].Token)
+
+	require.NoError(t, os.Unsetenv("CONFIG_REFERENCE_TOKEN"))
+	err := c.loadConfig()
+	assert.ErrorContains(t, err, "resolving ${env:CONFIG_REFERENCE_TOKEN} of runners.0.token")
+	assert.Equal(t, "glrt-reloaded-token", c.config.Runners[0].Token)
+}
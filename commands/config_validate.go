@@ -0,0 +1,488 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	clihelpers "gitlab.com/gitlab-org/golang-cli-helpers"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+type ConfigValidateCommand struct {
+	configOptions
+}
+
+// configProblem is a problem of a configuration file, at the line of the key
+// with the problem if it's known
+type configProblem struct {
+	file    string
+	line    int
+	message string
+}
+
+func (p configProblem) String() string {
+	if p.line == 0 {
+		return p.file + ": " + p.message
+	}
+
+	return p.file + ":" + strconv.Itoa(p.line) + ": " + p.message
+}
+
+func (c *ConfigValidateCommand) Execute(context *cli.Context) {
+	problems, err := c.validateConfig()
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if len(problems) > 0 {
+		logrus.Fatalf("Found %d problem(s) in %s", len(problems), c.ConfigFile)
+	}
+
+	logrus.Infoln(c.ConfigFile, "is valid")
+}
+
+// parsedConfigFile is a configuration file, or a fragment, decoded on its own
+type parsedConfigFile struct {
+	file string
+	data []byte
+	keys tomlKeys
+}
+
+// validateConfig returns the problems of the configuration file and of the
+// fragments of the configuration directory. Each file is validated on its
+// own, so that the problems are reported at the lines of the file, then the
+// semantic rules are checked on the configuration merged like the runner
+// loads it.
+func (c *configOptions) validateConfig() ([]configProblem, error) {
+	fragments, layered, err := c.configFragments()
+	if err != nil {
+		return nil, err
+	}
+
+	// a layered configuration can be defined only by its fragments
+	files := fragments
+	if _, err := os.Stat(c.ConfigFile); !layered || !os.IsNotExist(err) {
+		files = append([]string{c.ConfigFile}, fragments...)
+	}
+
+	problems := make([][]configProblem, len(files))
+	parsed := make([]*parsedConfigFile, 0, len(files))
+
+	for i, file := range files {
+		parsedFile, fileProblems, err := validateConfigFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		problems[i] = fileProblems
+		if parsedFile != nil {
+			parsed = append(parsed, parsedFile)
+		}
+	}
+
+	// the semantic rules can't be checked when a file can't be decoded
+	if len(parsed) == len(files) {
+		for _, problem := range semanticProblems(parsed) {
+			problems[problem.fileIndex] = append(problems[problem.fileIndex], problem.configProblem)
+		}
+	}
+
+	var sorted []configProblem
+	for _, fileProblems := range problems {
+		sort.SliceStable(fileProblems, func(i, j int) bool {
+			return fileProblems[i].line < fileProblems[j].line
+		})
+		sorted = append(sorted, fileProblems...)
+	}
+
+	return sorted, nil
+}
+
+// validateConfigFile returns the problems of the configuration file on its
+// own: its syntax errors, its unknown keys and the values which don't match
+// the schema. The parsed file is nil when the file can't be decoded.
+func validateConfigFile(file string) (*parsedConfigFile, []configProblem, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := common.NewConfig()
+	metadata, err := toml.Decode(string(data), config)
+
+	var parseErr toml.ParseError
+	if errors.As(err, &parseErr) {
+		line := parseErr.Position.Line
+		message := strings.TrimPrefix(parseErr.Error(), fmt.Sprintf("toml: line %d", line))
+
+		return nil, []configProblem{{file: file, line: line, message: strings.TrimPrefix(message, ": ")}}, nil
+	} else if err != nil {
+		return nil, []configProblem{{file: file, message: err.Error()}}, nil
+	}
+
+	keys := tomlKeyLines(data)
+
+	var problems []configProblem
+	for _, key := range unknownKeys(metadata) {
+		for _, line := range keys.linesOf(key) {
+			problems = append(problems, configProblem{file: file, line: line, message: "unknown key " + key})
+		}
+	}
+
+	for _, configErr := range common.SchemaErrors(config) {
+		problems = append(problems, configProblem{
+			file:    file,
+			line:    keys.lineOf(configErr.Path),
+			message: configErr.Error(),
+		})
+	}
+
+	return &parsedConfigFile{file: file, data: data, keys: keys}, problems, nil
+}
+
+// fileConfigProblem is a problem of the merged configuration, with the index
+// of the file it's reported in
+type fileConfigProblem struct {
+	configProblem
+	fileIndex int
+}
+
+// runnerLocation is the file defining a runner of the merged configuration
+// and the index of the runner in the file
+type runnerLocation struct {
+	fileIndex int
+	index     int
+}
+
+// semanticProblems returns the problems breaking the semantic rules of the
+// configuration merged from the files, in the order the runner loads them.
+// The problems of a runner are reported at the lines of the file defining it,
+// with the path of the runner in the merged configuration.
+func semanticProblems(files []*parsedConfigFile) []fileConfigProblem {
+	merged := common.NewConfig()
+
+	var locations []runnerLocation
+	for i, file := range files {
+		runners := merged.Runners
+		merged.Runners = nil
+
+		// the file was already decoded on its own
+		_, _ = toml.Decode(string(file.data), merged)
+
+		for j := range merged.Runners {
+			locations = append(locations, runnerLocation{fileIndex: i, index: j})
+		}
+		merged.Runners = append(runners, merged.Runners...)
+	}
+
+	var problems []fileConfigProblem
+	for _, configErr := range common.SemanticErrors(merged) {
+		fileIndex, line := locateMergedPath(files, locations, configErr.Path)
+
+		problems = append(problems, fileConfigProblem{
+			configProblem: configProblem{
+				file:    files[fileIndex].file,
+				line:    line,
+				message: configErr.Error(),
+			},
+			fileIndex: fileIndex,
+		})
+	}
+
+	return problems
+}
+
+// locateMergedPath returns the file and the line of the key of the merged
+// configuration. A global key is located in the last file defining it, as
+// the files loaded later override it.
+func locateMergedPath(files []*parsedConfigFile, locations []runnerLocation, path string) (int, int) {
+	segments := strings.SplitN(path, ".", 3)
+	if len(segments) > 1 && segments[0] == "runners" {
+		index, err := strconv.Atoi(segments[1])
+		if err == nil && index >= 0 && index < len(locations) {
+			location := locations[index]
+			segments[1] = strconv.Itoa(location.index)
+
+			return location.fileIndex, files[location.fileIndex].keys.lineOf(strings.Join(segments, "."))
+		}
+	}
+
+	for i := len(files) - 1; i >= 0; i-- {
+		if line := files[i].keys.lineOf(path); line > 0 {
+			return i, line
+		}
+	}
+
+	return 0, 0
+}
+
+// unknownKeys returns the keys of the configuration file which don't match
+// any setting, without the keys of the unknown tables
+func unknownKeys(metadata toml.MetaData) []string {
+	undecoded := make(map[string]bool)
+	for _, key := range metadata.Undecoded() {
+		undecoded[key.String()] = true
+	}
+
+	var keys []string
+	for _, key := range metadata.Undecoded() {
+		if len(key) > 1 && undecoded[key[:len(key)-1].String()] {
+			continue
+		}
+
+		keys = append(keys, key.String())
+	}
+
+	return keys
+}
+
+// tomlKey is a key of a TOML document with its line. Its path includes the
+// indexes of the arrays of tables, like runners.0.docker.image.
+type tomlKey struct {
+	path string
+	line int
+}
+
+type tomlKeys []tomlKey
+
+// tomlKeyLines returns the keys of the tables and of the values of the TOML
+// document. It expects a valid document: the keys of the inline tables
+// aren't returned.
+func tomlKeyLines(data []byte) tomlKeys {
+	var keys tomlKeys
+
+	arrays := make(map[string]int)
+	table := ""
+	multilineString := ""
+	arrayDepth := 0
+
+	for i, line := range strings.Split(string(data), "\n") {
+		lineNumber := i + 1
+
+		if multilineString != "" {
+			if strings.Count(line, multilineString)%2 == 1 {
+				multilineString = ""
+			}
+			continue
+		}
+
+		line = strings.TrimSpace(stripTOMLComment(line))
+		if arrayDepth > 0 {
+			arrayDepth += tomlBracketsDepth(line)
+			continue
+		}
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "[["):
+			name := strings.TrimSuffix(strings.TrimPrefix(line, "[["), "]]")
+			table = resolveTOMLTable(arrays, splitTOMLKey(name), true)
+			keys = append(keys, tomlKey{path: table, line: lineNumber})
+		case strings.HasPrefix(line, "["):
+			name := strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+			table = resolveTOMLTable(arrays, splitTOMLKey(name), false)
+			keys = append(keys, tomlKey{path: table, line: lineNumber})
+		default:
+			equal := indexOutsideTOMLStrings(line, '=')
+			if equal < 0 {
+				continue
+			}
+
+			path := strings.Join(splitTOMLKey(line[:equal]), ".")
+			if table != "" {
+				path = table + "." + path
+			}
+			keys = append(keys, tomlKey{path: path, line: lineNumber})
+
+			value := strings.TrimSpace(line[equal+1:])
+			for _, quotes := range []string{`"""`, `'''`} {
+				if strings.HasPrefix(value, quotes) && strings.Count(value, quotes) == 1 {
+					multilineString = quotes
+				}
+			}
+			arrayDepth = tomlBracketsDepth(value)
+		}
+	}
+
+	return keys
+}
+
+// lineOf returns the line of the key, or of its closest parent in the
+// document, or 0 if none is
+func (k tomlKeys) lineOf(path string) int {
+	for path != "" {
+		for _, key := range k {
+			if key.path == path {
+				return key.line
+			}
+		}
+
+		dot := strings.LastIndex(path, ".")
+		if dot < 0 {
+			break
+		}
+		path = path[:dot]
+	}
+
+	return 0
+}
+
+// linesOf returns the lines of the key, whatever the indexes of the arrays
+// of tables in its path
+func (k tomlKeys) linesOf(key string) []int {
+	var lines []int
+	for _, tomlKey := range k {
+		var segments []string
+		for _, segment := range strings.Split(tomlKey.path, ".") {
+			if _, err := strconv.Atoi(segment); err != nil {
+				segments = append(segments, segment)
+			}
+		}
+
+		if strings.Join(segments, ".") == key {
+			lines = append(lines, tomlKey.line)
+		}
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, 0)
+	}
+
+	return lines
+}
+
+// resolveTOMLTable returns the path of the table with the indexes of the last
+// elements of the arrays of tables it's in, declaring a new element if the
+// table is an array of tables
+func resolveTOMLTable(arrays map[string]int, segments []string, array bool) string {
+	resolved := ""
+	for i, segment := range segments {
+		if resolved != "" {
+			resolved += "."
+		}
+		resolved += segment
+
+		if array && i == len(segments)-1 {
+			arrays[resolved]++
+		}
+
+		if count, ok := arrays[resolved]; ok {
+			resolved += "." + strconv.Itoa(count-1)
+		}
+	}
+
+	return resolved
+}
+
+// splitTOMLKey splits the dotted key, unquoting its parts
+func splitTOMLKey(key string) []string {
+	var parts []string
+	for {
+		dot := indexOutsideTOMLStrings(key, '.')
+		if dot < 0 {
+			break
+		}
+
+		parts = append(parts, unquoteTOMLKey(key[:dot]))
+		key = key[dot+1:]
+	}
+
+	return append(parts, unquoteTOMLKey(key))
+}
+
+func unquoteTOMLKey(key string) string {
+	key = strings.TrimSpace(key)
+	if unquoted, err := strconv.Unquote(key); err == nil && strings.HasPrefix(key, `"`) {
+		return unquoted
+	}
+
+	return strings.Trim(key, "'")
+}
+
+// indexOutsideTOMLStrings returns the index of the first c of the line which
+// isn't in a string, or -1
+func indexOutsideTOMLStrings(line string, c byte) int {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		switch {
+		case quote == '"' && line[i] == '\\':
+			i++
+		case quote != 0:
+			if line[i] == quote {
+				quote = 0
+			}
+		case line[i] == '"' || line[i] == '\'':
+			quote = line[i]
+		case line[i] == c:
+			return i
+		}
+	}
+
+	return -1
+}
+
+func stripTOMLComment(line string) string {
+	if comment := indexOutsideTOMLStrings(line, '#'); comment >= 0 {
+		return line[:comment]
+	}
+
+	return line
+}
+
+// tomlBracketsDepth returns the number of arrays the value opens and doesn't
+// close
+func tomlBracketsDepth(value string) int {
+	depth := 0
+	for {
+		open := indexOutsideTOMLStrings(value, '[')
+		closing := indexOutsideTOMLStrings(value, ']')
+
+		switch {
+		case open >= 0 && (closing < 0 || open < closing):
+			depth++
+			value = value[open+1:]
+		case closing >= 0:
+			depth--
+			value = value[closing+1:]
+		default:
+			return depth
+		}
+	}
+}
+
+func init() {
+	validate := &ConfigValidateCommand{}
+	diff := &ConfigDiffCommand{}
+
+	common.RegisterCommand(cli.Command{
+		Name:  "config",
+		Usage: "validate and compare configuration files",
+		Subcommands: []cli.Command{
+			{
+				Name:   "validate",
+				Usage:  "validate the configuration file",
+				Action: validate.Execute,
+				Flags:  clihelpers.GetFlagsFromStruct(validate),
+			},
+			{
+				Name:      "diff",
+				Usage:     "show the changes reloading the configuration would apply if the configuration file was replaced by NEW_CONFIG_FILE",
+				ArgsUsage: "NEW_CONFIG_FILE",
+				Action:    diff.Execute,
+				Flags:     clihelpers.GetFlagsFromStruct(diff),
+			},
+		},
+	})
+}
@@ -0,0 +1,167 @@
+//go:build !integration
+
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testValidateConfig = `concurrent = 4 # jobs
+
+[[runners]]
+  name = "docker"
+  url = "https://gitlab.example.com"
+  token = "glrt-abcdefghijklmnop"
+  executor = "docker"
+  [runners.docker]
+    image = "alpine"
+    volumes = [
+      "/cache",
+      "/a:/b",
+    ]
+    imagee = "typo"
+
+[[runners]]
+  name = "kubernetes"
+  url = "https://gitlab.example.com"
+  token = "glrt-zyxwvutsrqponmlk"
+  executor = "kubernetes"
+  "environment" = ["A=[1]"]
+  [runners.kubernetes]
+    pull_policy = "sometimes"
+  [[runners.kubernetes.host_aliases]]
+    ip = "127.0.0.1"
+  [[runners.kubernetes.host_aliases]]
+    ip = "127.0.0.2"
+`
+
+func TestTOMLKeyLines(t *testing.T) {
+	keys := tomlKeyLines([]byte(testValidateConfig))
+
+	tests := map[string]int{
+		"concurrent":                             1,
+		"runners.0":                              3,
+		"runners.0.docker.image":                 9,
+		"runners.0.docker.volumes":               10,
+		"runners.0.docker.imagee":                14,
+		"runners.0.docker.volumes.1":             10,
+		"runners.1.environment":                  21,
+		"runners.1.kubernetes.pull_policy":       23,
+		"runners.1.kubernetes.host_aliases.1.ip": 27,
+		"runners.2":                              0,
+	}
+
+	for path, expectedLine := range tests {
+		t.Run(path, func(t *testing.T) {
+			assert.Equal(t, expectedLine, keys.lineOf(path))
+		})
+	}
+
+	assert.Equal(t, []int{14}, keys.linesOf("runners.docker.imagee"))
+	assert.Equal(t, []int{24, 26}, keys.linesOf("runners.kubernetes.host_aliases"))
+}
+
+const testValidateFragment = `[[runners]]
+  name = "fragment"
+  url = "https://gitlab.example.com"
+  token = "glrt-fragmentfragment"
+  executor = "kubernetes"
+  [runners.kubernetes]
+    imagee = "typo"
+    pull_policy = "sometimes"
+`
+
+func TestValidateConfig(t *testing.T) {
+	tests := map[string]struct {
+		config           string
+		fragments        map[string]string
+		expectedProblems []string
+	}{
+		"valid": {
+			config: `concurrent = 1`,
+		},
+		"syntax error": {
+			config: "concurrent = 1\ncheck_interval = 3x\n",
+			expectedProblems: []string{
+				"config.toml:2: expected a top-level item to end with a newline, comment, or EOF, but got 'x' instead",
+			},
+		},
+		"problems": {
+			config: testValidateConfig,
+			expectedProblems: []string{
+				"config.toml:14: unknown key runners.docker.imagee",
+				`config.toml:23: runners.1.kubernetes.pull_policy: unsupported pull policy "sometimes"`,
+			},
+		},
+		"valid fragments": {
+			config: `concurrent = 1`,
+			fragments: map[string]string{
+				"10-global.toml": `check_interval = 3`,
+			},
+		},
+		"bad fragment": {
+			config: testValidateConfig,
+			fragments: map[string]string{
+				"10-global.toml": `check_interval = 3`,
+				"20-runner.toml": testValidateFragment,
+			},
+			expectedProblems: []string{
+				"config.toml:14: unknown key runners.docker.imagee",
+				`config.toml:23: runners.1.kubernetes.pull_policy: unsupported pull policy "sometimes"`,
+				"20-runner.toml:7: unknown key runners.kubernetes.imagee",
+				`20-runner.toml:8: runners.2.kubernetes.pull_policy: unsupported pull policy "sometimes"`,
+			},
+		},
+		"fragments only": {
+			fragments: map[string]string{
+				"20-runner.toml": testValidateFragment,
+			},
+			expectedProblems: []string{
+				"20-runner.toml:7: unknown key runners.kubernetes.imagee",
+				`20-runner.toml:8: runners.0.kubernetes.pull_policy: unsupported pull policy "sometimes"`,
+			},
+		},
+		"fragment syntax error": {
+			config: `concurrent = 1`,
+			fragments: map[string]string{
+				"10-global.toml": "concurrent = 1\ncheck_interval = 3x\n",
+			},
+			expectedProblems: []string{
+				"10-global.toml:2: expected a top-level item to end with a newline, comment, or EOF, but got 'x' instead",
+			},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "config.toml")
+			if tt.config != "" {
+				require.NoError(t, os.WriteFile(file, []byte(tt.config), 0o600))
+			}
+
+			if tt.fragments != nil {
+				require.NoError(t, os.Mkdir(filepath.Join(dir, "conf.d"), 0o700))
+			}
+			for name, fragment := range tt.fragments {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "conf.d", name), []byte(fragment), 0o600))
+			}
+
+			options := configOptions{ConfigFile: file}
+			problems, err := options.validateConfig()
+			require.NoError(t, err)
+
+			var messages []string
+			for _, problem := range problems {
+				problem.file = filepath.Base(problem.file)
+				messages = append(messages, problem.String())
+			}
+			assert.Equal(t, tt.expectedProblems, messages)
+		})
+	}
+}
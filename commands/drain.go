@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+// DrainCommand drains the runners of the running runner process through its
+// admin API: the drained runners stop requesting jobs while their running
+// jobs finish
+type DrainCommand struct {
+	configOptionsWithListenAddress
+
+	Runners []string      `long:"runner" description:"Short token or name of a runner to drain, can be repeated. All the runners are drained when not set"`
+	Exit    bool          `long:"exit" description:"Exit the runner process once the drained runners have no job left"`
+	Timeout time.Duration `long:"timeout" description:"Abort the jobs of the drained runners still running after the timeout, like 30m"`
+	Wait    bool          `long:"wait" description:"Wait until the drained runners have no job left"`
+	Status  bool          `long:"status" description:"Show the status of the drain mode without changing it"`
+	Stop    bool          `long:"stop" description:"Stop the drain mode, the runners request jobs again"`
+}
+
+func (c *DrainCommand) Execute(_ *cli.Context) {
+	err := c.loadConfig()
+	if err != nil {
+		logrus.WithError(err).Fatalln("Failed to load configuration")
+	}
+
+	err = c.drain()
+	if err != nil {
+		logrus.WithError(err).Fatalln("Failed to drain the runner process")
+	}
+}
+
+func (c *DrainCommand) drain() error {
+	if c.Status && c.Stop {
+		return errors.New("--status and --stop can't be used together")
+	}
+
+	client, err := c.newAdminClient()
+	if err != nil {
+		return err
+	}
+
+	var status drainStatus
+	switch {
+	case c.Status:
+		err = client.do(http.MethodGet, "drain", nil, &status)
+	case c.Stop:
+		err = client.do(http.MethodDelete, "drain", nil, &status)
+	default:
+		request := drainRequest{Runners: c.Runners, Exit: c.Exit}
+		if c.Timeout > 0 {
+			request.Timeout = c.Timeout.String()
+		}
+
+		err = client.do(http.MethodPost, "drain", request, &status)
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.Stop {
+		logrus.Infoln("Drain mode stopped, the runners request jobs again")
+		return nil
+	}
+
+	if !status.Active {
+		logrus.Infoln("The runners aren't drained")
+		return nil
+	}
+
+	logrus.WithFields(status.logFields()).Infoln("The runners are drained")
+
+	if !c.Wait {
+		return nil
+	}
+
+	return waitForDrain(client, status)
+}
+
+// waitForDrain polls the status of the drain mode until the drained runners
+// have no job left. When the drain mode makes the process exit, the process
+// can exit before the next poll, so the process not answering anymore means
+// the drained runners have no job left.
+func waitForDrain(client *adminClient, status drainStatus) error {
+	remaining := status.RemainingJobs
+	for status.RemainingJobs > 0 {
+		time.Sleep(drainCheckInterval)
+
+		if err := client.do(http.MethodGet, "drain", nil, &status); err != nil {
+			var urlErr *url.Error
+			if status.Exit && errors.As(err, &urlErr) {
+				logrus.WithError(err).Infoln("The runner process exited, the drained runners have no job left")
+				return nil
+			}
+
+			return err
+		}
+
+		if !status.Active {
+			return errors.New("the drain mode was stopped")
+		}
+
+		if status.RemainingJobs != remaining {
+			remaining = status.RemainingJobs
+			logrus.WithField("remaining_jobs", remaining).Infoln("Waiting for the jobs of the drained runners to finish")
+		}
+	}
+
+	logrus.Infoln("The drained runners have no job left")
+
+	return nil
+}
+
+func init() {
+	common.RegisterCommand2(
+		"drain",
+		"stop requesting jobs for the runners of the running process, and optionally exit once their jobs are finished",
+		&DrainCommand{},
+	)
+}
@@ -0,0 +1,342 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+// drainCheckInterval is the interval at which the jobs of the drained runners
+// are checked, to apply the deadline and to exit once they're finished
+var drainCheckInterval = time.Second
+
+var errDrainDeadlineExceeded = errors.New("the runner was drained and the drain deadline was exceeded")
+
+// drainRequest is the drain mode requested through the admin API, the drain
+// command or the drain signal
+type drainRequest struct {
+	// Runners are the short tokens or names of the runners to drain, all the
+	// runners are drained when empty
+	Runners []string `json:"runners,omitempty"`
+	// Exit makes the process exit once the drained runners have no job left
+	Exit bool `json:"exit,omitempty"`
+	// Timeout is the duration, like 30m, after which the jobs of the drained
+	// runners still running are aborted
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// drainStatus is the status of the drain mode, as reported by the health
+// endpoint and the admin API
+type drainStatus struct {
+	Active        bool       `json:"active"`
+	AllRunners    bool       `json:"all_runners,omitempty"`
+	Runners       []string   `json:"runners,omitempty"`
+	Exit          bool       `json:"exit,omitempty"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	Deadline      *time.Time `json:"deadline,omitempty"`
+	RemainingJobs int        `json:"remaining_jobs"`
+}
+
+func (s drainStatus) logFields() logrus.Fields {
+	fields := logrus.Fields{
+		"exit":           s.Exit,
+		"remaining_jobs": s.RemainingJobs,
+	}
+	if s.AllRunners {
+		fields["runners"] = "all"
+	} else {
+		fields["runners"] = s.Runners
+	}
+	if s.Deadline != nil {
+		fields["deadline"] = s.Deadline.Format(time.RFC3339)
+	}
+
+	return fields
+}
+
+// healthStatus is the response of the health endpoint
+type healthStatus struct {
+	Status string       `json:"status"`
+	Jobs   int          `json:"jobs"`
+	Drain  *drainStatus `json:"drain,omitempty"`
+}
+
+// drainMode is the drain mode of the process, in which the drained runners
+// don't request jobs anymore while the process keeps running their jobs
+type drainMode struct {
+	lock sync.RWMutex
+
+	active bool
+	// runnerKeys are the identity keys of the drained runners, all the
+	// runners are drained when nil
+	runnerKeys map[string]bool
+	runners    []string
+	exit       bool
+	startedAt  time.Time
+	deadline   time.Time
+
+	// stop stops the watcher of the drain mode
+	stop chan struct{}
+}
+
+func (d *drainMode) isDraining(runner *common.RunnerConfig) bool {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return d.active && (d.runnerKeys == nil || d.runnerKeys[runnerIdentityKey(runner)])
+}
+
+func (d *drainMode) isDrainingAll() bool {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return d.active && d.runnerKeys == nil
+}
+
+// startDrain starts the drain mode requested, replacing the current one
+func (mr *RunCommand) startDrain(request drainRequest) (drainStatus, error) {
+	var timeout time.Duration
+	if request.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(request.Timeout)
+		if err != nil || timeout <= 0 {
+			return drainStatus{}, fmt.Errorf("invalid timeout %q", request.Timeout)
+		}
+	}
+
+	var runnerKeys map[string]bool
+	var runners []string
+	if len(request.Runners) > 0 {
+		if request.Exit {
+			return drainStatus{}, errors.New("exiting requires draining all the runners")
+		}
+
+		runnerKeys = make(map[string]bool)
+		for _, reference := range request.Runners {
+			found := mr.findRunners(reference)
+			if len(found) == 0 {
+				return drainStatus{}, fmt.Errorf("runner %q not found", reference)
+			}
+
+			for _, runner := range found {
+				runnerKeys[runnerIdentityKey(runner)] = true
+				runners = append(runners, runner.ShortDescription())
+			}
+		}
+	}
+
+	stop := make(chan struct{})
+	interval := drainCheckInterval
+
+	mr.drain.lock.Lock()
+	if mr.drain.stop != nil {
+		close(mr.drain.stop)
+	}
+	mr.drain.active = true
+	mr.drain.runnerKeys = runnerKeys
+	mr.drain.runners = runners
+	mr.drain.exit = request.Exit
+	mr.drain.startedAt = time.Now()
+	mr.drain.deadline = time.Time{}
+	if timeout > 0 {
+		mr.drain.deadline = mr.drain.startedAt.Add(timeout)
+	}
+	mr.drain.stop = stop
+	mr.drain.lock.Unlock()
+
+	status := mr.drainStatus()
+	mr.log().WithFields(status.logFields()).Warningln("Drain mode started, the drained runners stop requesting jobs")
+
+	go mr.watchDrain(stop, request.Exit, timeout, interval)
+
+	return status, nil
+}
+
+// stopDrain stops the drain mode, and returns whether it was active
+func (mr *RunCommand) stopDrain() bool {
+	mr.drain.lock.Lock()
+	defer mr.drain.lock.Unlock()
+
+	if !mr.drain.active {
+		return false
+	}
+
+	close(mr.drain.stop)
+	mr.drain.active = false
+	mr.drain.runnerKeys = nil
+	mr.drain.runners = nil
+	mr.drain.exit = false
+	mr.drain.stop = nil
+
+	mr.log().Warningln("Drain mode stopped, the runners request jobs again")
+
+	return true
+}
+
+// handleDrainSignal drains all the runners, unless they already are
+func (mr *RunCommand) handleDrainSignal() {
+	if mr.drain.isDrainingAll() {
+		mr.log().Infoln("Drain signal received, all the runners are already drained")
+		return
+	}
+
+	if _, err := mr.startDrain(drainRequest{}); err != nil {
+		mr.log().WithError(err).Errorln("Failed to start the drain mode")
+	}
+}
+
+func (mr *RunCommand) drainStatus() drainStatus {
+	mr.drain.lock.RLock()
+	status := drainStatus{
+		Active:     mr.drain.active,
+		AllRunners: mr.drain.active && mr.drain.runnerKeys == nil,
+		Runners:    mr.drain.runners,
+		Exit:       mr.drain.exit,
+	}
+	if mr.drain.active {
+		startedAt := mr.drain.startedAt
+		status.StartedAt = &startedAt
+	}
+	if mr.drain.active && !mr.drain.deadline.IsZero() {
+		deadline := mr.drain.deadline
+		status.Deadline = &deadline
+	}
+	mr.drain.lock.RUnlock()
+
+	if status.Active {
+		status.RemainingJobs = len(mr.buildsHelper.runnerBuilds(mr.drain.isDraining))
+	}
+
+	return status
+}
+
+// watchDrain aborts the jobs of the drained runners once the timeout is
+// exceeded, and starts the graceful shutdown once they're finished if the
+// process should exit, until the drain mode is stopped or replaced
+func (mr *RunCommand) watchDrain(stop chan struct{}, exit bool, timeout, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+
+	deadlineExceeded := false
+	exiting := false
+	for {
+		builds := mr.buildsHelper.runnerBuilds(mr.drain.isDraining)
+		if deadlineExceeded {
+			mr.abortDrainedBuilds(builds)
+		}
+
+		if exit && !exiting && len(builds) == 0 {
+			exiting = true
+
+			mr.log().Warningln("The drained runners have no job left, exiting")
+			go func() {
+				mr.stopSignals <- syscall.SIGQUIT
+			}()
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-deadline:
+			deadlineExceeded = true
+		case <-ticker.C:
+		}
+	}
+}
+
+func (mr *RunCommand) abortDrainedBuilds(builds []*common.Build) {
+	for _, build := range builds {
+		aborted := build.Abort(&common.BuildError{
+			Inner:         errDrainDeadlineExceeded,
+			FailureReason: common.RunnerDrainTimeout,
+		})
+		if !aborted {
+			continue
+		}
+
+		mr.log().
+			WithFields(logrus.Fields{
+				"job":    build.ID,
+				"runner": build.Runner.ShortDescription(),
+			}).
+			Warningln("Job aborted, the drain deadline was exceeded")
+	}
+}
+
+// jobRequestsStopped returns why the runner doesn't request jobs, or an empty
+// string if it does
+func (mr *RunCommand) jobRequestsStopped(runner *common.RunnerConfig) string {
+	switch {
+	case mr.runnerPauses.isPaused(runner):
+		return "paused"
+	case mr.drain.isDraining(runner):
+		return "drained"
+	}
+
+	return ""
+}
+
+func (mr *RunCommand) serveHealth(mux *http.ServeMux) {
+	mux.Handle("/health", restrictHTTPMethods(http.HandlerFunc(mr.healthHandler), http.MethodGet, http.MethodHead))
+}
+
+// healthHandler reports the number of jobs of the process and, in the drain
+// mode, the number of jobs of the drained runners left
+func (mr *RunCommand) healthHandler(w http.ResponseWriter, _ *http.Request) {
+	status := healthStatus{
+		Status: "ok",
+		Jobs:   mr.buildsHelper.buildsCount(),
+	}
+
+	if drain := mr.drainStatus(); drain.Active {
+		status.Status = "draining"
+		status.Drain = &drain
+	}
+
+	writeAdminResponse(w, http.StatusOK, status)
+}
+
+func (mr *RunCommand) adminDrainStatus(_ *http.Request, _ []string) (int, interface{}) {
+	return http.StatusOK, mr.drainStatus()
+}
+
+// adminDrain starts the drain mode described by the JSON body of the
+// request, all the runners are drained when it's empty
+func (mr *RunCommand) adminDrain(r *http.Request, _ []string) (int, interface{}) {
+	var request drainRequest
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		return http.StatusBadRequest, adminError{Error: fmt.Sprintf("invalid drain request: %v", err)}
+	}
+
+	status, err := mr.startDrain(request)
+	if err != nil {
+		return http.StatusBadRequest, adminError{Error: err.Error()}
+	}
+
+	return http.StatusAccepted, status
+}
+
+func (mr *RunCommand) adminStopDrain(_ *http.Request, _ []string) (int, interface{}) {
+	if !mr.stopDrain() {
+		return http.StatusConflict, adminError{Error: "the runners aren't drained"}
+	}
+
+	return http.StatusOK, mr.drainStatus()
+}
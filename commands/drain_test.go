@@ -0,0 +1,367 @@
+//go:build !integration
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+func setDrainCheckInterval(t *testing.T, interval time.Duration) {
+	previous := drainCheckInterval
+	drainCheckInterval = interval
+	t.Cleanup(func() {
+		drainCheckInterval = previous
+	})
+}
+
+func TestStartDrain(t *testing.T) {
+	tests := map[string]struct {
+		request          drainRequest
+		expectedDrained  []bool
+		expectedDeadline bool
+		expectedError    string
+	}{
+		"all the runners": {
+			request:         drainRequest{},
+			expectedDrained: []bool{true, true},
+		},
+		"runner by name": {
+			request:         drainRequest{Runners: []string{"second"}},
+			expectedDrained: []bool{false, true},
+		},
+		"with timeout": {
+			request:          drainRequest{Timeout: "30m"},
+			expectedDrained:  []bool{true, true},
+			expectedDeadline: true,
+		},
+		"unknown runner": {
+			request:       drainRequest{Runners: []string{"unknown"}},
+			expectedError: `runner "unknown" not found`,
+		},
+		"exit with selected runners": {
+			request:       drainRequest{Runners: []string{"first"}, Exit: true},
+			expectedError: "exiting requires draining all the runners",
+		},
+		"invalid timeout": {
+			request:       drainRequest{Timeout: "-1m"},
+			expectedError: `invalid timeout "-1m"`,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			mr, _ := newAdminTestCommand(t)
+			defer mr.stopDrain()
+
+			status, err := mr.startDrain(tt.request)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				assert.False(t, mr.drainStatus().Active)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, status.Active)
+			assert.Equal(t, tt.expectedDeadline, status.Deadline != nil)
+
+			for i, runner := range mr.config.Runners {
+				assert.Equal(t, tt.expectedDrained[i], mr.drain.isDraining(runner), runner.Name)
+			}
+		})
+	}
+}
+
+func TestDrainStopsJobRequests(t *testing.T) {
+	mr, _ := newAdminTestCommand(t)
+	first, second := mr.config.Runners[0], mr.config.Runners[1]
+
+	_, err := mr.startDrain(drainRequest{Runners: []string{first.ShortDescription()}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "drained", mr.jobRequestsStopped(first))
+	assert.Empty(t, mr.jobRequestsStopped(second))
+
+	trace, jobData, err := mr.requestJob(first, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, trace)
+	assert.Nil(t, jobData)
+
+	runners := make(chan *common.RunnerConfig, 1)
+	mr.feedRunner(first, runners)
+	assert.Empty(t, runners)
+
+	assert.True(t, mr.stopDrain())
+	assert.False(t, mr.stopDrain())
+	assert.Empty(t, mr.jobRequestsStopped(first))
+}
+
+func TestDrainSurvivesTokenReset(t *testing.T) {
+	mr, _ := newAdminTestCommand(t)
+	defer mr.stopDrain()
+
+	_, err := mr.startDrain(drainRequest{Runners: []string{"second"}})
+	require.NoError(t, err)
+
+	// the reloaded configuration has the token the runner reset to
+	reset := *mr.config.Runners[1]
+	reset.Token = "glrt-reset-token"
+
+	assert.True(t, mr.drain.isDraining(&reset))
+	assert.False(t, mr.drain.isDraining(mr.config.Runners[0]))
+
+	build := &common.Build{Runner: &reset}
+	mr.buildsHelper.addBuild(build)
+	defer mr.buildsHelper.removeBuild(build)
+
+	assert.Equal(t, 1, mr.drainStatus().RemainingJobs)
+}
+
+func TestDrainExitsWhenDrained(t *testing.T) {
+	setDrainCheckInterval(t, 10*time.Millisecond)
+
+	mr, _ := newAdminTestCommand(t)
+	defer mr.stopDrain()
+
+	build := &common.Build{Runner: mr.config.Runners[0]}
+	mr.buildsHelper.addBuild(build)
+
+	status, err := mr.startDrain(drainRequest{Exit: true})
+	require.NoError(t, err)
+	assert.True(t, status.AllRunners)
+	assert.Equal(t, 1, status.RemainingJobs)
+
+	select {
+	case <-mr.stopSignals:
+		require.Fail(t, "the process exits with a job left")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	mr.buildsHelper.removeBuild(build)
+
+	select {
+	case signal := <-mr.stopSignals:
+		assert.Equal(t, syscall.SIGQUIT, signal)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the process doesn't exit once drained")
+	}
+}
+
+func TestHandleDrainSignal(t *testing.T) {
+	mr, _ := newAdminTestCommand(t)
+	defer mr.stopDrain()
+
+	_, err := mr.startDrain(drainRequest{Runners: []string{"first"}, Timeout: "1h"})
+	require.NoError(t, err)
+
+	mr.handleDrainSignal()
+	status := mr.drainStatus()
+	assert.True(t, status.AllRunners)
+	assert.Nil(t, status.Deadline)
+
+	_, err = mr.startDrain(drainRequest{Timeout: "1h"})
+	require.NoError(t, err)
+
+	mr.handleDrainSignal()
+	assert.NotNil(t, mr.drainStatus().Deadline, "an existing drain of all the runners is kept")
+}
+
+func TestHealthHandler(t *testing.T) {
+	mr, server := newAdminTestCommand(t)
+	defer mr.stopDrain()
+
+	mux := http.NewServeMux()
+	mr.serveHealth(mux)
+	healthServer := httptest.NewServer(mux)
+	defer healthServer.Close()
+
+	getHealth := func() healthStatus {
+		var status healthStatus
+		resp, err := http.Get(healthServer.URL + "/health")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+		return status
+	}
+
+	mr.buildsHelper.addBuild(&common.Build{Runner: mr.config.Runners[0]})
+	mr.buildsHelper.addBuild(&common.Build{Runner: mr.config.Runners[1]})
+
+	status := getHealth()
+	assert.Equal(t, "ok", status.Status)
+	assert.Equal(t, 2, status.Jobs)
+	assert.Nil(t, status.Drain)
+
+	var drain drainStatus
+	require.Equal(
+		t,
+		http.StatusAccepted,
+		doAdminRequestWithBody(t, server, http.MethodPost, "/admin/drain", `{"runners": ["first"]}`, &drain),
+	)
+	assert.Equal(t, []string{mr.config.Runners[0].ShortDescription()}, drain.Runners)
+
+	status = getHealth()
+	assert.Equal(t, "draining", status.Status)
+	assert.Equal(t, 2, status.Jobs)
+	require.NotNil(t, status.Drain)
+	assert.Equal(t, 1, status.Drain.RemainingJobs)
+}
+
+func TestAdminAPIDrain(t *testing.T) {
+	mr, server := newAdminTestCommand(t)
+	defer mr.stopDrain()
+
+	var drain drainStatus
+	require.Equal(t, http.StatusOK, doAdminRequest(t, server, http.MethodGet, "/admin/drain", &drain))
+	assert.False(t, drain.Active)
+
+	var apiErr adminError
+	assert.Equal(t, http.StatusConflict, doAdminRequest(t, server, http.MethodDelete, "/admin/drain", &apiErr))
+	assert.Equal(t, "the runners aren't drained", apiErr.Error)
+
+	assert.Equal(
+		t,
+		http.StatusBadRequest,
+		doAdminRequestWithBody(t, server, http.MethodPost, "/admin/drain", `{"runner": ["first"]}`, &apiErr),
+	)
+	assert.Contains(t, apiErr.Error, "invalid drain request")
+
+	require.Equal(t, http.StatusAccepted, doAdminRequest(t, server, http.MethodPost, "/admin/drain", &drain))
+	assert.True(t, drain.Active)
+	assert.True(t, drain.AllRunners)
+
+	require.Equal(t, http.StatusOK, doAdminRequest(t, server, http.MethodDelete, "/admin/drain", &drain))
+	assert.False(t, drain.Active)
+}
+
+func TestDrainCommand(t *testing.T) {
+	setDrainCheckInterval(t, 10*time.Millisecond)
+
+	mr, server := newAdminTestCommand(t)
+	defer mr.stopDrain()
+
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	config := "listen_address = \"" + strings.TrimPrefix(server.URL, "http://") + "\"\n" +
+		"admin_token = \"" + testAdminToken + "\"\n"
+	require.NoError(t, os.WriteFile(configFile, []byte(config), 0o600))
+
+	newCommand := func() *DrainCommand {
+		c := &DrainCommand{}
+		c.ConfigFile = configFile
+		require.NoError(t, c.loadConfig())
+		return c
+	}
+
+	c := newCommand()
+	c.Runners = []string{"second"}
+	c.Timeout = time.Hour
+	require.NoError(t, c.drain())
+
+	status := mr.drainStatus()
+	assert.Equal(t, []string{mr.config.Runners[1].ShortDescription()}, status.Runners)
+	assert.NotNil(t, status.Deadline)
+
+	c = newCommand()
+	c.Runners = []string{"unknown"}
+	assert.EqualError(t, c.drain(), `POST /admin/drain: runner "unknown" not found`)
+
+	c = newCommand()
+	c.Status = true
+	c.Wait = true
+	require.NoError(t, c.drain())
+
+	build := &common.Build{Runner: mr.config.Runners[1]}
+	mr.buildsHelper.addBuild(build)
+	time.AfterFunc(50*time.Millisecond, func() {
+		mr.buildsHelper.removeBuild(build)
+	})
+
+	c = newCommand()
+	c.Status = true
+	c.Wait = true
+	require.NoError(t, c.drain())
+	assert.Zero(t, mr.buildsHelper.buildsCount())
+
+	c = newCommand()
+	c.Stop = true
+	require.NoError(t, c.drain())
+	assert.False(t, mr.drainStatus().Active)
+
+	c = newCommand()
+	c.Status = true
+	c.Stop = true
+	assert.EqualError(t, c.drain(), "--status and --stop can't be used together")
+}
+
+func TestWaitForDrainProcessExited(t *testing.T) {
+	setDrainCheckInterval(t, 10*time.Millisecond)
+
+	tests := map[string]struct {
+		status        drainStatus
+		expectedError bool
+	}{
+		"exit": {
+			status: drainStatus{Active: true, Exit: true, RemainingJobs: 1},
+		},
+		"no exit": {
+			status:        drainStatus{Active: true, RemainingJobs: 1},
+			expectedError: true,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			// the process exited and stopped serving the admin API
+			server := httptest.NewServer(http.NotFoundHandler())
+			server.Close()
+
+			client := &adminClient{
+				url:    server.URL + adminAPIPath,
+				token:  testAdminToken,
+				client: &http.Client{Timeout: time.Second},
+			}
+
+			err := waitForDrain(client, tt.status)
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func doAdminRequestWithBody(
+	t *testing.T,
+	server *httptest.Server,
+	method, path, body string,
+	response interface{},
+) int {
+	req, err := http.NewRequest(method, server.URL+path, bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(response))
+
+	return resp.StatusCode
+}
@@ -0,0 +1,12 @@
+//go:build aix || android || darwin || dragonfly || freebsd || hurd || illumos || linux || netbsd || openbsd || solaris
+
+package commands
+
+import (
+	"os"
+	"syscall"
+)
+
+// drainSignals are the signals draining all the runners, SIGUSR1 being used
+// to dump the goroutines
+var drainSignals = []os.Signal{syscall.SIGUSR2}
@@ -0,0 +1,9 @@
+package commands
+
+import (
+	"os"
+)
+
+// drainSignals are the signals draining all the runners, there's none on
+// Windows where the admin API or the drain command are used instead
+var drainSignals []os.Signal
@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"strings"
@@ -15,17 +17,31 @@ import (
 	// Force to load all executors, executes init() on them
 	_ "gitlab.com/gitlab-org/gitlab-runner/executors/custom"
 	_ "gitlab.com/gitlab-org/gitlab-runner/executors/docker"
+	_ "gitlab.com/gitlab-org/gitlab-runner/executors/kubernetes"
 	_ "gitlab.com/gitlab-org/gitlab-runner/executors/parallels"
 	_ "gitlab.com/gitlab-org/gitlab-runner/executors/shell"
 	_ "gitlab.com/gitlab-org/gitlab-runner/executors/ssh"
 	_ "gitlab.com/gitlab-org/gitlab-runner/executors/virtualbox"
 )
 
+// execArtifactsCacheKeyPrefix prefixes the keys of the caches passing the
+// artifacts of the jobs to the jobs which need them
+const execArtifactsCacheKeyPrefix = "exec-artifacts/"
+
+// localSourcesExecutors are the executors which can't clone the local
+// repository: the local working tree is copied to their build environment
+var localSourcesExecutors = map[string]bool{
+	"kubernetes": true,
+}
+
 type ExecCommand struct {
 	common.RunnerSettings
 	Job            string
 	CICDConfigFile string `long:"cicd-config-file" description:"CI/CD configuration file"`
 	Timeout        int    `long:"timeout" description:"Job execution timeout (in seconds)"`
+	StoreDir       string `long:"store-dir" description:"Directory keeping the caches and the artifacts of the jobs between exec invocations (default ~/.gitlab-runner/exec)"`
+	ClearCache     bool   `long:"clear-cache" description:"Remove the caches of the project from the store before running the job"`
+	ClearArtifacts bool   `long:"clear-artifacts" description:"Remove the artifacts of the project from the store before running the job"`
 }
 
 // nolint:unparam
@@ -37,7 +53,7 @@ func (c *ExecCommand) runCommand(name string, arg ...string) (string, error) {
 	return string(result), err
 }
 
-func (c *ExecCommand) createBuild(repoURL string, abortSignal chan os.Signal) (*common.Build, error) {
+func (c *ExecCommand) createBuild(repoURL string, id int64, abortSignal chan os.Signal) (*common.Build, error) {
 	// Check if we have uncommitted changes
 	_, err := c.runCommand("git", "diff", "--quiet", "HEAD")
 	if err != nil {
@@ -62,7 +78,7 @@ func (c *ExecCommand) createBuild(repoURL string, abortSignal chan os.Signal) (*
 	}
 
 	jobResponse := common.JobResponse{
-		ID:            1,
+		ID:            id,
 		Token:         "",
 		AllowGitFetch: false,
 		JobInfo: common.JobInfo{
@@ -95,7 +111,24 @@ func (c *ExecCommand) Execute(context *cli.Context) {
 		logrus.Fatalln(err)
 	}
 
+	store, err := c.store(wd)
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+
+	err = store.clear(c.ClearCache, c.ClearArtifacts)
+	if err != nil {
+		logrus.Fatalln("Failed to clear the store:", err)
+	}
+
 	switch len(context.Args()) {
+	case 0:
+		if c.ClearCache || c.ClearArtifacts {
+			return
+		}
+		_ = cli.ShowSubcommandHelp(context)
+		os.Exit(1)
+		return
 	case 1:
 		c.Job = context.Args().Get(0)
 	default:
@@ -117,22 +150,219 @@ func (c *ExecCommand) Execute(context *cli.Context) {
 	}
 	c.RunnerSettings.Docker.Volumes = append(c.RunnerSettings.Docker.Volumes, wd+":"+wd+":ro")
 
-	// Create build
-	build, err := c.createBuild(wd, abortSignal)
+	err = os.MkdirAll(store.dir, 0o700)
 	if err != nil {
 		logrus.Fatalln(err)
 	}
 
-	parser := gitlab_ci_yaml_parser.NewGitLabCiYamlParser(c.CICDConfigFile, c.Job)
-	err = parser.ParseYaml(&build.JobResponse)
+	if !c.useLocalCacheDir(store.dir) {
+		logrus.Warningln(c.storeUnavailableReason())
+		store = nil
+	}
+
+	err = c.runJobs(wd, store, abortSignal)
 	if err != nil {
 		logrus.Fatalln(err)
 	}
+}
+
+// store returns the store of the project of the working directory
+func (c *ExecCommand) store(wd string) (*execStore, error) {
+	root := c.StoreDir
+	if root == "" {
+		var err error
+		root, err = defaultExecStoreRoot()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newExecStore(root, wd), nil
+}
 
-	err = build.Run(&common.Config{}, &common.Trace{Writer: os.Stdout})
+// runJobs runs the job after the jobs it needs, in order. The artifacts of
+// the dependencies which aren't run are taken from the store, if any.
+func (c *ExecCommand) runJobs(wd string, store *execStore, abortSignal chan os.Signal) error {
+	parser := gitlab_ci_yaml_parser.NewGitLabCiYamlParser(c.CICDConfigFile, c.Job)
+	jobs, err := parser.NeedsChain()
 	if err != nil {
-		logrus.Fatalln(err)
+		return err
+	}
+
+	var builds []*common.Build
+	for i, job := range jobs {
+		build, err := c.createBuild(wd, int64(i+1), abortSignal)
+		if err != nil {
+			return err
+		}
+
+		parser := gitlab_ci_yaml_parser.NewGitLabCiYamlParser(c.CICDConfigFile, job)
+		err = parser.ParseYaml(&build.JobResponse)
+		if errors.Is(err, gitlab_ci_yaml_parser.ErrJobExcludedByRules) && job != c.Job {
+			logrus.Warningln("Skipping needed job:", err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if localSourcesExecutors[c.Executor] {
+			build.LocalSources = wd
+			build.Variables = append(build.Variables, common.JobVariable{
+				Key:   "GIT_STRATEGY",
+				Value: "none",
+			})
+		}
+
+		builds = append(builds, build)
+	}
+
+	passArtifacts(builds, c.storedArtifacts(builds, store))
+
+	for _, build := range builds {
+		if len(builds) > 1 {
+			logrus.Infoln("Running job", build.JobInfo.Name)
+		}
+
+		err := build.Run(&common.Config{}, &common.Trace{Writer: os.Stdout})
+		if err != nil {
+			return fmt.Errorf("job %q: %w", build.JobInfo.Name, err)
+		}
 	}
+
+	return nil
+}
+
+// storedArtifacts returns the artifacts of the dependencies of the builds
+// which aren't run but whose artifacts were stored by a previous run
+func (c *ExecCommand) storedArtifacts(builds []*common.Build, store *execStore) map[string]common.Artifacts {
+	stored := make(map[string]common.Artifacts)
+	if store == nil {
+		return stored
+	}
+
+	run := make(map[string]bool)
+	for _, build := range builds {
+		run[build.JobInfo.Name] = true
+	}
+
+	for _, build := range builds {
+		for _, dependency := range build.Dependencies {
+			if run[dependency.Name] {
+				continue
+			}
+			if _, ok := stored[dependency.Name]; ok {
+				continue
+			}
+
+			if !store.hasArtifacts(build, dependency.Name) {
+				logrus.Debugln("No stored artifacts of", dependency.Name)
+				continue
+			}
+
+			job := common.JobResponse{GitInfo: build.GitInfo}
+			parser := gitlab_ci_yaml_parser.NewGitLabCiYamlParser(c.CICDConfigFile, dependency.Name)
+			if err := parser.ParseYaml(&job); err != nil {
+				logrus.Warningln("Skipping the stored artifacts of", dependency.Name+":", err)
+				continue
+			}
+
+			logrus.Infoln("Using the stored artifacts of", dependency.Name)
+			stored[dependency.Name] = job.Artifacts
+		}
+	}
+
+	return stored
+}
+
+// passArtifacts passes the artifacts of the jobs to the jobs which depend on
+// them through the local cache: with no GitLab instance to upload them to,
+// the artifacts of every job are pushed to a cache which the jobs depending
+// on them pull. The stored artifacts are the ones of the dependencies which
+// aren't run, pushed by a previous run.
+func passArtifacts(builds []*common.Build, stored map[string]common.Artifacts) {
+	artifacts := make(map[string]common.Artifacts)
+	for name, artifact := range stored {
+		artifacts[name] = artifact
+	}
+	for _, build := range builds {
+		artifacts[build.JobInfo.Name] = build.Artifacts
+	}
+
+	for _, build := range builds {
+		for _, dependency := range build.Dependencies {
+			for _, artifact := range artifacts[dependency.Name] {
+				build.Cache = append(build.Cache, common.Cache{
+					Key:       execArtifactsCacheKeyPrefix + dependency.Name,
+					Paths:     artifact.Paths,
+					Untracked: artifact.Untracked,
+					Policy:    common.CachePolicyPull,
+				})
+			}
+		}
+	}
+
+	for _, build := range builds {
+		for _, artifact := range build.Artifacts {
+			when := common.CacheWhen(artifact.When)
+			if when == "" {
+				when = common.CacheWhenOnSuccess
+			}
+
+			build.Cache = append(build.Cache, common.Cache{
+				Key:       execArtifactsCacheKeyPrefix + build.JobInfo.Name,
+				Paths:     artifact.Paths,
+				Untracked: artifact.Untracked,
+				Policy:    common.CachePolicyPush,
+				When:      when,
+			})
+		}
+	}
+}
+
+// useLocalCacheDir stores the caches of the jobs in a local directory, shared
+// by the jobs run by exec, unless the cache directory is already configured.
+// It returns whether the caches are stored in the directory.
+func (c *ExecCommand) useLocalCacheDir(dir string) bool {
+	switch c.Executor {
+	case "shell":
+		if c.RunnerSettings.CacheDir == "" {
+			c.RunnerSettings.CacheDir = dir
+		}
+
+		return c.RunnerSettings.CacheDir == dir
+	case "docker":
+		cacheDir := c.RunnerSettings.CacheDir
+		if cacheDir == "" {
+			cacheDir = "/cache"
+		}
+
+		for _, volume := range c.RunnerSettings.Docker.Volumes {
+			parts := strings.Split(volume, ":")
+			if len(parts) == 1 && parts[0] == cacheDir || len(parts) > 1 && parts[1] == cacheDir {
+				return false
+			}
+		}
+
+		c.RunnerSettings.Docker.Volumes = append(c.RunnerSettings.Docker.Volumes, dir+":"+cacheDir)
+
+		return true
+	}
+
+	return false
+}
+
+// storeUnavailableReason explains why the caches and the artifacts of the
+// jobs aren't kept in the store
+func (c *ExecCommand) storeUnavailableReason() string {
+	switch c.Executor {
+	case "shell", "docker":
+		return "The caches and the artifacts of the jobs are kept in the configured cache directory, " +
+			"not in the store: the artifacts of the dependencies which aren't run can't be downloaded"
+	}
+
+	return fmt.Sprintf("The %s executor doesn't support the store: the caches and the artifacts of the jobs "+
+		"aren't kept between exec invocations", c.Executor)
 }
 
 func init() {
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers"
+)
+
+// execStore is the local directory keeping the caches and the artifacts of
+// the jobs of a project run by exec between exec invocations. It's laid out
+// like the cache directory of the runner: <project>/<cache key>/cache.zip,
+// the artifacts being stored in the caches prefixed by
+// execArtifactsCacheKeyPrefix.
+type execStore struct {
+	dir string
+}
+
+// defaultExecStoreRoot returns the directory of the stores of the projects
+func defaultExecStoreRoot() (string, error) {
+	homeDir := helpers.GetHomeDir()
+	if homeDir == "" {
+		return "", fmt.Errorf("the home directory is unknown, set --store-dir")
+	}
+
+	return filepath.Join(homeDir, ".gitlab-runner", "exec"), nil
+}
+
+// newExecStore returns the store of the project of the working directory,
+// named after the directory and a hash of its path, so that projects with
+// the same name don't share their store
+func newExecStore(root, wd string) *execStore {
+	sum := sha256.Sum256([]byte(wd))
+
+	return &execStore{
+		dir: filepath.Join(root, fmt.Sprintf("%s-%x", filepath.Base(wd), sum[:4])),
+	}
+}
+
+// artifactsFile returns the path of the archive of the artifacts of the job
+// in the store
+func (s *execStore) artifactsFile(build *common.Build, job string) string {
+	return filepath.Join(
+		s.dir,
+		filepath.FromSlash(build.ProjectUniqueDir(false)),
+		filepath.FromSlash(execArtifactsCacheKeyPrefix+job),
+		"cache.zip",
+	)
+}
+
+// hasArtifacts returns whether the artifacts of the job were stored by a
+// previous run of the job
+func (s *execStore) hasArtifacts(build *common.Build, job string) bool {
+	_, err := os.Stat(s.artifactsFile(build, job))
+	return err == nil
+}
+
+// clear removes the caches, the artifacts or both from the store
+func (s *execStore) clear(caches, artifacts bool) error {
+	projects, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	artifactsDir := filepath.Dir(filepath.FromSlash(execArtifactsCacheKeyPrefix))
+
+	for _, project := range projects {
+		projectDir := filepath.Join(s.dir, project.Name())
+
+		entries, err := os.ReadDir(projectDir)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			isArtifacts := entry.Name() == artifactsDir
+			if isArtifacts && !artifacts || !isArtifacts && !caches {
+				continue
+			}
+
+			entryPath := filepath.Join(projectDir, entry.Name())
+			err := os.RemoveAll(entryPath)
+			if errors.Is(err, fs.ErrPermission) {
+				// the jobs of the docker executor write the store as root
+				return fmt.Errorf("%w: the files were probably written as root by a docker job, remove %s as root", err, entryPath)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
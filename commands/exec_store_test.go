@@ -0,0 +1,102 @@
+//go:build !integration
+
+package commands
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+func TestNewExecStore(t *testing.T) {
+	store := newExecStore("/store", "/src/project")
+	other := newExecStore("/store", "/other/project")
+
+	assert.Equal(t, "/store", filepath.Dir(store.dir))
+	assert.Regexp(t, `^project-[0-9a-f]{8}$`, filepath.Base(store.dir))
+	assert.NotEqual(t, store.dir, other.dir)
+}
+
+func TestExecStoreClear(t *testing.T) {
+	tests := map[string]struct {
+		caches            bool
+		artifacts         bool
+		expectedCache     bool
+		expectedArtifacts bool
+	}{
+		"nothing": {
+			expectedCache:     true,
+			expectedArtifacts: true,
+		},
+		"caches": {
+			caches:            true,
+			expectedArtifacts: true,
+		},
+		"artifacts": {
+			artifacts:     true,
+			expectedCache: true,
+		},
+		"caches and artifacts": {
+			caches:    true,
+			artifacts: true,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			store := &execStore{dir: t.TempDir()}
+
+			build := &common.Build{}
+			build.JobInfo.ProjectID = 1
+
+			cacheFile := filepath.Join(store.dir, "project-1", "default", "cache.zip")
+			for _, file := range []string{cacheFile, store.artifactsFile(build, "compile")} {
+				require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o700))
+				require.NoError(t, os.WriteFile(file, []byte("zip"), 0o600))
+			}
+
+			require.NoError(t, store.clear(tt.caches, tt.artifacts))
+
+			assert.Equal(t, tt.expectedCache, fileExists(cacheFile))
+			assert.Equal(t, tt.expectedArtifacts, store.hasArtifacts(build, "compile"))
+		})
+	}
+}
+
+func TestExecStoreClearMissing(t *testing.T) {
+	store := &execStore{dir: filepath.Join(t.TempDir(), "missing")}
+
+	assert.NoError(t, store.clear(true, true))
+}
+
+func TestExecStoreClearPermissionDenied(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("the permissions of the directory aren't enforced")
+	}
+
+	store := &execStore{dir: t.TempDir()}
+
+	cacheFile := filepath.Join(store.dir, "project-1", "default", "cache.zip")
+	require.NoError(t, os.MkdirAll(filepath.Dir(cacheFile), 0o700))
+	require.NoError(t, os.WriteFile(cacheFile, []byte("zip"), 0o600))
+
+	projectDir := filepath.Join(store.dir, "project-1")
+	require.NoError(t, os.Chmod(projectDir, 0o500))
+	t.Cleanup(func() { _ = os.Chmod(projectDir, 0o700) })
+
+	err := store.clear(true, false)
+	assert.ErrorIs(t, err, fs.ErrPermission)
+	assert.ErrorContains(t, err, "remove "+filepath.Join(projectDir, "default")+" as root")
+}
+
+func fileExists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
@@ -0,0 +1,135 @@
+//go:build !integration
+
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+func newExecTestBuild(name string, artifacts common.Artifacts, dependencies ...string) *common.Build {
+	build := &common.Build{}
+	build.JobInfo.Name = name
+	build.Artifacts = artifacts
+	for _, dependency := range dependencies {
+		build.Dependencies = append(build.Dependencies, common.Dependency{Name: dependency})
+	}
+
+	return build
+}
+
+func TestPassArtifacts(t *testing.T) {
+	compile := newExecTestBuild("compile", common.Artifacts{{Paths: common.ArtifactPaths{"bin/"}}})
+	generate := newExecTestBuild("generate", common.Artifacts{{Untracked: true, When: common.ArtifactWhenAlways}})
+	unused := newExecTestBuild("unused", common.Artifacts{{Paths: common.ArtifactPaths{"out/"}}})
+	test := newExecTestBuild("test", nil, "compile", "generate", "skipped", "stored")
+
+	stored := map[string]common.Artifacts{
+		"stored": {{Paths: common.ArtifactPaths{"dist/"}}},
+	}
+
+	passArtifacts([]*common.Build{compile, generate, unused, test}, stored)
+
+	assert.Equal(t, common.Caches{
+		{
+			Key:    "exec-artifacts/compile",
+			Paths:  common.ArtifactPaths{"bin/"},
+			Policy: common.CachePolicyPush,
+			When:   common.CacheWhenOnSuccess,
+		},
+	}, compile.Cache)
+	assert.Equal(t, common.Caches{
+		{
+			Key:       "exec-artifacts/generate",
+			Untracked: true,
+			Policy:    common.CachePolicyPush,
+			When:      common.CacheWhenAlways,
+		},
+	}, generate.Cache)
+	assert.Equal(t, common.Caches{
+		{
+			Key:    "exec-artifacts/unused",
+			Paths:  common.ArtifactPaths{"out/"},
+			Policy: common.CachePolicyPush,
+			When:   common.CacheWhenOnSuccess,
+		},
+	}, unused.Cache)
+	assert.Equal(t, common.Caches{
+		{
+			Key:    "exec-artifacts/compile",
+			Paths:  common.ArtifactPaths{"bin/"},
+			Policy: common.CachePolicyPull,
+		},
+		{
+			Key:       "exec-artifacts/generate",
+			Untracked: true,
+			Policy:    common.CachePolicyPull,
+		},
+		{
+			Key:    "exec-artifacts/stored",
+			Paths:  common.ArtifactPaths{"dist/"},
+			Policy: common.CachePolicyPull,
+		},
+	}, test.Cache)
+}
+
+func TestExecUseLocalCacheDir(t *testing.T) {
+	tests := map[string]struct {
+		executor         string
+		settings         common.RunnerSettings
+		expectedSettings common.RunnerSettings
+		expectedLocal    bool
+	}{
+		"shell": {
+			executor:         "shell",
+			expectedSettings: common.RunnerSettings{CacheDir: "/tmp/exec"},
+			expectedLocal:    true,
+		},
+		"shell with cache dir": {
+			executor:         "shell",
+			settings:         common.RunnerSettings{CacheDir: "/var/cache"},
+			expectedSettings: common.RunnerSettings{CacheDir: "/var/cache"},
+		},
+		"docker": {
+			executor: "docker",
+			settings: common.RunnerSettings{Docker: &common.DockerConfig{Volumes: []string{"/src:/src:ro"}}},
+			expectedSettings: common.RunnerSettings{
+				Docker: &common.DockerConfig{Volumes: []string{"/src:/src:ro", "/tmp/exec:/cache"}},
+			},
+			expectedLocal: true,
+		},
+		"docker with cache dir": {
+			executor: "docker",
+			settings: common.RunnerSettings{CacheDir: "/ci-cache", Docker: &common.DockerConfig{}},
+			expectedSettings: common.RunnerSettings{
+				CacheDir: "/ci-cache",
+				Docker:   &common.DockerConfig{Volumes: []string{"/tmp/exec:/ci-cache"}},
+			},
+			expectedLocal: true,
+		},
+		"docker with cache volume": {
+			executor:         "docker",
+			settings:         common.RunnerSettings{Docker: &common.DockerConfig{Volumes: []string{"/cache"}}},
+			expectedSettings: common.RunnerSettings{Docker: &common.DockerConfig{Volumes: []string{"/cache"}}},
+		},
+		"kubernetes": {
+			executor: "kubernetes",
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			c := &ExecCommand{RunnerSettings: tt.settings}
+			c.Executor = tt.executor
+			tt.expectedSettings.Executor = tt.executor
+
+			local := c.useLocalCacheDir("/tmp/exec")
+
+			assert.Equal(t, tt.expectedLocal, local)
+			assert.Equal(t, tt.expectedSettings, c.RunnerSettings)
+		})
+	}
+}
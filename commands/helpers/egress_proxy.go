@@ -0,0 +1,42 @@
+package helpers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/egress"
+)
+
+type EgressProxyCommand struct {
+	Listen        string   `long:"listen" description:"Address the proxy listens on"`
+	Allow         []string `long:"allow" description:"CIDR, IP address or hostname the jobs can connect to"`
+	BlockMetadata bool     `long:"block-metadata" description:"Block the cloud instance metadata endpoints"`
+}
+
+func (c *EgressProxyCommand) Execute(_ *cli.Context) {
+	policy, err := egress.NewPolicy(c.Allow, c.BlockMetadata)
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+
+	server := &http.Server{
+		Addr:              c.Listen,
+		Handler:           egress.NewProxy(policy, logrus.StandardLogger()),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	logrus.Debugln("Egress proxy listening on", c.Listen)
+	logrus.Fatalln(server.ListenAndServe())
+}
+
+func init() {
+	common.RegisterCommand2(
+		"egress-proxy",
+		"proxy enforcing the egress policy of the jobs (internal)",
+		&EgressProxyCommand{Listen: ":8080"},
+	)
+}
@@ -1,14 +1,50 @@
 package commands
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 
 	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers"
+)
+
+const (
+	listFormatText  = "text"
+	listFormatJSON  = "json"
+	listFormatTable = "table"
 )
 
+// maskedToken replaces the secret part of the tokens which aren't shown
+const maskedToken = "[MASKED]"
+
 type ListCommand struct {
 	configOptions
+
+	ShowTokens bool   `long:"show-tokens" description:"Show the tokens of the runners instead of redacting them"`
+	Format     string `long:"format" description:"Output format: text, json or table"`
+}
+
+// listedRunner is a runner as listed in the json and table formats
+type listedRunner struct {
+	Name               string          `json:"name"`
+	Executor           string          `json:"executor"`
+	URL                string          `json:"url"`
+	Token              string          `json:"token"`
+	TokenExpiresAt     *time.Time      `json:"token_expires_at,omitempty"`
+	SystemID           string          `json:"system_id"`
+	Limit              int             `json:"limit"`
+	RequestConcurrency int             `json:"request_concurrency"`
+	FeatureFlags       map[string]bool `json:"feature_flags,omitempty"`
 }
 
 func (c *ListCommand) Execute(context *cli.Context) {
@@ -18,6 +54,24 @@ func (c *ListCommand) Execute(context *cli.Context) {
 		return
 	}
 
+	switch c.Format {
+	case "", listFormatText:
+		c.logRunners()
+	case listFormatJSON:
+		err = c.writeJSON(os.Stdout)
+	case listFormatTable:
+		err = c.writeTable(os.Stdout)
+	default:
+		err = fmt.Errorf("unsupported format %q, use %s, %s or %s",
+			c.Format, listFormatText, listFormatJSON, listFormatTable)
+	}
+
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+}
+
+func (c *ListCommand) logRunners() {
 	logrus.WithFields(logrus.Fields{
 		"ConfigFile": c.ConfigFile,
 	}).Println("Listing configured runners")
@@ -25,12 +79,107 @@ func (c *ListCommand) Execute(context *cli.Context) {
 	for _, runner := range c.getConfig().Runners {
 		logrus.WithFields(logrus.Fields{
 			"Executor": runner.RunnerSettings.Executor,
-			"Token":    runner.RunnerCredentials.Token,
+			"Token":    c.token(runner),
 			"URL":      runner.RunnerCredentials.URL,
 		}).Println(runner.Name)
 	}
 }
 
+func (c *ListCommand) writeJSON(w io.Writer) error {
+	runners := c.listedRunners()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(runners)
+}
+
+func (c *ListCommand) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "NAME\tEXECUTOR\tURL\tTOKEN\tTOKEN EXPIRES AT\tSYSTEM ID\tLIMIT\tCONCURRENCY\tFEATURE FLAGS")
+
+	for _, runner := range c.listedRunners() {
+		expiresAt := "never"
+		if runner.TokenExpiresAt != nil {
+			expiresAt = runner.TokenExpiresAt.Format(time.RFC3339)
+		}
+
+		_, _ = fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			runner.Name,
+			runner.Executor,
+			runner.URL,
+			runner.Token,
+			expiresAt,
+			runner.SystemID,
+			runner.Limit,
+			runner.RequestConcurrency,
+			formatFeatureFlags(runner.FeatureFlags),
+		)
+	}
+
+	return tw.Flush()
+}
+
+func (c *ListCommand) listedRunners() []listedRunner {
+	runners := make([]listedRunner, 0, len(c.getConfig().Runners))
+
+	for _, runner := range c.getConfig().Runners {
+		listed := listedRunner{
+			Name:               runner.Name,
+			Executor:           runner.Executor,
+			URL:                runner.URL,
+			Token:              c.token(runner),
+			SystemID:           runner.GetSystemID(),
+			Limit:              runner.Limit,
+			RequestConcurrency: runner.GetRequestConcurrency(),
+			FeatureFlags:       runner.FeatureFlags,
+		}
+
+		if !runner.TokenExpiresAt.IsZero() {
+			expiresAt := runner.TokenExpiresAt
+			listed.TokenExpiresAt = &expiresAt
+		}
+
+		runners = append(runners, listed)
+	}
+
+	return runners
+}
+
+// token returns the token of the runner, redacted to the short token which
+// identifies the runner in the logs unless the tokens are shown
+func (c *ListCommand) token(runner *common.RunnerConfig) string {
+	if c.ShowTokens || runner.Token == "" {
+		return runner.Token
+	}
+
+	short := helpers.ShortenToken(runner.Token)
+	if short == runner.Token {
+		return maskedToken
+	}
+
+	return short + maskedToken
+}
+
+// formatFeatureFlags formats the feature flags as a sorted list of
+// NAME=value pairs
+func formatFeatureFlags(featureFlags map[string]bool) string {
+	if len(featureFlags) == 0 {
+		return "-"
+	}
+
+	flags := make([]string, 0, len(featureFlags))
+	for name, value := range featureFlags {
+		flags = append(flags, name+"="+strconv.FormatBool(value))
+	}
+	sort.Strings(flags)
+
+	return strings.Join(flags, ",")
+}
+
 func init() {
 	common.RegisterCommand2("list", "List all configured runners", &ListCommand{})
 }
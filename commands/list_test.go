@@ -0,0 +1,124 @@
+//go:build !integration
+
+package commands
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+func newListTestCommand(showTokens bool) *ListCommand {
+	return &ListCommand{
+		ShowTokens: showTokens,
+		configOptions: configOptions{
+			config: &common.Config{
+				Runners: []*common.RunnerConfig{
+					{
+						Name: "docker-runner",
+						RunnerCredentials: common.RunnerCredentials{
+							URL:            "https://gitlab.example.com",
+							Token:          "glrt-abcdefghijklmnopqrst",
+							TokenExpiresAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+						},
+						Limit:              4,
+						RequestConcurrency: 2,
+						RunnerSettings: common.RunnerSettings{
+							Executor:     "docker",
+							FeatureFlags: map[string]bool{"FF_B": false, "FF_A": true},
+						},
+					},
+					{
+						Name: "shell-runner",
+						RunnerCredentials: common.RunnerCredentials{
+							URL:   "https://gitlab.example.com",
+							Token: "short",
+						},
+						RunnerSettings: common.RunnerSettings{
+							Executor: "shell",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestListCommandToken(t *testing.T) {
+	tests := map[string]struct {
+		showTokens    bool
+		token         string
+		expectedToken string
+	}{
+		"redacted": {
+			token:         "glrt-abcdefghijklmnopqrst",
+			expectedToken: "abcdefghi[MASKED]",
+		},
+		"redacted short token": {
+			token:         "short",
+			expectedToken: "[MASKED]",
+		},
+		"empty token": {
+			token:         "",
+			expectedToken: "",
+		},
+		"shown": {
+			showTokens:    true,
+			token:         "glrt-abcdefghijklmnopqrst",
+			expectedToken: "glrt-abcdefghijklmnopqrst",
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			c := &ListCommand{ShowTokens: tt.showTokens}
+			runner := &common.RunnerConfig{RunnerCredentials: common.RunnerCredentials{Token: tt.token}}
+
+			assert.Equal(t, tt.expectedToken, c.token(runner))
+		})
+	}
+}
+
+func TestListCommandJSON(t *testing.T) {
+	buf := new(bytes.Buffer)
+	require.NoError(t, newListTestCommand(false).writeJSON(buf))
+
+	assert.JSONEq(t, `[
+		{
+			"name": "docker-runner",
+			"executor": "docker",
+			"url": "https://gitlab.example.com",
+			"token": "abcdefghi[MASKED]",
+			"token_expires_at": "2026-01-02T03:04:05Z",
+			"system_id": "unknown",
+			"limit": 4,
+			"request_concurrency": 2,
+			"feature_flags": {"FF_A": true, "FF_B": false}
+		},
+		{
+			"name": "shell-runner",
+			"executor": "shell",
+			"url": "https://gitlab.example.com",
+			"token": "[MASKED]",
+			"system_id": "unknown",
+			"limit": 0,
+			"request_concurrency": 1
+		}
+	]`, buf.String())
+}
+
+func TestListCommandTable(t *testing.T) {
+	buf := new(bytes.Buffer)
+	require.NoError(t, newListTestCommand(true).writeTable(buf))
+
+	assert.Equal(t, ""+
+		"NAME           EXECUTOR  URL                         TOKEN                      TOKEN EXPIRES AT      SYSTEM ID  LIMIT  CONCURRENCY  FEATURE FLAGS\n"+
+		"docker-runner  docker    https://gitlab.example.com  glrt-abcdefghijklmnopqrst  2026-01-02T03:04:05Z  unknown    4      2            FF_A=true,FF_B=false\n"+
+		"shell-runner   shell     https://gitlab.example.com  short                      never                 unknown    0      1            -\n",
+		buf.String())
+}
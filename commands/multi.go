@@ -103,6 +103,17 @@ type RunCommand struct {
 
 	sessionServer *session.Server
 
+	// runnerPauses are the runners whose job requests were paused through
+	// the admin API
+	runnerPauses runnerPauses
+
+	// drain is the drain mode, in which the drained runners don't request
+	// jobs anymore
+	drain drainMode
+
+	// drainSignal is used to start the drain mode of all the runners
+	drainSignal chan os.Signal
+
 	// abortBuilds is used to abort running builds
 	abortBuilds chan os.Signal
 
@@ -159,6 +170,7 @@ func (mr *RunCommand) Start(_ service.Service) error {
 	mr.abortBuilds = make(chan os.Signal)
 	mr.runInterruptSignal = make(chan os.Signal, 1)
 	mr.reloadSignal = make(chan os.Signal, 1)
+	mr.drainSignal = make(chan os.Signal, 1)
 	mr.configReloaded = make(chan int, 1)
 	mr.runFinished = make(chan bool, 1)
 	mr.stopSignals = make(chan os.Signal)
@@ -320,6 +332,8 @@ func (mr *RunCommand) resetOneRunnerToken() bool {
 }
 
 func (mr *RunCommand) reloadConfig() error {
+	previousConfig := mr.getConfig()
+
 	err := mr.loadConfig()
 	if err != nil {
 		return err
@@ -343,6 +357,10 @@ func (mr *RunCommand) reloadConfig() error {
 	mr.log().Println("Configuration loaded")
 	mr.log().Debugln(helpers.ToYAML(config))
 
+	if previousConfig != nil {
+		mr.applyConfigChanges(previousConfig, config)
+	}
+
 	// initialize sentry
 	slh := sentry.LogHook{}
 	if config.SentryDSN != nil {
@@ -361,6 +379,83 @@ func (mr *RunCommand) reloadConfig() error {
 	return nil
 }
 
+// applyConfigChanges logs the changes of the reloaded configuration and
+// passes the changes of the runners to the executor providers keeping state
+// for them
+func (mr *RunCommand) applyConfigChanges(previousConfig, config *common.Config) {
+	diff, err := diffConfigs(previousConfig, config)
+	if err != nil {
+		mr.log().WithError(err).Warningln("Failed to compare the reloaded configuration")
+		return
+	}
+
+	if len(diff.Global) > 0 {
+		keys := make([]string, 0, len(diff.Global))
+		for _, setting := range diff.Global {
+			keys = append(keys, setting.Key)
+		}
+
+		mr.log().WithField("settings", keys).Infoln("Global configuration changed")
+	}
+
+	for _, change := range diff.Runners {
+		mr.log().WithFields(logrus.Fields{
+			"runner":      change.Token,
+			"runner_name": change.Name,
+			"change":      change.Kind,
+			"settings":    change.settingKeys(),
+		}).Infoln("Runner configuration changed")
+
+		mr.onConfigurationAccessCollector(func(m *configAccessCollector) {
+			m.runnerChanges.WithLabelValues(string(change.Kind)).Inc()
+		})
+
+		mr.reconfigureExecutorProviders(change)
+	}
+}
+
+// reconfigureExecutorProviders passes the change of the runner to the
+// providers of its previous and current executors
+func (mr *RunCommand) reconfigureExecutorProviders(change runnerChange) {
+	var previousExecutor, currentExecutor string
+	if change.Previous != nil {
+		previousExecutor = change.Previous.Executor
+	}
+	if change.Runner != nil {
+		currentExecutor = change.Runner.Executor
+	}
+
+	if previousExecutor == currentExecutor {
+		mr.reconfigureExecutorProvider(currentExecutor, change.Token, common.RunnerConfigChange{
+			Previous: change.Previous,
+			Current:  change.Runner,
+			Settings: change.settingKeys(),
+		})
+		return
+	}
+
+	mr.reconfigureExecutorProvider(previousExecutor, change.Token, common.RunnerConfigChange{Previous: change.Previous})
+	mr.reconfigureExecutorProvider(currentExecutor, change.Token, common.RunnerConfigChange{Current: change.Runner})
+}
+
+func (mr *RunCommand) reconfigureExecutorProvider(executor, runner string, change common.RunnerConfigChange) {
+	provider, ok := common.GetExecutorProvider(executor).(common.ReconfigurableExecutorProvider)
+	if !ok {
+		return
+	}
+
+	err := provider.Reconfigure(change)
+	if err != nil {
+		mr.log().
+			WithFields(logrus.Fields{
+				"runner":   runner,
+				"executor": executor,
+			}).
+			WithError(err).
+			Warningln("Failed to apply the runner configuration change to the executor provider")
+	}
+}
+
 func (mr *RunCommand) updateLoggingConfiguration() error {
 	reloadNeeded := false
 
@@ -410,6 +505,9 @@ func (mr *RunCommand) run() {
 
 	signal.Notify(mr.stopSignals, syscall.SIGQUIT, syscall.SIGTERM, os.Interrupt)
 	signal.Notify(mr.reloadSignal, syscall.SIGHUP)
+	if len(drainSignals) > 0 {
+		signal.Notify(mr.drainSignal, drainSignals...)
+	}
 
 	startWorker := make(chan int)
 	stopWorker := make(chan bool)
@@ -513,6 +611,8 @@ func (mr *RunCommand) setupMetricsAndDebugServer() {
 	mr.serveMetrics(mux)
 	mr.serveDebugData(mux)
 	mr.servePprof(mux)
+	mr.serveHealth(mux)
+	mr.serveAdminAPI(mux, listenAddress)
 
 	mr.log().
 		WithField("address", listenAddress).
@@ -658,6 +758,16 @@ func (mr *RunCommand) feedRunners(runners chan *common.RunnerConfig) {
 }
 
 func (mr *RunCommand) feedRunner(runner *common.RunnerConfig, runners chan *common.RunnerConfig) {
+	if reason := mr.jobRequestsStopped(runner); reason != "" {
+		mr.log().
+			WithFields(logrus.Fields{
+				"runner": runner.ShortDescription(),
+				"reason": reason,
+			}).
+			Debugln("Runner doesn't request jobs, not feeding it to channel")
+		return
+	}
+
 	if !mr.healthHelper.isHealthy(runner) {
 		mr.runnerWorkersFeedFailures.WithLabelValues(runner.ShortDescription(), runner.Name, runner.GetSystemID()).Inc()
 		return
@@ -778,7 +888,7 @@ func (mr *RunCommand) processBuildOnRunner(
 	provider common.ExecutorProvider,
 	executorData common.ExecutorData,
 ) error {
-	buildSession, sessionInfo, err := mr.createSession(provider)
+	buildSession, sessionInfo, err := mr.createSession(runner, provider)
 	if err != nil {
 		return err
 	}
@@ -802,6 +912,7 @@ func (mr *RunCommand) processBuildOnRunner(
 
 	// Add build to list of builds to assign numbers
 	mr.buildsHelper.addBuild(build)
+	mr.buildsHelper.setBuildTrace(build, trace)
 
 	fields := logrus.Fields{
 		"job":      build.ID,
@@ -836,10 +947,13 @@ func (mr *RunCommand) traceOutcome(trace common.JobTrace, err error) {
 // createSession checks if debug server is supported by configured executor and if the
 // debug server was configured. If both requirements are met, then it creates a debug session
 // that will be assigned to newly created job.
-func (mr *RunCommand) createSession(provider common.ExecutorProvider) (*session.Session, *common.SessionInfo, error) {
+func (mr *RunCommand) createSession(
+	runner *common.RunnerConfig,
+	provider common.ExecutorProvider,
+) (*session.Session, *common.SessionInfo, error) {
 	var features common.FeaturesInfo
 
-	if err := provider.GetFeatures(&features); err != nil {
+	if err := common.GetRunnerFeatures(provider, runner, &features); err != nil {
 		return nil, nil, err
 	}
 
@@ -867,6 +981,11 @@ func (mr *RunCommand) requestJob(
 	runner *common.RunnerConfig,
 	sessionInfo *common.SessionInfo,
 ) (common.JobTrace, *common.JobResponse, error) {
+	if reason := mr.jobRequestsStopped(runner); reason != "" {
+		mr.log().WithField("runner", runner.ShortDescription()).Debugln("Not requesting job: runner", reason)
+		return nil, nil, nil
+	}
+
 	mr.log().WithField("runner", runner.ShortDescription()).Debug("Acquiring request slot")
 	if !mr.buildsHelper.acquireRequest(runner) {
 		mr.log().WithField("runner", runner.ShortDescription()).
@@ -1019,6 +1138,9 @@ func (mr *RunCommand) updateConfig() os.Signal {
 			mr.log().Errorln("Failed to load config", err)
 		}
 
+	case <-mr.drainSignal:
+		mr.handleDrainSignal()
+
 	case signaled := <-mr.runInterruptSignal:
 		return signaled
 	}
@@ -1027,13 +1149,13 @@ func (mr *RunCommand) updateConfig() os.Signal {
 }
 
 func (mr *RunCommand) checkConfig() (err error) {
-	info, err := os.Stat(mr.ConfigFile)
+	modTime, err := mr.configModTime()
 	if err != nil {
 		return err
 	}
 
 	config := mr.getConfig()
-	if !config.ModTime.Before(info.ModTime()) {
+	if !config.ModTime.Before(modTime) {
 		return nil
 	}
 
@@ -1041,7 +1163,7 @@ func (mr *RunCommand) checkConfig() (err error) {
 	if err != nil {
 		mr.log().Errorln("Failed to load config", err)
 		// don't reload the same file
-		config.ModTime = info.ModTime()
+		config.ModTime = modTime
 		return
 	}
 	return nil
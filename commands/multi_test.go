@@ -4,6 +4,7 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -13,6 +14,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -1120,3 +1122,106 @@ func TestRunCommand_configReloadingRegression(t *testing.T) {
 
 	assert.Equal(t, int64(3), configReloadedCount.Load())
 }
+
+type reconfigurableExecutorProvider struct {
+	*common.MockExecutorProvider
+
+	changes []common.RunnerConfigChange
+	err     error
+}
+
+func (p *reconfigurableExecutorProvider) Reconfigure(change common.RunnerConfigChange) error {
+	p.changes = append(p.changes, change)
+	return p.err
+}
+
+func newReconfigurableExecutorProvider(t *testing.T, executor string, err error) *reconfigurableExecutorProvider {
+	mockProvider := common.NewMockExecutorProvider(t)
+	mockProvider.On("CanCreate").Return(true).Once()
+	mockProvider.On("GetDefaultShell").Return("bash").Once()
+	mockProvider.On("GetFeatures", mock.Anything).Return(nil).Once()
+
+	p := &reconfigurableExecutorProvider{MockExecutorProvider: mockProvider, err: err}
+	common.RegisterExecutorProvider(executor, p)
+
+	return p
+}
+
+func TestRunCommand_applyConfigChanges(t *testing.T) {
+	hook, cleanup := test.NewHook()
+	defer cleanup()
+
+	providerA := newReconfigurableExecutorProvider(t, "reconfigurable-a", nil)
+	providerB := newReconfigurableExecutorProvider(t, "reconfigurable-b", errors.New("not applied"))
+	hook.Reset()
+
+	newRunner := func(name, executor string) *common.RunnerConfig {
+		return &common.RunnerConfig{
+			Name:              name,
+			RunnerCredentials: common.RunnerCredentials{URL: "https://gitlab.example.com", Token: "glrt-" + name + "-token"},
+			RunnerSettings:    common.RunnerSettings{Executor: executor},
+		}
+	}
+
+	unchanged := newRunner("unchanged", "reconfigurable-a")
+	modified := newRunner("modified", "reconfigurable-a")
+	removed := newRunner("removed", "reconfigurable-a")
+	moved := newRunner("moved", "reconfigurable-a")
+
+	newModified := newRunner("modified", "reconfigurable-a")
+	newModified.Limit = 2
+	newMoved := newRunner("moved", "reconfigurable-b")
+	added := newRunner("added", "reconfigurable-b")
+
+	previousConfig := &common.Config{Concurrent: 1, Runners: []*common.RunnerConfig{unchanged, modified, removed, moved}}
+	config := &common.Config{
+		Concurrent: 2,
+		Runners:    []*common.RunnerConfig{newRunner("unchanged", "reconfigurable-a"), newModified, newMoved, added},
+	}
+
+	c := &RunCommand{
+		configOptionsWithListenAddress: configOptionsWithListenAddress{
+			configOptions: configOptions{configAccessCollector: newConfigAccessCollector()},
+		},
+	}
+	c.applyConfigChanges(previousConfig, config)
+
+	assert.Equal(t, []common.RunnerConfigChange{
+		{Previous: removed},
+		{Previous: modified, Current: newModified, Settings: []string{"limit"}},
+		{Previous: moved},
+	}, providerA.changes)
+	assert.Equal(t, []common.RunnerConfigChange{
+		{Current: newMoved},
+		{Current: added},
+	}, providerB.changes)
+
+	collector := c.configAccessCollector
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.runnerChanges.WithLabelValues("added")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.runnerChanges.WithLabelValues("removed")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(collector.runnerChanges.WithLabelValues("modified")))
+
+	var messages []string
+	for _, entry := range hook.AllEntries() {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{
+		"Global configuration changed",
+		"Runner configuration changed",
+		"Runner configuration changed",
+		"Runner configuration changed",
+		"Failed to apply the runner configuration change to the executor provider",
+		"Runner configuration changed",
+		"Failed to apply the runner configuration change to the executor provider",
+	}, messages)
+
+	globalEntry := hook.AllEntries()[0]
+	assert.Equal(t, []string{"concurrent"}, globalEntry.Data["settings"])
+	assert.NotContains(t, globalEntry.Data, "old")
+	assert.NotContains(t, globalEntry.Data, "new")
+
+	modifiedEntry := hook.AllEntries()[2]
+	assert.Equal(t, runnerModified, modifiedEntry.Data["change"])
+	assert.Equal(t, "modified", modifiedEntry.Data["runner_name"])
+	assert.Equal(t, []string{"limit"}, modifiedEntry.Data["settings"])
+}
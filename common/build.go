@@ -45,6 +45,11 @@ const (
 	gitFetchFlagsNone    = "none"
 )
 
+const (
+	GitCloneFilterBlobNone = "blob:none"
+	GitCloneFilterTreeZero = "tree:0"
+)
+
 type SubmoduleStrategy int
 
 const (
@@ -80,6 +85,7 @@ const (
 	BuildStageArchiveOnFailureCache    BuildStage = "archive_cache_on_failure"
 	BuildStageUploadOnSuccessArtifacts BuildStage = "upload_artifacts_on_success"
 	BuildStageUploadOnFailureArtifacts BuildStage = "upload_artifacts_on_failure"
+	BuildStageCreateGitBundle          BuildStage = "create_git_bundle"
 	// We only renamed the variable name here as a first step to renaming the stage.
 	// a separate issue will address changing the variable value, since it affects the
 	// contract with the custom executor: https://gitlab.com/gitlab-org/gitlab-runner/-/issues/28152.
@@ -98,6 +104,7 @@ var staticBuildStages = []BuildStage{
 	BuildStageArchiveOnFailureCache,
 	BuildStageUploadOnSuccessArtifacts,
 	BuildStageUploadOnFailureArtifacts,
+	BuildStageCreateGitBundle,
 	BuildStageCleanup,
 }
 
@@ -136,6 +143,15 @@ type Build struct {
 
 	SafeDirectoryCheckout bool `json:"-" yaml:"-"`
 
+	// GitMirrorDir is the path of the project's reference repository,
+	// as seen by the job. Empty when no reference repository is used.
+	GitMirrorDir string `json:"-" yaml:"-"`
+
+	// LocalSources is the local working tree which the executors supporting
+	// it copy to the project directory, for builds run by exec. The sources
+	// aren't fetched with the Git strategy set to none.
+	LocalSources string `json:"-" yaml:"-"`
+
 	// Unique ID for all running builds on this runner
 	RunnerID int `json:"runner_id"`
 
@@ -150,6 +166,9 @@ type Build struct {
 	currentState          BuildRuntimeState
 	executorStageResolver func() ExecutorStage
 
+	// abortFunc aborts the running build with an error, see Abort()
+	abortFunc context.CancelCauseFunc
+
 	secretsResolver func(l logger, registry SecretResolverRegistry, featureFlagOn func(string) bool) (SecretsResolver, error)
 
 	Session *session.Session
@@ -396,6 +415,7 @@ func getPredefinedEnv(buildStage BuildStage) bool {
 		BuildStageArchiveOnFailureCache:    true,
 		BuildStageUploadOnFailureArtifacts: true,
 		BuildStageUploadOnSuccessArtifacts: true,
+		BuildStageCreateGitBundle:          true,
 		BuildStageCleanup:                  true,
 	}
 
@@ -418,6 +438,7 @@ func GetStageDescription(stage BuildStage) string {
 		BuildStageArchiveOnFailureCache:    "Saving cache for failed job",
 		BuildStageUploadOnFailureArtifacts: "Uploading artifacts for failed job",
 		BuildStageUploadOnSuccessArtifacts: "Uploading artifacts for successful job",
+		BuildStageCreateGitBundle:          "Creating git bundle",
 		BuildStageCleanup:                  "Cleaning up project directory and file based variables",
 	}
 
@@ -515,6 +536,10 @@ func (b *Build) executeScript(ctx context.Context, executor Executor) error {
 
 	artifactUploadErr := b.executeUploadArtifacts(ctx, err, executor)
 
+	if b.pickPriorityError(err, archiveCacheErr, artifactUploadErr) == nil {
+		b.executeCreateGitBundle(ctx, executor)
+	}
+
 	// track job end and execute referees
 	endTime := time.Now()
 	b.executeUploadReferees(ctx, startTime, endTime)
@@ -878,6 +903,30 @@ func (b *Build) setTraceStatus(trace JobTrace, err error) {
 	trace.Fail(err, JobFailureData{Reason: RunnerSystemFailure})
 }
 
+func (b *Build) setAbortFunc(abortFunc context.CancelCauseFunc) {
+	b.statusLock.Lock()
+	defer b.statusLock.Unlock()
+
+	b.abortFunc = abortFunc
+}
+
+// Abort aborts the running build, which fails with the error and its failure
+// reason. It returns false when the build isn't running or was already
+// aborted.
+func (b *Build) Abort(err *BuildError) bool {
+	b.statusLock.Lock()
+	abortFunc := b.abortFunc
+	b.abortFunc = nil
+	b.statusLock.Unlock()
+
+	if abortFunc == nil {
+		return false
+	}
+
+	abortFunc(err)
+	return true
+}
+
 func (b *Build) setExecutorStageResolver(resolver func() ExecutorStage) {
 	b.statusLock.Lock()
 	defer b.statusLock.Unlock()
@@ -923,9 +972,24 @@ func (b *Build) Run(globalConfig *Config, trace JobTrace) (err error) {
 
 	b.expandContainerOptions()
 
-	ctx, cancel := context.WithTimeout(context.Background(), b.GetBuildTimeout())
+	abortCtx, abort := context.WithCancelCause(context.Background())
+	defer abort(nil)
+
+	b.setAbortFunc(abort)
+	defer b.setAbortFunc(nil)
+
+	ctx, cancel := context.WithTimeout(abortCtx, b.GetBuildTimeout())
 	defer cancel()
 
+	// The build aborted by Abort() fails with the error it was aborted with,
+	// whatever the stage it was interrupted in returned
+	defer func() {
+		var abortErr *BuildError
+		if errors.As(context.Cause(abortCtx), &abortErr) {
+			err = abortErr
+		}
+	}()
+
 	b.configureTrace(trace, cancel)
 
 	options := b.createExecutorPrepareOptions(ctx, globalConfig, trace)
@@ -934,7 +998,7 @@ func (b *Build) Run(globalConfig *Config, trace JobTrace) (err error) {
 		return errors.New("executor not found")
 	}
 
-	err = provider.GetFeatures(&b.ExecutorFeatures)
+	err = GetRunnerFeatures(provider, b.Runner, &b.ExecutorFeatures)
 	if err != nil {
 		return fmt.Errorf("retrieving executor features: %w", err)
 	}
@@ -1478,6 +1542,27 @@ func (b *Build) GetGitSubmoduleUpdateFlags() []string {
 	return strings.Fields(flags)
 }
 
+// GetGitSparseCheckoutPaths returns the directories checked out in cone mode
+// https://git-scm.com/docs/git-sparse-checkout#_internalscone_pattern_set
+func (b *Build) GetGitSparseCheckoutPaths() []string {
+	paths := b.GetAllVariables().Value("GIT_SPARSE_CHECKOUT_PATHS")
+	return strings.Fields(paths)
+}
+
+// GetGitCloneFilter returns the object filter used for partial clones
+// https://git-scm.com/docs/partial-clone
+func (b *Build) GetGitCloneFilter() (string, error) {
+	filter := strings.TrimSpace(b.GetAllVariables().Value("GIT_CLONE_FILTER"))
+
+	switch filter {
+	case "", GitCloneFilterBlobNone, GitCloneFilterTreeZero:
+		return filter, nil
+	default:
+		return "", fmt.Errorf("invalid GIT_CLONE_FILTER %q, supported values: %s, %s",
+			filter, GitCloneFilterBlobNone, GitCloneFilterTreeZero)
+	}
+}
+
 func (b *Build) IsDebugTraceEnabled() bool {
 	trace, err := strconv.ParseBool(b.GetAllVariables().Value("CI_DEBUG_TRACE"))
 	if err != nil {
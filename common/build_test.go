@@ -664,6 +664,35 @@ func TestRestoreCacheRunFailure(t *testing.T) {
 	assert.EqualError(t, err, "build fail")
 }
 
+func TestBuildAbort(t *testing.T) {
+	executor, provider := setupMockExecutorAndProvider()
+	defer executor.AssertExpectations(t)
+	defer provider.AssertExpectations(t)
+
+	abortErr := &BuildError{Inner: errors.New("drain deadline exceeded"), FailureReason: RunnerDrainTimeout}
+
+	var build *Build
+	executor.On("Prepare", mock.Anything).Return(nil).Once()
+	executor.On("Shell").Return(&ShellScriptInfo{Shell: "script-shell"})
+	executor.On("Run", matchBuildStage("step_script")).
+		Run(func(args mock.Arguments) {
+			assert.True(t, build.Abort(abortErr))
+			<-args.Get(0).(ExecutorCommand).Context.Done()
+		}).
+		Return(context.Canceled).
+		Once()
+	executor.On("Run", mock.Anything).Return(nil)
+	executor.On("Finish", mock.Anything).Once()
+	executor.On("Cleanup").Once()
+
+	build = registerExecutorWithSuccessfulBuild(t, provider, new(RunnerConfig))
+	assert.False(t, build.Abort(abortErr), "the build isn't running yet")
+
+	err := build.Run(&Config{}, &Trace{Writer: os.Stdout})
+	assert.Equal(t, abortErr, err)
+	assert.False(t, build.Abort(abortErr), "the build isn't running anymore")
+}
+
 func TestRunWrongAttempts(t *testing.T) {
 	executor, provider := setupMockExecutorAndProvider()
 	defer provider.AssertExpectations(t)
@@ -1920,6 +1949,84 @@ func TestGitSubmoduleUpdateFlags(t *testing.T) {
 	}
 }
 
+func TestGitSparseCheckoutPaths(t *testing.T) {
+	tests := map[string]struct {
+		value          string
+		expectedResult []string
+	}{
+		"empty paths": {
+			value:          "",
+			expectedResult: []string{},
+		},
+		"multiple paths": {
+			value:          "services/api  docs\tlibs/shared",
+			expectedResult: []string{"services/api", "docs", "libs/shared"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			build := &Build{
+				Runner: &RunnerConfig{},
+				JobResponse: JobResponse{
+					Variables: JobVariables{
+						{Key: "GIT_SPARSE_CHECKOUT_PATHS", Value: test.value},
+					},
+				},
+			}
+
+			assert.Equal(t, test.expectedResult, build.GetGitSparseCheckoutPaths())
+		})
+	}
+}
+
+func TestGitCloneFilter(t *testing.T) {
+	tests := map[string]struct {
+		value          string
+		expectedResult string
+		expectedError  bool
+	}{
+		"no filter": {
+			value:          "",
+			expectedResult: "",
+		},
+		"blob:none": {
+			value:          "blob:none",
+			expectedResult: GitCloneFilterBlobNone,
+		},
+		"tree:0": {
+			value:          " tree:0 ",
+			expectedResult: GitCloneFilterTreeZero,
+		},
+		"unsupported filter": {
+			value:         "sparse:oid=main:.gitfilterspec",
+			expectedError: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			build := &Build{
+				Runner: &RunnerConfig{},
+				JobResponse: JobResponse{
+					Variables: JobVariables{
+						{Key: "GIT_CLONE_FILTER", Value: test.value},
+					},
+				},
+			}
+
+			result, err := build.GetGitCloneFilter()
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedResult, result)
+		})
+	}
+}
+
 func TestDefaultVariables(t *testing.T) {
 	tests := map[string]struct {
 		jobVariables  JobVariables
@@ -170,62 +170,107 @@ func (p *StringOrArray) UnmarshalTOML(data interface{}) error {
 
 type DockerConfig struct {
 	docker.Credentials
-	Hostname                   string             `toml:"hostname,omitempty" json:"hostname" long:"hostname" env:"DOCKER_HOSTNAME" description:"Custom container hostname"`
-	Image                      string             `toml:"image" json:"image" long:"image" env:"DOCKER_IMAGE" description:"Docker image to be used"`
-	Runtime                    string             `toml:"runtime,omitempty" json:"runtime" long:"runtime" env:"DOCKER_RUNTIME" description:"Docker runtime to be used"`
-	Memory                     string             `toml:"memory,omitempty" json:"memory" long:"memory" env:"DOCKER_MEMORY" description:"Memory limit (format: <number>[<unit>]). Unit can be one of b, k, m, or g. Minimum is 4M."`
-	MemorySwap                 string             `toml:"memory_swap,omitempty" json:"memory_swap" long:"memory-swap" env:"DOCKER_MEMORY_SWAP" description:"Total memory limit (memory + swap, format: <number>[<unit>]). Unit can be one of b, k, m, or g."`
-	MemoryReservation          string             `toml:"memory_reservation,omitempty" json:"memory_reservation" long:"memory-reservation" env:"DOCKER_MEMORY_RESERVATION" description:"Memory soft limit (format: <number>[<unit>]). Unit can be one of b, k, m, or g."`
-	CPUSetCPUs                 string             `toml:"cpuset_cpus,omitempty" json:"cpuset_cpus" long:"cpuset-cpus" env:"DOCKER_CPUSET_CPUS" description:"String value containing the cgroups CpusetCpus to use"`
-	CPUS                       string             `toml:"cpus,omitempty" json:"cpus" long:"cpus" env:"DOCKER_CPUS" description:"Number of CPUs"`
-	CPUShares                  int64              `toml:"cpu_shares,omitzero" json:"cpu_shares" long:"cpu-shares" env:"DOCKER_CPU_SHARES" description:"Number of CPU shares"`
-	DNS                        []string           `toml:"dns,omitempty" json:"dns,omitempty" long:"dns" env:"DOCKER_DNS" description:"A list of DNS servers for the container to use"`
-	DNSSearch                  []string           `toml:"dns_search,omitempty" json:"dns_search,omitempty" long:"dns-search" env:"DOCKER_DNS_SEARCH" description:"A list of DNS search domains"`
-	Privileged                 bool               `toml:"privileged,omitzero" json:"privileged" long:"privileged" env:"DOCKER_PRIVILEGED" description:"Give extended privileges to container"`
-	ServicesPrivileged         *bool              `toml:"services_privileged,omitempty" json:"services_privileged,omitempty" long:"services_privileged" env:"DOCKER_SERVICES_PRIVILEGED" description:"When set this will give or remove extended privileges to container services"`
-	DisableEntrypointOverwrite bool               `toml:"disable_entrypoint_overwrite,omitzero" json:"disable_entrypoint_overwrite" long:"disable-entrypoint-overwrite" env:"DOCKER_DISABLE_ENTRYPOINT_OVERWRITE" description:"Disable the possibility for a container to overwrite the default image entrypoint"`
-	User                       string             `toml:"user,omitempty" json:"user" long:"user" env:"DOCKER_USER" description:"Run all commands in the container as the specified user."`
-	GroupAdd                   []string           `toml:"group_add" json:"group_add,omitempty" long:"group-add" env:"DOCKER_GROUP_ADD" description:"Add additional groups to join"`
-	UsernsMode                 string             `toml:"userns_mode,omitempty" json:"userns_mode" long:"userns" env:"DOCKER_USERNS_MODE" description:"User namespace to use"`
-	CapAdd                     []string           `toml:"cap_add" json:"cap_add,omitempty" long:"cap-add" env:"DOCKER_CAP_ADD" description:"Add Linux capabilities"`
-	CapDrop                    []string           `toml:"cap_drop" json:"cap_drop,omitempty" long:"cap-drop" env:"DOCKER_CAP_DROP" description:"Drop Linux capabilities"`
-	OomKillDisable             bool               `toml:"oom_kill_disable,omitzero" json:"oom_kill_disable" long:"oom-kill-disable" env:"DOCKER_OOM_KILL_DISABLE" description:"Do not kill processes in a container if an out-of-memory (OOM) error occurs"`
-	OomScoreAdjust             int                `toml:"oom_score_adjust,omitzero" json:"oom_score_adjust" long:"oom-score-adjust" env:"DOCKER_OOM_SCORE_ADJUST" description:"Adjust OOM score"`
-	SecurityOpt                []string           `toml:"security_opt" json:"security_opt,omitempty" long:"security-opt" env:"DOCKER_SECURITY_OPT" description:"Security Options"`
-	ServicesSecurityOpt        []string           `toml:"services_security_opt" json:"services_security_opt,omitempty" long:"services-security-opt" env:"DOCKER_SERVICES_SECURITY_OPT" description:"Security Options for container services"`
-	Devices                    []string           `toml:"devices" json:"devices,omitempty" long:"devices" env:"DOCKER_DEVICES" description:"Add a host device to the container"`
-	DeviceCgroupRules          []string           `toml:"device_cgroup_rules,omitempty" json:"device_cgroup_rules,omitempty" long:"device-cgroup-rules" env:"DOCKER_DEVICE_CGROUP_RULES" description:"Add a device cgroup rule to the container"`
-	Gpus                       string             `toml:"gpus,omitempty" json:"gpus" long:"gpus" env:"DOCKER_GPUS" description:"Request GPUs to be used by Docker"`
-	DisableCache               bool               `toml:"disable_cache,omitzero" json:"disable_cache" long:"disable-cache" env:"DOCKER_DISABLE_CACHE" description:"Disable all container caching"`
-	Volumes                    []string           `toml:"volumes,omitempty" json:"volumes,omitempty" long:"volumes" env:"DOCKER_VOLUMES" description:"Bind-mount a volume and create it if it doesn't exist prior to mounting. Can be specified multiple times once per mountpoint, e.g. --docker-volumes 'test0:/test0' --docker-volumes 'test1:/test1'"`
-	VolumeDriver               string             `toml:"volume_driver,omitempty" json:"volume_driver" long:"volume-driver" env:"DOCKER_VOLUME_DRIVER" description:"Volume driver to be used"`
-	VolumeDriverOps            map[string]string  `toml:"volume_driver_ops,omitempty" json:"volume_driver_ops,omitempty" long:"volume-driver-ops" env:"DOCKER_VOLUME_DRIVER_OPS" description:"A toml table/json object with the format key=values. Volume driver ops to be specified"`
-	CacheDir                   string             `toml:"cache_dir,omitempty" json:"cache_dir" long:"cache-dir" env:"DOCKER_CACHE_DIR" description:"Directory where to store caches"`
-	ExtraHosts                 []string           `toml:"extra_hosts,omitempty" json:"extra_hosts,omitempty" long:"extra-hosts" env:"DOCKER_EXTRA_HOSTS" description:"Add a custom host-to-IP mapping"`
-	VolumesFrom                []string           `toml:"volumes_from,omitempty" json:"volumes_from,omitempty" long:"volumes-from" env:"DOCKER_VOLUMES_FROM" description:"A list of volumes to inherit from another container"`
-	NetworkMode                string             `toml:"network_mode,omitempty" json:"network_mode" long:"network-mode" env:"DOCKER_NETWORK_MODE" description:"Add container to a custom network"`
-	IpcMode                    string             `toml:"ipcmode,omitempty" json:"ipcmode" long:"ipcmode" env:"DOCKER_IPC_MODE" description:"Select IPC mode for container"`
-	MacAddress                 string             `toml:"mac_address,omitempty" json:"mac_address" long:"mac-address" env:"DOCKER_MAC_ADDRESS" description:"Container MAC address (e.g., 92:d0:c6:0a:29:33)"`
-	Links                      []string           `toml:"links,omitempty" json:"links,omitempty" long:"links" env:"DOCKER_LINKS" description:"Add link to another container"`
-	Services                   []Service          `toml:"services,omitempty" json:"services,omitempty" description:"Add service that is started with container"`
-	WaitForServicesTimeout     int                `toml:"wait_for_services_timeout,omitzero" json:"wait_for_services_timeout" long:"wait-for-services-timeout" env:"DOCKER_WAIT_FOR_SERVICES_TIMEOUT" description:"How long to wait for service startup"`
-	AllowedImages              []string           `toml:"allowed_images,omitempty" json:"allowed_images,omitempty" long:"allowed-images" env:"DOCKER_ALLOWED_IMAGES" description:"Image allowlist"`
-	AllowedPrivilegedImages    []string           `toml:"allowed_privileged_images,omitempty" json:"allowed_privileged_images,omitempty" long:"allowed-privileged-images" env:"DOCKER_ALLOWED_PRIVILEGED_IMAGES" description:"Privileged image allowlist"`
-	AllowedPrivilegedServices  []string           `toml:"allowed_privileged_services,omitempty" json:"allowed_privileged_services,omitempty" long:"allowed-privileged-services" env:"DOCKER_ALLOWED_PRIVILEGED_SERVICES" description:"Privileged Service allowlist"`
-	AllowedPullPolicies        []DockerPullPolicy `toml:"allowed_pull_policies,omitempty" json:"allowed_pull_policies,omitempty" long:"allowed-pull-policies" env:"DOCKER_ALLOWED_PULL_POLICIES" description:"Pull policy allowlist"`
-	AllowedServices            []string           `toml:"allowed_services,omitempty" json:"allowed_services,omitempty" long:"allowed-services" env:"DOCKER_ALLOWED_SERVICES" description:"Service allowlist"`
-	PullPolicy                 StringOrArray      `toml:"pull_policy,omitempty" json:"pull_policy,omitempty" long:"pull-policy" env:"DOCKER_PULL_POLICY" description:"Image pull policy: never, if-not-present, always"`
-	Isolation                  string             `toml:"isolation,omitempty" json:"isolation" long:"isolation" env:"DOCKER_ISOLATION" description:"Container isolation technology. Windows only"`
-	ShmSize                    int64              `toml:"shm_size,omitempty" json:"shm_size" long:"shm-size" env:"DOCKER_SHM_SIZE" description:"Shared memory size for docker images (in bytes)"`
-	Tmpfs                      map[string]string  `toml:"tmpfs,omitempty" json:"tmpfs,omitempty" long:"tmpfs" env:"DOCKER_TMPFS" description:"A toml table/json object with the format key=values. When set this will mount the specified path in the key as a tmpfs volume in the main container, using the options specified as key. For the supported options, see the documentation for the unix 'mount' command"`
-	ServicesTmpfs              map[string]string  `toml:"services_tmpfs,omitempty" json:"services_tmpfs,omitempty" long:"services-tmpfs" env:"DOCKER_SERVICES_TMPFS" description:"A toml table/json object with the format key=values. When set this will mount the specified path in the key as a tmpfs volume in all the service containers, using the options specified as key. For the supported options, see the documentation for the unix 'mount' command"`
-	SysCtls                    DockerSysCtls      `toml:"sysctls,omitempty" json:"sysctls,omitempty" long:"sysctls" env:"DOCKER_SYSCTLS" description:"Sysctl options, a toml table/json object of key=value. Value is expected to be a string."`
-	HelperImage                string             `toml:"helper_image,omitempty" json:"helper_image" long:"helper-image" env:"DOCKER_HELPER_IMAGE" description:"[ADVANCED] Override the default helper image used to clone repos and upload artifacts"`
-	HelperImageFlavor          string             `toml:"helper_image_flavor,omitempty" json:"helper_image_flavor" long:"helper-image-flavor" env:"DOCKER_HELPER_IMAGE_FLAVOR" description:"Set helper image flavor (alpine, ubuntu), defaults to alpine"`
-	ContainerLabels            map[string]string  `toml:"container_labels,omitempty" json:"container_labels,omitempty" long:"container-labels" description:"A toml table/json object of key-value. Value is expected to be a string. When set, this will create containers with the given container labels. Environment variables will be substituted for values here."`
-	EnableIPv6                 bool               `toml:"enable_ipv6,omitempty" json:"enable_ipv6" long:"enable-ipv6" description:"Enable IPv6 for automatically created networks. This is only takes affect when the feature flag FF_NETWORK_PER_BUILD is enabled."`
-	Ulimit                     map[string]string  `toml:"ulimit,omitempty" json:"ulimit,omitempty" long:"ulimit" env:"DOCKER_ULIMIT" description:"Ulimit options for container"`
-	NetworkMTU                 int                `toml:"network_mtu,omitempty" json:"network_mtu" long:"network-mtu" description:"MTU of the Docker network created for the job IFF the FF_NETWORK_PER_BUILD feature-flag was specified."`
+	Hostname                   string                    `toml:"hostname,omitempty" json:"hostname" long:"hostname" env:"DOCKER_HOSTNAME" description:"Custom container hostname"`
+	Image                      string                    `toml:"image" json:"image" long:"image" env:"DOCKER_IMAGE" description:"Docker image to be used"`
+	Runtime                    string                    `toml:"runtime,omitempty" json:"runtime" long:"runtime" env:"DOCKER_RUNTIME" description:"Docker runtime to be used"`
+	Memory                     string                    `toml:"memory,omitempty" json:"memory" long:"memory" env:"DOCKER_MEMORY" description:"Memory limit (format: <number>[<unit>]). Unit can be one of b, k, m, or g. Minimum is 4M."`
+	MemoryOverwriteMaxAllowed  string                    `toml:"memory_overwrite_max_allowed,omitempty" json:"memory_overwrite_max_allowed" long:"memory-overwrite-max-allowed" env:"DOCKER_MEMORY_OVERWRITE_MAX_ALLOWED" description:"If set, the max memory limit a job can request with the DOCKER_MEMORY variable"`
+	MemorySwap                 string                    `toml:"memory_swap,omitempty" json:"memory_swap" long:"memory-swap" env:"DOCKER_MEMORY_SWAP" description:"Total memory limit (memory + swap, format: <number>[<unit>]). Unit can be one of b, k, m, or g."`
+	MemoryReservation          string                    `toml:"memory_reservation,omitempty" json:"memory_reservation" long:"memory-reservation" env:"DOCKER_MEMORY_RESERVATION" description:"Memory soft limit (format: <number>[<unit>]). Unit can be one of b, k, m, or g."`
+	CPUSetCPUs                 string                    `toml:"cpuset_cpus,omitempty" json:"cpuset_cpus" long:"cpuset-cpus" env:"DOCKER_CPUSET_CPUS" description:"String value containing the cgroups CpusetCpus to use"`
+	CPUS                       string                    `toml:"cpus,omitempty" json:"cpus" long:"cpus" env:"DOCKER_CPUS" description:"Number of CPUs"`
+	CPUSOverwriteMaxAllowed    string                    `toml:"cpus_overwrite_max_allowed,omitempty" json:"cpus_overwrite_max_allowed" long:"cpus-overwrite-max-allowed" env:"DOCKER_CPUS_OVERWRITE_MAX_ALLOWED" description:"If set, the max number of CPUs a job can request with the DOCKER_CPUS variable"`
+	CPUShares                  int64                     `toml:"cpu_shares,omitzero" json:"cpu_shares" long:"cpu-shares" env:"DOCKER_CPU_SHARES" description:"Number of CPU shares"`
+	DNS                        []string                  `toml:"dns,omitempty" json:"dns,omitempty" long:"dns" env:"DOCKER_DNS" description:"A list of DNS servers for the container to use"`
+	DNSSearch                  []string                  `toml:"dns_search,omitempty" json:"dns_search,omitempty" long:"dns-search" env:"DOCKER_DNS_SEARCH" description:"A list of DNS search domains"`
+	Privileged                 bool                      `toml:"privileged,omitzero" json:"privileged" long:"privileged" env:"DOCKER_PRIVILEGED" description:"Give extended privileges to container"`
+	ServicesPrivileged         *bool                     `toml:"services_privileged,omitempty" json:"services_privileged,omitempty" long:"services_privileged" env:"DOCKER_SERVICES_PRIVILEGED" description:"When set this will give or remove extended privileges to container services"`
+	DisableEntrypointOverwrite bool                      `toml:"disable_entrypoint_overwrite,omitzero" json:"disable_entrypoint_overwrite" long:"disable-entrypoint-overwrite" env:"DOCKER_DISABLE_ENTRYPOINT_OVERWRITE" description:"Disable the possibility for a container to overwrite the default image entrypoint"`
+	User                       string                    `toml:"user,omitempty" json:"user" long:"user" env:"DOCKER_USER" description:"Run all commands in the container as the specified user."`
+	GroupAdd                   []string                  `toml:"group_add" json:"group_add,omitempty" long:"group-add" env:"DOCKER_GROUP_ADD" description:"Add additional groups to join"`
+	UsernsMode                 string                    `toml:"userns_mode,omitempty" json:"userns_mode" long:"userns" env:"DOCKER_USERNS_MODE" description:"User namespace to use"`
+	CapAdd                     []string                  `toml:"cap_add" json:"cap_add,omitempty" long:"cap-add" env:"DOCKER_CAP_ADD" description:"Add Linux capabilities"`
+	CapDrop                    []string                  `toml:"cap_drop" json:"cap_drop,omitempty" long:"cap-drop" env:"DOCKER_CAP_DROP" description:"Drop Linux capabilities"`
+	OomKillDisable             bool                      `toml:"oom_kill_disable,omitzero" json:"oom_kill_disable" long:"oom-kill-disable" env:"DOCKER_OOM_KILL_DISABLE" description:"Do not kill processes in a container if an out-of-memory (OOM) error occurs"`
+	OomScoreAdjust             int                       `toml:"oom_score_adjust,omitzero" json:"oom_score_adjust" long:"oom-score-adjust" env:"DOCKER_OOM_SCORE_ADJUST" description:"Adjust OOM score"`
+	SecurityOpt                []string                  `toml:"security_opt" json:"security_opt,omitempty" long:"security-opt" env:"DOCKER_SECURITY_OPT" description:"Security Options"`
+	ServicesSecurityOpt        []string                  `toml:"services_security_opt" json:"services_security_opt,omitempty" long:"services-security-opt" env:"DOCKER_SERVICES_SECURITY_OPT" description:"Security Options for container services"`
+	Devices                    []string                  `toml:"devices" json:"devices,omitempty" long:"devices" env:"DOCKER_DEVICES" description:"Add a host device to the container"`
+	DeviceCgroupRules          []string                  `toml:"device_cgroup_rules,omitempty" json:"device_cgroup_rules,omitempty" long:"device-cgroup-rules" env:"DOCKER_DEVICE_CGROUP_RULES" description:"Add a device cgroup rule to the container"`
+	Gpus                       string                    `toml:"gpus,omitempty" json:"gpus" long:"gpus" env:"DOCKER_GPUS" description:"Request GPUs to be used by Docker"`
+	DisableCache               bool                      `toml:"disable_cache,omitzero" json:"disable_cache" long:"disable-cache" env:"DOCKER_DISABLE_CACHE" description:"Disable all container caching"`
+	Volumes                    []string                  `toml:"volumes,omitempty" json:"volumes,omitempty" long:"volumes" env:"DOCKER_VOLUMES" description:"Bind-mount a volume and create it if it doesn't exist prior to mounting. Can be specified multiple times once per mountpoint, e.g. --docker-volumes 'test0:/test0' --docker-volumes 'test1:/test1'"`
+	VolumeDriver               string                    `toml:"volume_driver,omitempty" json:"volume_driver" long:"volume-driver" env:"DOCKER_VOLUME_DRIVER" description:"Volume driver to be used"`
+	VolumeDriverOps            map[string]string         `toml:"volume_driver_ops,omitempty" json:"volume_driver_ops,omitempty" long:"volume-driver-ops" env:"DOCKER_VOLUME_DRIVER_OPS" description:"A toml table/json object with the format key=values. Volume driver ops to be specified"`
+	CacheDir                   string                    `toml:"cache_dir,omitempty" json:"cache_dir" long:"cache-dir" env:"DOCKER_CACHE_DIR" description:"Directory where to store caches"`
+	ExtraHosts                 []string                  `toml:"extra_hosts,omitempty" json:"extra_hosts,omitempty" long:"extra-hosts" env:"DOCKER_EXTRA_HOSTS" description:"Add a custom host-to-IP mapping"`
+	VolumesFrom                []string                  `toml:"volumes_from,omitempty" json:"volumes_from,omitempty" long:"volumes-from" env:"DOCKER_VOLUMES_FROM" description:"A list of volumes to inherit from another container"`
+	NetworkMode                string                    `toml:"network_mode,omitempty" json:"network_mode" long:"network-mode" env:"DOCKER_NETWORK_MODE" description:"Add container to a custom network"`
+	IpcMode                    string                    `toml:"ipcmode,omitempty" json:"ipcmode" long:"ipcmode" env:"DOCKER_IPC_MODE" description:"Select IPC mode for container"`
+	MacAddress                 string                    `toml:"mac_address,omitempty" json:"mac_address" long:"mac-address" env:"DOCKER_MAC_ADDRESS" description:"Container MAC address (e.g., 92:d0:c6:0a:29:33)"`
+	Links                      []string                  `toml:"links,omitempty" json:"links,omitempty" long:"links" env:"DOCKER_LINKS" description:"Add link to another container"`
+	Services                   []Service                 `toml:"services,omitempty" json:"services,omitempty" description:"Add service that is started with container"`
+	WaitForServicesTimeout     int                       `toml:"wait_for_services_timeout,omitzero" json:"wait_for_services_timeout" long:"wait-for-services-timeout" env:"DOCKER_WAIT_FOR_SERVICES_TIMEOUT" description:"How long to wait for service startup"`
+	AllowedImages              []string                  `toml:"allowed_images,omitempty" json:"allowed_images,omitempty" long:"allowed-images" env:"DOCKER_ALLOWED_IMAGES" description:"Image allowlist"`
+	AllowedPrivilegedImages    []string                  `toml:"allowed_privileged_images,omitempty" json:"allowed_privileged_images,omitempty" long:"allowed-privileged-images" env:"DOCKER_ALLOWED_PRIVILEGED_IMAGES" description:"Privileged image allowlist"`
+	AllowedPrivilegedServices  []string                  `toml:"allowed_privileged_services,omitempty" json:"allowed_privileged_services,omitempty" long:"allowed-privileged-services" env:"DOCKER_ALLOWED_PRIVILEGED_SERVICES" description:"Privileged Service allowlist"`
+	AllowedPullPolicies        []DockerPullPolicy        `toml:"allowed_pull_policies,omitempty" json:"allowed_pull_policies,omitempty" long:"allowed-pull-policies" env:"DOCKER_ALLOWED_PULL_POLICIES" description:"Pull policy allowlist"`
+	AllowedServices            []string                  `toml:"allowed_services,omitempty" json:"allowed_services,omitempty" long:"allowed-services" env:"DOCKER_ALLOWED_SERVICES" description:"Service allowlist"`
+	PullPolicy                 StringOrArray             `toml:"pull_policy,omitempty" json:"pull_policy,omitempty" long:"pull-policy" env:"DOCKER_PULL_POLICY" description:"Image pull policy: never, if-not-present, always"`
+	Isolation                  string                    `toml:"isolation,omitempty" json:"isolation" long:"isolation" env:"DOCKER_ISOLATION" description:"Container isolation technology. Windows only"`
+	ShmSize                    int64                     `toml:"shm_size,omitempty" json:"shm_size" long:"shm-size" env:"DOCKER_SHM_SIZE" description:"Shared memory size for docker images (in bytes)"`
+	ShmSizeOverwriteMaxAllowed string                    `toml:"shm_size_overwrite_max_allowed,omitempty" json:"shm_size_overwrite_max_allowed" long:"shm-size-overwrite-max-allowed" env:"DOCKER_SHM_SIZE_OVERWRITE_MAX_ALLOWED" description:"If set, the max shared memory size a job can request with the DOCKER_SHM_SIZE variable"`
+	Tmpfs                      map[string]string         `toml:"tmpfs,omitempty" json:"tmpfs,omitempty" long:"tmpfs" env:"DOCKER_TMPFS" description:"A toml table/json object with the format key=values. When set this will mount the specified path in the key as a tmpfs volume in the main container, using the options specified as key. For the supported options, see the documentation for the unix 'mount' command"`
+	ServicesTmpfs              map[string]string         `toml:"services_tmpfs,omitempty" json:"services_tmpfs,omitempty" long:"services-tmpfs" env:"DOCKER_SERVICES_TMPFS" description:"A toml table/json object with the format key=values. When set this will mount the specified path in the key as a tmpfs volume in all the service containers, using the options specified as key. For the supported options, see the documentation for the unix 'mount' command"`
+	SysCtls                    DockerSysCtls             `toml:"sysctls,omitempty" json:"sysctls,omitempty" long:"sysctls" env:"DOCKER_SYSCTLS" description:"Sysctl options, a toml table/json object of key=value. Value is expected to be a string."`
+	HelperImage                string                    `toml:"helper_image,omitempty" json:"helper_image" long:"helper-image" env:"DOCKER_HELPER_IMAGE" description:"[ADVANCED] Override the default helper image used to clone repos and upload artifacts"`
+	HelperImageFlavor          string                    `toml:"helper_image_flavor,omitempty" json:"helper_image_flavor" long:"helper-image-flavor" env:"DOCKER_HELPER_IMAGE_FLAVOR" description:"Set helper image flavor (alpine, ubuntu), defaults to alpine"`
+	ContainerLabels            map[string]string         `toml:"container_labels,omitempty" json:"container_labels,omitempty" long:"container-labels" description:"A toml table/json object of key-value. Value is expected to be a string. When set, this will create containers with the given container labels. Environment variables will be substituted for values here."`
+	EnableIPv6                 bool                      `toml:"enable_ipv6,omitempty" json:"enable_ipv6" long:"enable-ipv6" description:"Enable IPv6 for automatically created networks. This is only takes affect when the feature flag FF_NETWORK_PER_BUILD is enabled."`
+	Ulimit                     map[string]string         `toml:"ulimit,omitempty" json:"ulimit,omitempty" long:"ulimit" env:"DOCKER_ULIMIT" description:"Ulimit options for container"`
+	NetworkMTU                 int                       `toml:"network_mtu,omitempty" json:"network_mtu" long:"network-mtu" description:"MTU of the Docker network created for the job IFF the FF_NETWORK_PER_BUILD feature-flag was specified."`
+	DaemonMode                 string                    `toml:"daemon_mode,omitempty" json:"daemon_mode" long:"daemon-mode" env:"DOCKER_DAEMON_MODE" description:"Compatibility mode of the daemon serving the Docker API: auto (default), docker, rootless or podman"`
+	BuildVolumes               *DockerBuildVolumesConfig `toml:"build_volumes,omitempty" json:"build_volumes,omitempty" namespace:"build_volumes" description:"Reuse policy of the volumes holding the build directory when the fetch Git strategy is used"`
+	BuildKit                   *BuildKitConfig           `toml:"buildkit,omitempty" json:"buildkit,omitempty" namespace:"buildkit" description:"Rootless BuildKit sidecar that jobs can request to build images without a privileged Docker-in-Docker service"`
+	EgressPolicy               *DockerEgressPolicy       `toml:"egress_policy,omitempty" json:"egress_policy,omitempty" namespace:"egress_policy" description:"Restrict the destinations the build and service containers can connect to"`
+	FailureCheckpoint          *DockerFailureCheckpoint  `toml:"failure_checkpoint,omitempty" json:"failure_checkpoint,omitempty" namespace:"failure_checkpoint" description:"Preserve the build container of a failed job for debugging"`
+}
+
+// DockerFailureCheckpoint preserves the build container of a job whose script
+// failed: its file changes are uploaded as a job artifact, or it's kept
+// running for the web terminal to attach to.
+type DockerFailureCheckpoint struct {
+	Mode        string         `toml:"mode,omitempty" json:"mode" long:"mode" env:"DOCKER_FAILURE_CHECKPOINT_MODE" description:"What to do with the build container of a failed job: artifact (upload its file changes) or keep (keep it for the web terminal). Disabled when empty"`
+	MaxSize     string         `toml:"max_size,omitempty" json:"max_size,omitempty" long:"max-size" env:"DOCKER_FAILURE_CHECKPOINT_MAX_SIZE" description:"Maximum size of the file changes uploaded in the artifact mode, defaults to 100MB"`
+	ExpireIn    string         `toml:"expire_in,omitempty" json:"expire_in,omitempty" long:"expire-in" env:"DOCKER_FAILURE_CHECKPOINT_EXPIRE_IN" description:"How long the artifact holding the file changes is kept, like 1 week. Uses the instance default when empty"`
+	KeepTimeout *time.Duration `toml:"keep_timeout,omitzero" json:"keep_timeout,omitempty" long:"keep-timeout" env:"DOCKER_FAILURE_CHECKPOINT_KEEP_TIMEOUT" description:"How long the build container is kept in the keep mode, waiting for the web terminal to attach, defaults to 10m"`
+}
+
+// DockerEgressPolicy restricts the outgoing connections of the jobs. The
+// per-build network is created as internal, and the containers reach the
+// allowed destinations through a proxy started by the runner.
+type DockerEgressPolicy struct {
+	Enabled              bool     `toml:"enabled,omitempty" json:"enabled" long:"enabled" env:"DOCKER_EGRESS_POLICY_ENABLED" description:"Enforce the egress policy"`
+	Allow                []string `toml:"allow,omitempty" json:"allow,omitempty" long:"allow" env:"DOCKER_EGRESS_POLICY_ALLOW" description:"CIDRs, IP addresses and hostnames the jobs can connect to. Hostnames can start with a *. wildcard"`
+	AllowMetadataService bool     `toml:"allow_metadata_service,omitempty" json:"allow_metadata_service" long:"allow-metadata-service" env:"DOCKER_EGRESS_POLICY_ALLOW_METADATA_SERVICE" description:"Don't block the cloud instance metadata endpoints, like 169.254.169.254"`
+}
+
+// BuildKitConfig configures the rootless BuildKit daemon started next to the
+// jobs requesting it with the BUILDKIT_SIDECAR variable
+type BuildKitConfig struct {
+	Enabled        bool     `toml:"enabled,omitempty" json:"enabled" long:"enabled" env:"@ENABLED" description:"Allow jobs to request a BuildKit sidecar"`
+	Image          string   `toml:"image,omitempty" json:"image" long:"image" env:"@IMAGE" description:"Image of the BuildKit sidecar, defaults to moby/buildkit:rootless"`
+	AllowedImages  []string `toml:"allowed_images,omitempty" json:"allowed_images,omitempty" long:"allowed-images" env:"@ALLOWED_IMAGES" description:"BuildKit images that jobs can select with the BUILDKIT_SIDECAR_IMAGE variable"`
+	CacheClaimName string   `toml:"cache_claim_name,omitempty" json:"cache_claim_name,omitempty" long:"cache-claim-name" env:"@CACHE_CLAIM_NAME" description:"Kubernetes only: persistent volume claim keeping the layer cache between jobs, instead of an emptyDir"`
+	CacheHostPath  string   `toml:"cache_host_path,omitempty" json:"cache_host_path,omitempty" long:"cache-host-path" env:"@CACHE_HOST_PATH" description:"Kubernetes only: node directory keeping the layer cache between jobs, instead of an emptyDir"`
+}
+
+// DockerBuildVolumesConfig configures the pool of volumes holding the build
+// directory of the jobs using the fetch Git strategy. The volumes are reused
+// across jobs of the same project and removed once they are too old or too big.
+type DockerBuildVolumesConfig struct {
+	MaxAge  *time.Duration `toml:"max_age,omitzero" json:"max_age,omitempty" long:"max-age" env:"DOCKER_BUILD_VOLUMES_MAX_AGE" description:"Remove a build volume once it has been created for longer than this duration"`
+	MaxSize string         `toml:"max_size,omitempty" json:"max_size,omitempty" long:"max-size" env:"DOCKER_BUILD_VOLUMES_MAX_SIZE" description:"Remove a build volume once it uses more disk space than this size (for example 10GB)"`
 }
 
 type InstanceConfig struct {
@@ -342,6 +387,10 @@ type DockerMachine struct {
 	OffPeakIdleTime  int      `toml:"OffPeakIdleTime,omitzero" description:"Minimum time after machine can be destroyed when the scheduler is in the OffPeak mode. DEPRECATED"` // DEPRECATED
 
 	AutoscalingConfigs []*DockerMachineAutoscaling `toml:"autoscaling" json:",omitempty" description:"Ordered list of configurations for autoscaling periods (last match wins)"`
+
+	ScalingStrategy       string `toml:"ScalingStrategy,omitempty" long:"scaling-strategy" env:"MACHINE_SCALING_STRATEGY" description:"How the number of idle machines is decided: classic (default) or predictive"`
+	PredictiveLeadTime    int    `toml:"PredictiveLeadTime,omitzero" long:"predictive-lead-time" env:"MACHINE_PREDICTIVE_LEAD_TIME" description:"With the predictive scaling strategy, time in seconds ahead of which idle machines are created for the expected jobs (defaults to 600)"`
+	PredictiveHistoryDays int    `toml:"PredictiveHistoryDays,omitzero" long:"predictive-history-days" env:"MACHINE_PREDICTIVE_HISTORY_DAYS" description:"With the predictive scaling strategy, number of past days of job arrivals used for the prediction (defaults to 7)"`
 }
 
 type DockerMachineAutoscaling struct {
@@ -387,6 +436,10 @@ type CustomConfig struct {
 	CleanupArgs        []string `toml:"cleanup_args,omitempty" json:"cleanup_args,omitempty" long:"cleanup-args" description:"Arguments for the cleanup executable"`
 	CleanupExecTimeout *int     `toml:"cleanup_exec_timeout,omitempty" json:"cleanup_exec_timeout,omitempty" long:"cleanup-exec-timeout" env:"CUSTOM_CLEANUP_EXEC_TIMEOUT" description:"Timeout for the cleanup executable (in seconds)"`
 
+	DriverExec      string   `toml:"driver_exec,omitempty" json:"driver_exec" long:"driver-exec" env:"CUSTOM_DRIVER_EXEC" description:"Long-lived driver executable, started once per job, that prepares the executor, runs the job stages and cleans up using JSON-RPC"`
+	DriverArgs      []string `toml:"driver_args,omitempty" json:"driver_args,omitempty" long:"driver-args" description:"Arguments for the driver executable"`
+	DriverTransport string   `toml:"driver_transport,omitempty" json:"driver_transport" long:"driver-transport" env:"CUSTOM_DRIVER_TRANSPORT" description:"Transport used to communicate with the driver: stdio (default) or unix"`
+
 	GracefulKillTimeout *int `toml:"graceful_kill_timeout,omitempty" json:"graceful_kill_timeout,omitempty" long:"graceful-kill-timeout" env:"CUSTOM_GRACEFUL_KILL_TIMEOUT" description:"Graceful timeout for scripts execution after SIGTERM is sent to the process (in seconds). This limits the time given for scripts to perform the cleanup before exiting"`
 	ForceKillTimeout    *int `toml:"force_kill_timeout,omitempty" json:"force_kill_timeout,omitempty" long:"force-kill-timeout" env:"CUSTOM_FORCE_KILL_TIMEOUT" description:"Force timeout for scripts execution (in seconds). Counted from the force kill call; if process will be not terminated, Runner will abandon process termination and log an error"`
 }
@@ -556,6 +609,7 @@ type KubernetesConfig struct {
 	CleanupResourcesTimeout                           *time.Duration                     `toml:"cleanup_resources_timeout,omitzero" json:"cleanup_resources_timeout,omitempty" long:"cleanup_resources_timeout" env:"KUBERNETES_CLEANUP_RESOURCES_TIMEOUT" description:"The total amount of time for Kubernetes resources to be cleaned up after the job completes. Supported syntax: '1h30m', '300s', '10m'. Default is 5 minutes ('5m')."`
 	PollInterval                                      int                                `toml:"poll_interval,omitzero" json:"poll_interval" long:"poll-interval" env:"KUBERNETES_POLL_INTERVAL" description:"How frequently, in seconds, the runner will poll the Kubernetes pod it has just created to check its status"`
 	PollTimeout                                       int                                `toml:"poll_timeout,omitzero" json:"poll_timeout" long:"poll-timeout" env:"KUBERNETES_POLL_TIMEOUT" description:"The total amount of time, in seconds, that needs to pass before the runner will timeout attempting to connect to the pod it has just created (useful for queueing more builds that the cluster can handle at a time)"`
+	UnschedulablePodGracePeriod                       int                                `toml:"unschedulable_pod_grace_period,omitzero" json:"unschedulable_pod_grace_period" long:"unschedulable-pod-grace-period" env:"KUBERNETES_UNSCHEDULABLE_POD_GRACE_PERIOD" description:"The amount of time, in seconds, an unschedulable pod is waited for when no cluster autoscaler reported scaling up for it. Defaults to poll_timeout"`
 	ResourceAvailabilityCheckMaxAttempts              int                                `toml:"resource_availability_check_max_attempts,omitzero" json:"resource_availability_check_max_attempts" long:"resource-availability-check-max-attempts" env:"KUBERNETES_RESOURCE_AVAILABILITY_CHECK_MAX_ATTEMPTS" default:"5" description:"The maximum number of attempts to check if a resource (service account and/or pull secret) set is available before giving up. There is 5 seconds interval between each attempt"`
 	PodLabels                                         map[string]string                  `toml:"pod_labels,omitempty" json:"pod_labels,omitempty" long:"pod-labels" description:"A toml table/json object of key-value. Value is expected to be a string. When set, this will create pods with the given pod labels. Environment variables will be substituted for values here."`
 	PodLabelsOverwriteAllowed                         string                             `toml:"pod_labels_overwrite_allowed" json:"pod_labels_overwrite_allowed" long:"pod_labels_overwrite_allowed" env:"KUBERNETES_POD_LABELS_OVERWRITE_ALLOWED" description:"Regex to validate 'KUBERNETES_POD_LABELS_*' values"`
@@ -578,6 +632,9 @@ type KubernetesConfig struct {
 	DNSConfig                                         KubernetesDNSConfig                `toml:"dns_config" json:"dns_config" description:"Pod DNS config"`
 	ContainerLifecycle                                KubernetesContainerLifecyle        `toml:"container_lifecycle,omitempty" json:"container_lifecycle,omitempty" description:"Actions that the management system should take in response to container lifecycle events"`
 	PriorityClassName                                 string                             `toml:"priority_class_name,omitempty" json:"priority_class_name" long:"priority_class_name" env:"KUBERNETES_PRIORITY_CLASS_NAME" description:"If set, the Kubernetes Priority Class to be set to the Pods"`
+	NativeSidecarServices                             bool                               `toml:"native_sidecar_services,omitempty" json:"native_sidecar_services" long:"native-sidecar-services" env:"KUBERNETES_NATIVE_SIDECAR_SERVICES" description:"Run services as native sidecar init containers with restartPolicy Always, started in order before the build container. Requires Kubernetes 1.29 or later"`
+	BuildKit                                          *BuildKitConfig                    `toml:"buildkit,omitempty" json:"buildkit,omitempty" namespace:"buildkit" description:"Rootless BuildKit sidecar that jobs can request to build images without a privileged Docker-in-Docker service"`
+	Clusters                                          []KubernetesCluster                `toml:"clusters,omitempty" json:"clusters,omitempty" description:"A list of Kubernetes clusters to run jobs on. A healthy cluster is selected for each job by priority and weight, falling back to the next one on API or capacity failures. Overrides host and the credentials settings"`
 	PodSpec                                           []KubernetesPodSpec                `toml:"pod_spec" json:",omitempty"`
 }
 
@@ -863,6 +920,39 @@ type KubernetesPodAntiAffinity struct {
 	PreferredDuringSchedulingIgnoredDuringExecution []WeightedPodAffinityTerm `toml:"preferred_during_scheduling_ignored_during_execution,omitempty" json:"preferred_during_scheduling_ignored_during_execution"`
 }
 
+type KubernetesCluster struct {
+	Name        string `toml:"name" json:"name" long:"name" description:"Name of the cluster, used in logs and metrics"`
+	Host        string `toml:"host" json:"host" long:"host" description:"Kubernetes master host URL of the cluster"`
+	CertFile    string `toml:"cert_file,omitempty" json:"cert_file" long:"cert-file" description:"Optional Kubernetes master auth certificate"`
+	KeyFile     string `toml:"key_file,omitempty" json:"key_file" long:"key-file" description:"Optional Kubernetes master auth private key"`
+	CAFile      string `toml:"ca_file,omitempty" json:"ca_file" long:"ca-file" description:"Optional Kubernetes master auth ca certificate"`
+	BearerToken string `toml:"bearer_token,omitempty" json:"bearer_token" long:"bearer_token" description:"Optional Kubernetes service account token used to start build pods"`
+	Priority    int    `toml:"priority,omitzero" json:"priority" long:"priority" description:"Clusters with a lower priority are selected first"`
+	Weight      int    `toml:"weight,omitzero" json:"weight" long:"weight" description:"Relative share of jobs sent to the cluster among the clusters of the same priority (default 1)"`
+}
+
+// GetWeight returns the configured weight of the cluster, defaulting to 1.
+func (c KubernetesCluster) GetWeight() int {
+	if c.Weight <= 0 {
+		return 1
+	}
+
+	return c.Weight
+}
+
+// KubernetesConfig returns a copy of the Kubernetes configuration using the
+// host and credentials of the cluster.
+func (c KubernetesCluster) KubernetesConfig(config *KubernetesConfig) *KubernetesConfig {
+	clusterConfig := *config
+	clusterConfig.Host = c.Host
+	clusterConfig.CertFile = c.CertFile
+	clusterConfig.KeyFile = c.KeyFile
+	clusterConfig.CAFile = c.CAFile
+	clusterConfig.BearerToken = c.BearerToken
+
+	return &clusterConfig
+}
+
 type KubernetesHostAliases struct {
 	IP        string   `toml:"ip" json:"ip" long:"ip" description:"The IP address you want to attach hosts to"`
 	Hostnames []string `toml:"hostnames" json:"hostnames,omitempty" long:"hostnames" description:"A list of hostnames that will be attached to the IP"`
@@ -1058,6 +1148,8 @@ type CacheConfig struct {
 	Shared                 bool   `toml:"Shared,omitempty" long:"shared" env:"CACHE_SHARED" description:"Enable cache sharing between runners."`
 	MaxUploadedArchiveSize int64  `toml:"MaxUploadedArchiveSize,omitempty" long:"max_uploaded_archive_size" env:"CACHE_MAXIMUM_UPLOADED_ARCHIVE_SIZE" description:"Limit the size of the cache archive being uploaded to cloud storage, in bytes."`
 
+	GitBundleInterval *time.Duration `toml:"GitBundleInterval,omitzero" json:",omitempty" long:"git-bundle-interval" env:"CACHE_GIT_BUNDLE_INTERVAL" description:"Interval at which a git bundle of each project is stored in the cache and used to seed new clones. Supports syntax like '12h', '30m' etc. Disabled when not set."`
+
 	S3    *CacheS3Config    `toml:"s3,omitempty" json:"s3,omitempty" namespace:"s3"`
 	GCS   *CacheGCSConfig   `toml:"gcs,omitempty" json:"gcs,omitempty" namespace:"gcs"`
 	Azure *CacheAzureConfig `toml:"azure,omitempty" json:"azure,omitempty" namespace:"azure"`
@@ -1069,6 +1161,8 @@ type RunnerSettings struct {
 	CacheDir  string `toml:"cache_dir,omitempty" json:"cache_dir" long:"cache-dir" env:"RUNNER_CACHE_DIR" description:"Directory where build cache is stored"`
 	CloneURL  string `toml:"clone_url,omitempty" json:"clone_url" long:"clone-url" env:"CLONE_URL" description:"Overwrite the default URL used to clone or fetch the git ref"`
 
+	GitMirrorDir string `toml:"git_mirror_dir,omitempty" json:"git_mirror_dir" long:"git-mirror-dir" env:"RUNNER_GIT_MIRROR_DIR" description:"Directory on the runner host where bare reference repositories shared by the jobs of a project are stored (shell and docker executors only)"`
+
 	Environment []string `toml:"environment,omitempty" json:"environment,omitempty" long:"env" env:"RUNNER_ENV" description:"Custom environment variables injected to build environment"`
 
 	// DEPRECATED
@@ -1138,6 +1232,7 @@ type SessionServer struct {
 
 type Config struct {
 	ListenAddress string        `toml:"listen_address,omitempty" json:"listen_address"`
+	AdminToken    string        `toml:"admin_token,omitempty" json:"admin_token" description:"Token authenticating the requests to the admin API of the metrics server, the admin API is disabled when it's not set"`
 	SessionServer SessionServer `toml:"session_server,omitempty" json:"session_server"`
 
 	Concurrent    int             `toml:"concurrent" json:"concurrent"`
@@ -1153,6 +1248,9 @@ type Config struct {
 	ShutdownTimeout int `toml:"shutdown_timeout,omitempty" json:"shutdown_timeout" description:"Number of seconds until the forceful shutdown operation times out and exits the process"`
 
 	configSaver ConfigSaver
+
+	// resolvedSettings are the settings whose references were resolved
+	resolvedSettings resolvedSettings
 }
 
 //go:generate mockery --name=ConfigSaver --inpackage
@@ -1216,6 +1314,16 @@ func (c *CacheConfig) GetShared() bool {
 	return c.Shared
 }
 
+// GetGitBundleInterval returns the interval at which the git bundles are
+// refreshed, or 0 when git bundles are disabled
+func (c *CacheConfig) GetGitBundleInterval() time.Duration {
+	if c == nil || c.GitBundleInterval == nil || *c.GitBundleInterval < 0 {
+		return 0
+	}
+
+	return *c.GitBundleInterval
+}
+
 func (r *RunnerSettings) GetGracefulKillTimeout() time.Duration {
 	return getDuration(r.GracefulKillTimeout, process.GracefulTimeout)
 }
@@ -1325,6 +1433,82 @@ func (c *DockerConfig) GetMemoryReservation() int64 {
 	return c.getMemoryBytes(c.MemoryReservation, "memory_reservation")
 }
 
+// IsEnabled reports whether the build volumes pool is configured. The section
+// alone doesn't enable it, as it's always created when registering a runner.
+func (c *DockerBuildVolumesConfig) IsEnabled() bool {
+	return c != nil && (c.MaxAge != nil || c.MaxSize != "")
+}
+
+// GetMaxAge returns the age after which a build volume is removed, 0 meaning
+// that the volumes don't expire
+func (c *DockerBuildVolumesConfig) GetMaxAge() time.Duration {
+	if c == nil || c.MaxAge == nil || *c.MaxAge < 0 {
+		return 0
+	}
+
+	return *c.MaxAge
+}
+
+// GetMaxSize returns the size in bytes above which a build volume is removed,
+// 0 meaning that the size of the volumes isn't limited
+func (c *DockerBuildVolumesConfig) GetMaxSize() (int64, error) {
+	if c == nil || c.MaxSize == "" {
+		return 0, nil
+	}
+
+	size, err := units.FromHumanSize(c.MaxSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid build volumes max_size %q: %w", c.MaxSize, err)
+	}
+
+	return size, nil
+}
+
+// GetMode returns what to do with the build container of a failed job, an
+// empty mode meaning that the container isn't preserved
+func (c *DockerFailureCheckpoint) GetMode() string {
+	if c == nil {
+		return ""
+	}
+
+	return c.Mode
+}
+
+// GetMaxSize returns the maximum size in bytes of the file changes uploaded
+// in the artifact mode
+func (c *DockerFailureCheckpoint) GetMaxSize() (int64, error) {
+	if c == nil || c.MaxSize == "" {
+		return DefaultFailureCheckpointMaxSize, nil
+	}
+
+	size, err := units.FromHumanSize(c.MaxSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid failure checkpoint max_size %q: %w", c.MaxSize, err)
+	}
+
+	return size, nil
+}
+
+// GetKeepTimeout returns how long the build container is kept for the web
+// terminal in the keep mode
+func (c *DockerFailureCheckpoint) GetKeepTimeout() time.Duration {
+	if c == nil || c.KeepTimeout == nil || *c.KeepTimeout <= 0 {
+		return DefaultFailureCheckpointKeepTimeout
+	}
+
+	return *c.KeepTimeout
+}
+
+// IsEnabled reports whether the egress of the jobs is restricted
+func (c *DockerEgressPolicy) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// IsEnabled reports whether jobs can request a BuildKit sidecar
+func (c *BuildKitConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
 func (c *DockerConfig) GetOomKillDisable() *bool {
 	return &c.OomKillDisable
 }
@@ -1354,6 +1538,17 @@ func (c *KubernetesConfig) GetPollAttempts() int {
 	return c.PollTimeout / c.GetPollInterval()
 }
 
+// GetUnschedulablePodGracePeriod returns how long an unschedulable pod is
+// waited for when no cluster autoscaler reported scaling up for it, which is
+// the poll timeout unless configured otherwise
+func (c *KubernetesConfig) GetUnschedulablePodGracePeriod() time.Duration {
+	if c.UnschedulablePodGracePeriod <= 0 {
+		return time.Duration(c.GetPollAttempts()*c.GetPollInterval()) * time.Second
+	}
+
+	return time.Duration(c.UnschedulablePodGracePeriod) * time.Second
+}
+
 func (c *KubernetesConfig) GetCleanupResourcesTimeout() time.Duration {
 	if c.CleanupResourcesTimeout == nil || c.CleanupResourcesTimeout.Seconds() <= 0 {
 		return KubernetesCleanupResourcesTimeout
@@ -1717,6 +1912,26 @@ func (c *DockerMachine) GetIdleTime() int {
 	return c.IdleTime
 }
 
+// GetPredictiveLeadTime returns how far ahead the predictive scaling strategy
+// creates idle machines for the expected jobs
+func (c *DockerMachine) GetPredictiveLeadTime() time.Duration {
+	if c == nil || c.PredictiveLeadTime <= 0 {
+		return DefaultMachinePredictiveLeadTime
+	}
+
+	return time.Duration(c.PredictiveLeadTime) * time.Second
+}
+
+// GetPredictiveHistoryDays returns the number of past days of job arrivals
+// the predictive scaling strategy averages
+func (c *DockerMachine) GetPredictiveHistoryDays() int {
+	if c == nil || c.PredictiveHistoryDays <= 0 {
+		return DefaultMachinePredictiveHistoryDays
+	}
+
+	return c.PredictiveHistoryDays
+}
+
 // getActiveAutoscalingConfig returns the autoscaling config matching the current time.
 // It goes through the [[docker.machine.autoscaling]] entries and returns the last one to match.
 // Returns nil on no matching entries.
@@ -1949,7 +2164,49 @@ func (c *Config) LoadConfig(configFile string) error {
 		return err
 	}
 
-	for _, runner := range c.Runners {
+	if err := prepareRunners(c.Runners); err != nil {
+		return err
+	}
+
+	c.ModTime = info.ModTime()
+	c.Loaded = true
+
+	return nil
+}
+
+// LoadConfigFragment merges the fragment of configuration into the loaded
+// configuration: the global settings it defines override the loaded ones and
+// its runners are appended to the loaded runners
+func (c *Config) LoadConfigFragment(fragmentFile string) error {
+	info, err := os.Stat(fragmentFile)
+	if err != nil {
+		return err
+	}
+
+	runners := c.Runners
+	c.Runners = nil
+
+	_, err = toml.DecodeFile(fragmentFile, c)
+	fragmentRunners := c.Runners
+	c.Runners = append(runners, fragmentRunners...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fragmentFile, err)
+	}
+
+	if err := prepareRunners(fragmentRunners); err != nil {
+		return fmt.Errorf("%s: %w", fragmentFile, err)
+	}
+
+	if info.ModTime().After(c.ModTime) {
+		c.ModTime = info.ModTime()
+	}
+	c.Loaded = true
+
+	return nil
+}
+
+func prepareRunners(runners []*RunnerConfig) error {
+	for _, runner := range runners {
 		runner.rewriteGetSourcesHooks()
 
 		if runner.Machine == nil {
@@ -1963,9 +2220,6 @@ func (c *Config) LoadConfig(configFile string) error {
 		runner.Machine.logDeprecationWarning()
 	}
 
-	c.ModTime = info.ModTime()
-	c.Loaded = true
-
 	return nil
 }
 
@@ -1973,7 +2227,12 @@ func (c *Config) SaveConfig(configFile string) error {
 	var newConfig bytes.Buffer
 	newBuffer := bufio.NewWriter(&newConfig)
 
-	if err := toml.NewEncoder(newBuffer).Encode(c); err != nil {
+	config, err := c.WithReferences()
+	if err != nil {
+		return err
+	}
+
+	if err := toml.NewEncoder(newBuffer).Encode(config); err != nil {
 		logrus.Fatalf("Error encoding TOML: %s", err)
 		return err
 	}
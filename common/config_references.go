@@ -0,0 +1,339 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// configReferenceRegex matches the references of the string settings to the
+// value of a secret: ${env:NAME}, ${file:/path} and ${vault:path#field}. A
+// reference preceded by another $, like $${env:NAME}, is escaped.
+var configReferenceRegex = regexp.MustCompile(`\$?\$\{(env|file|vault):([^}]+)}`)
+
+// globalReferenceSettings and runnerReferenceSettings are the key paths of
+// the credential settings whose references are resolved, the global ones and
+// the ones of the runners. A * matches any key, like the index of a list.
+// The other settings, like the scripts and the environment of the jobs, are
+// kept as is, so that they can use the ${env:NAME} syntax of PowerShell.
+var (
+	globalReferenceSettings = []string{
+		"admin_token",
+		"sentry_dsn",
+	}
+
+	runnerReferenceSettings = []string{
+		"token",
+		"cache.s3.AccessKey",
+		"cache.s3.SecretKey",
+		"cache.gcs.AccessID",
+		"cache.gcs.PrivateKey",
+		"cache.azure.AccountName",
+		"cache.azure.AccountKey",
+		"kubernetes.bearer_token",
+		"kubernetes.clusters.*.bearer_token",
+		"machine.MachineOptions.*",
+	}
+)
+
+// ConfigReferenceResolver returns the value the reference of the kind (env,
+// file or vault) refers to
+type ConfigReferenceResolver func(kind, reference string) (string, error)
+
+// resolvedSetting is a string setting whose references were replaced by the
+// value they refer to
+type resolvedSetting struct {
+	raw      string
+	resolved string
+}
+
+// resolvedSettings are the settings whose references were resolved: the
+// global ones by their key path, and the ones of the runners by runner and by
+// their key path in the runner, so that removing or reordering runners
+// doesn't restore the references of a runner to another one
+type resolvedSettings struct {
+	global  map[string]resolvedSetting
+	runners map[*RunnerConfig]map[string]resolvedSetting
+}
+
+func (s resolvedSettings) empty() bool {
+	return len(s.global) == 0 && len(s.runners) == 0
+}
+
+// ResolveReferences replaces the references of the string settings by the
+// value they refer to. The settings keep their references when the
+// configuration is saved, unless their value changed since.
+func (c *Config) ResolveReferences(resolve ConfigReferenceResolver) error {
+	settings := resolvedSettings{
+		global:  make(map[string]resolvedSetting),
+		runners: make(map[*RunnerConfig]map[string]resolvedSetting),
+	}
+
+	err := resolveConfigStrings(reflect.ValueOf(c).Elem(), "", globalReferenceSettings, resolve, settings.global)
+	if err != nil {
+		return err
+	}
+
+	for i, runner := range c.Runners {
+		resolved := make(map[string]resolvedSetting)
+
+		err := resolveConfigStrings(
+			reflect.ValueOf(runner).Elem(),
+			"runners."+strconv.Itoa(i),
+			runnerReferenceSettings,
+			resolve,
+			resolved,
+		)
+		if err != nil {
+			return err
+		}
+
+		if len(resolved) > 0 {
+			settings.runners[runner] = resolved
+		}
+	}
+
+	c.resolvedSettings = settings
+
+	return nil
+}
+
+// resolveConfigStrings resolves the references of the settings of the value,
+// whose key path is the given one, that match one of the allowed key paths,
+// and records them in resolved by their key path in the value. The runners
+// are skipped, they're resolved on their own.
+func resolveConfigStrings(
+	v reflect.Value,
+	path string,
+	allowed []string,
+	resolve ConfigReferenceResolver,
+	resolved map[string]resolvedSetting,
+) error {
+	return walkConfigStrings(v, "", func(key, value string, set func(string)) error {
+		if isRunnerSettingPath(key) || !matchesConfigPath(allowed, key) || !configReferenceRegex.MatchString(value) {
+			return nil
+		}
+
+		var resolveErr error
+		newValue := configReferenceRegex.ReplaceAllStringFunc(value, func(reference string) string {
+			if strings.HasPrefix(reference, "$$") {
+				return reference[1:]
+			}
+
+			match := configReferenceRegex.FindStringSubmatch(reference)
+
+			referenced, err := resolve(match[1], match[2])
+			if err != nil && resolveErr == nil {
+				resolveErr = fmt.Errorf("resolving %s of %s: %w", reference, joinConfigPath(path, key), err)
+			}
+
+			return referenced
+		})
+		if resolveErr != nil {
+			return resolveErr
+		}
+
+		set(newValue)
+		resolved[key] = resolvedSetting{raw: value, resolved: newValue}
+
+		return nil
+	})
+}
+
+// WithReferences returns a copy of the configuration whose resolved settings
+// are set back to their references, or the configuration itself if none was
+// resolved
+func (c *Config) WithReferences() (*Config, error) {
+	if c.resolvedSettings.empty() {
+		return c, nil
+	}
+
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(c); err != nil {
+		return nil, err
+	}
+
+	config := new(Config)
+	if _, err := toml.Decode(buf.String(), config); err != nil {
+		return nil, err
+	}
+
+	err := restoreConfigReferences(reflect.ValueOf(config).Elem(), c.resolvedSettings.global)
+	if err != nil {
+		return nil, err
+	}
+
+	// the copy has the runners of the configuration, in the same order
+	for i, runner := range config.Runners {
+		err := restoreConfigReferences(reflect.ValueOf(runner).Elem(), c.resolvedSettings.runners[c.Runners[i]])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+// HasReference reports whether the global setting with the key path, or one
+// of the settings it holds, had a reference
+func (c *Config) HasReference(key string) bool {
+	return hasResolvedSetting(c.resolvedSettings.global, key)
+}
+
+// RunnerHasReference reports whether the setting of the runner with the key
+// path, or one of the settings it holds, had a reference
+func (c *Config) RunnerHasReference(runner *RunnerConfig, key string) bool {
+	return hasResolvedSetting(c.resolvedSettings.runners[runner], key)
+}
+
+func hasResolvedSetting(resolved map[string]resolvedSetting, key string) bool {
+	for path := range resolved {
+		if path == key || strings.HasPrefix(path, key+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// restoreConfigReferences sets the settings of the value which still have
+// their resolved value back to their references
+func restoreConfigReferences(v reflect.Value, resolved map[string]resolvedSetting) error {
+	if len(resolved) == 0 {
+		return nil
+	}
+
+	return walkConfigStrings(v, "", func(key, value string, set func(string)) error {
+		if setting, ok := resolved[key]; ok && !isRunnerSettingPath(key) && setting.resolved == value {
+			set(setting.raw)
+		}
+
+		return nil
+	})
+}
+
+// matchesConfigPath reports whether the key path matches one of the paths,
+// whose * segments match any key
+func matchesConfigPath(paths []string, key string) bool {
+	keySegments := strings.Split(key, ".")
+
+	for _, path := range paths {
+		segments := strings.Split(path, ".")
+		if len(segments) != len(keySegments) {
+			continue
+		}
+
+		matches := true
+		for i, segment := range segments {
+			if segment != "*" && segment != keySegments[i] {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isRunnerSettingPath(key string) bool {
+	return strings.HasPrefix(key, "runners.")
+}
+
+// walkConfigStrings calls fn for each string setting of the value, with its
+// TOML key path like runners.0.cache.s3.SecretKey
+func walkConfigStrings(v reflect.Value, path string, fn func(path, value string, set func(string)) error) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+
+		return walkConfigStrings(v.Elem(), path, fn)
+	case reflect.Struct:
+		return walkConfigStructStrings(v, path, fn)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkConfigStrings(v.Index(i), joinConfigPath(path, strconv.Itoa(i)), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		return walkConfigMapStrings(v, path, fn)
+	case reflect.String:
+		if v.CanSet() {
+			return fn(path, v.String(), v.SetString)
+		}
+	}
+
+	return nil
+}
+
+func walkConfigStructStrings(v reflect.Value, path string, fn func(path, value string, set func(string)) error) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() || field.Tag.Get("toml") == "-" {
+			continue
+		}
+
+		fieldPath := path
+		if !field.Anonymous || field.Tag.Get("toml") != "" {
+			fieldPath = joinConfigPath(path, tagName(field, "toml"))
+		}
+
+		if err := walkConfigStrings(v.Field(i), fieldPath, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func walkConfigMapStrings(v reflect.Value, path string, fn func(path, value string, set func(string)) error) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	for _, key := range keys {
+		keyPath := joinConfigPath(path, key.String())
+		value := v.MapIndex(key)
+
+		switch value.Kind() {
+		case reflect.String:
+			set := func(s string) {
+				v.SetMapIndex(key, reflect.ValueOf(s).Convert(value.Type()))
+			}
+			if err := fn(keyPath, value.String(), set); err != nil {
+				return err
+			}
+		case reflect.Ptr:
+			if err := walkConfigStrings(value, keyPath, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func joinConfigPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
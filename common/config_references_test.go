@@ -0,0 +1,233 @@
+//go:build !integration
+
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const configWithReferences = `
+concurrent = 1
+
+[[runners]]
+  name = "runner"
+  url = "https://gitlab.example.com"
+  token = "${env:RUNNER_TOKEN}"
+  executor = "docker"
+  environment = ["PLAIN=value", "SECRET=${file:/secret}", "VARIABLE=${CI_JOB_ID}"]
+  pre_get_sources_script = "Write-Output ${env:CI_PROJECT_DIR}"
+  pre_build_script = "echo ${env:CI_JOB_ID}"
+  post_build_script = "echo ${file:/secret}"
+  [runners.cache]
+    Type = "s3"
+    [runners.cache.s3]
+      AccessKey = "access-key"
+      SecretKey = "${vault:secret/data/runner#s3}"
+  [runners.docker]
+    image = "alpine"
+    [runners.docker.container_labels]
+      team = "team-${env:TEAM}"
+  [runners.machine]
+    MachineOptions = ["token=${env:MACHINE_TOKEN}", "literal=$${env:LITERAL}"]
+`
+
+func testConfigReferenceResolver(kind, reference string) (string, error) {
+	values := map[string]string{
+		"env:RUNNER_TOKEN":            "glrt-resolved",
+		"env:TEAM":                    "ci",
+		"env:MACHINE_TOKEN":           "machine-token",
+		"file:/secret":                "file-secret",
+		"vault:secret/data/runner#s3": "s3-secret",
+	}
+
+	value, ok := values[kind+":"+reference]
+	if !ok {
+		return "", errors.New("not found")
+	}
+
+	return value, nil
+}
+
+func TestConfigResolveReferences(t *testing.T) {
+	config := NewConfig()
+	_, err := toml.Decode(configWithReferences, config)
+	require.NoError(t, err)
+
+	require.NoError(t, config.ResolveReferences(testConfigReferenceResolver))
+
+	runner := config.Runners[0]
+	assert.Equal(t, "glrt-resolved", runner.Token)
+	assert.Equal(t, "s3-secret", runner.Cache.S3.SecretKey)
+	assert.Equal(t, "access-key", runner.Cache.S3.AccessKey)
+	assert.Equal(t, []string{"token=machine-token", "literal=${env:LITERAL}"}, runner.Machine.MachineOptions)
+
+	// only the credential settings are resolved, the PowerShell scripts and
+	// the other settings keep their ${env:NAME} variables
+	assert.Equal(t, []string{"PLAIN=value", "SECRET=${file:/secret}", "VARIABLE=${CI_JOB_ID}"}, runner.Environment)
+	assert.Equal(t, "Write-Output ${env:CI_PROJECT_DIR}", runner.PreGetSourcesScript)
+	assert.Equal(t, "echo ${env:CI_JOB_ID}", runner.PreBuildScript)
+	assert.Equal(t, "echo ${file:/secret}", runner.PostBuildScript)
+	assert.Equal(t, map[string]string{"team": "team-${env:TEAM}"}, runner.Docker.ContainerLabels)
+	assert.False(t, config.RunnerHasReference(runner, "pre_build_script"))
+}
+
+func TestConfigResolveReferencesError(t *testing.T) {
+	config := NewConfig()
+	_, err := toml.Decode(configWithReferences, config)
+	require.NoError(t, err)
+
+	err = config.ResolveReferences(func(kind, reference string) (string, error) {
+		if kind == "vault" {
+			return "", errors.New("permission denied")
+		}
+
+		return testConfigReferenceResolver(kind, reference)
+	})
+
+	assert.EqualError(
+		t,
+		err,
+		"resolving ${vault:secret/data/runner#s3} of runners.0.cache.s3.SecretKey: permission denied",
+	)
+}
+
+func TestSaveConfigKeepsReferences(t *testing.T) {
+	var saved string
+	saver := NewMockConfigSaver(t)
+	saver.On("Save", "config.toml", mock.Anything).
+		Run(func(args mock.Arguments) {
+			saved = string(args.Get(1).([]byte))
+		}).
+		Return(nil).
+		Once()
+
+	config := NewConfigWithSaver(saver)
+	_, err := toml.Decode(configWithReferences, config)
+	require.NoError(t, err)
+	require.NoError(t, config.ResolveReferences(testConfigReferenceResolver))
+
+	config.Runners[0].Docker.Image = "ubuntu"
+	config.Runners[0].Machine.MachineOptions[0] = "token=changed"
+
+	require.NoError(t, config.SaveConfig("config.toml"))
+
+	savedConfig := NewConfig()
+	_, err = toml.Decode(saved, savedConfig)
+	require.NoError(t, err)
+
+	runner := savedConfig.Runners[0]
+	assert.Equal(t, "${env:RUNNER_TOKEN}", runner.Token)
+	assert.Equal(t, []string{"PLAIN=value", "SECRET=${file:/secret}", "VARIABLE=${CI_JOB_ID}"}, runner.Environment)
+	assert.Equal(t, "${vault:secret/data/runner#s3}", runner.Cache.S3.SecretKey)
+	assert.Equal(t, map[string]string{"team": "team-${env:TEAM}"}, runner.Docker.ContainerLabels)
+	assert.Equal(t, "ubuntu", runner.Docker.Image)
+	assert.Equal(t, []string{"token=changed", "literal=$${env:LITERAL}"}, runner.Machine.MachineOptions)
+	assert.Equal(t, "echo ${env:CI_JOB_ID}", runner.PreBuildScript)
+
+	assert.Equal(t, "glrt-resolved", config.Runners[0].Token)
+	assert.Equal(t, "s3-secret", config.Runners[0].Cache.S3.SecretKey)
+}
+
+func TestSaveConfigKeepsReferencesOfRunners(t *testing.T) {
+	const runners = `
+[[runners]]
+  name = "first"
+  url = "https://gitlab.example.com"
+  token = "${env:FIRST_TOKEN}"
+  executor = "shell"
+
+[[runners]]
+  name = "second"
+  url = "https://gitlab.example.com"
+  token = "${env:SECOND_TOKEN}"
+  executor = "shell"
+
+[[runners]]
+  name = "third"
+  url = "https://gitlab.example.com"
+  token = "glrt-third"
+  executor = "shell"
+`
+
+	resolve := func(kind, reference string) (string, error) {
+		return map[string]string{
+			"FIRST_TOKEN":  "glrt-first",
+			"SECOND_TOKEN": "glrt-second",
+		}[reference], nil
+	}
+
+	tests := map[string]struct {
+		change         func(runners []*RunnerConfig) []*RunnerConfig
+		expectedTokens map[string]string
+	}{
+		"runner unregistered": {
+			change: func(runners []*RunnerConfig) []*RunnerConfig {
+				return runners[1:]
+			},
+			expectedTokens: map[string]string{
+				"second": "${env:SECOND_TOKEN}",
+				"third":  "glrt-third",
+			},
+		},
+		"runners reordered": {
+			change: func(runners []*RunnerConfig) []*RunnerConfig {
+				return []*RunnerConfig{runners[2], runners[1], runners[0]}
+			},
+			expectedTokens: map[string]string{
+				"first":  "${env:FIRST_TOKEN}",
+				"second": "${env:SECOND_TOKEN}",
+				"third":  "glrt-third",
+			},
+		},
+		"runner registered": {
+			change: func(runners []*RunnerConfig) []*RunnerConfig {
+				registered := &RunnerConfig{Name: "registered"}
+				registered.Token = "glrt-second"
+				return append([]*RunnerConfig{registered}, runners...)
+			},
+			expectedTokens: map[string]string{
+				"registered": "glrt-second",
+				"first":      "${env:FIRST_TOKEN}",
+				"second":     "${env:SECOND_TOKEN}",
+				"third":      "glrt-third",
+			},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			var saved string
+			saver := NewMockConfigSaver(t)
+			saver.On("Save", "config.toml", mock.Anything).
+				Run(func(args mock.Arguments) {
+					saved = string(args.Get(1).([]byte))
+				}).
+				Return(nil).
+				Once()
+
+			config := NewConfigWithSaver(saver)
+			_, err := toml.Decode(runners, config)
+			require.NoError(t, err)
+			require.NoError(t, config.ResolveReferences(resolve))
+
+			config.Runners = tt.change(config.Runners)
+			require.NoError(t, config.SaveConfig("config.toml"))
+
+			savedConfig := NewConfig()
+			_, err = toml.Decode(saved, savedConfig)
+			require.NoError(t, err)
+
+			tokens := make(map[string]string)
+			for _, runner := range savedConfig.Runners {
+				tokens[runner.Name] = runner.Token
+			}
+			assert.Equal(t, tt.expectedTokens, tokens)
+		})
+	}
+}
@@ -1217,6 +1217,152 @@ func TestRunnerSettings_GetGracefulKillTimeout_GetForceKillTimeout(t *testing.T)
 	}
 }
 
+func TestCacheConfig_GetGitBundleInterval(t *testing.T) {
+	duration := func(d time.Duration) *time.Duration { return &d }
+
+	tests := map[string]struct {
+		config           *CacheConfig
+		expectedInterval time.Duration
+	}{
+		"no cache config": {
+			config:           nil,
+			expectedInterval: 0,
+		},
+		"undefined": {
+			config:           &CacheConfig{},
+			expectedInterval: 0,
+		},
+		"negative interval": {
+			config:           &CacheConfig{GitBundleInterval: duration(-time.Hour)},
+			expectedInterval: 0,
+		},
+		"interval defined": {
+			config:           &CacheConfig{GitBundleInterval: duration(12 * time.Hour)},
+			expectedInterval: 12 * time.Hour,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tt.expectedInterval, tt.config.GetGitBundleInterval())
+		})
+	}
+}
+
+func TestDockerBuildVolumesConfig(t *testing.T) {
+	duration := func(d time.Duration) *time.Duration { return &d }
+
+	tests := map[string]struct {
+		config          *DockerBuildVolumesConfig
+		expectedMaxAge  time.Duration
+		expectedMaxSize int64
+		expectedEnabled bool
+		expectedErr     bool
+	}{
+		"no build volumes config": {
+			config: nil,
+		},
+		"undefined": {
+			config: &DockerBuildVolumesConfig{},
+		},
+		"negative max age": {
+			config:          &DockerBuildVolumesConfig{MaxAge: duration(-time.Hour)},
+			expectedEnabled: true,
+		},
+		"all defined": {
+			config: &DockerBuildVolumesConfig{
+				MaxAge:  duration(24 * time.Hour),
+				MaxSize: "10GB",
+			},
+			expectedMaxAge:  24 * time.Hour,
+			expectedMaxSize: 10_000_000_000,
+			expectedEnabled: true,
+		},
+		"invalid max size": {
+			config:          &DockerBuildVolumesConfig{MaxSize: "ten"},
+			expectedEnabled: true,
+			expectedErr:     true,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tt.expectedEnabled, tt.config.IsEnabled())
+			assert.Equal(t, tt.expectedMaxAge, tt.config.GetMaxAge())
+
+			size, err := tt.config.GetMaxSize()
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedMaxSize, size)
+		})
+	}
+}
+
+func TestDockerFailureCheckpoint(t *testing.T) {
+	duration := func(d time.Duration) *time.Duration { return &d }
+
+	tests := map[string]struct {
+		config              *DockerFailureCheckpoint
+		expectedMode        string
+		expectedMaxSize     int64
+		expectedKeepTimeout time.Duration
+		expectedErr         bool
+	}{
+		"no failure checkpoint config": {
+			expectedMaxSize:     DefaultFailureCheckpointMaxSize,
+			expectedKeepTimeout: DefaultFailureCheckpointKeepTimeout,
+		},
+		"defaults": {
+			config:              &DockerFailureCheckpoint{Mode: "artifact"},
+			expectedMode:        "artifact",
+			expectedMaxSize:     DefaultFailureCheckpointMaxSize,
+			expectedKeepTimeout: DefaultFailureCheckpointKeepTimeout,
+		},
+		"all defined": {
+			config: &DockerFailureCheckpoint{
+				Mode:        "keep",
+				MaxSize:     "1GB",
+				KeepTimeout: duration(time.Hour),
+			},
+			expectedMode:        "keep",
+			expectedMaxSize:     1_000_000_000,
+			expectedKeepTimeout: time.Hour,
+		},
+		"negative keep timeout": {
+			config:              &DockerFailureCheckpoint{Mode: "keep", KeepTimeout: duration(-time.Hour)},
+			expectedMode:        "keep",
+			expectedMaxSize:     DefaultFailureCheckpointMaxSize,
+			expectedKeepTimeout: DefaultFailureCheckpointKeepTimeout,
+		},
+		"invalid max size": {
+			config:              &DockerFailureCheckpoint{Mode: "artifact", MaxSize: "huge"},
+			expectedMode:        "artifact",
+			expectedKeepTimeout: DefaultFailureCheckpointKeepTimeout,
+			expectedErr:         true,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tt.expectedMode, tt.config.GetMode())
+			assert.Equal(t, tt.expectedKeepTimeout, tt.config.GetKeepTimeout())
+
+			size, err := tt.config.GetMaxSize()
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedMaxSize, size)
+		})
+	}
+}
+
 func TestDockerConfig_GetPullPolicies(t *testing.T) {
 	tests := map[string]struct {
 		config               DockerConfig
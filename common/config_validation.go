@@ -2,10 +2,17 @@ package common
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
 
+	"github.com/gorhill/cronexpr"
 	jsonschema_generator "github.com/invopop/jsonschema"
 	jsonschema_validator "github.com/santhosh-tekuri/jsonschema/v5"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 var configSchema *jsonschema_validator.Schema
@@ -47,5 +54,368 @@ func Validate(config *Config) error {
 		panic(err)
 	}
 
-	return configSchema.Validate(jsonValue)
+	return configSchema.Validate(removeNulls(jsonValue))
+}
+
+// removeNulls removes the null values of the objects, which are the settings
+// left unset like the nil slices and maps, and don't have to match the schema
+func removeNulls(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if child == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = removeNulls(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = removeNulls(child)
+		}
+	}
+
+	return value
+}
+
+// ConfigError is a problem of a value of the configuration, located by the
+// path of its TOML key, like runners.0.docker.pull_policy
+type ConfigError struct {
+	Path    string
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+
+	return e.Path + ": " + e.Message
+}
+
+type configErrors []ConfigError
+
+func (e *configErrors) add(path string, format string, args ...interface{}) {
+	*e = append(*e, ConfigError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// SchemaErrors returns the values of the configuration which don't match the
+// JSON schema generated from the configuration types
+func SchemaErrors(config *Config) []ConfigError {
+	err := Validate(config)
+
+	var validationErr *jsonschema_validator.ValidationError
+	if !errors.As(err, &validationErr) {
+		if err != nil {
+			return []ConfigError{{Message: err.Error()}}
+		}
+		return nil
+	}
+
+	var errs configErrors
+	var walk func(e *jsonschema_validator.ValidationError)
+	walk = func(e *jsonschema_validator.ValidationError) {
+		if len(e.Causes) == 0 {
+			errs.add(tomlPath(e.InstanceLocation), "%s", e.Message)
+			return
+		}
+
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(validationErr)
+
+	return errs
+}
+
+// tomlPath returns the path of the TOML key of the value of the configuration
+// at the JSON pointer, the JSON and TOML names of the fields being different
+func tomlPath(pointer string) string {
+	var path []string
+
+	t := reflect.TypeOf(Config{})
+	for _, segment := range strings.Split(pointer, "/") {
+		if segment == "" {
+			continue
+		}
+		segment = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		if t == nil {
+			path = append(path, segment)
+			continue
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := jsonField(t, segment)
+			if !ok {
+				path = append(path, segment)
+				t = nil
+				continue
+			}
+
+			path = append(path, tagName(field, "toml"))
+			t = field.Type
+		case reflect.Slice, reflect.Array, reflect.Map:
+			path = append(path, segment)
+			t = t.Elem()
+		default:
+			path = append(path, segment)
+			t = nil
+		}
+	}
+
+	return strings.Join(path, ".")
+}
+
+// jsonField returns the field of the struct, or of the structs it embeds,
+// with the JSON name
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.Anonymous && strings.Split(field.Tag.Get("json"), ",")[0] == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				if f, ok := jsonField(embedded, name); ok {
+					return f, true
+				}
+			}
+			continue
+		}
+
+		if tagName(field, "json") == name {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// tagName returns the name of the field in the encoding of the tag
+func tagName(field reflect.StructField, tag string) string {
+	name := strings.Split(field.Tag.Get(tag), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+// SemanticErrors returns the problems of the configuration which the schema
+// can't express, and which otherwise only surface when the jobs run
+func SemanticErrors(config *Config) []ConfigError {
+	var errs configErrors
+
+	for i, runner := range config.Runners {
+		prefix := fmt.Sprintf("runners.%d.", i)
+
+		if runner.Docker != nil {
+			errs.checkPullPolicies(prefix+"docker.", runner.Docker.PullPolicy, runner.Docker.AllowedPullPolicies)
+		}
+
+		if runner.Kubernetes != nil {
+			errs.checkPullPolicies(prefix+"kubernetes.", runner.Kubernetes.PullPolicy, runner.Kubernetes.AllowedPullPolicies)
+			errs.checkOverwriteMaxima(prefix+"kubernetes.", runner.Kubernetes)
+		}
+
+		if runner.Autoscaler != nil {
+			errs.checkAutoscalerPolicies(prefix+"autoscaler.policy.", runner.Autoscaler.Policy)
+		}
+
+		if runner.Machine != nil {
+			for j, autoscaling := range runner.Machine.AutoscalingConfigs {
+				if err := autoscaling.compilePeriods(); err != nil {
+					errs.add(fmt.Sprintf("%smachine.autoscaling.%d.Periods", prefix, j), "%v", err)
+				}
+			}
+		}
+
+		if runner.Cache != nil {
+			errs.checkCache(prefix+"cache.", runner.Cache)
+		}
+	}
+
+	return errs
+}
+
+func isPullPolicy(policy string) bool {
+	switch policy {
+	case PullPolicyAlways, PullPolicyIfNotPresent, PullPolicyNever:
+		return true
+	}
+
+	return false
+}
+
+func (e *configErrors) checkPullPolicies(prefix string, policies StringOrArray, allowed []DockerPullPolicy) {
+	for _, policy := range allowed {
+		if !isPullPolicy(string(policy)) {
+			e.add(prefix+"allowed_pull_policies", "unsupported pull policy %q", policy)
+		}
+	}
+
+	for _, policy := range policies {
+		if !isPullPolicy(policy) {
+			e.add(prefix+"pull_policy", "unsupported pull policy %q", policy)
+			continue
+		}
+
+		if len(allowed) == 0 {
+			continue
+		}
+
+		isAllowed := false
+		for _, allowedPolicy := range allowed {
+			isAllowed = isAllowed || string(allowedPolicy) == policy
+		}
+
+		if !isAllowed {
+			e.add(prefix+"pull_policy", "pull policy %q isn't in allowed_pull_policies %v", policy, allowed)
+		}
+	}
+}
+
+// checkOverwriteMaxima checks the maxima of the resources the jobs can
+// overwrite, which must be quantities at least equal to their default
+func (e *configErrors) checkOverwriteMaxima(prefix string, config *KubernetesConfig) {
+	const suffix = "OverwriteMaxAllowed"
+
+	v := reflect.ValueOf(config).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		maxField := t.Field(i)
+		if !strings.HasSuffix(maxField.Name, suffix) || maxField.Type.Kind() != reflect.String {
+			continue
+		}
+
+		maxValue := v.Field(i).String()
+		if maxValue == "" {
+			continue
+		}
+
+		maxQuantity, err := resource.ParseQuantity(maxValue)
+		if err != nil {
+			e.add(prefix+tagName(maxField, "toml"), "invalid quantity %q: %v", maxValue, err)
+			continue
+		}
+
+		field, ok := t.FieldByName(strings.TrimSuffix(maxField.Name, suffix))
+		if !ok {
+			continue
+		}
+
+		value := v.FieldByIndex(field.Index).String()
+		if value == "" {
+			continue
+		}
+
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			e.add(prefix+tagName(field, "toml"), "invalid quantity %q: %v", value, err)
+			continue
+		}
+
+		if quantity.Cmp(maxQuantity) > 0 {
+			e.add(
+				prefix+tagName(field, "toml"),
+				"%q is greater than %s %q",
+				value, tagName(maxField, "toml"), maxValue,
+			)
+		}
+	}
+}
+
+// checkAutoscalerPolicies checks the periods of the autoscaler policies,
+// which are crontab expressions of 5 fields
+func (e *configErrors) checkAutoscalerPolicies(prefix string, policies []AutoscalerPolicyConfig) {
+	for i, policy := range policies {
+		path := fmt.Sprintf("%s%d.", prefix, i)
+
+		for _, period := range policy.Periods {
+			if len(strings.Fields(period)) != 5 {
+				e.add(path+"periods", "period %q must have 5 fields: minute, hour, day of month, month and day of week", period)
+				continue
+			}
+
+			if _, err := cronexpr.Parse(period); err != nil {
+				e.add(path+"periods", "invalid period %q: %v", period, err)
+			}
+		}
+
+		if policy.Timezone != "" {
+			if _, err := time.LoadLocation(policy.Timezone); err != nil {
+				e.add(path+"timezone", "%v", err)
+			}
+		}
+	}
+}
+
+// checkCache checks the cache server settings are complete, the incomplete
+// credentials being otherwise only reported when a job uses the cache
+func (e *configErrors) checkCache(prefix string, cache *CacheConfig) {
+	switch cache.Type {
+	case "":
+	case "s3":
+		if cache.S3 == nil {
+			e.add(prefix+"Type", "the s3 cache requires a [runners.cache.s3] section")
+			return
+		}
+	case "gcs":
+		if cache.GCS == nil {
+			e.add(prefix+"Type", "the gcs cache requires a [runners.cache.gcs] section")
+			return
+		}
+	case "azure":
+		if cache.Azure == nil {
+			e.add(prefix+"Type", "the azure cache requires a [runners.cache.azure] section")
+			return
+		}
+	default:
+		e.add(prefix+"Type", "unsupported cache type %q, use s3, gcs or azure", cache.Type)
+	}
+
+	if s3 := cache.S3; s3 != nil && cache.Type == "s3" {
+		if s3.BucketName == "" {
+			e.add(prefix+"s3.BucketName", "the bucket name is required")
+		}
+
+		switch s3.AuthType() {
+		case "":
+			e.add(prefix+"s3.AuthenticationType", "unsupported authentication type %q, use iam or access-key", s3.AuthenticationType)
+		case S3AuthTypeAccessKey:
+			if s3.AccessKey == "" || s3.SecretKey == "" {
+				e.add(prefix+"s3", "the access-key authentication requires AccessKey and SecretKey")
+			}
+		}
+	}
+
+	if gcs := cache.GCS; gcs != nil && cache.Type == "gcs" {
+		if gcs.BucketName == "" {
+			e.add(prefix+"gcs.BucketName", "the bucket name is required")
+		}
+
+		if gcs.CredentialsFile == "" && (gcs.AccessID == "") != (gcs.PrivateKey == "") {
+			e.add(prefix+"gcs", "AccessID and PrivateKey must be set together")
+		}
+	}
+
+	if azure := cache.Azure; azure != nil && cache.Type == "azure" {
+		if azure.ContainerName == "" {
+			e.add(prefix+"azure.ContainerName", "the container name is required")
+		}
+
+		if azure.AccountName == "" || azure.AccountKey == "" {
+			e.add(prefix+"azure", "AccountName and AccountKey are required")
+		}
+	}
 }
@@ -0,0 +1,217 @@
+//go:build !integration
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOMLPath(t *testing.T) {
+	tests := map[string]string{
+		"":                                "",
+		"/concurrent":                     "concurrent",
+		"/runners/0/token":                "runners.0.token",
+		"/runners/1/request_concurrency":  "runners.1.request_concurrency",
+		"/runners/0/docker/pull_policy/1": "runners.0.docker.pull_policy.1",
+		"/runners/0/Autoscaler/Policy/0/IdleTime": "runners.0.autoscaler.policy.0.idle_time",
+		"/runners/0/cache/s3/AccessKey":           "runners.0.cache.s3.AccessKey",
+		"/runners/0/unknown/key":                  "runners.0.unknown.key",
+	}
+
+	for pointer, expectedPath := range tests {
+		t.Run(pointer, func(t *testing.T) {
+			assert.Equal(t, expectedPath, tomlPath(pointer))
+		})
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	config := &Config{
+		Runners: []*RunnerConfig{
+			{
+				Name:              "runner",
+				RunnerCredentials: RunnerCredentials{URL: "https://gitlab.example.com", Token: "token"},
+				RunnerSettings:    RunnerSettings{Executor: "shell", Shell: "fish"},
+			},
+		},
+	}
+
+	errs := SchemaErrors(config)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "runners.0.shell", errs[0].Path)
+	}
+
+	config.Runners[0].Shell = "bash"
+	assert.Empty(t, SchemaErrors(config))
+}
+
+func TestSemanticErrors(t *testing.T) {
+	tests := map[string]struct {
+		runner         RunnerConfig
+		expectedErrors []ConfigError
+	}{
+		"valid": {
+			runner: RunnerConfig{
+				RunnerSettings: RunnerSettings{
+					Docker: &DockerConfig{
+						PullPolicy:          StringOrArray{PullPolicyIfNotPresent},
+						AllowedPullPolicies: []DockerPullPolicy{PullPolicyAlways, PullPolicyIfNotPresent},
+					},
+					Kubernetes: &KubernetesConfig{
+						CPULimit:                    "1",
+						CPULimitOverwriteMaxAllowed: "2",
+					},
+					Autoscaler: &AutoscalerConfig{
+						Policy: []AutoscalerPolicyConfig{{Periods: []string{"* 9-17 * * mon-fri"}, Timezone: "UTC"}},
+					},
+					Cache: &CacheConfig{
+						Type: "s3",
+						S3:   &CacheS3Config{BucketName: "cache", AuthenticationType: S3AuthTypeIAM},
+					},
+				},
+			},
+		},
+		"pull policies": {
+			runner: RunnerConfig{
+				RunnerSettings: RunnerSettings{
+					Docker: &DockerConfig{
+						PullPolicy:          StringOrArray{PullPolicyAlways, "sometimes"},
+						AllowedPullPolicies: []DockerPullPolicy{PullPolicyIfNotPresent, "often"},
+					},
+				},
+			},
+			expectedErrors: []ConfigError{
+				{Path: "runners.0.docker.allowed_pull_policies", Message: `unsupported pull policy "often"`},
+				{
+					Path:    "runners.0.docker.pull_policy",
+					Message: `pull policy "always" isn't in allowed_pull_policies [if-not-present often]`,
+				},
+				{Path: "runners.0.docker.pull_policy", Message: `unsupported pull policy "sometimes"`},
+			},
+		},
+		"kubernetes overwrite maxima": {
+			runner: RunnerConfig{
+				RunnerSettings: RunnerSettings{
+					Kubernetes: &KubernetesConfig{
+						CPULimit:                             "4",
+						CPULimitOverwriteMaxAllowed:          "2",
+						MemoryRequestOverwriteMaxAllowed:     "lots",
+						HelperMemoryLimit:                    "1Gi",
+						HelperMemoryLimitOverwriteMaxAllowed: "2Gi",
+					},
+				},
+			},
+			expectedErrors: []ConfigError{
+				{
+					Path:    "runners.0.kubernetes.cpu_limit",
+					Message: `"4" is greater than cpu_limit_overwrite_max_allowed "2"`,
+				},
+				{
+					Path: "runners.0.kubernetes.memory_request_overwrite_max_allowed",
+					Message: `invalid quantity "lots": quantities must match the regular expression ` +
+						`'^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$'`,
+				},
+			},
+		},
+		"autoscaler policy periods": {
+			runner: RunnerConfig{
+				RunnerSettings: RunnerSettings{
+					Autoscaler: &AutoscalerConfig{
+						Policy: []AutoscalerPolicyConfig{
+							{Periods: []string{"* * * * * *"}},
+							{Periods: []string{"* 25 * * *"}, Timezone: "Mars/Olympus"},
+						},
+					},
+				},
+			},
+			expectedErrors: []ConfigError{
+				{
+					Path: "runners.0.autoscaler.policy.0.periods",
+					Message: `period "* * * * * *" must have 5 fields: ` +
+						"minute, hour, day of month, month and day of week",
+				},
+				{
+					Path:    "runners.0.autoscaler.policy.1.periods",
+					Message: `invalid period "* 25 * * *": syntax error in hour field: '25'`,
+				},
+				{Path: "runners.0.autoscaler.policy.1.timezone", Message: "unknown time zone Mars/Olympus"},
+			},
+		},
+		"docker machine periods": {
+			runner: RunnerConfig{
+				RunnerSettings: RunnerSettings{
+					Machine: &DockerMachine{
+						AutoscalingConfigs: []*DockerMachineAutoscaling{{Periods: []string{"invalid"}}},
+					},
+				},
+			},
+			expectedErrors: []ConfigError{
+				{
+					Path:    "runners.0.machine.autoscaling.0.Periods",
+					Message: "invalid time periods [invalid], caused by: missing field(s)",
+				},
+			},
+		},
+		"s3 cache credentials": {
+			runner: RunnerConfig{
+				RunnerSettings: RunnerSettings{
+					Cache: &CacheConfig{
+						Type: "s3",
+						S3:   &CacheS3Config{AuthenticationType: S3AuthTypeAccessKey, AccessKey: "key"},
+					},
+				},
+			},
+			expectedErrors: []ConfigError{
+				{Path: "runners.0.cache.s3.BucketName", Message: "the bucket name is required"},
+				{Path: "runners.0.cache.s3", Message: "the access-key authentication requires AccessKey and SecretKey"},
+			},
+		},
+		"gcs cache credentials": {
+			runner: RunnerConfig{
+				RunnerSettings: RunnerSettings{
+					Cache: &CacheConfig{
+						Type: "gcs",
+						GCS: &CacheGCSConfig{
+							BucketName:          "cache",
+							CacheGCSCredentials: CacheGCSCredentials{AccessID: "id"},
+						},
+					},
+				},
+			},
+			expectedErrors: []ConfigError{
+				{Path: "runners.0.cache.gcs", Message: "AccessID and PrivateKey must be set together"},
+			},
+		},
+		"azure cache credentials": {
+			runner: RunnerConfig{
+				RunnerSettings: RunnerSettings{
+					Cache: &CacheConfig{Type: "azure", Azure: &CacheAzureConfig{}},
+				},
+			},
+			expectedErrors: []ConfigError{
+				{Path: "runners.0.cache.azure.ContainerName", Message: "the container name is required"},
+				{Path: "runners.0.cache.azure", Message: "AccountName and AccountKey are required"},
+			},
+		},
+		"missing cache section": {
+			runner: RunnerConfig{
+				RunnerSettings: RunnerSettings{
+					Cache: &CacheConfig{Type: "s3"},
+				},
+			},
+			expectedErrors: []ConfigError{
+				{Path: "runners.0.cache.Type", Message: "the s3 cache requires a [runners.cache.s3] section"},
+			},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			config := &Config{Runners: []*RunnerConfig{&tt.runner}}
+
+			assert.Equal(t, tt.expectedErrors, SemanticErrors(config))
+		})
+	}
+}
@@ -32,6 +32,10 @@ const DefaultCacheRequestTimeout = 10
 const DefaultNetworkClientTimeout = 60 * time.Minute
 const DefaultSessionTimeout = 30 * time.Minute
 const WaitForBuildFinishTimeout = 5 * time.Minute
+const DefaultFailureCheckpointMaxSize = 100 * 1000 * 1000 // in bytes
+const DefaultFailureCheckpointKeepTimeout = 10 * time.Minute
+const DefaultMachinePredictiveLeadTime = 10 * time.Minute
+const DefaultMachinePredictiveHistoryDays = 7
 const SecretVariableDefaultsToFile = true
 const TokenResetIntervalFactor = 0.75
 
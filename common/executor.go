@@ -103,6 +103,57 @@ type ManagedExecutorProvider interface {
 	Shutdown(ctx context.Context)
 }
 
+// RunnerConfigChange is the change of the configuration of a runner applied
+// by a configuration reload
+type RunnerConfigChange struct {
+	// Previous is the runner in the previous configuration, nil when the
+	// runner was added
+	Previous *RunnerConfig
+	// Current is the runner in the reloaded configuration, nil when the
+	// runner was removed
+	Current *RunnerConfig
+	// Settings are the TOML keys of the settings which changed, like
+	// autoscaler.max_instances, when the runner was modified
+	Settings []string
+}
+
+// ReconfigurableExecutorProvider is implemented by the executor providers
+// which keep state for each runner, like autoscaled instances, to apply the
+// changes of the configuration of the runners when it's reloaded
+type ReconfigurableExecutorProvider interface {
+	// Reconfigure applies the change of the configuration of a runner using
+	// the executor provider. A runner whose executor changed is passed as
+	// removed to the provider of its previous executor and as added to the
+	// provider of its current executor.
+	//
+	// An error describes the changes the provider couldn't apply.
+	//
+	// Reconfigure MUST BE NON-BLOCKING!
+	Reconfigure(change RunnerConfigChange) error
+}
+
+// RunnerFeaturesExecutorProvider is implemented by the executor providers
+// whose features depend on the runner using them, like the features declared
+// by the driver of a custom executor
+type RunnerFeaturesExecutorProvider interface {
+	// GetRunnerFeatures updates the features returned by GetFeatures with the
+	// ones of the runner.
+	GetRunnerFeatures(config *RunnerConfig, features *FeaturesInfo)
+}
+
+// GetRunnerFeatures returns the features the executor supports for the runner
+func GetRunnerFeatures(provider ExecutorProvider, config *RunnerConfig, features *FeaturesInfo) error {
+	if err := provider.GetFeatures(features); err != nil {
+		return err
+	}
+
+	if p, ok := provider.(RunnerFeaturesExecutorProvider); ok && config != nil {
+		p.GetRunnerFeatures(config, features)
+	}
+
+	return nil
+}
+
 // ExecutorProvider is responsible for managing the lifetime of executors, acquiring resources,
 // retrieving executor metadata, etc.
 //
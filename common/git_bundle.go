@@ -0,0 +1,112 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// gitBundleDeadlineMargin is the time left to the job when the creation of the
+// git bundle is stopped, so that it can't make the job time out
+const gitBundleDeadlineMargin = 30 * time.Second
+
+// gitBundleSchedule tracks when the jobs handled by the process last produced
+// the git bundle of a project
+type gitBundleSchedule struct {
+	lock      sync.Mutex
+	produced  map[string]time.Time
+	producing map[string]bool
+	now       func() time.Time
+}
+
+func newGitBundleSchedule() *gitBundleSchedule {
+	return &gitBundleSchedule{
+		produced:  make(map[string]time.Time),
+		producing: make(map[string]bool),
+		now:       time.Now,
+	}
+}
+
+// reserve reports whether the bundle identified by key must be produced, and
+// reserves it so that concurrent jobs of the project don't produce it too
+// until it's released
+func (s *gitBundleSchedule) reserve(key string, interval time.Duration) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.producing[key] {
+		return false
+	}
+
+	if last, ok := s.produced[key]; ok && s.now().Sub(last) < interval {
+		return false
+	}
+
+	s.producing[key] = true
+
+	return true
+}
+
+// release ends the reservation of the bundle identified by key, the next
+// bundle is due after the interval only if it was produced
+func (s *gitBundleSchedule) release(key string, produced bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.producing, key)
+	if produced {
+		s.produced[key] = s.now()
+	}
+}
+
+var gitBundles = newGitBundleSchedule()
+
+func (b *Build) gitBundleScheduleKey() string {
+	return fmt.Sprintf("%s/%d", b.Runner.ShortDescription(), b.JobInfo.ProjectID)
+}
+
+// CreatesGitBundle returns whether the job can produce the git bundle of the
+// project. Shallow and partial clones can't produce a complete bundle.
+func (b *Build) CreatesGitBundle() bool {
+	if b.Runner.Cache.GetGitBundleInterval() <= 0 || b.GetGitStrategy() == GitNone || b.GitInfo.Depth > 0 {
+		return false
+	}
+
+	filter, err := b.GetGitCloneFilter()
+
+	return err == nil && filter == ""
+}
+
+// executeCreateGitBundle produces the git bundle of the project once the job
+// succeeded, at most once per GitBundleInterval. The job isn't affected by the
+// bundle failing to be produced, and the next job of the project produces it
+// instead.
+func (b *Build) executeCreateGitBundle(ctx context.Context, executor Executor) {
+	if !b.CreatesGitBundle() {
+		return
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		deadline = deadline.Add(-gitBundleDeadlineMargin)
+		if time.Until(deadline) <= 0 {
+			return
+		}
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	key := b.gitBundleScheduleKey()
+	if !gitBundles.reserve(key, b.Runner.Cache.GetGitBundleInterval()) {
+		return
+	}
+
+	err := b.executeStage(ctx, BuildStageCreateGitBundle, executor)
+	gitBundles.release(key, err == nil)
+
+	if err != nil {
+		b.logger.Warningln("Creating the git bundle failed, but job will continue unaffected:", err)
+	}
+}
@@ -0,0 +1,111 @@
+//go:build !integration
+
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitBundleSchedule(t *testing.T) {
+	now := time.Now()
+
+	s := newGitBundleSchedule()
+	s.now = func() time.Time { return now }
+
+	assert.True(t, s.reserve("project-1", time.Hour))
+	assert.False(t, s.reserve("project-1", time.Hour), "the bundle is being produced")
+	assert.True(t, s.reserve("project-2", time.Hour))
+
+	s.release("project-1", false)
+	assert.True(t, s.reserve("project-1", time.Hour), "the bundle wasn't produced")
+
+	s.release("project-1", true)
+	assert.False(t, s.reserve("project-1", time.Hour))
+
+	now = now.Add(time.Hour)
+	assert.True(t, s.reserve("project-1", time.Hour))
+}
+
+func TestBuildCreatesGitBundle(t *testing.T) {
+	interval := time.Hour
+
+	tests := map[string]struct {
+		interval  *time.Duration
+		depth     int
+		variables JobVariables
+		expected  bool
+	}{
+		"git bundles disabled": {},
+		"shallow clone": {
+			interval: &interval,
+			depth:    20,
+		},
+		"partial clone": {
+			interval:  &interval,
+			variables: JobVariables{{Key: "GIT_CLONE_FILTER", Value: "blob:none"}},
+		},
+		"git strategy none": {
+			interval:  &interval,
+			variables: JobVariables{{Key: "GIT_STRATEGY", Value: "none"}},
+		},
+		"git bundles enabled": {
+			interval: &interval,
+			expected: true,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			build := &Build{
+				Runner: &RunnerConfig{
+					RunnerSettings: RunnerSettings{Cache: &CacheConfig{GitBundleInterval: tt.interval}},
+				},
+				JobResponse: JobResponse{
+					GitInfo:   GitInfo{Depth: tt.depth},
+					Variables: tt.variables,
+				},
+			}
+
+			assert.Equal(t, tt.expected, build.CreatesGitBundle())
+		})
+	}
+}
+
+func TestBuildExecuteCreateGitBundle(t *testing.T) {
+	oldGitBundles := gitBundles
+	t.Cleanup(func() { gitBundles = oldGitBundles })
+	gitBundles = newGitBundleSchedule()
+
+	interval := time.Hour
+	build := &Build{
+		Runner: &RunnerConfig{
+			RunnerSettings: RunnerSettings{Cache: &CacheConfig{GitBundleInterval: &interval}},
+		},
+		JobResponse: JobResponse{JobInfo: JobInfo{ProjectID: 1}},
+	}
+
+	executor := NewMockExecutor(t)
+	executor.On("Shell").Return(&ShellScriptInfo{Shell: "script-shell"})
+	executor.On("Run", matchBuildStage(BuildStageCreateGitBundle)).Return(errors.New("upload failed")).Once()
+	executor.On("Run", matchBuildStage(BuildStageCreateGitBundle)).Return(nil).Once()
+
+	// the bundle failing to be produced is retried by the next job
+	build.executeCreateGitBundle(context.Background(), executor)
+	build.executeCreateGitBundle(context.Background(), executor)
+
+	// the bundle is produced at most once per interval
+	build.executeCreateGitBundle(context.Background(), executor)
+
+	ctx, cancel := context.WithTimeout(context.Background(), gitBundleDeadlineMargin)
+	defer cancel()
+
+	gitBundles = newGitBundleSchedule()
+	build.executeCreateGitBundle(ctx, executor)
+	executor.AssertNumberOfCalls(t, "Run", 2)
+
+}
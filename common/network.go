@@ -39,6 +39,15 @@ const (
 	ImagePullFailure    JobFailureReason = "image_pull_failure"
 	UnknownFailure      JobFailureReason = "unknown_failure"
 
+	PodSchedulingFailure  JobFailureReason = "pod_scheduling_failure"
+	ResourceQuotaExceeded JobFailureReason = "resource_quota_exceeded"
+	OutOfMemoryFailure    JobFailureReason = "out_of_memory_failure"
+	PodEvictedFailure     JobFailureReason = "pod_evicted_failure"
+
+	// RunnerDrainTimeout is the failure of the jobs still running when the
+	// deadline of the drain mode of the runner is reached
+	RunnerDrainTimeout JobFailureReason = "runner_drain_timeout"
+
 	// When defining new job failure reasons, consider if its meaning is
 	// extracted from the scope of already existing one. If yes - update
 	// the failureReasonsCompatibilityMap variable below.
@@ -57,6 +66,11 @@ var (
 		JobExecutionTimeout,
 		ImagePullFailure,
 		UnknownFailure,
+		PodSchedulingFailure,
+		ResourceQuotaExceeded,
+		OutOfMemoryFailure,
+		PodEvictedFailure,
+		RunnerDrainTimeout,
 	}
 
 	// failureReasonsCompatibilityMap contains a mapping of new failure reasons
@@ -67,7 +81,12 @@ var (
 	// category for them (yet we still need to pass the that value through
 	// supported list check).
 	failureReasonsCompatibilityMap = map[JobFailureReason]JobFailureReason{
-		ImagePullFailure: RunnerSystemFailure,
+		ImagePullFailure:      RunnerSystemFailure,
+		PodSchedulingFailure:  RunnerSystemFailure,
+		ResourceQuotaExceeded: RunnerSystemFailure,
+		OutOfMemoryFailure:    ScriptFailure,
+		PodEvictedFailure:     RunnerSystemFailure,
+		RunnerDrainTimeout:    RunnerSystemFailure,
 	}
 
 	// A small list of failure reasons that are supported by all
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/gitmirror"
 	"gitlab.com/gitlab-org/gitlab-runner/session/proxy"
 )
 
@@ -146,6 +147,39 @@ func (e *AbstractExecutor) PrepareBuildAndShell() error {
 	return nil
 }
 
+// RefreshGitMirror refreshes the reference repository of the project when
+// git_mirror_dir is configured and returns its path on the runner host.
+// A failing refresh isn't fatal: the job fetches from the remote instead.
+func (e *AbstractExecutor) RefreshGitMirror() string {
+	if e.Config.GitMirrorDir == "" {
+		return ""
+	}
+
+	strategy := e.Build.GetGitStrategy()
+	if strategy != common.GitClone && strategy != common.GitFetch {
+		return ""
+	}
+
+	ctx := e.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	e.Debugln("Refreshing the reference repository in", e.Config.GitMirrorDir)
+
+	repoPath, err := gitmirror.Get(e.Config.GitMirrorDir).Refresh(
+		ctx,
+		e.Build.GetRemoteURL(),
+		gitmirror.RefreshOptions{CAFile: e.Config.TLSCAFile},
+	)
+	if err != nil {
+		e.Warningln("Failed to refresh the reference repository, fetching from the remote:", err)
+		return ""
+	}
+
+	return repoPath
+}
+
 func (e *AbstractExecutor) Finish(err error) {
 	e.SetCurrentStage(common.ExecutorStageFinish)
 }
@@ -16,6 +16,53 @@ type ConfigExecOutput struct {
 	JobEnv *map[string]string `json:"job_env,omitempty"`
 
 	Shell *string `json:"shell,omitempty"`
+
+	Features *FeaturesInfo `json:"features,omitempty"`
+
+	// StageTimeouts limits, in seconds, the time given to run_exec for
+	// the given stages, for example build_script
+	StageTimeouts *map[string]int `json:"stage_timeouts,omitempty"`
+
+	// FailureReasons maps exit codes of the executables to the job failure
+	// reasons known by GitLab, like out_of_memory_failure
+	FailureReasons *map[int]string `json:"failure_reasons,omitempty"`
+
+	// MaskedValues are additional values masked in the job log
+	MaskedValues *[]string `json:"masked_values,omitempty"`
+
+	// TerminalCommand is the command started, with a PTY, for the interactive
+	// web terminal. It's required by the terminal feature.
+	TerminalCommand *[]string `json:"terminal_command,omitempty"`
+
+	// ProxyServices are the services reachable with the session server
+	// proxy. They're required by the proxy feature.
+	ProxyServices *[]ProxyService `json:"proxy_services,omitempty"`
+}
+
+// FeaturesInfo defines the features supported by the environment
+// provided by the Custom Executor driver
+type FeaturesInfo struct {
+	Services *bool `json:"services,omitempty"`
+	Terminal *bool `json:"terminal,omitempty"`
+	Proxy    *bool `json:"proxy,omitempty"`
+
+	// Shared defines whether the environment, including the builds and cache
+	// directories, is shared between jobs. Defaults to true.
+	Shared *bool `json:"shared,omitempty"`
+}
+
+// ProxyService defines a service reachable with the session server proxy
+type ProxyService struct {
+	Name  string      `json:"name"`
+	Host  string      `json:"host"`
+	Ports []ProxyPort `json:"ports"`
+}
+
+// ProxyPort defines a port of a ProxyService. Protocol is either http or https.
+type ProxyPort struct {
+	Number   int    `json:"number"`
+	Protocol string `json:"protocol"`
+	Name     string `json:"name,omitempty"`
 }
 
 // DriverInfo wraps the information about Custom Executor driver details
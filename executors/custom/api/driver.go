@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+)
+
+// DriverProtocolVersion is the version of the protocol used to communicate
+// with a long-lived Custom Executor driver. It's sent to the driver with
+// the prepare call.
+const DriverProtocolVersion = "1"
+
+// JSONRPCVersion is the version of JSON-RPC used by the driver protocol.
+const JSONRPCVersion = "2.0"
+
+// DriverSocketVariable is the name of the variable used to pass the path of
+// the unix socket the driver should connect to, when the unix transport
+// is used
+const DriverSocketVariable = "DRIVER_SOCKET"
+
+// Methods of the driver protocol
+const (
+	// DriverMethodPrepare is called once per job, before any stage is run.
+	// The params are DriverPrepareParams and the result is DriverPrepareResult.
+	DriverMethodPrepare = "prepare"
+
+	// DriverMethodRun is called for each stage of the job.
+	// The params are DriverRunParams and the result is DriverRunResult.
+	DriverMethodRun = "run"
+
+	// DriverMethodCleanup is called once per job, after all the stages were run,
+	// and before the driver process is stopped. The params are DriverCleanupParams.
+	DriverMethodCleanup = "cleanup"
+
+	// DriverMethodCancel is a notification sent by Runner to the driver when
+	// a call is canceled, for example when the job is canceled or timed out.
+	// The params are DriverCancelParams.
+	DriverMethodCancel = "cancel"
+
+	// DriverMethodOutput is a notification sent by the driver to Runner to
+	// stream the output of a call into the job log. The params are DriverOutputParams.
+	DriverMethodOutput = "output"
+)
+
+// Error codes of the driver protocol
+const (
+	DriverErrorCodeParseError     = -32700
+	DriverErrorCodeInvalidRequest = -32600
+	DriverErrorCodeMethodNotFound = -32601
+	DriverErrorCodeInvalidParams  = -32602
+	DriverErrorCodeInternalError  = -32603
+
+	// DriverErrorCodeJobFailure should be used when the call failed because
+	// of the job. The reason can be set with DriverErrorData.
+	DriverErrorCodeJobFailure = 1
+)
+
+// DriverMessage is a JSON-RPC request, response or notification exchanged
+// with the driver. The messages are encoded as one JSON document per line.
+type DriverMessage struct {
+	JSONRPC string `json:"jsonrpc"`
+
+	ID     *int64          `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *DriverError    `json:"error,omitempty"`
+}
+
+// DriverError is the error returned by the driver when a call fails
+type DriverError struct {
+	Code    int              `json:"code"`
+	Message string           `json:"message"`
+	Data    *DriverErrorData `json:"data,omitempty"`
+}
+
+// DriverErrorData gives Runner details about why a call failed.
+//
+// FailureReason is one of the job failure reasons known by GitLab,
+// like script_failure or runner_system_failure. When empty, the failure
+// is reported as a script_failure for the DriverErrorCodeJobFailure code
+// and as a runner_system_failure otherwise.
+type DriverErrorData struct {
+	FailureReason string `json:"failure_reason,omitempty"`
+	ExitCode      int    `json:"exit_code,omitempty"`
+}
+
+// DriverPrepareParams are the params of the prepare call
+type DriverPrepareParams struct {
+	ProtocolVersion string `json:"protocol_version"`
+	JobResponseFile string `json:"job_response_file"`
+}
+
+// DriverPrepareResult is the result of the prepare call. It allows the driver
+// to pass the same configuration values as the config_exec output.
+type DriverPrepareResult struct {
+	ConfigExecOutput
+}
+
+// DriverRunParams are the params of the run call
+type DriverRunParams struct {
+	Stage      string `json:"stage"`
+	Script     string `json:"script"`
+	ScriptFile string `json:"script_file"`
+}
+
+// DriverRunResult is the result of the run call
+type DriverRunResult struct{}
+
+// DriverCleanupParams are the params of the cleanup call
+type DriverCleanupParams struct{}
+
+// DriverCancelParams are the params of the cancel notification
+type DriverCancelParams struct {
+	ID int64 `json:"id"`
+}
+
+// DriverOutputParams are the params of the output notification
+type DriverOutputParams struct {
+	// ID is the ID of the call the output belongs to
+	ID     int64  `json:"id"`
+	Stream string `json:"stream,omitempty"`
+	Data   string `json:"data"`
+}
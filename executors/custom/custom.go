@@ -15,6 +15,7 @@ import (
 	"gitlab.com/gitlab-org/gitlab-runner/executors"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/command"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/driver"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/featureflags"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/process"
 )
@@ -67,6 +68,28 @@ func (c *ConfigExecOutput) InjectInto(executor *executor) {
 	if c.Shell != nil {
 		executor.Config.Shell = *c.Shell
 	}
+
+	if c.StageTimeouts != nil {
+		executor.stageTimeouts = *c.StageTimeouts
+	}
+
+	if c.FailureReasons != nil {
+		executor.failureReasons = *c.FailureReasons
+	}
+
+	if c.MaskedValues != nil {
+		executor.maskedValues = *c.MaskedValues
+	}
+
+	if c.TerminalCommand != nil {
+		executor.terminalCommand = *c.TerminalCommand
+	}
+
+	if c.ProxyServices != nil {
+		executor.setProxyServices(*c.ProxyServices)
+	}
+
+	executor.applyFeatures(c.Features)
 }
 
 type executor struct {
@@ -77,8 +100,16 @@ type executor struct {
 	jobResponseFile string
 
 	driverInfo *api.DriverInfo
+	driver     driver.Driver
 
 	jobEnv map[string]string
+
+	stageTimeouts    map[string]int
+	failureReasons   map[int]string
+	maskedValues     []string
+	terminalCommand  []string
+	proxyServices    map[string]api.ProxyService
+	declaredFeatures *declaredFeatures
 }
 
 func (e *executor) Prepare(options common.ExecutorPrepareOptions) error {
@@ -104,6 +135,12 @@ func (e *executor) Prepare(options common.ExecutorPrepareOptions) error {
 		return err
 	}
 
+	err = e.prepareDriver()
+	if err != nil {
+		return err
+	}
+
+	e.setMaskedValues()
 	e.logStartupMessage()
 
 	err = e.AbstractExecutor.PrepareBuildAndShell()
@@ -125,7 +162,7 @@ func (e *executor) Prepare(options common.ExecutorPrepareOptions) error {
 		out:        e.defaultCommandOutputs(),
 	}
 
-	return e.prepareCommand(ctx, opts).Run()
+	return e.mapFailureReason(e.prepareCommand(ctx, opts).Run())
 }
 
 func (e *executor) prepareConfig() error {
@@ -137,7 +174,7 @@ func (e *executor) prepareConfig() error {
 		CustomConfig: e.Config.Custom,
 	}
 
-	if e.config.RunExec == "" {
+	if e.config.RunExec == "" && e.config.DriverExec == "" {
 		return common.MakeBuildError("custom executor is missing RunExec")
 	}
 
@@ -234,13 +271,21 @@ func (e *executor) defaultCommandOutputs() commandOutputs {
 var commandFactory = command.New
 
 func (e *executor) prepareCommand(ctx context.Context, opts prepareCommandOpts) command.Command {
+	options := command.Options{
+		JobResponseFile: e.jobResponseFile,
+	}
+
+	return commandFactory(ctx, opts.executable, opts.args, e.commandOptions(opts.out), options)
+}
+
+func (e *executor) commandOptions(out commandOutputs) process.CommandOptions {
 	logger := common.NewProcessLoggerAdapter(e.BuildLogger)
 
 	cmdOpts := process.CommandOptions{
 		Dir:                             e.tempDir,
 		Env:                             make([]string, 0),
-		Stdout:                          opts.out.stdout,
-		Stderr:                          opts.out.stderr,
+		Stdout:                          out.stdout,
+		Stderr:                          out.stderr,
 		Logger:                          logger,
 		GracefulKillTimeout:             e.config.GetGracefulKillTimeout(),
 		ForceKillTimeout:                e.config.GetForceKillTimeout(),
@@ -257,11 +302,7 @@ func (e *executor) prepareCommand(ctx context.Context, opts prepareCommandOpts)
 		cmdOpts.Env = append(cmdOpts.Env, fmt.Sprintf("CUSTOM_ENV_%s=%s", variable.Key, variable.Value))
 	}
 
-	options := command.Options{
-		JobResponseFile: e.jobResponseFile,
-	}
-
-	return commandFactory(ctx, opts.executable, opts.args, cmdOpts, options)
+	return cmdOpts
 }
 
 func (e *executor) getCIJobServicesEnv() common.JobVariable {
@@ -310,6 +351,14 @@ func (e *executor) Run(cmd common.ExecutorCommand) error {
 		stage = "build_script"
 	}
 
+	ctx, cancelFunc := e.withStageTimeout(cmd.Context, string(stage))
+	defer cancelFunc()
+
+	if e.driver != nil {
+		err = e.runDriver(ctx, string(stage), cmd.Script, scriptFile)
+		return e.stageError(ctx, cmd.Context, string(stage), err)
+	}
+
 	args := append(e.config.RunArgs, scriptFile, string(stage))
 
 	opts := prepareCommandOpts{
@@ -318,7 +367,9 @@ func (e *executor) Run(cmd common.ExecutorCommand) error {
 		out:        e.defaultCommandOutputs(),
 	}
 
-	return e.prepareCommand(cmd.Context, opts).Run()
+	err = e.prepareCommand(ctx, opts).Run()
+
+	return e.stageError(ctx, cmd.Context, string(stage), err)
 }
 
 func (e *executor) Cleanup() {
@@ -334,6 +385,8 @@ func (e *executor) Cleanup() {
 
 	defer func() { _ = os.RemoveAll(e.tempDir) }()
 
+	e.cleanupDriver()
+
 	// nothing to do, as there's no cleanup_script
 	if e.config.CleanupExec == "" {
 		return
@@ -379,6 +432,7 @@ func init() {
 			AbstractExecutor: executors.AbstractExecutor{
 				ExecutorOptions: options,
 			},
+			declaredFeatures: defaultDeclaredFeatures,
 		}
 	}
 
@@ -387,9 +441,12 @@ func init() {
 		features.Shared = true
 	}
 
-	common.RegisterExecutorProvider("custom", executors.DefaultExecutorProvider{
-		Creator:          creator,
-		FeaturesUpdater:  featuresUpdater,
-		DefaultShellName: options.Shell.Shell,
+	common.RegisterExecutorProvider("custom", executorProvider{
+		DefaultExecutorProvider: executors.DefaultExecutorProvider{
+			Creator:          creator,
+			FeaturesUpdater:  featuresUpdater,
+			DefaultShellName: options.Shell.Shell,
+		},
+		features: defaultDeclaredFeatures,
 	})
 }
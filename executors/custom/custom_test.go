@@ -362,6 +362,40 @@ func TestExecutor_Prepare(t *testing.T) {
 				assert.Contains(t, output, "Using Custom executor with driver test driver...")
 			},
 		},
+		"custom executor set with ConfigExec declaring features": {
+			config: getRunnerConfig(&common.CustomConfig{
+				RunExec:    "bash",
+				ConfigExec: "echo",
+			}),
+			commandStdoutContent: `{
+				"features": {
+					"services": true,
+					"terminal": true,
+					"proxy": true,
+					"shared": false
+				},
+				"stage_timeouts": {"build_script": 60},
+				"failure_reasons": {"3": "out_of_memory_failure"},
+				"terminal_command": ["ssh", "vm"],
+				"proxy_services": [
+					{"name": "build", "host": "10.0.0.2", "ports": [{"number": 80, "protocol": "http"}]}
+				]
+			}`,
+			assertExecutor: func(t *testing.T, e *executor) {
+				assert.Equal(t, map[string]int{"build_script": 60}, e.stageTimeouts)
+				assert.Equal(t, map[int]string{3: "out_of_memory_failure"}, e.failureReasons)
+				assert.Equal(t, []string{"ssh", "vm"}, e.terminalCommand)
+				assert.Contains(t, e.Pool(), "build")
+			},
+			assertBuild: func(t *testing.T, b *common.Build) {
+				assert.True(t, b.ExecutorFeatures.Services)
+				assert.True(t, b.ExecutorFeatures.Terminal)
+				assert.True(t, b.ExecutorFeatures.Proxy)
+				assert.True(t, b.ExecutorFeatures.Session)
+				assert.False(t, b.ExecutorFeatures.Shared)
+				assert.False(t, b.IsSharedEnv())
+			},
+		},
 		"custom executor set with ConfigExec": {
 			config: getRunnerConfig(&common.CustomConfig{
 				RunExec:    "bash",
@@ -0,0 +1,141 @@
+package custom
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/driver"
+)
+
+var driverFactory = driver.Start
+
+// prepareDriver starts the long-lived driver, when configured, and calls its
+// prepare method. The configuration returned by the driver is injected into the
+// executor in the same way as the config_exec output.
+func (e *executor) prepareDriver() error {
+	if e.config.DriverExec == "" {
+		return nil
+	}
+
+	ctx, cancelFunc := context.WithTimeout(e.Context, e.config.GetPrepareExecTimeout())
+	defer cancelFunc()
+
+	// Force refresh of all build variables, ensuring that the up-to-date
+	// environment variables are provided to the driver.
+	e.Build.RefreshAllVariables()
+
+	d, err := driverFactory(
+		ctx,
+		e.config.DriverExec,
+		e.config.DriverArgs,
+		e.commandOptions(e.defaultCommandOutputs()),
+		driver.Options{
+			Transport:       e.config.DriverTransport,
+			StartTimeout:    e.config.GetPrepareExecTimeout(),
+			JobResponseFile: e.jobResponseFile,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	e.driver = d
+
+	params := api.DriverPrepareParams{
+		ProtocolVersion: api.DriverProtocolVersion,
+		JobResponseFile: e.jobResponseFile,
+	}
+
+	result := new(api.DriverPrepareResult)
+
+	err = e.driver.Call(ctx, api.DriverMethodPrepare, params, result, e.Trace)
+	if err != nil {
+		return driverCallError(err)
+	}
+
+	config := &ConfigExecOutput{ConfigExecOutput: result.ConfigExecOutput}
+	config.InjectInto(e)
+
+	return nil
+}
+
+func (e *executor) runDriver(ctx context.Context, stage string, script string, scriptFile string) error {
+	params := api.DriverRunParams{
+		Stage:      stage,
+		Script:     script,
+		ScriptFile: scriptFile,
+	}
+
+	err := e.driver.Call(ctx, api.DriverMethodRun, params, &api.DriverRunResult{}, e.Trace)
+
+	return driverCallError(err)
+}
+
+// cleanupDriver calls the cleanup method of the driver and stops it
+func (e *executor) cleanupDriver() {
+	if e.driver == nil {
+		return
+	}
+
+	defer func() {
+		err := e.driver.Close()
+		if err != nil {
+			e.Warningln("Stopping driver failed:", err)
+		}
+
+		e.driver = nil
+	}()
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), e.config.GetCleanupScriptTimeout())
+	defer cancelFunc()
+
+	stdoutLogger := e.BuildLogger.WithFields(logrus.Fields{"cleanup_std": "out"})
+
+	err := e.driver.Call(
+		ctx,
+		api.DriverMethodCleanup,
+		api.DriverCleanupParams{},
+		nil,
+		stdoutLogger.WriterLevel(logrus.DebugLevel),
+	)
+	if err != nil {
+		e.Warningln("Driver cleanup failed:", err)
+	}
+}
+
+// driverCallError converts the errors returned by the driver into job
+// failures. Runner system failures are returned as they are, which is how
+// the failures of the *_exec executables are reported too.
+func driverCallError(err error) error {
+	var callErr *driver.CallError
+	if !errors.As(err, &callErr) {
+		return err
+	}
+
+	var data api.DriverErrorData
+	if callErr.Data != nil {
+		data = *callErr.Data
+	}
+
+	reason := common.JobFailureReason(data.FailureReason)
+	if reason == "" {
+		reason = common.RunnerSystemFailure
+		if callErr.Code == api.DriverErrorCodeJobFailure {
+			reason = common.ScriptFailure
+		}
+	}
+
+	if reason == common.RunnerSystemFailure {
+		return callErr
+	}
+
+	return &common.BuildError{
+		Inner:         callErr,
+		FailureReason: reason,
+		ExitCode:      data.ExitCode,
+	}
+}
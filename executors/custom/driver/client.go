@@ -0,0 +1,262 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
+)
+
+var ErrDriverClosed = errors.New("driver connection closed")
+
+// Driver is a connection with a long-lived Custom Executor driver
+type Driver interface {
+	Call(ctx context.Context, method string, params interface{}, result interface{}, output io.Writer) error
+	Close() error
+}
+
+type call struct {
+	response chan *api.DriverMessage
+	output   io.Writer
+}
+
+// Client implements the JSON-RPC protocol used to communicate with a
+// long-lived Custom Executor driver.
+type Client struct {
+	writer    io.WriteCloser
+	writeLock sync.Mutex
+
+	lock    sync.Mutex
+	nextID  int64
+	pending map[int64]*call
+
+	done chan struct{}
+	err  error
+
+	cancelTimeout time.Duration
+}
+
+// NewClient creates a client exchanging messages over the given reader and
+// writer. Once the reader is exhausted all the pending calls fail.
+//
+// When a call is canceled, the driver is notified and it has cancelTimeout
+// to finish the call before it's abandoned.
+func NewClient(r io.Reader, w io.WriteCloser, cancelTimeout time.Duration) *Client {
+	c := &Client{
+		writer:        w,
+		pending:       make(map[int64]*call),
+		done:          make(chan struct{}),
+		cancelTimeout: cancelTimeout,
+	}
+
+	go c.read(r)
+
+	return c
+}
+
+// Done is closed when the connection with the driver is lost
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) Call(
+	ctx context.Context,
+	method string,
+	params interface{},
+	result interface{},
+	output io.Writer,
+) error {
+	id, pending := c.register(output)
+	defer c.unregister(id)
+
+	err := c.send(&id, method, params)
+	if err != nil {
+		return fmt.Errorf("sending %s request: %w", method, err)
+	}
+
+	var response *api.DriverMessage
+
+	select {
+	case response = <-pending.response:
+	case <-c.done:
+		// the driver may have responded right before closing the connection
+		select {
+		case response = <-pending.response:
+		default:
+			return fmt.Errorf("%s: %w", method, c.closeErr())
+		}
+	case <-ctx.Done():
+		response, err = c.cancel(id, pending, ctx.Err())
+		if err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+	}
+
+	if response.Error != nil {
+		return &CallError{Method: method, DriverError: *response.Error}
+	}
+
+	if result == nil || len(response.Result) == 0 {
+		return nil
+	}
+
+	err = json.Unmarshal(response.Result, result)
+	if err != nil {
+		return fmt.Errorf("decoding %s result: %w", method, err)
+	}
+
+	return nil
+}
+
+func (c *Client) cancel(id int64, pending *call, ctxErr error) (*api.DriverMessage, error) {
+	err := c.send(nil, api.DriverMethodCancel, api.DriverCancelParams{ID: id})
+	if err != nil {
+		return nil, ctxErr
+	}
+
+	timer := time.NewTimer(c.cancelTimeout)
+	defer timer.Stop()
+
+	select {
+	case response := <-pending.response:
+		// the call was still canceled, even if the driver managed to finish it
+		if response.Error == nil {
+			return nil, ctxErr
+		}
+
+		return response, nil
+	case <-c.done:
+		return nil, ctxErr
+	case <-timer.C:
+		return nil, ctxErr
+	}
+}
+
+func (c *Client) register(output io.Writer) (int64, *call) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.nextID++
+	pending := &call{
+		response: make(chan *api.DriverMessage, 1),
+		output:   output,
+	}
+	c.pending[c.nextID] = pending
+
+	return c.nextID, pending
+}
+
+func (c *Client) unregister(id int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.pending, id)
+}
+
+func (c *Client) get(id int64) *call {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.pending[id]
+}
+
+func (c *Client) send(id *int64, method string, params interface{}) error {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	msg := api.DriverMessage{
+		JSONRPC: api.JSONRPCVersion,
+		ID:      id,
+		Method:  method,
+		Params:  rawParams,
+	}
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	return json.NewEncoder(c.writer).Encode(msg)
+}
+
+func (c *Client) read(r io.Reader) {
+	decoder := json.NewDecoder(r)
+
+	for {
+		var msg api.DriverMessage
+
+		err := decoder.Decode(&msg)
+		if err != nil {
+			c.lock.Lock()
+			c.err = err
+			c.lock.Unlock()
+
+			close(c.done)
+			return
+		}
+
+		c.handle(&msg)
+	}
+}
+
+func (c *Client) handle(msg *api.DriverMessage) {
+	if msg.Method == api.DriverMethodOutput {
+		c.handleOutput(msg)
+		return
+	}
+
+	// requests and notifications other than output aren't
+	// supported from the driver
+	if msg.Method != "" || msg.ID == nil {
+		return
+	}
+
+	pending := c.get(*msg.ID)
+	if pending == nil {
+		return
+	}
+
+	select {
+	case pending.response <- msg:
+	default:
+	}
+}
+
+func (c *Client) handleOutput(msg *api.DriverMessage) {
+	var params api.DriverOutputParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return
+	}
+
+	pending := c.get(params.ID)
+	if pending == nil || pending.output == nil {
+		return
+	}
+
+	_, _ = io.WriteString(pending.output, params.Data)
+}
+
+func (c *Client) closeErr() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.err == nil || errors.Is(c.err, io.EOF) {
+		return ErrDriverClosed
+	}
+
+	return fmt.Errorf("%w: %v", ErrDriverClosed, c.err)
+}
+
+// Close closes the writing side of the connection, which signals the driver
+// that no more calls will be made
+func (c *Client) Close() error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	return c.writer.Close()
+}
@@ -0,0 +1,189 @@
+//go:build !integration
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
+)
+
+type testServer struct {
+	t       *testing.T
+	encoder *json.Encoder
+}
+
+func (s *testServer) send(msg api.DriverMessage) {
+	msg.JSONRPC = api.JSONRPCVersion
+	require.NoError(s.t, s.encoder.Encode(msg))
+}
+
+func (s *testServer) notify(method string, params interface{}) {
+	raw, err := json.Marshal(params)
+	require.NoError(s.t, err)
+
+	s.send(api.DriverMessage{Method: method, Params: raw})
+}
+
+func (s *testServer) respond(id *int64, result interface{}) {
+	raw, err := json.Marshal(result)
+	require.NoError(s.t, err)
+
+	s.send(api.DriverMessage{ID: id, Result: raw})
+}
+
+func newTestClient(t *testing.T, handler func(s *testServer, msg api.DriverMessage)) *Client {
+	requestReader, requestWriter := io.Pipe()
+	responseReader, responseWriter := io.Pipe()
+
+	server := &testServer{t: t, encoder: json.NewEncoder(responseWriter)}
+
+	go func() {
+		defer func() { _ = responseWriter.Close() }()
+
+		decoder := json.NewDecoder(requestReader)
+		for {
+			var msg api.DriverMessage
+			if err := decoder.Decode(&msg); err != nil {
+				return
+			}
+
+			assert.Equal(t, api.JSONRPCVersion, msg.JSONRPC)
+			handler(server, msg)
+		}
+	}()
+
+	c := NewClient(responseReader, requestWriter, time.Second)
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c
+}
+
+func TestClient_Call(t *testing.T) {
+	c := newTestClient(t, func(s *testServer, msg api.DriverMessage) {
+		require.Equal(t, api.DriverMethodRun, msg.Method)
+		require.NotNil(t, msg.ID)
+
+		var params api.DriverRunParams
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+		assert.Equal(t, "build_script", params.Stage)
+
+		s.notify(api.DriverMethodOutput, api.DriverOutputParams{ID: *msg.ID, Data: "line 1\n"})
+		s.notify(api.DriverMethodOutput, api.DriverOutputParams{ID: *msg.ID + 1, Data: "unknown call\n"})
+		s.notify(api.DriverMethodOutput, api.DriverOutputParams{ID: *msg.ID, Data: "line 2\n"})
+		s.respond(msg.ID, map[string]string{"hostname": "driver-host"})
+	})
+
+	output := new(bytes.Buffer)
+	result := new(api.DriverPrepareResult)
+
+	err := c.Call(context.Background(), api.DriverMethodRun, api.DriverRunParams{Stage: "build_script"}, result, output)
+	require.NoError(t, err)
+
+	assert.Equal(t, "line 1\nline 2\n", output.String())
+	require.NotNil(t, result.Hostname)
+	assert.Equal(t, "driver-host", *result.Hostname)
+}
+
+func TestClient_CallError(t *testing.T) {
+	c := newTestClient(t, func(s *testServer, msg api.DriverMessage) {
+		s.send(api.DriverMessage{
+			ID: msg.ID,
+			Error: &api.DriverError{
+				Code:    api.DriverErrorCodeJobFailure,
+				Message: "script failed",
+				Data:    &api.DriverErrorData{FailureReason: "script_failure", ExitCode: 42},
+			},
+		})
+	})
+
+	err := c.Call(context.Background(), api.DriverMethodRun, api.DriverRunParams{}, nil, nil)
+
+	var callErr *CallError
+	require.ErrorAs(t, err, &callErr)
+	assert.Equal(t, api.DriverMethodRun, callErr.Method)
+	assert.Equal(t, api.DriverErrorCodeJobFailure, callErr.Code)
+	require.NotNil(t, callErr.Data)
+	assert.Equal(t, 42, callErr.Data.ExitCode)
+	assert.Contains(t, err.Error(), "script failed")
+}
+
+func TestClient_CallCanceled(t *testing.T) {
+	tests := map[string]struct {
+		respondToCancel bool
+		expectCallError bool
+	}{
+		"driver acknowledges the cancellation": {
+			respondToCancel: true,
+			expectCallError: true,
+		},
+		"driver ignores the cancellation": {},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+
+			canceled := make(chan int64, 1)
+			c := newTestClient(t, func(s *testServer, msg api.DriverMessage) {
+				switch msg.Method {
+				case api.DriverMethodRun:
+					cancel()
+				case api.DriverMethodCancel:
+					var params api.DriverCancelParams
+					require.NoError(t, json.Unmarshal(msg.Params, &params))
+					assert.Nil(t, msg.ID)
+
+					canceled <- params.ID
+
+					if tt.respondToCancel {
+						s.send(api.DriverMessage{
+							ID:    &params.ID,
+							Error: &api.DriverError{Code: api.DriverErrorCodeJobFailure, Message: "canceled"},
+						})
+					}
+				}
+			})
+			c.cancelTimeout = 100 * time.Millisecond
+
+			err := c.Call(ctx, api.DriverMethodRun, api.DriverRunParams{}, nil, nil)
+			require.Error(t, err)
+
+			if tt.expectCallError {
+				var callErr *CallError
+				assert.ErrorAs(t, err, &callErr)
+			} else {
+				assert.ErrorIs(t, err, context.Canceled)
+			}
+
+			assert.Equal(t, int64(1), <-canceled)
+		})
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func TestClient_ConnectionClosed(t *testing.T) {
+	responseReader, responseWriter := io.Pipe()
+	c := NewClient(responseReader, nopWriteCloser{io.Discard}, time.Second)
+	require.NoError(t, responseWriter.Close())
+
+	<-c.Done()
+
+	err := c.Call(context.Background(), api.DriverMethodPrepare, api.DriverPrepareParams{}, nil, nil)
+	assert.ErrorIs(t, err, ErrDriverClosed)
+}
@@ -0,0 +1,17 @@
+package driver
+
+import (
+	"fmt"
+
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
+)
+
+// CallError is returned when the driver responded to a call with an error
+type CallError struct {
+	Method string
+	api.DriverError
+}
+
+func (e *CallError) Error() string {
+	return fmt.Sprintf("custom executor driver %s call failed (code %d): %s", e.Method, e.Code, e.Message)
+}
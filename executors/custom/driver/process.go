@@ -0,0 +1,262 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/process"
+)
+
+const (
+	TransportStdio = "stdio"
+	TransportUnix  = "unix"
+
+	socketFileName = "driver.sock"
+)
+
+var newProcessKillWaiter = process.NewOSKillWait
+var newCommander = process.NewOSCmd
+
+type Options struct {
+	// Transport is either TransportStdio, where the messages are exchanged over
+	// the standard input and output of the driver, or TransportUnix, where the
+	// driver connects to the unix socket passed with the DRIVER_SOCKET variable.
+	Transport string
+
+	StartTimeout    time.Duration
+	JobResponseFile string
+}
+
+// driverProcess is a driver started by Runner for the duration of a job
+type driverProcess struct {
+	*Client
+
+	cmd    process.Commander
+	waitCh chan error
+	conn   io.Closer
+
+	logger process.Logger
+
+	gracefulKillTimeout time.Duration
+	forceKillTimeout    time.Duration
+}
+
+// Start starts the driver executable and connects to it. The working
+// directory of the driver is used for the unix socket.
+func Start(
+	ctx context.Context,
+	executable string,
+	args []string,
+	cmdOpts process.CommandOptions,
+	options Options,
+) (Driver, error) {
+	p := &driverProcess{
+		waitCh:              make(chan error, 1),
+		logger:              cmdOpts.Logger,
+		gracefulKillTimeout: cmdOpts.GracefulKillTimeout,
+		forceKillTimeout:    cmdOpts.ForceKillTimeout,
+	}
+
+	env := append(
+		os.Environ(),
+		fmt.Sprintf("TMPDIR=%s", cmdOpts.Dir),
+		fmt.Sprintf("%s=%s", api.JobResponseFileVariable, options.JobResponseFile),
+	)
+	cmdOpts.Env = append(env, cmdOpts.Env...)
+
+	var err error
+	switch options.Transport {
+	case "", TransportStdio:
+		err = p.startStdio(executable, args, cmdOpts)
+	case TransportUnix:
+		err = p.startUnix(ctx, executable, args, cmdOpts, options.StartTimeout)
+	default:
+		err = fmt.Errorf("unsupported driver transport %q", options.Transport)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *driverProcess) startStdio(executable string, args []string, cmdOpts process.CommandOptions) error {
+	stdinReader, stdinWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("creating driver stdin: %w", err)
+	}
+
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		_ = stdinReader.Close()
+		_ = stdinWriter.Close()
+		return fmt.Errorf("creating driver stdout: %w", err)
+	}
+
+	cmdOpts.Stdin = stdinReader
+	cmdOpts.Stdout = stdoutWriter
+
+	err = p.start(executable, args, cmdOpts)
+
+	// the driver process has its own copy of these ends of the pipes
+	_ = stdinReader.Close()
+	_ = stdoutWriter.Close()
+
+	if err != nil {
+		_ = stdinWriter.Close()
+		_ = stdoutReader.Close()
+		return err
+	}
+
+	p.conn = stdoutReader
+	p.Client = NewClient(stdoutReader, stdinWriter, p.gracefulKillTimeout)
+
+	return nil
+}
+
+func (p *driverProcess) startUnix(
+	ctx context.Context,
+	executable string,
+	args []string,
+	cmdOpts process.CommandOptions,
+	startTimeout time.Duration,
+) error {
+	socketPath := filepath.Join(cmdOpts.Dir, socketFileName)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("listening on driver socket: %w", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	cmdOpts.Env = append(cmdOpts.Env, fmt.Sprintf("%s=%s", api.DriverSocketVariable, socketPath))
+
+	err = p.start(executable, args, cmdOpts)
+	if err != nil {
+		return err
+	}
+
+	conn, err := p.accept(ctx, listener, startTimeout)
+	if err != nil {
+		_ = p.kill()
+		return err
+	}
+
+	p.conn = conn
+	p.Client = NewClient(conn, halfCloser{conn}, p.gracefulKillTimeout)
+
+	return nil
+}
+
+func (p *driverProcess) accept(ctx context.Context, listener net.Listener, timeout time.Duration) (net.Conn, error) {
+	type acceptResult struct {
+		conn net.Conn
+		err  error
+	}
+
+	acceptCh := make(chan acceptResult, 1)
+	go func() {
+		conn, err := listener.Accept()
+		acceptCh <- acceptResult{conn: conn, err: err}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	select {
+	case result := <-acceptCh:
+		if result.err != nil {
+			return nil, fmt.Errorf("accepting driver connection: %w", result.err)
+		}
+
+		return result.conn, nil
+	case err := <-p.waitCh:
+		// let Close() know the process already finished
+		p.waitCh <- err
+		return nil, fmt.Errorf("driver exited before connecting: %w", exitError(err))
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for driver connection: %w", ctx.Err())
+	}
+}
+
+func (p *driverProcess) start(
+	executable string,
+	args []string,
+	cmdOpts process.CommandOptions,
+) error {
+	p.cmd = newCommander(executable, args, cmdOpts)
+
+	err := p.cmd.Start()
+	if err != nil {
+		return fmt.Errorf("failed to start driver: %w", err)
+	}
+
+	go func() {
+		p.waitCh <- p.cmd.Wait()
+	}()
+
+	return nil
+}
+
+// Close closes the connection with the driver and waits for the process to
+// exit. If it doesn't exit within the graceful kill timeout, it's killed.
+func (p *driverProcess) Close() error {
+	_ = p.Client.Close()
+	if p.conn != nil {
+		defer func() { _ = p.conn.Close() }()
+	}
+
+	timer := time.NewTimer(p.gracefulKillTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-p.waitCh:
+		return exitError(err)
+	case <-timer.C:
+		return p.kill()
+	}
+}
+
+func (p *driverProcess) kill() error {
+	return newProcessKillWaiter(p.logger, p.gracefulKillTimeout, p.forceKillTimeout).
+		KillAndWait(p.cmd, p.waitCh)
+}
+
+// halfCloser closes only the writing side of the connection, so that the
+// responses to the pending calls can still be read
+type halfCloser struct {
+	conn net.Conn
+}
+
+func (h halfCloser) Write(p []byte) (int, error) {
+	return h.conn.Write(p)
+}
+
+func (h halfCloser) Close() error {
+	if c, ok := h.conn.(interface{ CloseWrite() error }); ok {
+		return c.CloseWrite()
+	}
+
+	return h.conn.Close()
+}
+
+func exitError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("driver exited with code %d: %w", exitErr.ExitCode(), err)
+	}
+
+	return err
+}
@@ -0,0 +1,214 @@
+//go:build !integration
+
+package custom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/driver"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/process"
+)
+
+type fakeDriverCall struct {
+	method string
+	params interface{}
+}
+
+type fakeDriver struct {
+	calls  []fakeDriverCall
+	closed bool
+
+	results map[string]interface{}
+	errors  map[string]error
+	output  map[string]string
+}
+
+func (d *fakeDriver) Call(
+	_ context.Context,
+	method string,
+	params interface{},
+	result interface{},
+	output io.Writer,
+) error {
+	d.calls = append(d.calls, fakeDriverCall{method: method, params: params})
+
+	if out, ok := d.output[method]; ok && output != nil {
+		_, _ = io.WriteString(output, out)
+	}
+
+	if res, ok := d.results[method]; ok && result != nil {
+		data, err := json.Marshal(res)
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(data, result); err != nil {
+			return err
+		}
+	}
+
+	return d.errors[method]
+}
+
+func (d *fakeDriver) Close() error {
+	d.closed = true
+	return nil
+}
+
+func (d *fakeDriver) methods() []string {
+	methods := make([]string, len(d.calls))
+	for i, call := range d.calls {
+		methods[i] = call.method
+	}
+
+	return methods
+}
+
+func mockDriverFactory(t *testing.T, d *fakeDriver, assertFn func(executable string, options driver.Options)) {
+	oldFactory := driverFactory
+	t.Cleanup(func() { driverFactory = oldFactory })
+
+	driverFactory = func(
+		ctx context.Context,
+		executable string,
+		args []string,
+		cmdOpts process.CommandOptions,
+		options driver.Options,
+	) (driver.Driver, error) {
+		if assertFn != nil {
+			assertFn(executable, options)
+		}
+
+		return d, nil
+	}
+}
+
+func TestExecutor_Driver(t *testing.T) {
+	config := getRunnerConfig(&common.CustomConfig{
+		DriverExec:      "driver",
+		DriverTransport: driver.TransportUnix,
+	})
+
+	d := &fakeDriver{
+		results: map[string]interface{}{
+			api.DriverMethodPrepare: map[string]interface{}{
+				"hostname": "driver-host",
+				"driver":   map[string]string{"name": "test driver", "version": "v1"},
+			},
+		},
+		output: map[string]string{
+			api.DriverMethodPrepare: "preparing environment\n",
+			api.DriverMethodRun:     "running stage\n",
+		},
+	}
+
+	mockDriverFactory(t, d, func(executable string, options driver.Options) {
+		assert.Equal(t, "driver", executable)
+		assert.Equal(t, driver.TransportUnix, options.Transport)
+		assert.NotEmpty(t, options.JobResponseFile)
+	})
+
+	e, options, out := prepareExecutor(t, executorTestCase{config: config})
+
+	require.NoError(t, e.Prepare(options))
+	assert.Equal(t, "driver-host", e.Build.Hostname)
+	assert.Contains(t, out.String(), "Using Custom executor with driver test driver v1...")
+	assert.Contains(t, out.String(), "preparing environment")
+
+	err := e.Run(common.ExecutorCommand{
+		Context: context.Background(),
+		Script:  "echo test",
+		Stage:   "step_script",
+	})
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "running stage")
+
+	require.Len(t, d.calls, 2)
+	runParams, ok := d.calls[1].params.(api.DriverRunParams)
+	require.True(t, ok)
+	assert.Equal(t, "build_script", runParams.Stage)
+	assert.Equal(t, "echo test", runParams.Script)
+	assert.FileExists(t, runParams.ScriptFile)
+
+	e.Cleanup()
+
+	assert.Equal(
+		t,
+		[]string{api.DriverMethodPrepare, api.DriverMethodRun, api.DriverMethodCleanup},
+		d.methods(),
+	)
+	assert.True(t, d.closed)
+
+	prepareParams, ok := d.calls[0].params.(api.DriverPrepareParams)
+	require.True(t, ok)
+	assert.Equal(t, api.DriverProtocolVersion, prepareParams.ProtocolVersion)
+}
+
+func TestDriverCallError(t *testing.T) {
+	genericErr := errors.New("generic error")
+
+	callErr := func(code int, data *api.DriverErrorData) error {
+		return &driver.CallError{
+			Method:      api.DriverMethodRun,
+			DriverError: api.DriverError{Code: code, Message: "failed", Data: data},
+		}
+	}
+
+	tests := map[string]struct {
+		err              error
+		expectedBuildErr *common.BuildError
+	}{
+		"no error": {},
+		"generic error": {
+			err: genericErr,
+		},
+		"job failure without reason": {
+			err: callErr(api.DriverErrorCodeJobFailure, &api.DriverErrorData{ExitCode: 3}),
+			expectedBuildErr: &common.BuildError{
+				FailureReason: common.ScriptFailure,
+				ExitCode:      3,
+			},
+		},
+		"job failure with reason": {
+			err: callErr(api.DriverErrorCodeJobFailure, &api.DriverErrorData{
+				FailureReason: string(common.JobExecutionTimeout),
+			}),
+			expectedBuildErr: &common.BuildError{
+				FailureReason: common.JobExecutionTimeout,
+			},
+		},
+		"internal error": {
+			err: callErr(api.DriverErrorCodeInternalError, nil),
+		},
+		"system failure reason": {
+			err: callErr(api.DriverErrorCodeJobFailure, &api.DriverErrorData{
+				FailureReason: string(common.RunnerSystemFailure),
+			}),
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			err := driverCallError(tt.err)
+			if tt.expectedBuildErr == nil {
+				assert.Equal(t, tt.err, err)
+				return
+			}
+
+			var buildErr *common.BuildError
+			require.ErrorAs(t, err, &buildErr)
+			assert.Equal(t, tt.expectedBuildErr.FailureReason, buildErr.FailureReason)
+			assert.Equal(t, tt.expectedBuildErr.ExitCode, buildErr.ExitCode)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
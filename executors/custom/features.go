@@ -0,0 +1,195 @@
+package custom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/command"
+)
+
+// executorProvider reports the features declared by the driver of each
+// runner on top of the default features of the custom executor.
+type executorProvider struct {
+	executors.DefaultExecutorProvider
+	features *declaredFeatures
+}
+
+func (p executorProvider) GetRunnerFeatures(config *common.RunnerConfig, features *common.FeaturesInfo) {
+	p.features.apply(config.Token, features)
+}
+
+func (p executorProvider) Reconfigure(change common.RunnerConfigChange) error {
+	if change.Previous != nil && (change.Current == nil || change.Current.Token != change.Previous.Token) {
+		p.features.remove(change.Previous.Token)
+	}
+
+	return nil
+}
+
+// declaredFeatures stores the features declared by the latest config_exec
+// output of each runner, by token, so that they can be reported when the
+// runner requests new jobs
+type declaredFeatures struct {
+	lock     sync.Mutex
+	features map[string]*api.FeaturesInfo
+}
+
+func (d *declaredFeatures) set(token string, features *api.FeaturesInfo) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.features == nil {
+		d.features = make(map[string]*api.FeaturesInfo)
+	}
+
+	d.features[token] = features
+}
+
+func (d *declaredFeatures) remove(token string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	delete(d.features, token)
+}
+
+func (d *declaredFeatures) apply(token string, features *common.FeaturesInfo) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	applyDeclaredFeatures(features, d.features[token])
+}
+
+func applyDeclaredFeatures(features *common.FeaturesInfo, declared *api.FeaturesInfo) {
+	if declared == nil {
+		return
+	}
+
+	if declared.Services != nil {
+		features.Services = *declared.Services
+	}
+
+	if declared.Terminal != nil {
+		features.Terminal = *declared.Terminal
+		features.Session = features.Session || *declared.Terminal
+	}
+
+	if declared.Proxy != nil {
+		features.Proxy = *declared.Proxy
+		features.Session = features.Session || *declared.Proxy
+	}
+
+	if declared.Shared != nil {
+		features.Shared = *declared.Shared
+	}
+}
+
+var defaultDeclaredFeatures = new(declaredFeatures)
+
+// applyFeatures honours the features declared by the driver for the job
+func (e *executor) applyFeatures(features *api.FeaturesInfo) {
+	if features == nil {
+		return
+	}
+
+	if features.Terminal != nil && *features.Terminal && len(e.terminalCommand) == 0 {
+		e.Warningln("The driver declared the terminal feature without a terminal_command. The terminal is disabled.")
+		disabled := false
+		features.Terminal = &disabled
+	}
+
+	if features.Proxy != nil && *features.Proxy && len(e.ProxyPool) == 0 {
+		e.Warningln("The driver declared the proxy feature without proxy_services. The proxy is disabled.")
+		disabled := false
+		features.Proxy = &disabled
+	}
+
+	if features.Services != nil && !*features.Services && len(e.Build.Services) > 0 {
+		e.Warningln("The driver doesn't support services. The services defined for the job are ignored.")
+	}
+
+	applyDeclaredFeatures(&e.Build.ExecutorFeatures, features)
+
+	if e.declaredFeatures != nil {
+		e.declaredFeatures.set(e.Config.Token, features)
+	}
+}
+
+// setMaskedValues adds the values declared by the driver to the values
+// masked in the job log
+func (e *executor) setMaskedValues() {
+	if len(e.maskedValues) == 0 {
+		return
+	}
+
+	e.Trace.SetMasked(common.MaskOptions{
+		Phrases:       append(e.Build.GetAllVariables().Masked(), e.maskedValues...),
+		TokenPrefixes: e.Build.JobResponse.Features.TokenMaskPrefixes,
+	})
+}
+
+// withStageTimeout returns the context used to run the stage, limited by
+// the timeout declared by the driver for it
+func (e *executor) withStageTimeout(ctx context.Context, stage string) (context.Context, context.CancelFunc) {
+	timeout, ok := e.stageTimeouts[stage]
+	if !ok || timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+}
+
+// stageError converts the error of a stage that timed out, or exited with
+// one of the exit codes mapped by the driver, into a job failure
+func (e *executor) stageError(ctx context.Context, parent context.Context, stage string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+		return &common.BuildError{
+			Inner:         fmt.Errorf("stage %s timed out after %ds: %w", stage, e.stageTimeouts[stage], err),
+			FailureReason: common.JobExecutionTimeout,
+		}
+	}
+
+	return e.mapFailureReason(err)
+}
+
+func (e *executor) mapFailureReason(err error) error {
+	if err == nil || len(e.failureReasons) == 0 {
+		return err
+	}
+
+	var buildErr *common.BuildError
+	var unknownErr *command.ErrUnknownFailure
+	var exitErr interface{ ExitCode() int }
+
+	exitCode := 0
+	switch {
+	case errors.As(err, &buildErr):
+		exitCode = buildErr.ExitCode
+	case errors.As(err, &unknownErr):
+		exitCode = unknownErr.ExitCode
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+	default:
+		return err
+	}
+
+	reason, ok := e.failureReasons[exitCode]
+	if !ok {
+		return err
+	}
+
+	return &common.BuildError{
+		Inner:         err,
+		FailureReason: common.JobFailureReason(reason),
+		ExitCode:      exitCode,
+	}
+}
@@ -0,0 +1,309 @@
+//go:build !integration
+
+package custom
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/command"
+)
+
+func TestExecutorProvider_GetRunnerFeatures(t *testing.T) {
+	enabled := true
+	disabled := false
+
+	provider := executorProvider{
+		DefaultExecutorProvider: executors.DefaultExecutorProvider{
+			FeaturesUpdater: func(features *common.FeaturesInfo) {
+				features.Variables = true
+				features.Shared = true
+			},
+		},
+		features: new(declaredFeatures),
+	}
+
+	first := &common.RunnerConfig{RunnerCredentials: common.RunnerCredentials{Token: "first-token"}}
+	second := &common.RunnerConfig{RunnerCredentials: common.RunnerCredentials{Token: "second-token"}}
+
+	features := common.FeaturesInfo{}
+	require.NoError(t, common.GetRunnerFeatures(provider, first, &features))
+	assert.Equal(t, common.FeaturesInfo{Variables: true, Shared: true}, features)
+
+	provider.features.set(first.Token, &api.FeaturesInfo{
+		Services: &enabled,
+		Terminal: &enabled,
+		Shared:   &disabled,
+	})
+	provider.features.set(second.Token, &api.FeaturesInfo{
+		Proxy: &enabled,
+	})
+
+	features = common.FeaturesInfo{}
+	require.NoError(t, common.GetRunnerFeatures(provider, first, &features))
+	assert.Equal(t, common.FeaturesInfo{
+		Variables: true,
+		Services:  true,
+		Terminal:  true,
+		Session:   true,
+	}, features)
+
+	features = common.FeaturesInfo{}
+	require.NoError(t, common.GetRunnerFeatures(provider, second, &features))
+	assert.Equal(t, common.FeaturesInfo{
+		Variables: true,
+		Shared:    true,
+		Proxy:     true,
+		Session:   true,
+	}, features)
+
+	features = common.FeaturesInfo{}
+	require.NoError(t, provider.GetFeatures(&features))
+	assert.Equal(t, common.FeaturesInfo{Variables: true, Shared: true}, features, "no runner has no declared features")
+
+	require.NoError(t, provider.Reconfigure(common.RunnerConfigChange{Previous: first}))
+
+	features = common.FeaturesInfo{}
+	require.NoError(t, common.GetRunnerFeatures(provider, first, &features))
+	assert.Equal(t, common.FeaturesInfo{Variables: true, Shared: true}, features)
+}
+
+func TestExecutor_ApplyFeatures(t *testing.T) {
+	enabled := true
+
+	newExecutor := func(token string, features *declaredFeatures) *executor {
+		e := &executor{
+			AbstractExecutor: executors.AbstractExecutor{
+				Build: &common.Build{},
+			},
+			declaredFeatures: features,
+		}
+		e.Config.Token = token
+
+		return e
+	}
+
+	declared := new(declaredFeatures)
+
+	e := newExecutor("first-token", declared)
+	e.applyFeatures(&api.FeaturesInfo{
+		Services: &enabled,
+		Terminal: &enabled,
+		Proxy:    &enabled,
+	})
+
+	// terminal and proxy require the terminal command and proxy services
+	assert.True(t, e.Build.ExecutorFeatures.Services)
+	assert.False(t, e.Build.ExecutorFeatures.Terminal)
+	assert.False(t, e.Build.ExecutorFeatures.Proxy)
+
+	other := newExecutor("second-token", declared)
+	other.applyFeatures(&api.FeaturesInfo{})
+
+	features := common.FeaturesInfo{}
+	declared.apply("first-token", &features)
+	assert.Equal(t, common.FeaturesInfo{Services: true}, features)
+
+	features = common.FeaturesInfo{}
+	declared.apply("second-token", &features)
+	assert.Equal(t, common.FeaturesInfo{}, features)
+}
+
+func TestExecutor_SetMaskedValues(t *testing.T) {
+	trace := common.NewMockJobTrace(t)
+	trace.On("SetMasked", mock.MatchedBy(func(opts common.MaskOptions) bool {
+		return assert.Contains(t, opts.Phrases, "masked-variable") &&
+			assert.Contains(t, opts.Phrases, "driver-secret") &&
+			assert.Equal(t, []string{"glpat-"}, opts.TokenPrefixes)
+	})).Once()
+
+	e := &executor{
+		AbstractExecutor: executors.AbstractExecutor{
+			Build: &common.Build{
+				JobResponse: common.JobResponse{
+					Variables: common.JobVariables{
+						{Key: "SECRET", Value: "masked-variable", Masked: true},
+					},
+					Features: common.GitlabFeatures{TokenMaskPrefixes: []string{"glpat-"}},
+				},
+				Runner: &common.RunnerConfig{},
+			},
+			Trace: trace,
+		},
+	}
+
+	// nothing to mask
+	e.setMaskedValues()
+
+	e.maskedValues = []string{"driver-secret"}
+	e.setMaskedValues()
+}
+
+func TestExecutor_StageError(t *testing.T) {
+	exitErr := &command.ErrUnknownFailure{Inner: errors.New("exit status 3"), ExitCode: 3}
+
+	expiredCtx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-expiredCtx.Done()
+
+	tests := map[string]struct {
+		ctx            context.Context
+		parent         context.Context
+		err            error
+		failureReasons map[int]string
+		expectedReason common.JobFailureReason
+		expectedSame   bool
+	}{
+		"no error": {
+			ctx:    context.Background(),
+			parent: context.Background(),
+		},
+		"error without mapping": {
+			ctx:          context.Background(),
+			parent:       context.Background(),
+			err:          exitErr,
+			expectedSame: true,
+		},
+		"mapped unknown exit code": {
+			ctx:            context.Background(),
+			parent:         context.Background(),
+			err:            exitErr,
+			failureReasons: map[int]string{3: string(common.OutOfMemoryFailure)},
+			expectedReason: common.OutOfMemoryFailure,
+		},
+		"mapped build failure exit code": {
+			ctx:            context.Background(),
+			parent:         context.Background(),
+			err:            &common.BuildError{ExitCode: 1},
+			failureReasons: map[int]string{1: string(common.ImagePullFailure)},
+			expectedReason: common.ImagePullFailure,
+		},
+		"not mapped exit code": {
+			ctx:            context.Background(),
+			parent:         context.Background(),
+			err:            exitErr,
+			failureReasons: map[int]string{4: string(common.OutOfMemoryFailure)},
+			expectedSame:   true,
+		},
+		"stage timed out": {
+			ctx:            expiredCtx,
+			parent:         context.Background(),
+			err:            exitErr,
+			expectedReason: common.JobExecutionTimeout,
+		},
+		"job timed out": {
+			ctx:          expiredCtx,
+			parent:       expiredCtx,
+			err:          exitErr,
+			expectedSame: true,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			e := &executor{failureReasons: tt.failureReasons}
+
+			err := e.stageError(tt.ctx, tt.parent, "build_script", tt.err)
+			if tt.err == nil || tt.expectedSame {
+				assert.Equal(t, tt.err, err)
+				return
+			}
+
+			var buildErr *common.BuildError
+			require.ErrorAs(t, err, &buildErr)
+			assert.Equal(t, tt.expectedReason, buildErr.FailureReason)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestExecutor_WithStageTimeout(t *testing.T) {
+	e := &executor{stageTimeouts: map[string]int{"build_script": 60}}
+
+	ctx, cancel := e.withStageTimeout(context.Background(), "build_script")
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 5*time.Second)
+
+	ctx, cancel = e.withStageTimeout(context.Background(), "after_script")
+	defer cancel()
+
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+}
+
+func TestExecutor_ProxyRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("proxied " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	srvURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	port, err := strconv.Atoi(srvURL.Port())
+	require.NoError(t, err)
+
+	e := new(executor)
+	e.setProxyServices([]api.ProxyService{
+		{
+			Name:  "build",
+			Host:  srvURL.Hostname(),
+			Ports: []api.ProxyPort{{Number: port, Protocol: "http", Name: "web"}},
+		},
+	})
+
+	pool := e.Pool()
+	require.Contains(t, pool, "build")
+
+	tests := map[string]struct {
+		port           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		"by port name": {
+			port:           "web",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "proxied /some/path",
+		},
+		"by port number": {
+			port:           srvURL.Port(),
+			expectedStatus: http.StatusOK,
+			expectedBody:   "proxied /some/path",
+		},
+		"unknown port": {
+			port:           "unknown",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/session/proxy/build/"+tt.port+"/some/path", nil)
+
+			proxy := pool["build"]
+			proxy.ConnectionHandler.ProxyRequest(w, r, "some/path", tt.port, proxy.Settings)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
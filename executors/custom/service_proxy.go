@@ -0,0 +1,98 @@
+package custom
+
+import (
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
+	"gitlab.com/gitlab-org/gitlab-runner/session/proxy"
+)
+
+func (e *executor) Pool() proxy.Pool {
+	return e.ProxyPool
+}
+
+// setProxyServices registers the services declared by the driver in the
+// pool used by the session server proxy
+func (e *executor) setProxyServices(services []api.ProxyService) {
+	e.ProxyPool = proxy.NewPool()
+	e.proxyServices = make(map[string]api.ProxyService, len(services))
+
+	for _, service := range services {
+		ports := make([]proxy.Port, 0, len(service.Ports))
+		for _, port := range service.Ports {
+			ports = append(ports, proxy.Port{
+				Number:   port.Number,
+				Protocol: port.Protocol,
+				Name:     port.Name,
+			})
+		}
+
+		e.proxyServices[service.Name] = service
+		e.ProxyPool[service.Name] = &proxy.Proxy{
+			Settings:          proxy.NewProxySettings(service.Name, ports),
+			ConnectionHandler: e,
+		}
+	}
+}
+
+func (e *executor) ProxyRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+	requestedURI string,
+	port string,
+	settings *proxy.Settings,
+) {
+	logger := logrus.WithFields(logrus.Fields{
+		"uri":      r.RequestURI,
+		"method":   r.Method,
+		"port":     port,
+		"settings": settings,
+	})
+
+	portSettings, err := settings.PortByNameOrNumber(port)
+	if err != nil {
+		logger.WithError(err).Errorf("port proxy %q not found", port)
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	scheme, err := portSettings.Scheme()
+	if err != nil {
+		logger.WithError(err).Errorf("port proxy %q has an invalid scheme", port)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	service, ok := e.proxyServices[settings.ServiceName]
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	target := &url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(service.Host, strconv.Itoa(portSettings.Number)),
+	}
+
+	reverseProxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = target.Scheme
+			req.URL.Host = target.Host
+			req.URL.Path = "/" + requestedURI
+			req.URL.RawPath = ""
+			req.Host = target.Host
+		},
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			logger.WithError(err).Errorln("service proxy: error proxying request")
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		},
+	}
+
+	reverseProxy.ServeHTTP(w, r)
+}
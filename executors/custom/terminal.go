@@ -4,10 +4,60 @@ package custom
 
 import (
 	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+
+	"github.com/creack/pty"
+	terminal "gitlab.com/gitlab-org/gitlab-terminal"
 
 	terminalsession "gitlab.com/gitlab-org/gitlab-runner/session/terminal"
 )
 
+type terminalConn struct {
+	fd  *os.File
+	cmd *exec.Cmd
+}
+
+func (t terminalConn) Start(w http.ResponseWriter, r *http.Request, timeoutCh, disconnectCh chan error) {
+	proxy := terminal.NewFileDescriptorProxy(1) // one stopper: terminal exit handler
+
+	terminalsession.ProxyTerminal(
+		timeoutCh,
+		disconnectCh,
+		proxy.StopCh,
+		func() {
+			terminal.ProxyFileDescriptor(w, r, t.fd, proxy)
+		},
+	)
+}
+
+func (t terminalConn) Close() error {
+	err := t.fd.Close()
+	if t.cmd.Process != nil {
+		_ = t.cmd.Process.Kill()
+		_ = t.cmd.Wait()
+	}
+
+	return err
+}
+
+// Connect starts the terminal_command declared by the driver
 func (e *executor) Connect() (terminalsession.Conn, error) {
-	return nil, errors.New("not yet supported")
+	if len(e.terminalCommand) == 0 {
+		return nil, errors.New("not yet supported")
+	}
+
+	cmdOpts := e.commandOptions(e.defaultCommandOutputs())
+
+	cmd := exec.Command(e.terminalCommand[0], e.terminalCommand[1:]...)
+	cmd.Dir = cmdOpts.Dir
+	cmd.Env = append(os.Environ(), cmdOpts.Env...)
+
+	fd, err := pty.Start(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return terminalConn{fd: fd, cmd: cmd}, nil
 }
@@ -0,0 +1,145 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/volume"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/buildkit"
+)
+
+const labelBuildKitType = "buildkit"
+
+// buildKitSecurityOpt are the options the rootless daemon needs to create
+// the namespaces and mounts of the build steps, without being privileged
+var buildKitSecurityOpt = []string{"seccomp=unconfined", "apparmor=unconfined"}
+
+// createBuildKit starts the rootless BuildKit sidecar requested by the job.
+// The daemon doesn't authenticate its clients, so it isn't reachable over the
+// network: the build container connects to its socket through a volume only
+// the two containers share.
+func (e *executor) createBuildKit() error {
+	sidecar, err := buildkit.Resolve(e.Build.GetAllVariables(), e.Config.Docker.BuildKit, e.BuildLogger)
+	if err != nil || sidecar == nil {
+		return err
+	}
+
+	if e.info.OSType == osTypeWindows {
+		return fmt.Errorf("the BuildKit sidecar isn't supported on Windows containers")
+	}
+
+	e.Println("Starting BuildKit sidecar", sidecar.Image, "...")
+	image, err := e.pullManager.GetDockerImage(sidecar.Image, common.ImageDockerOptions{}, nil)
+	if err != nil {
+		return err
+	}
+
+	socketBind, err := e.createBuildKitSocketVolume()
+	if err != nil {
+		return err
+	}
+
+	binds, err := e.createBuildKitCache()
+	if err != nil {
+		return err
+	}
+	binds = append(binds, socketBind)
+
+	containerName := e.getProjectUniqRandomizedName() + "-buildkit"
+
+	// this will fail potentially some builds if there's name collision
+	_ = e.removeContainer(e.Context, containerName)
+
+	config := &container.Config{
+		Image:  image.ID,
+		Labels: e.labeler.Labels(map[string]string{"type": labelBuildKitType}),
+		Cmd:    sidecar.Args(),
+	}
+
+	hostConfig := &container.HostConfig{
+		DNS:           e.Config.Docker.DNS,
+		DNSSearch:     e.Config.Docker.DNSSearch,
+		RestartPolicy: neverRestartPolicy,
+		ExtraHosts:    e.Config.Docker.ExtraHosts,
+		SecurityOpt:   buildKitSecurityOpt,
+		UsernsMode:    container.UsernsMode(e.Config.Docker.UsernsMode),
+		NetworkMode:   e.networkMode,
+		Binds:         binds,
+		LogConfig: container.LogConfig{
+			Type: "json-file",
+		},
+	}
+
+	e.Debugln("Creating BuildKit container", containerName, "...")
+	resp, err := e.client.ContainerCreate(
+		e.Context,
+		config,
+		hostConfig,
+		e.networkConfig(nil),
+		nil,
+		containerName,
+	)
+	if err != nil {
+		return err
+	}
+
+	e.temporary = append(e.temporary, resp.ID)
+
+	e.Debugln(fmt.Sprintf("Starting BuildKit container %s (%s)...", containerName, resp.ID))
+	err = e.client.ContainerStart(e.Context, resp.ID, types.ContainerStartOptions{})
+	if err != nil {
+		return err
+	}
+
+	e.Build.Variables = append(e.Build.Variables, common.JobVariable{
+		Key:    buildkit.HostVariable,
+		Value:  buildkit.Host(),
+		Public: true,
+	})
+	e.Build.RefreshAllVariables()
+
+	return nil
+}
+
+// createBuildKitSocketVolume creates the temporary volume mounted in the build
+// containers to expose the daemon's socket, and returns its binding in the
+// sidecar
+func (e *executor) createBuildKitSocketVolume() (string, error) {
+	err := e.volumesManager.CreateTemporary(e.Context, buildkit.SocketDir)
+	if err != nil {
+		return "", fmt.Errorf("creating BuildKit socket volume: %w", err)
+	}
+
+	for _, bind := range e.volumesManager.Binds() {
+		if source, ok := strings.CutSuffix(bind, ":"+buildkit.SocketDir); ok {
+			return source + ":" + buildkit.RuntimeDir, nil
+		}
+	}
+
+	return "", errors.New("creating BuildKit socket volume: volume binding not found")
+}
+
+// createBuildKitCache creates the volume persisting the layer cache of the
+// sidecar across the jobs of the project, unless the cache is disabled
+func (e *executor) createBuildKitCache() ([]string, error) {
+	if e.Config.Docker.DisableCache {
+		return nil, nil
+	}
+
+	v, err := e.client.VolumeCreate(e.Context, volume.CreateOptions{
+		Name:   e.Build.ProjectUniqueName() + "-cache-buildkit",
+		Labels: e.labeler.Labels(map[string]string{"type": "cache"}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating BuildKit cache volume: %w", err)
+	}
+
+	e.Debugln(fmt.Sprintf("Using volume %q as BuildKit cache...", v.Name))
+
+	return []string{v.Name + ":" + buildkit.StateDir}, nil
+}
@@ -0,0 +1,154 @@
+//go:build !integration
+
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/volume"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/pull"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/volumes"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/buildkit"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+)
+
+func TestCreateBuildKit(t *testing.T) {
+	const (
+		cacheVolume  = "runner-abcdef12-project-0-concurrent-0-cache-buildkit"
+		socketVolume = "runner-abcdef12-project-0-concurrent-0-tmp-cache-socket"
+	)
+
+	requested := common.JobVariables{{Key: buildkit.EnableVariable, Value: "true"}}
+
+	tests := map[string]struct {
+		variables     common.JobVariables
+		config        *common.BuildKitConfig
+		disableCache  bool
+		networkMode   container.NetworkMode
+		expectStarted bool
+		expectedBinds []string
+		expectedErr   error
+	}{
+		"not requested": {
+			config: &common.BuildKitConfig{Enabled: true},
+		},
+		"not enabled": {
+			variables:   requested,
+			expectedErr: buildkit.ErrNotEnabled,
+		},
+		"default network": {
+			variables: requested,
+			config:    &common.BuildKitConfig{Enabled: true},
+			expectedBinds: []string{
+				cacheVolume + ":" + buildkit.StateDir,
+				socketVolume + ":" + buildkit.RuntimeDir,
+			},
+			expectStarted: true,
+		},
+		"per-build network": {
+			variables:   requested,
+			config:      &common.BuildKitConfig{Enabled: true},
+			networkMode: "runner-net",
+			expectedBinds: []string{
+				cacheVolume + ":" + buildkit.StateDir,
+				socketVolume + ":" + buildkit.RuntimeDir,
+			},
+			expectStarted: true,
+		},
+		"cache disabled": {
+			variables:     requested,
+			config:        &common.BuildKitConfig{Enabled: true},
+			disableCache:  true,
+			expectedBinds: []string{socketVolume + ":" + buildkit.RuntimeDir},
+			expectStarted: true,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			c := docker.NewMockClient(t)
+			p := pull.NewMockManager(t)
+			vm := volumes.NewMockManager(t)
+
+			e := &executor{
+				client:         c,
+				pullManager:    p,
+				volumesManager: vm,
+				networkMode:    tt.networkMode,
+			}
+			e.Context = context.Background()
+			e.Config.Docker = &common.DockerConfig{BuildKit: tt.config, DisableCache: tt.disableCache}
+			e.Build = &common.Build{Runner: &common.RunnerConfig{}}
+			e.Build.Runner.Token = "abcdef1234567890"
+			e.Build.Variables = tt.variables
+			require.NoError(t, e.createLabeler())
+
+			if tt.expectStarted {
+				p.On("GetDockerImage", buildkit.DefaultImage, common.ImageDockerOptions{}, []common.DockerPullPolicy(nil)).
+					Return(&types.ImageInspect{ID: "buildkit-image"}, nil).
+					Once()
+
+				vm.On("CreateTemporary", e.Context, buildkit.SocketDir).Return(nil).Once()
+				vm.On("Binds").Return([]string{"/builds:/builds", socketVolume + ":" + buildkit.SocketDir}).Once()
+
+				if !tt.disableCache {
+					c.On("VolumeCreate", e.Context, mock.MatchedBy(func(o volume.CreateOptions) bool {
+						return o.Name == cacheVolume
+					})).
+						Return(volume.Volume{Name: cacheVolume}, nil).
+						Once()
+				}
+
+				c.On("NetworkList", e.Context, mock.Anything).Return(nil, nil).Once()
+				c.On("ContainerRemove", e.Context, mock.Anything, mock.Anything).Return(nil).Once()
+				c.On(
+					"ContainerCreate",
+					e.Context,
+					mock.MatchedBy(func(config *container.Config) bool {
+						return assert.Equal(t, "buildkit-image", config.Image) &&
+							assert.Equal(t, "buildkit", config.Labels["com.gitlab.gitlab-runner.type"]) &&
+							assert.Contains(t, config.Cmd, "--oci-worker-no-process-sandbox")
+					}),
+					mock.MatchedBy(func(hostConfig *container.HostConfig) bool {
+						return assert.False(t, hostConfig.Privileged) &&
+							assert.Equal(t, buildKitSecurityOpt, hostConfig.SecurityOpt) &&
+							assert.Equal(t, tt.expectedBinds, hostConfig.Binds)
+					}),
+					mock.AnythingOfType("*network.NetworkingConfig"),
+					(*v1.Platform)(nil),
+					mock.Anything,
+				).
+					Return(container.CreateResponse{ID: "buildkit-id"}, nil).
+					Once()
+				c.On("ContainerStart", e.Context, "buildkit-id", types.ContainerStartOptions{}).Return(nil).Once()
+			}
+
+			err := e.createBuildKit()
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Empty(t, e.links)
+
+			if !tt.expectStarted {
+				assert.Empty(t, e.temporary)
+				assert.Empty(t, e.Build.GetAllVariables().Get(buildkit.HostVariable))
+				return
+			}
+
+			assert.Equal(t, []string{"buildkit-id"}, e.temporary)
+			assert.Equal(t, "unix:///run/buildkit/buildkitd.sock", e.Build.GetAllVariables().Get(buildkit.HostVariable))
+		})
+	}
+}
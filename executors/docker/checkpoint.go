@@ -0,0 +1,407 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+)
+
+const (
+	failureCheckpointModeArtifact = "artifact"
+	failureCheckpointModeKeep     = "keep"
+
+	// failureCheckpointDir is the directory, relative to the project
+	// directory, receiving the file changes of the build container
+	failureCheckpointDir          = ".gitlab-runner-checkpoint"
+	failureCheckpointArchive      = "container-diff.tar"
+	failureCheckpointChanges      = "changes.txt"
+	failureCheckpointArtifactName = "failure-checkpoint"
+
+	// failureCheckpointContainerType is the type of the container started
+	// from the committed build container in the keep mode
+	failureCheckpointContainerType = "checkpoint"
+)
+
+var (
+	errFailureCheckpointTooLarge = errors.New("the file changes exceed the max_size of the failure checkpoint")
+
+	failureCheckpointPollInterval = time.Second
+)
+
+// checkpointFailure preserves the build container after a failure of the job
+// script. It's best effort: an error is reported in the job log, but doesn't
+// change the result of the job.
+func (s *commandExecutor) checkpointFailure(stage common.BuildStage, containerID string) {
+	if stage == common.BuildStageAfterScript || s.Context.Err() != nil {
+		return
+	}
+
+	var err error
+	switch mode := s.Config.Docker.FailureCheckpoint.GetMode(); mode {
+	case "":
+		return
+	case failureCheckpointModeArtifact:
+		err = s.exportContainerDiff(containerID)
+	case failureCheckpointModeKeep:
+		err = s.keepContainerForTerminal(containerID)
+	default:
+		err = fmt.Errorf("unsupported mode %q", mode)
+	}
+
+	if err != nil {
+		s.Warningln("Failed to checkpoint the build container:", err)
+	}
+}
+
+// exportContainerDiff stores the files added or changed in the build container
+// in the project directory and adds them to the artifacts uploaded on failure.
+// The files of the volumes, like the project directory itself, aren't part of
+// the changes.
+func (e *executor) exportContainerDiff(containerID string) error {
+	maxSize, err := e.Config.Docker.FailureCheckpoint.GetMaxSize()
+	if err != nil {
+		return err
+	}
+
+	changes, err := e.client.ContainerDiff(e.Context, containerID)
+	if err != nil {
+		return fmt.Errorf("listing the changes: %w", err)
+	}
+
+	archive, err := os.CreateTemp("", "container-diff")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = archive.Close()
+		_ = os.Remove(archive.Name())
+	}()
+
+	list := new(bytes.Buffer)
+	size, err := e.writeContainerDiff(archive, list, containerID, changes, maxSize)
+	if err != nil {
+		return err
+	}
+
+	e.Println(fmt.Sprintf(
+		"Exported %d changes (%d bytes) of the build container to %s",
+		len(changes), size, path.Join(failureCheckpointDir, failureCheckpointArchive),
+	))
+
+	archiveSize, err := archive.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	content, writer := io.Pipe()
+	go func() {
+		_ = writer.CloseWithError(writeCheckpointArchive(writer, list, archive, archiveSize))
+	}()
+	defer func() { _ = content.Close() }()
+
+	err = e.client.CopyToContainer(
+		e.Context,
+		containerID,
+		e.Build.FullProjectDir(),
+		content,
+		types.CopyToContainerOptions{},
+	)
+	if err != nil {
+		return fmt.Errorf("copying the changes to the project directory: %w", err)
+	}
+
+	e.addFailureCheckpointArtifact()
+
+	return nil
+}
+
+// writeContainerDiff writes the changed files to w as a tar archive and their
+// list to list. The files are read from a single export of the container's
+// file system. The files which would exceed maxSize are left out of the
+// archive, but remain in the list.
+func (e *executor) writeContainerDiff(
+	w io.Writer,
+	list io.Writer,
+	containerID string,
+	changes []container.FilesystemChange,
+	maxSize int64,
+) (int64, error) {
+	changed := make(map[string]bool, len(changes))
+	for _, change := range changes {
+		_, _ = fmt.Fprintln(list, change.Kind, change.Path)
+
+		if change.Kind != container.ChangeDelete {
+			changed[strings.TrimPrefix(change.Path, "/")] = true
+		}
+	}
+
+	tw := tar.NewWriter(w)
+	if len(changed) == 0 {
+		return 0, tw.Close()
+	}
+
+	export, err := e.client.ContainerExport(e.Context, containerID)
+	if err != nil {
+		return 0, fmt.Errorf("exporting the file system: %w", err)
+	}
+	defer func() { _ = export.Close() }()
+
+	size, err := copyChangedFiles(tw, tar.NewReader(export), changed, maxSize)
+	if errors.Is(err, errFailureCheckpointTooLarge) {
+		e.Warningln(fmt.Sprintf("%v (%d bytes), the next changes aren't exported", err, maxSize))
+	} else if err != nil {
+		return 0, fmt.Errorf("exporting the changes: %w", err)
+	}
+
+	return size, tw.Close()
+}
+
+// copyChangedFiles appends the changed files of the file system archive tr to
+// tw, until the next file would exceed maxSize. The directories are skipped:
+// the files added or changed in a directory are listed separately.
+func copyChangedFiles(tw *tar.Writer, tr *tar.Reader, changed map[string]bool, maxSize int64) (int64, error) {
+	var size int64
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return size, nil
+		}
+		if err != nil {
+			return size, err
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+header.Name), "/")
+		if header.Typeflag == tar.TypeDir || !changed[name] {
+			continue
+		}
+
+		if size+header.Size > maxSize {
+			return size, errFailureCheckpointTooLarge
+		}
+
+		header.Name = name
+		if err := tw.WriteHeader(header); err != nil {
+			return size, err
+		}
+
+		n, err := io.Copy(tw, tr)
+		size += n
+		if err != nil {
+			return size, err
+		}
+	}
+}
+
+// writeCheckpointArchive writes the archive extracted in the project directory
+func writeCheckpointArchive(w io.Writer, list *bytes.Buffer, archive io.Reader, archiveSize int64) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+
+	files := []struct {
+		name    string
+		size    int64
+		content io.Reader
+	}{
+		{name: failureCheckpointChanges, size: int64(list.Len()), content: list},
+		{name: failureCheckpointArchive, size: archiveSize, content: archive},
+	}
+
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     failureCheckpointDir + "/",
+		Mode:     0o755,
+		ModTime:  now,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     path.Join(failureCheckpointDir, file.name),
+			Mode:     0o644,
+			Size:     file.size,
+			ModTime:  now,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(tw, file.content); err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+// addFailureCheckpointArtifact adds the checkpoint directory to the artifacts
+// uploaded on failure. GitLab accepts a single archive per job, so the
+// directory is added to the job's archive uploaded on failure if there's one.
+func (e *executor) addFailureCheckpointArtifact() {
+	checkpointPath := failureCheckpointDir + "/"
+
+	for i, artifact := range e.Build.Artifacts {
+		if artifact.Type != "" && artifact.Type != "archive" {
+			continue
+		}
+
+		if artifact.When.OnFailure() {
+			e.Build.Artifacts[i].Paths = append(e.Build.Artifacts[i].Paths, checkpointPath)
+			return
+		}
+	}
+
+	e.Build.Artifacts = append(e.Build.Artifacts, common.Artifact{
+		Name:     failureCheckpointArtifactName,
+		Paths:    common.ArtifactPaths{checkpointPath},
+		When:     common.ArtifactWhenOnFailure,
+		Type:     "archive",
+		Format:   common.ArtifactFormatZip,
+		ExpireIn: e.Config.Docker.FailureCheckpoint.ExpireIn,
+	})
+}
+
+// keepContainerForTerminal keeps the file system of the build container for
+// a web terminal to attach to, until the terminal disconnects or the keep
+// timeout. Restarting the exited build container would run its entrypoint and
+// the job shell again, so the container is committed and a container of the
+// committed image is started with the job shell as its only command. The shell
+// blocks on its standard input, which stays open as nothing attaches to it.
+func (s *commandExecutor) keepContainerForTerminal(containerID string) error {
+	if s.Build.Session == nil {
+		return errors.New("the web terminal isn't available, configure the [session_server] section")
+	}
+
+	timeout := s.Config.Docker.FailureCheckpoint.GetKeepTimeout()
+
+	kept, err := s.createCheckpointContainer(containerID)
+	if err != nil {
+		return err
+	}
+	defer s.removeCheckpointContainer(kept)
+
+	err = s.client.ContainerStart(s.Context, kept.ID, types.ContainerStartOptions{})
+	if err != nil {
+		return fmt.Errorf("starting the checkpoint container: %w", err)
+	}
+
+	// the web terminal attaches to the build container
+	s.lock.Lock()
+	buildContainer := s.buildContainer
+	s.buildContainer = kept
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		s.buildContainer = buildContainer
+		s.lock.Unlock()
+	}()
+
+	s.Println(fmt.Sprintf(
+		"Keeping the build container for %s for the web terminal to attach to...",
+		timeout.Round(time.Second),
+	))
+
+	ctx, cancel := context.WithTimeout(s.Context, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(failureCheckpointPollInterval)
+	defer ticker.Stop()
+
+	connected := false
+	for {
+		select {
+		case <-ctx.Done():
+			s.Println("Stopping the build container kept for the web terminal")
+			return nil
+		case <-s.Build.Session.DisconnectCh:
+			s.Println("Terminal disconnected, stopping the build container")
+			return nil
+		case <-ticker.C:
+			if !connected && s.Build.Session.Connected() {
+				connected = true
+				s.Println("Terminal is connected to the build container")
+			}
+		}
+	}
+}
+
+// createCheckpointContainer commits the build container and creates a
+// container of the committed image, with the configuration of the build
+// container and the job shell as its entrypoint
+func (s *commandExecutor) createCheckpointContainer(containerID string) (*types.ContainerJSON, error) {
+	build, err := s.client.ContainerInspect(s.Context, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("inspecting the build container: %w", err)
+	}
+
+	image, err := s.client.ContainerCommit(s.Context, containerID, types.ContainerCommitOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("committing the build container: %w", err)
+	}
+
+	config := *build.Config
+	config.Image = image.ID
+	config.Entrypoint = s.BuildShell.DockerCommand
+	config.Cmd = nil
+	config.OpenStdin = true
+	config.StdinOnce = false
+	config.Labels = s.prepareContainerLabels(map[string]string{"type": failureCheckpointContainerType})
+
+	name := s.getProjectUniqRandomizedName() + "-" + failureCheckpointContainerType
+
+	resp, err := s.client.ContainerCreate(s.Context, &config, build.HostConfig, nil, nil, name)
+	if resp.ID != "" {
+		s.temporary = append(s.temporary, resp.ID)
+	}
+	if err != nil {
+		s.removeCheckpointImage(image.ID)
+		return nil, fmt.Errorf("creating the checkpoint container: %w", err)
+	}
+
+	return &types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{ID: resp.ID, Name: name, Image: image.ID},
+		Config:            &config,
+	}, nil
+}
+
+func (s *commandExecutor) removeCheckpointContainer(kept *types.ContainerJSON) {
+	stopTimeout := 0
+	err := s.waiter.StopKillWait(s.Context, kept.ID, &stopTimeout, nil)
+	if err != nil {
+		s.Warningln("Failed to stop the checkpoint container:", err)
+	}
+
+	err = s.removeContainer(s.Context, kept.ID)
+	if err != nil {
+		s.Warningln("Failed to remove the checkpoint container:", err)
+	}
+
+	s.removeCheckpointImage(kept.Image)
+}
+
+func (s *commandExecutor) removeCheckpointImage(imageID string) {
+	_, err := s.client.ImageRemove(s.Context, imageID, types.ImageRemoveOptions{Force: true})
+	if err != nil && !docker.IsErrNotFound(err) {
+		s.Warningln("Failed to remove the checkpoint image:", err)
+	}
+}
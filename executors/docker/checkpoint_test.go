@@ -0,0 +1,385 @@
+//go:build !integration
+
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/labels"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/wait"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+	"gitlab.com/gitlab-org/gitlab-runner/session"
+)
+
+func newCheckpointTestExecutor(
+	t *testing.T,
+	checkpoint *common.DockerFailureCheckpoint,
+) (*commandExecutor, *docker.MockClient) {
+	c := docker.NewMockClient(t)
+
+	e := &commandExecutor{executor: executor{client: c}}
+	e.Context = context.Background()
+	e.BuildLogger = common.NewBuildLogger(&common.Trace{Writer: &bytes.Buffer{}}, logrus.WithField("test", t.Name()))
+	e.Config.Docker = &common.DockerConfig{FailureCheckpoint: checkpoint}
+	e.Build = &common.Build{Runner: &common.RunnerConfig{}}
+	e.Build.BuildDir = "/builds/group/project"
+	e.BuildShell = &common.ShellConfiguration{DockerCommand: []string{"sh"}}
+	e.labeler = labels.NewLabeler(e.Build)
+
+	return e, c
+}
+
+// tarFileSystem returns the archive of a container's file system, the
+// content of the directories ending with a /
+func tarFileSystem(t *testing.T, files map[string]string, order ...string) io.ReadCloser {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+
+	for _, name := range order {
+		content := files[name]
+		header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}
+		if strings.HasSuffix(name, "/") {
+			header = &tar.Header{Name: name, Mode: 0o755, Typeflag: tar.TypeDir}
+		}
+
+		require.NoError(t, tw.WriteHeader(header))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	return io.NopCloser(buf)
+}
+
+func readTar(t *testing.T, r io.Reader) map[string]string {
+	files := make(map[string]string)
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files
+		}
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+}
+
+func TestExportContainerDiff(t *testing.T) {
+	tests := map[string]struct {
+		maxSize       string
+		expectedFiles map[string]string
+	}{
+		"all changes": {
+			expectedFiles: map[string]string{
+				"etc/app.conf":       "debug = true",
+				"usr/local/bin/tool": "#!/bin/sh",
+			},
+		},
+		"changes exceeding the max size": {
+			maxSize: "15B",
+			expectedFiles: map[string]string{
+				"etc/app.conf": "debug = true",
+			},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			e, c := newCheckpointTestExecutor(t, &common.DockerFailureCheckpoint{
+				Mode:    failureCheckpointModeArtifact,
+				MaxSize: tt.maxSize,
+			})
+
+			c.On("ContainerDiff", e.Context, "build-id").
+				Return([]container.FilesystemChange{
+					{Kind: container.ChangeModify, Path: "/etc"},
+					{Kind: container.ChangeModify, Path: "/etc/app.conf"},
+					{Kind: container.ChangeDelete, Path: "/etc/old.conf"},
+					{Kind: container.ChangeAdd, Path: "/usr/local/bin/tool"},
+					{Kind: container.ChangeAdd, Path: "/root/.cache/downloads"},
+				}, nil).
+				Once()
+			fileSystem := map[string]string{
+				"etc/":               "",
+				"etc/app.conf":       "debug = true",
+				"etc/hosts":          "127.0.0.1 localhost",
+				"usr/local/bin/tool": "#!/bin/sh",
+				"bin/sh":             "ELF",
+			}
+			c.On("ContainerExport", e.Context, "build-id").
+				Return(tarFileSystem(t, fileSystem, "bin/sh", "etc/", "etc/app.conf", "etc/hosts", "usr/local/bin/tool"), nil).
+				Once()
+
+			var extracted map[string]string
+			c.On("CopyToContainer", e.Context, "build-id", "/builds/group/project", mock.Anything, types.CopyToContainerOptions{}).
+				Run(func(args mock.Arguments) {
+					extracted = readTar(t, args.Get(3).(io.Reader))
+				}).
+				Return(nil).
+				Once()
+
+			require.NoError(t, e.exportContainerDiff("build-id"))
+
+			assert.Equal(
+				t,
+				"C /etc\nC /etc/app.conf\nD /etc/old.conf\nA /usr/local/bin/tool\nA /root/.cache/downloads\n",
+				extracted[".gitlab-runner-checkpoint/changes.txt"],
+			)
+
+			files := readTar(t, bytes.NewBufferString(extracted[".gitlab-runner-checkpoint/container-diff.tar"]))
+			assert.Equal(t, tt.expectedFiles, files)
+
+			require.Len(t, e.Build.Artifacts, 1)
+			assert.Equal(t, common.ArtifactPaths{".gitlab-runner-checkpoint/"}, e.Build.Artifacts[0].Paths)
+		})
+	}
+}
+
+func TestAddFailureCheckpointArtifact(t *testing.T) {
+	tests := map[string]struct {
+		artifacts         common.Artifacts
+		expectedArtifacts common.Artifacts
+	}{
+		"no artifacts": {
+			expectedArtifacts: common.Artifacts{
+				{
+					Name:     "failure-checkpoint",
+					Paths:    common.ArtifactPaths{".gitlab-runner-checkpoint/"},
+					When:     common.ArtifactWhenOnFailure,
+					Type:     "archive",
+					Format:   common.ArtifactFormatZip,
+					ExpireIn: "1 day",
+				},
+			},
+		},
+		"archive uploaded on success": {
+			artifacts: common.Artifacts{
+				{Name: "build", Paths: common.ArtifactPaths{"bin/"}, When: common.ArtifactWhenOnSuccess},
+			},
+			expectedArtifacts: common.Artifacts{
+				{Name: "build", Paths: common.ArtifactPaths{"bin/"}, When: common.ArtifactWhenOnSuccess},
+				{
+					Name:     "failure-checkpoint",
+					Paths:    common.ArtifactPaths{".gitlab-runner-checkpoint/"},
+					When:     common.ArtifactWhenOnFailure,
+					Type:     "archive",
+					Format:   common.ArtifactFormatZip,
+					ExpireIn: "1 day",
+				},
+			},
+		},
+		"archive always uploaded": {
+			artifacts: common.Artifacts{
+				{Name: "junit", Paths: common.ArtifactPaths{"report.xml"}, When: common.ArtifactWhenAlways, Type: "junit"},
+				{Name: "logs", Paths: common.ArtifactPaths{"logs/"}, When: common.ArtifactWhenAlways, Type: "archive"},
+			},
+			expectedArtifacts: common.Artifacts{
+				{Name: "junit", Paths: common.ArtifactPaths{"report.xml"}, When: common.ArtifactWhenAlways, Type: "junit"},
+				{
+					Name:  "logs",
+					Paths: common.ArtifactPaths{"logs/", ".gitlab-runner-checkpoint/"},
+					When:  common.ArtifactWhenAlways,
+					Type:  "archive",
+				},
+			},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			e, _ := newCheckpointTestExecutor(t, &common.DockerFailureCheckpoint{
+				Mode:     failureCheckpointModeArtifact,
+				ExpireIn: "1 day",
+			})
+			e.Build.Artifacts = tt.artifacts
+
+			e.addFailureCheckpointArtifact()
+
+			assert.Equal(t, tt.expectedArtifacts, e.Build.Artifacts)
+		})
+	}
+}
+
+func TestKeepContainerForTerminal(t *testing.T) {
+	oldPollInterval := failureCheckpointPollInterval
+	failureCheckpointPollInterval = time.Millisecond
+	defer func() { failureCheckpointPollInterval = oldPollInterval }()
+
+	timeout := 50 * time.Millisecond
+
+	// mockCheckpointContainer expects the build container to be committed and
+	// the committed image to be run with the job shell, then removed
+	mockCheckpointContainer := func(t *testing.T, e *commandExecutor, c *docker.MockClient) {
+		hostConfig := &container.HostConfig{Binds: []string{"build-volume:/builds"}, NetworkMode: "build-network"}
+
+		c.On("ContainerInspect", e.Context, "build-id").
+			Return(types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{ID: "build-id", HostConfig: hostConfig},
+				Config: &container.Config{
+					Image:      "alpine",
+					Entrypoint: []string{"/docker-entrypoint.sh"},
+					Cmd:        []string{"sh"},
+					OpenStdin:  true,
+					StdinOnce:  true,
+					Env:        []string{"CI=true"},
+				},
+			}, nil).
+			Once()
+		c.On("ContainerCommit", e.Context, "build-id", types.ContainerCommitOptions{}).
+			Return(types.IDResponse{ID: "checkpoint-image"}, nil).
+			Once()
+		c.On("ContainerCreate", e.Context, mock.Anything, hostConfig, (*network.NetworkingConfig)(nil), (*v1.Platform)(nil), mock.Anything).
+			Run(func(args mock.Arguments) {
+				config := args.Get(1).(*container.Config)
+				assert.Equal(t, "checkpoint-image", config.Image)
+				assert.Equal(t, []string{"sh"}, []string(config.Entrypoint))
+				assert.Empty(t, config.Cmd)
+				assert.True(t, config.OpenStdin)
+				assert.False(t, config.StdinOnce)
+				assert.Equal(t, []string{"CI=true"}, config.Env)
+			}).
+			Return(container.CreateResponse{ID: "checkpoint-id"}, nil).
+			Once()
+		c.On("ContainerStart", e.Context, "checkpoint-id", types.ContainerStartOptions{}).
+			Run(func(mock.Arguments) {
+				assert.Nil(t, e.getBuildContainer())
+			}).
+			Return(nil).
+			Once()
+		c.On("NetworkList", e.Context, types.NetworkListOptions{}).Return(nil, nil).Once()
+		c.On("ContainerRemove", e.Context, "checkpoint-id", mock.Anything).Return(nil).Once()
+		c.On("ImageRemove", e.Context, "checkpoint-image", types.ImageRemoveOptions{Force: true}).Return(nil, nil).Once()
+
+		waiter := wait.NewMockKillWaiter(t)
+		waiter.On("StopKillWait", e.Context, "checkpoint-id", mock.Anything, mock.Anything).Return(nil).Once()
+		e.waiter = waiter
+	}
+
+	t.Run("no session", func(t *testing.T) {
+		e, _ := newCheckpointTestExecutor(t, &common.DockerFailureCheckpoint{
+			Mode:        failureCheckpointModeKeep,
+			KeepTimeout: &timeout,
+		})
+
+		assert.Error(t, e.keepContainerForTerminal("build-id"))
+	})
+
+	t.Run("kept until the timeout", func(t *testing.T) {
+		e, c := newCheckpointTestExecutor(t, &common.DockerFailureCheckpoint{
+			Mode:        failureCheckpointModeKeep,
+			KeepTimeout: &timeout,
+		})
+
+		sess, err := session.NewSession(nil)
+		require.NoError(t, err)
+		e.Build.Session = sess
+
+		mockCheckpointContainer(t, e, c)
+
+		started := time.Now()
+		require.NoError(t, e.keepContainerForTerminal("build-id"))
+		assert.GreaterOrEqual(t, time.Since(started), timeout)
+		assert.Nil(t, e.getBuildContainer(), "the build container is restored")
+		assert.Equal(t, []string{"checkpoint-id"}, e.temporary)
+	})
+
+	t.Run("stopped when the terminal disconnects", func(t *testing.T) {
+		keepTimeout := time.Hour
+		e, c := newCheckpointTestExecutor(t, &common.DockerFailureCheckpoint{
+			Mode:        failureCheckpointModeKeep,
+			KeepTimeout: &keepTimeout,
+		})
+
+		sess, err := session.NewSession(nil)
+		require.NoError(t, err)
+		e.Build.Session = sess
+
+		mockCheckpointContainer(t, e, c)
+
+		go func() {
+			assert.Eventually(t, func() bool {
+				ctr := e.getBuildContainer()
+				return ctr != nil && ctr.ID == "checkpoint-id"
+			}, time.Second, time.Millisecond, "the terminal attaches to the kept container")
+
+			sess.DisconnectCh <- errors.New("disconnected")
+		}()
+
+		require.NoError(t, e.keepContainerForTerminal("build-id"))
+		assert.Nil(t, e.getBuildContainer(), "the build container is restored")
+	})
+
+	t.Run("commit failure", func(t *testing.T) {
+		e, c := newCheckpointTestExecutor(t, &common.DockerFailureCheckpoint{
+			Mode:        failureCheckpointModeKeep,
+			KeepTimeout: &timeout,
+		})
+
+		sess, err := session.NewSession(nil)
+		require.NoError(t, err)
+		e.Build.Session = sess
+
+		c.On("ContainerInspect", e.Context, "build-id").
+			Return(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: "build-id"}, Config: &container.Config{}}, nil).
+			Once()
+		c.On("ContainerCommit", e.Context, "build-id", types.ContainerCommitOptions{}).
+			Return(types.IDResponse{}, errors.New("commit failed")).
+			Once()
+
+		assert.ErrorContains(t, e.keepContainerForTerminal("build-id"), "commit failed")
+	})
+}
+
+func TestCheckpointFailure(t *testing.T) {
+	tests := map[string]struct {
+		checkpoint *common.DockerFailureCheckpoint
+		stage      common.BuildStage
+		exported   bool
+	}{
+		"disabled": {
+			stage: common.BuildStage("step_script"),
+		},
+		"after_script failure": {
+			checkpoint: &common.DockerFailureCheckpoint{Mode: failureCheckpointModeArtifact},
+			stage:      common.BuildStageAfterScript,
+		},
+		"script failure": {
+			checkpoint: &common.DockerFailureCheckpoint{Mode: failureCheckpointModeArtifact},
+			stage:      common.BuildStage("step_script"),
+			exported:   true,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			e, c := newCheckpointTestExecutor(t, tt.checkpoint)
+
+			if tt.exported {
+				c.On("ContainerDiff", e.Context, "build-id").Return(nil, errors.New("diff failed")).Once()
+			}
+
+			e.checkpointFailure(tt.stage, "build-id")
+		})
+	}
+}
@@ -27,6 +27,7 @@ import (
 
 	"gitlab.com/gitlab-org/gitlab-runner/common"
 	"gitlab.com/gitlab-org/gitlab-runner/executors"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/daemon"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/exec"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/labels"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/networks"
@@ -37,6 +38,7 @@ import (
 	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/wait"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/helperimage"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/dns"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/featureflags"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/limitwriter"
@@ -71,8 +73,19 @@ const (
 // reserved TLD ".invalid" for safety.
 const internalFakeTunnelHostname = "http://internel.tunnel.invalid"
 
+// Paths where the reference repository of the project is mounted
+// when git_mirror_dir is configured
+const (
+	gitMirrorContainerPath        = "/gitlab-runner-git-mirror"
+	gitMirrorContainerPathWindows = `C:\gitlab-runner-git-mirror`
+)
+
 var neverRestartPolicy = container.RestartPolicy{Name: "no"}
 
+// usernsModeKeepID maps the user running a rootless Podman daemon to the
+// same user in the container
+const usernsModeKeepID container.UsernsMode = "keep-id"
+
 var (
 	errVolumesManagerUndefined  = errors.New("volumesManager is undefined")
 	errNetworksManagerUndefined = errors.New("networksManager is undefined")
@@ -103,8 +116,15 @@ type executor struct {
 	labeler         labels.Labeler
 	pullManager     pull.Manager
 
+	buildVolumePool    *volumes.Pool
+	releaseBuildVolume func()
+
+	runtime daemon.Runtime
+
 	networkMode container.NetworkMode
 
+	resourceOverwrites resourceOverwrites
+
 	projectUniqRandomizedName string
 
 	tunnelClient executors.Client
@@ -464,6 +484,10 @@ func (e *executor) createHostConfigForService() *container.HostConfig {
 	}
 
 	return &container.HostConfig{
+		Resources: container.Resources{
+			Memory:   e.resourceOverwrites.memory,
+			NanoCPUs: e.resourceOverwrites.nanoCPUs,
+		},
 		DNS:           e.Config.Docker.DNS,
 		DNSSearch:     e.Config.Docker.DNSSearch,
 		RestartPolicy: neverRestartPolicy,
@@ -474,7 +498,7 @@ func (e *executor) createHostConfigForService() *container.HostConfig {
 		UsernsMode:    container.UsernsMode(e.Config.Docker.UsernsMode),
 		NetworkMode:   e.networkMode,
 		Binds:         e.volumesManager.Binds(),
-		ShmSize:       e.Config.Docker.ShmSize,
+		ShmSize:       e.shmSize(),
 		Tmpfs:         e.Config.Docker.ServicesTmpfs,
 		LogConfig: container.LogConfig{
 			Type: "json-file",
@@ -655,8 +679,19 @@ func (e *executor) createContainerConfig(
 	return config
 }
 
+// buildUsernsMode returns the user namespace mode of the build and helper
+// containers. With rootless Podman, the user running the daemon owns the
+// volumes and is mapped to the same user in the containers by default.
+func (e *executor) buildUsernsMode() container.UsernsMode {
+	if e.Config.Docker.UsernsMode == "" && e.runtime.KeepID() {
+		return usernsModeKeepID
+	}
+
+	return container.UsernsMode(e.Config.Docker.UsernsMode)
+}
+
 func (e *executor) createHostConfig() (*container.HostConfig, error) {
-	nanoCPUs, err := e.Config.Docker.GetNanoCPUs()
+	nanoCPUs, err := e.nanoCPUs()
 	if err != nil {
 		return nil, err
 	}
@@ -674,7 +709,7 @@ func (e *executor) createHostConfig() (*container.HostConfig, error) {
 
 	return &container.HostConfig{
 		Resources: container.Resources{
-			Memory:            e.Config.Docker.GetMemory(),
+			Memory:            e.memory(),
 			MemorySwap:        e.Config.Docker.GetMemorySwap(),
 			MemoryReservation: e.Config.Docker.GetMemoryReservation(),
 			CpusetCpus:        e.Config.Docker.CPUSetCPUs,
@@ -691,7 +726,7 @@ func (e *executor) createHostConfig() (*container.HostConfig, error) {
 		Runtime:       e.Config.Docker.Runtime,
 		Privileged:    e.Config.Docker.Privileged,
 		GroupAdd:      e.Config.Docker.GroupAdd,
-		UsernsMode:    container.UsernsMode(e.Config.Docker.UsernsMode),
+		UsernsMode:    e.buildUsernsMode(),
 		CapAdd:        e.Config.Docker.CapAdd,
 		CapDrop:       e.Config.Docker.CapDrop,
 		SecurityOpt:   e.Config.Docker.SecurityOpt,
@@ -702,7 +737,7 @@ func (e *executor) createHostConfig() (*container.HostConfig, error) {
 		Links:         append(e.Config.Docker.Links, e.links...),
 		Binds:         e.volumesManager.Binds(),
 		OomScoreAdj:   e.Config.Docker.OomScoreAdjust,
-		ShmSize:       e.Config.Docker.ShmSize,
+		ShmSize:       e.shmSize(),
 		Isolation:     isolation,
 		VolumeDriver:  e.Config.Docker.VolumeDriver,
 		VolumesFrom:   e.Config.Docker.VolumesFrom,
@@ -894,6 +929,19 @@ func (e *executor) connectDocker(options common.ExecutorPrepareOptions) error {
 		return err
 	}
 
+	e.runtime, err = daemon.Detect(e.Context, e.client, e.info, e.Config.Docker.DaemonMode)
+	if err != nil {
+		return err
+	}
+
+	if e.runtime != (daemon.Runtime{}) {
+		e.Println(fmt.Sprintf("Using %s compatibility mode", e.runtime))
+	}
+
+	if e.runtime.Rootless && e.Config.Docker.Privileged {
+		e.Warningln("Privileged containers don't get privileges on the host with a rootless daemon")
+	}
+
 	e.waiter = wait.NewDockerKillWaiter(e.client)
 
 	return err
@@ -945,8 +993,11 @@ func (e *executor) createDependencies() error {
 		e.bindDeviceRequests,
 		e.createVolumesManager,
 		e.createVolumes,
+		e.createGitMirrorVolume,
 		e.createBuildVolume,
+		e.createEgressProxy,
 		e.createServices,
+		e.createBuildKit,
 	}
 
 	for _, setup := range createDependenciesStrategy {
@@ -985,6 +1036,52 @@ func (e *executor) createVolumes() error {
 	return nil
 }
 
+// createGitMirrorVolume bind mounts the reference repository of the project,
+// read-only, when git_mirror_dir is configured and the Docker daemon runs on
+// the runner host
+func (e *executor) createGitMirrorVolume() error {
+	if e.Config.GitMirrorDir == "" {
+		return nil
+	}
+
+	if !e.isLocalDockerHost() {
+		e.Warningln("git_mirror_dir is ignored: the Docker daemon doesn't run on the runner host")
+		return nil
+	}
+
+	if e.volumesManager == nil {
+		return errVolumesManagerUndefined
+	}
+
+	repoPath := e.RefreshGitMirror()
+	if repoPath == "" {
+		return nil
+	}
+
+	containerPath := gitMirrorContainerPath
+	if e.helperImageInfo.OSType == helperimage.OSTypeWindows {
+		containerPath = gitMirrorContainerPathWindows
+	}
+
+	err := e.volumesManager.Create(e.Context, fmt.Sprintf("%s:%s:ro", repoPath, containerPath))
+	if err != nil {
+		return err
+	}
+
+	e.Build.GitMirrorDir = containerPath
+
+	return nil
+}
+
+func (e *executor) isLocalDockerHost() bool {
+	if _, ok := e.Build.ExecutorData.(executors.Environment); ok {
+		return false
+	}
+
+	host := e.Config.Docker.Host
+	return host == "" || strings.HasPrefix(host, "unix://") || strings.HasPrefix(host, "npipe://")
+}
+
 func (e *executor) createBuildVolume() error {
 	e.SetCurrentStage(ExecutorStageCreatingBuildVolumes)
 	e.Debugln("Creating build volume...")
@@ -997,7 +1094,9 @@ func (e *executor) createBuildVolume() error {
 
 	var err error
 
-	if e.Build.GetGitStrategy() == common.GitFetch {
+	if e.Build.GetGitStrategy() == common.GitFetch && e.useBuildVolumePool() {
+		err = e.createPooledBuildVolume(jobsDir)
+	} else if e.Build.GetGitStrategy() == common.GitFetch {
 		err = e.volumesManager.Create(e.Context, jobsDir)
 		if err == nil {
 			return nil
@@ -1020,6 +1119,62 @@ func (e *executor) createBuildVolume() error {
 	return nil
 }
 
+// useBuildVolumePool reports whether the build volume is taken from the pool
+// of volumes reused across jobs. The pool only tracks the volumes of the
+// Docker daemon running on the runner host.
+func (e *executor) useBuildVolumePool() bool {
+	return e.buildVolumePool != nil &&
+		e.Config.Docker.BuildVolumes.IsEnabled() &&
+		!e.Config.Docker.DisableCache &&
+		e.isLocalDockerHost()
+}
+
+// buildVolumePoolPolicy returns the policy of the pooled build volumes. A
+// volume is used by one job at a time: the jobs of a project check out the
+// repository in the same project directory, so concurrent jobs sharing a
+// volume would overwrite each other's checkout.
+func buildVolumePoolPolicy(config *common.DockerBuildVolumesConfig) (volumes.PoolPolicy, error) {
+	maxSize, err := config.GetMaxSize()
+	if err != nil {
+		return volumes.PoolPolicy{}, err
+	}
+
+	return volumes.PoolPolicy{
+		MaxAge:  config.GetMaxAge(),
+		MaxSize: maxSize,
+	}, nil
+}
+
+func (e *executor) createPooledBuildVolume(jobsDir string) error {
+	config := e.Config.Docker.BuildVolumes
+
+	policy, err := buildVolumePoolPolicy(config)
+	if err != nil {
+		return err
+	}
+
+	project := dns.MakeRFC1123Compatible(
+		fmt.Sprintf("runner-%s-project-%d", e.Build.Runner.ShortDescription(), e.Build.JobInfo.ProjectID),
+	)
+
+	name, release := e.buildVolumePool.Acquire(volumes.PoolRequest{
+		Project:     project,
+		Credentials: e.Config.Docker.Credentials,
+		Policy:      policy,
+	})
+
+	err = e.volumesManager.CreateNamed(e.Context, name, jobsDir)
+	if err != nil {
+		release()
+		return err
+	}
+
+	e.Debugln(fmt.Sprintf("Using build volume %q from the pool...", name))
+	e.releaseBuildVolume = release
+
+	return nil
+}
+
 func (e *executor) Prepare(options common.ExecutorPrepareOptions) error {
 	e.SetCurrentStage(ExecutorStagePrepare)
 
@@ -1061,6 +1216,11 @@ func (e *executor) Prepare(options common.ExecutorPrepareOptions) error {
 		return err
 	}
 
+	e.resourceOverwrites, err = createResourceOverwrites(e.Config.Docker, e.Build.GetAllVariables(), e.BuildLogger)
+	if err != nil {
+		return err
+	}
+
 	e.Println("Using Docker executor with image", imageName, "...")
 
 	err = e.createDependencies()
@@ -1100,6 +1260,12 @@ func (e *executor) setupDefaultExecutorOptions(os string) {
 			e.volumeParser = parser.NewLinuxParser()
 		}
 
+		if e.newVolumePermissionSetter == nil && e.runtime.KeepID() {
+			e.newVolumePermissionSetter = func() (permission.Setter, error) {
+				return permission.NewNoopSetter(), nil
+			}
+		}
+
 		if e.newVolumePermissionSetter == nil {
 			e.newVolumePermissionSetter = func() (permission.Setter, error) {
 				helperImage, err := e.getPrebuiltImage()
@@ -1174,6 +1340,10 @@ func (e *executor) Cleanup() {
 
 	wg.Wait()
 
+	if e.releaseBuildVolume != nil {
+		e.releaseBuildVolume()
+	}
+
 	err := e.cleanupVolume(ctx)
 	if err != nil {
 		volumeLogger := e.WithFields(logrus.Fields{
@@ -16,6 +16,7 @@ import (
 	"gitlab.com/gitlab-org/gitlab-runner/executors"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/exec"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/user"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/volumes"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/featureflags"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/limitwriter"
@@ -105,6 +106,10 @@ func (s *commandExecutor) Run(cmd common.ExecutorCommand) error {
 
 		runErr = s.startAndWatchContainer(cmd.Context, ctr.ID, bytes.NewBufferString(cmd.Script))
 		if !docker.IsErrNotFound(runErr) {
+			if runErr != nil && !cmd.Predefined {
+				s.checkpointFailure(cmd.Stage, ctr.ID)
+			}
+
 			return runErr
 		}
 
@@ -214,6 +219,9 @@ func (s *commandExecutor) changeFilesOwnership() error {
 
 	dockerExec := exec.NewDocker(s.Context, s.client, s.waiter, s.Build.Log())
 	inspect := user.NewInspect(s.client, dockerExec)
+	if s.runtime.KeepID() {
+		inspect = user.NewKeepIDInspect(s.client, dockerExec)
+	}
 	imageSHA := s.buildContainer.Image
 	imageName := s.Build.Image.Name
 
@@ -344,12 +352,16 @@ func init() {
 		ShowHostname: true,
 	}
 
+	// the build volumes are shared by the jobs of all the docker runners
+	buildVolumePool := volumes.NewPool()
+
 	creator := func() common.Executor {
 		e := &commandExecutor{
 			executor: executor{
 				AbstractExecutor: executors.AbstractExecutor{
 					ExecutorOptions: options,
 				},
+				buildVolumePool: buildVolumePool,
 			},
 		}
 
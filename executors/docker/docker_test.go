@@ -9,10 +9,13 @@ import (
 	"io"
 	"net"
 	"os"
+	"os/exec"
 	"path"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -26,6 +29,7 @@ import (
 
 	"gitlab.com/gitlab-org/gitlab-runner/common"
 	"gitlab.com/gitlab-org/gitlab-runner/executors"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/daemon"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/networks"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/pull"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/user"
@@ -36,6 +40,7 @@ import (
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker/auth"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/featureflags"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/gitmirror"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/test"
 	"gitlab.com/gitlab-org/gitlab-runner/shells"
 )
@@ -707,6 +712,238 @@ func TestCreateBuildVolume(t *testing.T) {
 	}
 }
 
+func TestCreatePooledBuildVolume(t *testing.T) {
+	const expectedVolume = "runner-abcdef12-project-0-build-0"
+
+	tests := map[string]struct {
+		volumesTestCase
+		maxSize        string
+		emptyConfig    bool
+		expectedPooled bool
+	}{
+		"empty build volumes section": {
+			volumesTestCase: volumesTestCase{
+				gitStrategy: "fetch",
+				volumesManagerAssertions: func(vm *volumes.MockManager) {
+					vm.On("Create", mock.Anything, volumesTestsDefaultBuildsDir).
+						Return(nil).
+						Once()
+				},
+				createVolumeManager: true,
+			},
+			emptyConfig: true,
+		},
+		"pooled build volume": {
+			volumesTestCase: volumesTestCase{
+				gitStrategy: "fetch",
+				volumesManagerAssertions: func(vm *volumes.MockManager) {
+					vm.On("CreateNamed", mock.Anything, expectedVolume, volumesTestsDefaultBuildsDir).
+						Return(nil).
+						Once()
+				},
+				createVolumeManager: true,
+			},
+			expectedPooled: true,
+		},
+		"pooled build volume, duplicated error": {
+			volumesTestCase: volumesTestCase{
+				gitStrategy: "fetch",
+				volumesManagerAssertions: func(vm *volumes.MockManager) {
+					vm.On("CreateNamed", mock.Anything, expectedVolume, volumesTestsDefaultBuildsDir).
+						Return(volumes.NewErrVolumeAlreadyDefined(volumesTestsDefaultBuildsDir)).
+						Once()
+				},
+				createVolumeManager: true,
+			},
+		},
+		"pooled build volume, other error": {
+			volumesTestCase: volumesTestCase{
+				gitStrategy: "fetch",
+				volumesManagerAssertions: func(vm *volumes.MockManager) {
+					vm.On("CreateNamed", mock.Anything, expectedVolume, volumesTestsDefaultBuildsDir).
+						Return(errors.New("test-error")).
+						Once()
+				},
+				createVolumeManager: true,
+				expectedError:       errors.New("test-error"),
+			},
+		},
+		"invalid max size": {
+			volumesTestCase: volumesTestCase{
+				gitStrategy:         "fetch",
+				createVolumeManager: true,
+				expectedError:       errors.New(`invalid build volumes max_size "invalid": invalid size: 'invalid'`),
+			},
+			maxSize: "invalid",
+		},
+		"git strategy clone": {
+			volumesTestCase: volumesTestCase{
+				gitStrategy: "clone",
+				volumesManagerAssertions: func(vm *volumes.MockManager) {
+					vm.On("CreateTemporary", mock.Anything, volumesTestsDefaultBuildsDir).
+						Return(nil).
+						Once()
+				},
+				createVolumeManager: true,
+			},
+		},
+		"remote docker host": {
+			volumesTestCase: volumesTestCase{
+				gitStrategy: "fetch",
+				adjustConfiguration: func(e *executor) {
+					e.Config.Docker.Host = "tcp://docker.example.com:2376"
+				},
+				volumesManagerAssertions: func(vm *volumes.MockManager) {
+					vm.On("Create", mock.Anything, volumesTestsDefaultBuildsDir).
+						Return(nil).
+						Once()
+				},
+				createVolumeManager: true,
+			},
+		},
+		"cache disabled": {
+			volumesTestCase: volumesTestCase{
+				gitStrategy: "fetch",
+				adjustConfiguration: func(e *executor) {
+					e.Config.Docker.DisableCache = true
+				},
+				volumesManagerAssertions: func(vm *volumes.MockManager) {
+					vm.On("Create", mock.Anything, volumesTestsDefaultBuildsDir).
+						Return(volumes.ErrCacheVolumesDisabled).
+						Once()
+					vm.On("CreateTemporary", mock.Anything, volumesTestsDefaultBuildsDir).
+						Return(nil).
+						Once()
+				},
+				createVolumeManager: true,
+			},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			maxSize := test.maxSize
+			if maxSize == "" {
+				maxSize = "10GB"
+			}
+
+			adjustConfiguration := test.adjustConfiguration
+			test.adjustConfiguration = func(e *executor) {
+				e.buildVolumePool = volumes.NewPool()
+				e.Config.Docker.BuildVolumes = &common.DockerBuildVolumesConfig{MaxSize: maxSize}
+				if test.emptyConfig {
+					e.Config.Docker.BuildVolumes = &common.DockerBuildVolumesConfig{}
+				}
+
+				if adjustConfiguration != nil {
+					adjustConfiguration(e)
+				}
+			}
+
+			e, closureFn := getExecutorForVolumesTests(t, test.volumesTestCase)
+			defer closureFn()
+
+			err := e.createBuildVolume()
+			if test.expectedError != nil {
+				assert.EqualError(t, err, test.expectedError.Error())
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedPooled, e.releaseBuildVolume != nil)
+		})
+	}
+}
+
+func TestCreateGitMirrorVolume(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	upstream := t.TempDir()
+	out, err := exec.Command("git", "init", "--quiet", "--bare", upstream).CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	mirrorDir := t.TempDir()
+	upstreamURL := "file://localhost" + filepath.ToSlash(upstream)
+	mirrorPath, err := gitmirror.New(mirrorDir).Path(upstreamURL)
+	require.NoError(t, err)
+
+	withGitMirror := func(repoURL string) func(e *executor) {
+		return func(e *executor) {
+			e.Config.GitMirrorDir = mirrorDir
+			e.Build.GitInfo.RepoURL = repoURL
+		}
+	}
+
+	tests := map[string]struct {
+		volumesTestCase
+		expectedGitMirrorDir string
+	}{
+		"git mirror not configured": {
+			volumesTestCase: volumesTestCase{
+				gitStrategy:         "clone",
+				createVolumeManager: true,
+			},
+		},
+		"volumes manager not created": {
+			volumesTestCase: volumesTestCase{
+				gitStrategy:         "clone",
+				adjustConfiguration: withGitMirror(upstreamURL),
+				expectedError:       errVolumesManagerUndefined,
+			},
+		},
+		"remote docker host": {
+			volumesTestCase: volumesTestCase{
+				gitStrategy: "clone",
+				adjustConfiguration: func(e *executor) {
+					withGitMirror(upstreamURL)(e)
+					e.Config.Docker.Host = "tcp://docker.example.com:2376"
+				},
+				createVolumeManager: true,
+			},
+		},
+		"git strategy none": {
+			volumesTestCase: volumesTestCase{
+				gitStrategy:         "none",
+				adjustConfiguration: withGitMirror(upstreamURL),
+				createVolumeManager: true,
+			},
+		},
+		"refresh failure": {
+			volumesTestCase: volumesTestCase{
+				gitStrategy:         "fetch",
+				adjustConfiguration: withGitMirror("file://localhost/does/not/exist.git"),
+				createVolumeManager: true,
+			},
+		},
+		"git mirror mounted": {
+			volumesTestCase: volumesTestCase{
+				gitStrategy:         "clone",
+				adjustConfiguration: withGitMirror(upstreamURL),
+				volumesManagerAssertions: func(vm *volumes.MockManager) {
+					vm.On("Create", mock.Anything, mirrorPath+":"+gitMirrorContainerPath+":ro").
+						Return(nil).
+						Once()
+				},
+				createVolumeManager: true,
+			},
+			expectedGitMirrorDir: gitMirrorContainerPath,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			e, closureFn := getExecutorForVolumesTests(t, test.volumesTestCase)
+			defer closureFn()
+
+			err := e.createGitMirrorVolume()
+			assert.Equal(t, test.expectedError, err)
+			assert.Equal(t, test.expectedGitMirrorDir, e.Build.GitMirrorDir)
+		})
+	}
+}
+
 func TestCreateDependencies(t *testing.T) {
 	const containerID = "container-ID"
 	containerNameRegex, err := regexp.Compile("runner-abcdef12-project-0-concurrent-0-[^-]+-alpine-0")
@@ -1099,6 +1336,56 @@ func TestDockerUserNSSetting(t *testing.T) {
 	testDockerConfigurationWithJobContainer(t, dockerConfigWithHostUsernsMode, cceWithHostUsernsMode)
 }
 
+func TestDockerDaemonRuntimeAdaptations(t *testing.T) {
+	tests := map[string]struct {
+		runtime            daemon.Runtime
+		usernsMode         string
+		expectedUsernsMode container.UsernsMode
+		expectedNoopSetter bool
+	}{
+		"rootful docker": {
+			expectedUsernsMode: "",
+		},
+		"rootless docker": {
+			runtime:            daemon.Runtime{Rootless: true},
+			expectedUsernsMode: "",
+		},
+		"rootful podman": {
+			runtime:            daemon.Runtime{Podman: true},
+			expectedUsernsMode: "",
+		},
+		"rootless podman": {
+			runtime:            daemon.Runtime{Podman: true, Rootless: true},
+			expectedUsernsMode: usernsModeKeepID,
+			expectedNoopSetter: true,
+		},
+		"rootless podman with userns_mode": {
+			runtime:            daemon.Runtime{Podman: true, Rootless: true},
+			usernsMode:         "auto",
+			expectedUsernsMode: "auto",
+			expectedNoopSetter: true,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			e := &executor{runtime: tt.runtime}
+			e.Config.Docker = &common.DockerConfig{UsernsMode: tt.usernsMode}
+
+			assert.Equal(t, tt.expectedUsernsMode, e.buildUsernsMode())
+
+			e.setupDefaultExecutorOptions(helperimage.OSTypeLinux)
+			require.NotNil(t, e.newVolumePermissionSetter)
+
+			if tt.expectedNoopSetter {
+				setter, err := e.newVolumePermissionSetter()
+				require.NoError(t, err)
+				assert.Equal(t, permission.NewNoopSetter(), setter)
+			}
+		})
+	}
+}
+
 func TestDockerRuntimeSetting(t *testing.T) {
 	dockerConfig := &common.DockerConfig{
 		Runtime: "runc",
@@ -2034,3 +2321,17 @@ func TestConnectEnvironment(t *testing.T) {
 func init() {
 	auth.HomeDirectory = ""
 }
+
+func TestBuildVolumePoolPolicy(t *testing.T) {
+	maxAge := 60 * time.Minute
+
+	policy, err := buildVolumePoolPolicy(&common.DockerBuildVolumesConfig{
+		MaxAge:  &maxAge,
+		MaxSize: "10GB",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, volumes.PoolPolicy{MaxAge: time.Hour, MaxSize: 10_000_000_000}, policy)
+
+	_, err = buildVolumePoolPolicy(&common.DockerBuildVolumesConfig{MaxSize: "ten"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,169 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/services"
+	service_helpers "gitlab.com/gitlab-org/gitlab-runner/helpers/service"
+)
+
+const (
+	labelEgressProxyType = "egress-proxy"
+
+	egressProxyAlias = "egress-proxy"
+	egressProxyPort  = 8080
+)
+
+var (
+	errEgressPolicyNetworkMode = errors.New("the egress policy requires the per-build network and can't be used with network_mode")
+	errEgressPolicyWindows     = errors.New("the egress policy isn't supported on Windows containers")
+)
+
+// createEgressProxy starts the proxy enforcing the egress policy. The per-build
+// network is internal, so the proxy, also attached to the default network, is
+// the only way out for the build and service containers. The violations logged
+// by the proxy are streamed to the job log.
+func (e *executor) createEgressProxy() error {
+	if !e.Config.Docker.EgressPolicy.IsEnabled() {
+		return nil
+	}
+
+	if e.info.OSType == osTypeWindows {
+		return errEgressPolicyWindows
+	}
+
+	buildNetwork := e.networkMode.UserDefined()
+	if e.Config.Docker.NetworkMode != "" || buildNetwork == "" {
+		return errEgressPolicyNetworkMode
+	}
+
+	image, err := e.getPrebuiltImage()
+	if err != nil {
+		return err
+	}
+
+	containerName := e.getProjectUniqRandomizedName() + "-" + egressProxyAlias
+
+	// this will fail potentially some builds if there's name collision
+	_ = e.removeContainer(e.Context, containerName)
+
+	config := &container.Config{
+		Image:  image.ID,
+		Cmd:    e.egressProxyCommand(),
+		Labels: e.labeler.Labels(map[string]string{"type": labelEgressProxyType}),
+	}
+
+	hostConfig := &container.HostConfig{
+		DNS:           e.Config.Docker.DNS,
+		DNSSearch:     e.Config.Docker.DNSSearch,
+		RestartPolicy: neverRestartPolicy,
+		ExtraHosts:    e.Config.Docker.ExtraHosts,
+		LogConfig: container.LogConfig{
+			Type: "json-file",
+		},
+	}
+
+	e.Debugln("Creating egress proxy container", containerName, "...")
+	resp, err := e.client.ContainerCreate(e.Context, config, hostConfig, nil, nil, containerName)
+	if err != nil {
+		return fmt.Errorf("creating egress proxy container: %w", err)
+	}
+
+	e.temporary = append(e.temporary, resp.ID)
+
+	err = e.client.NetworkConnect(e.Context, buildNetwork, resp.ID, &network.EndpointSettings{
+		Aliases: []string{egressProxyAlias},
+	})
+	if err != nil {
+		return fmt.Errorf("connecting egress proxy to the build network: %w", err)
+	}
+
+	e.Debugln(fmt.Sprintf("Starting egress proxy container %s (%s)...", containerName, resp.ID))
+	err = e.client.ContainerStart(e.Context, resp.ID, types.ContainerStartOptions{})
+	if err != nil {
+		return fmt.Errorf("starting egress proxy container: %w", err)
+	}
+
+	sink := service_helpers.NewInlineServiceLogWriter(egressProxyAlias, e.Trace)
+	if err := e.captureContainerLogs(e.Context, resp.ID, containerName, sink); err != nil {
+		e.Warningln(err.Error())
+	}
+
+	e.Build.Variables = append(e.Build.Variables, e.egressProxyVariables()...)
+	e.Build.RefreshAllVariables()
+
+	return nil
+}
+
+// egressProxyCommand returns the command of the proxy. The GitLab instance is
+// always allowed, so that the sources and artifacts can be transferred.
+func (e *executor) egressProxyCommand() []string {
+	policy := e.Config.Docker.EgressPolicy
+
+	cmd := []string{"gitlab-runner-helper", "egress-proxy", "--listen", ":" + strconv.Itoa(egressProxyPort)}
+	if !policy.AllowMetadataService {
+		cmd = append(cmd, "--block-metadata")
+	}
+
+	allowed := append([]string{}, policy.Allow...)
+	for _, rawURL := range []string{e.Config.URL, e.Config.CloneURL, e.Build.GitInfo.RepoURL} {
+		if u, err := url.Parse(rawURL); err == nil && u.Hostname() != "" {
+			allowed = append(allowed, u.Hostname())
+		}
+	}
+
+	seen := make(map[string]bool, len(allowed))
+	for _, destination := range allowed {
+		if seen[destination] {
+			continue
+		}
+		seen[destination] = true
+
+		cmd = append(cmd, "--allow", destination)
+	}
+
+	return cmd
+}
+
+// egressProxyVariables points the jobs to the proxy. The services are on the
+// build network, so they're reached directly.
+func (e *executor) egressProxyVariables() common.JobVariables {
+	proxyURL := fmt.Sprintf("http://%s:%d", egressProxyAlias, egressProxyPort)
+	noProxy := append([]string{"localhost", "127.0.0.1", "::1"}, e.serviceAliases()...)
+
+	var variables common.JobVariables
+	for _, key := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		variables = append(variables, common.JobVariable{Key: key, Value: proxyURL, Public: true})
+	}
+
+	for _, key := range []string{"NO_PROXY", "no_proxy"} {
+		variables = append(variables, common.JobVariable{Key: key, Value: strings.Join(noProxy, ","), Public: true})
+	}
+
+	return variables
+}
+
+func (e *executor) serviceAliases() []string {
+	definitions := common.Services{}
+	for _, service := range e.Config.Docker.GetExpandedServices(e.Build.GetAllVariables()) {
+		definitions = append(definitions, service.ToImageDefinition())
+	}
+	definitions = append(definitions, e.Build.Services...)
+
+	var aliases []string
+	for _, definition := range definitions {
+		aliases = append(aliases, services.SplitNameAndVersion(definition.Name).Aliases...)
+		aliases = append(aliases, definition.Aliases()...)
+	}
+
+	return aliases
+}
@@ -0,0 +1,162 @@
+//go:build !integration
+
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/pull"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+)
+
+func TestCreateEgressProxy(t *testing.T) {
+	const buildNetwork = "runner-abcdef12-project-0-concurrent-0-job-0-network"
+
+	tests := map[string]struct {
+		egressPolicy  *common.DockerEgressPolicy
+		networkMode   string
+		buildNetwork  container.NetworkMode
+		osType        string
+		expectStarted bool
+		expectedErr   error
+	}{
+		"no egress policy": {
+			buildNetwork: buildNetwork,
+		},
+		"disabled egress policy": {
+			egressPolicy: &common.DockerEgressPolicy{Allow: []string{"10.0.0.0/8"}},
+			buildNetwork: buildNetwork,
+		},
+		"configured network mode": {
+			egressPolicy: &common.DockerEgressPolicy{Enabled: true},
+			networkMode:  "host",
+			buildNetwork: "host",
+			expectedErr:  errEgressPolicyNetworkMode,
+		},
+		"no per-build network": {
+			egressPolicy: &common.DockerEgressPolicy{Enabled: true},
+			expectedErr:  errEgressPolicyNetworkMode,
+		},
+		"windows": {
+			egressPolicy: &common.DockerEgressPolicy{Enabled: true},
+			buildNetwork: buildNetwork,
+			osType:       osTypeWindows,
+			expectedErr:  errEgressPolicyWindows,
+		},
+		"enabled egress policy": {
+			egressPolicy:  &common.DockerEgressPolicy{Enabled: true, Allow: []string{"10.0.0.0/8"}},
+			buildNetwork:  buildNetwork,
+			expectStarted: true,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			c := docker.NewMockClient(t)
+			p := pull.NewMockManager(t)
+
+			e := &executor{
+				client:      c,
+				pullManager: p,
+				networkMode: tt.buildNetwork,
+				info:        types.Info{OSType: tt.osType},
+			}
+			e.Context = context.Background()
+			e.BuildLogger = common.NewBuildLogger(&common.Trace{Writer: &bytes.Buffer{}}, logrus.WithField("test", tn))
+			e.Config.URL = "https://gitlab.example.com/"
+			e.Config.Docker = &common.DockerConfig{
+				EgressPolicy: tt.egressPolicy,
+				NetworkMode:  tt.networkMode,
+				HelperImage:  "gitlab/gitlab-runner-helper:latest",
+			}
+			e.Build = &common.Build{Runner: &common.RunnerConfig{}}
+			e.Build.Runner.Token = "abcdef1234567890"
+			require.NoError(t, e.createLabeler())
+
+			if tt.expectStarted {
+				p.On("GetDockerImage", "gitlab/gitlab-runner-helper:latest", common.ImageDockerOptions{}, []common.DockerPullPolicy(nil)).
+					Return(&types.ImageInspect{ID: "helper-image"}, nil).
+					Once()
+
+				c.On("NetworkList", e.Context, mock.Anything).Return(nil, nil).Once()
+				c.On("ContainerRemove", e.Context, mock.Anything, mock.Anything).Return(nil).Once()
+				c.On(
+					"ContainerCreate",
+					e.Context,
+					mock.MatchedBy(func(config *container.Config) bool {
+						return assert.Equal(t, "helper-image", config.Image) &&
+							assert.Equal(t, "egress-proxy", config.Labels["com.gitlab.gitlab-runner.type"]) &&
+							assert.Equal(t, []string{
+								"gitlab-runner-helper", "egress-proxy", "--listen", ":8080", "--block-metadata",
+								"--allow", "10.0.0.0/8", "--allow", "gitlab.example.com",
+							}, []string(config.Cmd))
+					}),
+					mock.MatchedBy(func(hostConfig *container.HostConfig) bool {
+						return assert.Empty(t, hostConfig.NetworkMode)
+					}),
+					(*network.NetworkingConfig)(nil),
+					(*v1.Platform)(nil),
+					mock.Anything,
+				).
+					Return(container.CreateResponse{ID: "proxy-id"}, nil).
+					Once()
+				c.On("NetworkConnect", e.Context, buildNetwork, "proxy-id", &network.EndpointSettings{Aliases: []string{"egress-proxy"}}).
+					Return(nil).
+					Once()
+				c.On("ContainerStart", e.Context, "proxy-id", types.ContainerStartOptions{}).Return(nil).Once()
+				c.On("ContainerLogs", e.Context, "proxy-id", mock.Anything).Return(nil, errors.New("no logs")).Once()
+			}
+
+			err := e.createEgressProxy()
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+
+			variables := e.Build.GetAllVariables()
+			if !tt.expectStarted {
+				assert.Empty(t, e.temporary)
+				assert.Empty(t, variables.Get("HTTPS_PROXY"))
+				return
+			}
+
+			assert.Equal(t, []string{"proxy-id"}, e.temporary)
+			assert.Equal(t, "http://egress-proxy:8080", variables.Get("HTTPS_PROXY"))
+			assert.Equal(t, "http://egress-proxy:8080", variables.Get("http_proxy"))
+			assert.Equal(t, "localhost,127.0.0.1,::1", variables.Get("NO_PROXY"))
+		})
+	}
+}
+
+func TestEgressProxyVariablesSkipServices(t *testing.T) {
+	e := &executor{}
+	e.Config.Docker = &common.DockerConfig{
+		Services: []common.Service{{Name: "registry.example.com/group/postgres:15"}},
+	}
+	e.Build = &common.Build{Runner: &common.RunnerConfig{}}
+	e.Build.Services = common.Services{{Name: "redis:7", Alias: "cache"}}
+
+	variables := e.egressProxyVariables()
+
+	assert.Equal(
+		t,
+		"localhost,127.0.0.1,::1,registry.example.com__group__postgres,registry.example.com-group-postgres,"+
+			"redis,cache",
+		variables.Get("no_proxy"),
+	)
+}
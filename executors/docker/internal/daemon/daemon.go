@@ -0,0 +1,103 @@
+// Package daemon detects the flavor of the daemon serving the Docker API, so
+// that the executor can adapt to the daemons that don't behave like a rootful
+// Docker daemon.
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+)
+
+const (
+	ModeAuto     = "auto"
+	ModeDocker   = "docker"
+	ModeRootless = "rootless"
+	ModePodman   = "podman"
+)
+
+var ErrUnsupportedMode = errors.New("unsupported daemon_mode")
+
+// Runtime describes the daemon the executor is connected to
+type Runtime struct {
+	// Podman is set when the Docker API is served by Podman
+	Podman bool
+	// Rootless is set when the daemon runs as an unprivileged user
+	Rootless bool
+}
+
+// KeepID reports whether the containers should be started with the keep-id
+// user namespace mode, which maps the user running the daemon to the same
+// user in the container. Only rootless Podman supports it.
+func (r Runtime) KeepID() bool {
+	return r.Podman && r.Rootless
+}
+
+func (r Runtime) String() string {
+	name := ModeDocker
+	if r.Podman {
+		name = ModePodman
+	}
+
+	if r.Rootless {
+		return "rootless " + name
+	}
+
+	return name
+}
+
+// Detect returns the runtime of the daemon for the configured mode. With the
+// auto mode, or no mode, it's detected from the daemon's version and info.
+func Detect(ctx context.Context, c docker.Client, info types.Info, mode string) (Runtime, error) {
+	switch mode {
+	case "", ModeAuto:
+	case ModeDocker:
+		return Runtime{}, nil
+	case ModeRootless:
+		return Runtime{Rootless: true}, nil
+	case ModePodman:
+		return Runtime{Podman: true, Rootless: isRootless(info)}, nil
+	default:
+		return Runtime{}, fmt.Errorf("%w: %q", ErrUnsupportedMode, mode)
+	}
+
+	version, err := c.ServerVersion(ctx)
+	if err != nil {
+		return Runtime{}, fmt.Errorf("detecting daemon mode: %w", err)
+	}
+
+	return Runtime{Podman: isPodman(version), Rootless: isRootless(info)}, nil
+}
+
+func isPodman(version types.Version) bool {
+	if strings.Contains(strings.ToLower(version.Platform.Name), "podman") {
+		return true
+	}
+
+	for _, component := range version.Components {
+		if strings.Contains(strings.ToLower(component.Name), "podman") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isRootless looks for the rootless security option, reported with the
+// `name=rootless` format by both Docker and Podman
+func isRootless(info types.Info) bool {
+	for _, option := range info.SecurityOptions {
+		for _, field := range strings.Split(option, ",") {
+			if field == "name=rootless" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,105 @@
+//go:build !integration
+
+package daemon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+)
+
+func TestDetect(t *testing.T) {
+	rootlessInfo := types.Info{SecurityOptions: []string{"name=seccomp,profile=default", "name=rootless"}}
+	podmanVersion := types.Version{
+		Components: []types.ComponentVersion{{Name: "Podman Engine", Version: "4.9.3"}},
+	}
+
+	tests := map[string]struct {
+		mode            string
+		info            types.Info
+		version         *types.Version
+		versionErr      error
+		expectedRuntime Runtime
+		expectedErr     error
+	}{
+		"auto, rootful docker": {
+			mode:            ModeAuto,
+			version:         &types.Version{Components: []types.ComponentVersion{{Name: "Engine"}}},
+			expectedRuntime: Runtime{},
+		},
+		"no mode, rootless docker": {
+			info: rootlessInfo,
+			version: &types.Version{
+				Platform: struct{ Name string }{Name: "Docker Engine - Community"},
+			},
+			expectedRuntime: Runtime{Rootless: true},
+		},
+		"auto, rootless podman": {
+			mode:            ModeAuto,
+			info:            rootlessInfo,
+			version:         &podmanVersion,
+			expectedRuntime: Runtime{Podman: true, Rootless: true},
+		},
+		"auto, rootful podman": {
+			mode:            ModeAuto,
+			version:         &podmanVersion,
+			expectedRuntime: Runtime{Podman: true},
+		},
+		"auto, version error": {
+			mode:        ModeAuto,
+			version:     &types.Version{},
+			versionErr:  assert.AnError,
+			expectedErr: assert.AnError,
+		},
+		"docker": {
+			mode:            ModeDocker,
+			info:            rootlessInfo,
+			expectedRuntime: Runtime{},
+		},
+		"rootless": {
+			mode:            ModeRootless,
+			expectedRuntime: Runtime{Rootless: true},
+		},
+		"podman": {
+			mode:            ModePodman,
+			info:            rootlessInfo,
+			expectedRuntime: Runtime{Podman: true, Rootless: true},
+		},
+		"unsupported mode": {
+			mode:        "containerd",
+			expectedErr: ErrUnsupportedMode,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			c := docker.NewMockClient(t)
+			if tt.version != nil {
+				c.On("ServerVersion", context.Background()).Return(*tt.version, tt.versionErr).Once()
+			}
+
+			runtime, err := Detect(context.Background(), c, tt.info, tt.mode)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedRuntime, runtime)
+		})
+	}
+}
+
+func TestRuntime(t *testing.T) {
+	assert.Equal(t, "docker", Runtime{}.String())
+	assert.Equal(t, "rootless podman", Runtime{Podman: true, Rootless: true}.String())
+
+	assert.True(t, Runtime{Podman: true, Rootless: true}.KeepID())
+	assert.False(t, Runtime{Podman: true}.KeepID())
+	assert.False(t, Runtime{Rootless: true}.KeepID())
+}
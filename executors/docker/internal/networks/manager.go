@@ -33,14 +33,26 @@ type manager struct {
 	networkMode  container.NetworkMode
 	buildNetwork types.NetworkResource
 	perBuild     bool
+
+	// alwaysPerBuild creates the build network even without the
+	// FF_NETWORK_PER_BUILD feature flag, for daemons that don't support the
+	// legacy container links
+	alwaysPerBuild bool
 }
 
-func NewManager(logger debugLogger, dockerClient docker.Client, build *common.Build, labeler labels.Labeler) Manager {
+func NewManager(
+	logger debugLogger,
+	dockerClient docker.Client,
+	build *common.Build,
+	labeler labels.Labeler,
+	alwaysPerBuild bool,
+) Manager {
 	return &manager{
-		logger:  logger,
-		client:  dockerClient,
-		build:   build,
-		labeler: labeler,
+		logger:         logger,
+		client:         dockerClient,
+		build:          build,
+		labeler:        labeler,
+		alwaysPerBuild: alwaysPerBuild,
 	}
 }
 
@@ -52,7 +64,7 @@ func (m *manager) Create(ctx context.Context, networkMode string, enableIPv6 boo
 		return m.networkMode, nil
 	}
 
-	if !m.build.IsFeatureFlagOn(featureflags.NetworkPerBuild) {
+	if !m.networkPerBuild() {
 		return m.networkMode, nil
 	}
 
@@ -70,6 +82,7 @@ func (m *manager) Create(ctx context.Context, networkMode string, enableIPv6 boo
 		types.NetworkCreate{
 			Labels:     m.labeler.Labels(map[string]string{}),
 			EnableIPv6: enableIPv6,
+			Internal:   internalNetwork(m.build.Runner.Docker),
 			Options:    networkOptionsFromConfig(m.build.Runner.Docker),
 		},
 	)
@@ -89,6 +102,10 @@ func (m *manager) Create(ctx context.Context, networkMode string, enableIPv6 boo
 	return m.networkMode, nil
 }
 
+func (m *manager) networkPerBuild() bool {
+	return m.alwaysPerBuild || m.build.IsFeatureFlagOn(featureflags.NetworkPerBuild)
+}
+
 func networkOptionsFromConfig(config *common.DockerConfig) map[string]string {
 	networkOptions := make(map[string]string)
 	if config != nil && config.NetworkMTU != 0 {
@@ -98,6 +115,12 @@ func networkOptionsFromConfig(config *common.DockerConfig) map[string]string {
 	return networkOptions
 }
 
+// internalNetwork reports whether the network is cut from the outside, when
+// the egress of the jobs goes through the egress proxy
+func internalNetwork(config *common.DockerConfig) bool {
+	return config != nil && config.EgressPolicy.IsEnabled()
+}
+
 func (m *manager) Inspect(ctx context.Context) (types.NetworkResource, error) {
 	if !m.perBuild {
 		return types.NetworkResource{}, nil
@@ -109,7 +132,7 @@ func (m *manager) Inspect(ctx context.Context) (types.NetworkResource, error) {
 }
 
 func (m *manager) Cleanup(ctx context.Context) error {
-	if !m.build.IsFeatureFlagOn(featureflags.NetworkPerBuild) {
+	if !m.networkPerBuild() {
 		return nil
 	}
 
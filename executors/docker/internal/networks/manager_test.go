@@ -30,7 +30,7 @@ func newDebugLoggerMock() *mockDebugLogger {
 func TestNewDefaultManager(t *testing.T) {
 	logger := newDebugLoggerMock()
 
-	m := NewManager(logger, nil, nil, nil)
+	m := NewManager(logger, nil, nil, nil, false)
 	assert.IsType(t, &manager{}, m)
 }
 
@@ -66,6 +66,7 @@ func TestCreateNetwork(t *testing.T) {
 	testCases := map[string]struct {
 		networkMode         string
 		networkPerBuild     string
+		alwaysPerBuild      bool
 		buildNetwork        types.NetworkResource
 		enableIPv6          bool
 		expectedNetworkMode container.NetworkMode
@@ -107,6 +108,33 @@ func TestCreateNetwork(t *testing.T) {
 					Once()
 			},
 		},
+		"network always created per-build": {
+			networkMode:         "",
+			networkPerBuild:     "false",
+			alwaysPerBuild:      true,
+			expectedNetworkMode: container.NetworkMode("runner-test-tok-project-0-concurrent-0-job-0-network"),
+			clientAssertions: func(mc *docker.MockClient) {
+				mc.On(
+					"NetworkCreate",
+					mock.Anything,
+					mock.AnythingOfType("string"),
+					mock.AnythingOfType("types.NetworkCreate"),
+				).
+					Return(types.NetworkCreateResponse{ID: "test-network"}, nil).
+					Once()
+				mc.On("NetworkInspect", mock.Anything, mock.AnythingOfType("string")).
+					Return(types.NetworkResource{
+						ID:   "test-network",
+						Name: "test-network",
+					}, nil).
+					Once()
+			},
+		},
+		"network always created per-build with network mode": {
+			networkMode:         "default",
+			alwaysPerBuild:      true,
+			expectedNetworkMode: container.NetworkMode("default"),
+		},
 		"network create per-build network failure": {
 			networkMode:         "",
 			networkPerBuild:     "true",
@@ -185,6 +213,7 @@ func TestCreateNetwork(t *testing.T) {
 			m := newDefaultManager()
 			m.build.ID = 0
 			m.buildNetwork = testCase.buildNetwork
+			m.alwaysPerBuild = testCase.alwaysPerBuild
 
 			client := addClient(m)
 			defer client.AssertExpectations(t)
@@ -278,6 +307,52 @@ func TestCreateNetworkWithCustomMTU(t *testing.T) {
 	}
 }
 
+func TestCreateInternalNetworkForEgressPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		egressPolicy     *common.DockerEgressPolicy
+		expectedInternal bool
+	}{
+		"no egress policy": {},
+		"disabled egress policy": {
+			egressPolicy: &common.DockerEgressPolicy{Allow: []string{"10.0.0.0/8"}},
+		},
+		"enabled egress policy": {
+			egressPolicy:     &common.DockerEgressPolicy{Enabled: true},
+			expectedInternal: true,
+		},
+	}
+
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			m := newDefaultManager()
+			m.alwaysPerBuild = true
+
+			client := addClient(m)
+			defer client.AssertExpectations(t)
+
+			m.build.Runner.Docker = &common.DockerConfig{EgressPolicy: testCase.egressPolicy}
+
+			client.On(
+				"NetworkCreate",
+				mock.Anything,
+				mock.AnythingOfType("string"),
+				mock.MatchedBy(func(options types.NetworkCreate) bool {
+					return options.Internal == testCase.expectedInternal
+				}),
+			).
+				Return(types.NetworkCreateResponse{ID: "test-network"}, nil).
+				Once()
+
+			client.On("NetworkInspect", mock.Anything, "test-network").
+				Return(types.NetworkResource{ID: "test-network"}, nil).
+				Once()
+
+			_, err := m.Create(context.Background(), "", false)
+			assert.NoError(t, err)
+		})
+	}
+}
+
 func TestInspectNetwork(t *testing.T) {
 	networkName := "test-network"
 	testError := errors.New("failure")
@@ -344,12 +419,22 @@ func TestCleanupNetwork(t *testing.T) {
 	testCases := map[string]struct {
 		networkMode      string
 		networkPerBuild  string
+		alwaysPerBuild   bool
 		clientAssertions func(*docker.MockClient)
 		expectErr        error
 	}{
 		"network per-build flag off": {
 			networkPerBuild: "false",
 		},
+		"network always created per-build": {
+			networkPerBuild: "false",
+			alwaysPerBuild:  true,
+			clientAssertions: func(mc *docker.MockClient) {
+				mc.On("NetworkRemove", mock.Anything, mock.AnythingOfType("string")).
+					Return(nil).
+					Once()
+			},
+		},
 		"network per-build flag on with defined network": {
 			networkPerBuild: "true",
 			networkMode:     "default",
@@ -386,7 +471,8 @@ func TestCleanupNetwork(t *testing.T) {
 				Value: testCase.networkPerBuild,
 			})
 
-			if testCase.networkPerBuild == "true" {
+			m.alwaysPerBuild = testCase.alwaysPerBuild
+			if testCase.networkPerBuild == "true" || testCase.alwaysPerBuild {
 				if testCase.networkMode == "" {
 					m.perBuild = true
 				}
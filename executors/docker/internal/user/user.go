@@ -39,6 +39,28 @@ type defaultInspect struct {
 	exec exec.Docker
 }
 
+// NewKeepIDInspect returns an Inspect for containers started with the keep-id
+// user namespace mode, where the containers run by default as the user
+// running the daemon instead of the user of the image
+func NewKeepIDInspect(c docker.Client, exec exec.Docker) Inspect {
+	return &keepIDInspect{
+		defaultInspect: defaultInspect{
+			c:    c,
+			exec: exec,
+		},
+	}
+}
+
+type keepIDInspect struct {
+	defaultInspect
+}
+
+// IsRoot can't tell from the image whether the container runs as root, so
+// the user is always detected from the running container
+func (i *keepIDInspect) IsRoot(_ context.Context, _ string) (bool, error) {
+	return false, nil
+}
+
 func (i *defaultInspect) IsRoot(ctx context.Context, imageID string) (bool, error) {
 	img, _, err := i.c.ImageInspectWithRaw(ctx, imageID)
 	if err != nil {
@@ -100,6 +100,17 @@ func TestDefaultInspect_IsRoot(t *testing.T) {
 	}
 }
 
+func TestKeepIDInspect_IsRoot(t *testing.T) {
+	clientMock := docker.NewMockClient(t)
+	execMock := exec.NewMockDocker(t)
+
+	inspect := NewKeepIDInspect(clientMock, execMock)
+
+	isRoot, err := inspect.IsRoot(context.Background(), "image-id")
+	assert.NoError(t, err)
+	assert.False(t, isRoot, "the user must be detected from the container")
+}
+
 type uidAndGidTestCase struct {
 	assertExecMock func(t *testing.T, clientMock *exec.MockDocker, expectedCtx context.Context)
 	expectedID     int
@@ -19,6 +19,7 @@ var ErrCacheVolumesDisabled = errors.New("cache volumes feature disabled")
 type Manager interface {
 	Create(ctx context.Context, volume string) error
 	CreateTemporary(ctx context.Context, destination string) error
+	CreateNamed(ctx context.Context, name string, destination string) error
 	RemoveTemporary(ctx context.Context) error
 	Binds() []string
 }
@@ -146,7 +147,7 @@ func (m *manager) addCacheVolume(ctx context.Context, volume *parser.Volume) err
 		return m.createHostBasedCacheVolume(volume.Destination)
 	}
 
-	_, err := m.createCacheVolume(ctx, volume.Destination, true, m.config.DriverOpts)
+	_, err := m.createCacheVolume(ctx, "", volume.Destination, true, m.config.DriverOpts)
 
 	return err
 }
@@ -174,6 +175,7 @@ func (m *manager) createHostBasedCacheVolume(destination string) error {
 
 func (m *manager) createCacheVolume(
 	ctx context.Context,
+	volumeName string,
 	destination string,
 	reusable bool,
 	driverOps map[string]string,
@@ -188,12 +190,15 @@ func (m *manager) createCacheVolume(
 		return "", fmt.Errorf("updating managed volumes list: %w", err)
 	}
 
-	name := m.config.TemporaryName
-	if reusable {
-		name = m.config.UniqueName
+	if volumeName == "" {
+		name := m.config.TemporaryName
+		if reusable {
+			name = m.config.UniqueName
+		}
+
+		volumeName = fmt.Sprintf("%s-cache-%s", name, hashPath(destination))
 	}
 
-	volumeName := fmt.Sprintf("%s-cache-%s", name, hashPath(destination))
 	vBody := volume.CreateOptions{
 		Name:       volumeName,
 		DriverOpts: driverOps,
@@ -226,7 +231,7 @@ func (m *manager) createCacheVolume(
 // It's up to the caller to clean up the temporary volumes by calling
 // `RemoveTemporary`.
 func (m *manager) CreateTemporary(ctx context.Context, destination string) error {
-	volumeName, err := m.createCacheVolume(ctx, destination, false, m.config.DriverOpts)
+	volumeName, err := m.createCacheVolume(ctx, "", destination, false, m.config.DriverOpts)
 	if err != nil {
 		return fmt.Errorf("creating cache volume: %w", err)
 	}
@@ -236,6 +241,18 @@ func (m *manager) CreateTemporary(ctx context.Context, destination string) error
 	return nil
 }
 
+// CreateNamed will create, or reuse when it already exists, the volume with
+// the specified name and mount it to the destination. The volume isn't
+// removed by `RemoveTemporary`, its lifecycle is managed by the caller.
+func (m *manager) CreateNamed(ctx context.Context, name string, destination string) error {
+	_, err := m.createCacheVolume(ctx, name, destination, true, m.config.DriverOpts)
+	if err != nil {
+		return fmt.Errorf("creating named volume: %w", err)
+	}
+
+	return nil
+}
+
 // RemoveTemporary will remove all the volumes that are marked as temporary. If
 // the volume is not found the error is ignored, any other error is returned to
 // the caller.
@@ -553,6 +553,65 @@ func TestDefaultManager_CreateTemporary(t *testing.T) {
 	}
 }
 
+func TestDefaultManager_CreateNamed(t *testing.T) {
+	volumeCreateErr := errors.New("volume-create")
+
+	testCases := map[string]struct {
+		volumeCreateErr error
+
+		expectedBindings []string
+		expectedError    error
+	}{
+		"volume created": {
+			expectedBindings: []string{"project-build-0:/builds/project/volume"},
+		},
+		"volume creation error": {
+			volumeCreateErr: volumeCreateErr,
+			expectedError:   volumeCreateErr,
+		},
+	}
+
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			config := ManagerConfig{
+				BasePath:      "/builds/project",
+				UniqueName:    "unique",
+				TemporaryName: "temporary",
+			}
+
+			m := newDefaultManager(config)
+			volumeParser := addUnixParser(m)
+			mClient := new(docker.MockClient)
+			m.client = mClient
+
+			defer func() {
+				mClient.AssertExpectations(t)
+				volumeParser.AssertExpectations(t)
+			}()
+
+			mClient.On(
+				"VolumeCreate",
+				mock.Anything,
+				mock.MatchedBy(func(v volume.CreateOptions) bool {
+					return testCreateOptionsContent(v, "project-build-0")
+				}),
+			).
+				Return(volume.Volume{Name: "project-build-0"}, testCase.volumeCreateErr).
+				Once()
+
+			err := m.CreateNamed(context.Background(), "project-build-0", "volume")
+			if testCase.expectedError != nil {
+				assert.ErrorIs(t, err, testCase.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Empty(t, m.temporaryVolumes)
+			assert.Equal(t, testCase.expectedBindings, m.Binds())
+		})
+	}
+}
+
 func TestDefaultManager_RemoveTemporary(t *testing.T) {
 	testErr := errors.New("test-err")
 	testCases := map[string]struct {
@@ -0,0 +1,19 @@
+package permission
+
+import (
+	"context"
+)
+
+type noopSetter struct{}
+
+// NewNoopSetter leaves the permissions of the volumes untouched, for daemons
+// where the user of the job containers already owns the volumes, like
+// rootless Podman with the keep-id user namespace mode.
+func NewNoopSetter() Setter {
+	return &noopSetter{}
+}
+
+// Set leaves the permissions of the volume untouched
+func (d *noopSetter) Set(_ context.Context, _ string, _ map[string]string) error {
+	return nil
+}
@@ -0,0 +1,278 @@
+package volumes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+)
+
+const (
+	defaultPoolCleanupInterval = time.Minute
+	defaultPoolCleanupTimeout  = 5 * time.Minute
+)
+
+// PoolPolicy defines when a pooled volume can't be reused anymore
+type PoolPolicy struct {
+	MaxAge  time.Duration
+	MaxSize int64
+}
+
+// PoolRequest describes the volume needed by a job
+type PoolRequest struct {
+	// Project identifies the volumes that can be shared, it's also used as
+	// the prefix of the volume names
+	Project     string
+	Credentials docker.Credentials
+	Policy      PoolPolicy
+}
+
+type pooledVolume struct {
+	name        string
+	project     string
+	credentials docker.Credentials
+	policy      PoolPolicy
+
+	createdAt time.Time
+	lastUsed  time.Time
+	size      int64
+	users     int
+	removing  bool
+}
+
+func (v *pooledVolume) expired(now time.Time) bool {
+	return v.policy.MaxAge > 0 && now.Sub(v.createdAt) >= v.policy.MaxAge
+}
+
+func (v *pooledVolume) oversized() bool {
+	return v.policy.MaxSize > 0 && v.size > v.policy.MaxSize
+}
+
+func (v *pooledVolume) reusable(req PoolRequest, now time.Time) bool {
+	return v.project == req.Project &&
+		v.credentials == req.Credentials &&
+		!v.removing &&
+		v.users == 0 &&
+		!v.expired(now) &&
+		!v.oversized()
+}
+
+// Pool keeps track of the volumes holding the build directory of the jobs,
+// so that they can be reused by the following jobs of the same project. The
+// volumes that are too old or too big are removed in the background once no
+// job uses them anymore.
+type Pool struct {
+	lock    sync.Mutex
+	volumes map[string]*pooledVolume
+
+	now       func() time.Time
+	newClient func(docker.Credentials) (docker.Client, error)
+	logger    logrus.FieldLogger
+	interval  time.Duration
+
+	janitorOnce sync.Once
+}
+
+func NewPool() *Pool {
+	return &Pool{
+		volumes: make(map[string]*pooledVolume),
+		now:     time.Now,
+		newClient: func(c docker.Credentials) (docker.Client, error) {
+			return docker.New(c)
+		},
+		logger:   logrus.WithField("component", "build-volumes-pool"),
+		interval: defaultPoolCleanupInterval,
+	}
+}
+
+// Acquire returns the name of the volume the job should use, together with
+// the function releasing it once the job is done. A volume is used by one job
+// at a time, as the jobs of a project check out the repository in the same
+// project directory. The most recently used idle volume is preferred. Volumes
+// names are deterministic, so that the volumes left by a previous process are
+// adopted again.
+func (p *Pool) Acquire(req PoolRequest) (string, func()) {
+	p.janitorOnce.Do(func() {
+		go p.runJanitor()
+	})
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := p.now()
+
+	var selected *pooledVolume
+	for _, v := range p.volumes {
+		if !v.reusable(req, now) {
+			continue
+		}
+
+		if selected == nil || v.lastUsed.After(selected.lastUsed) {
+			selected = v
+		}
+	}
+
+	if selected == nil {
+		selected = p.allocate(req, now)
+	}
+
+	selected.policy = req.Policy
+	selected.users++
+	selected.lastUsed = now
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			p.release(selected)
+		})
+	}
+
+	return selected.name, release
+}
+
+func (p *Pool) allocate(req PoolRequest, now time.Time) *pooledVolume {
+	for i := 0; ; i++ {
+		name := fmt.Sprintf("%s-build-%d", req.Project, i)
+		if _, ok := p.volumes[name]; ok {
+			continue
+		}
+
+		v := &pooledVolume{
+			name:        name,
+			project:     req.Project,
+			credentials: req.Credentials,
+			createdAt:   now,
+		}
+		p.volumes[name] = v
+
+		return v
+	}
+}
+
+func (p *Pool) release(v *pooledVolume) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	v.users--
+	v.lastUsed = p.now()
+}
+
+func (p *Pool) runJanitor() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultPoolCleanupTimeout)
+		p.cleanup(ctx)
+		cancel()
+	}
+}
+
+// cleanup refreshes the size of the pooled volumes and removes the idle ones
+// which exceed their policy
+func (p *Pool) cleanup(ctx context.Context) {
+	for creds, volumes := range p.limitedVolumes() {
+		client, err := p.newClient(creds)
+		if err != nil {
+			p.logger.WithError(err).Warningln("Failed to connect to Docker to clean up build volumes")
+			continue
+		}
+
+		p.updateUsage(ctx, client, volumes)
+
+		for _, v := range p.reserveRemovable(volumes) {
+			p.remove(ctx, client, v)
+		}
+
+		_ = client.Close()
+	}
+}
+
+func (p *Pool) limitedVolumes() map[docker.Credentials][]*pooledVolume {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	volumes := make(map[docker.Credentials][]*pooledVolume)
+	for _, v := range p.volumes {
+		if v.policy.MaxAge <= 0 && v.policy.MaxSize <= 0 {
+			continue
+		}
+
+		volumes[v.credentials] = append(volumes[v.credentials], v)
+	}
+
+	return volumes
+}
+
+func (p *Pool) updateUsage(ctx context.Context, client docker.Client, volumes []*pooledVolume) {
+	du, err := client.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+	if err != nil {
+		p.logger.WithError(err).Warningln("Failed to get the disk usage of build volumes")
+		return
+	}
+
+	byName := make(map[string]*pooledVolume, len(volumes))
+	for _, v := range volumes {
+		byName[v.name] = v
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, dv := range du.Volumes {
+		v, ok := byName[dv.Name]
+		if !ok {
+			continue
+		}
+
+		if dv.UsageData != nil && dv.UsageData.Size >= 0 {
+			v.size = dv.UsageData.Size
+		}
+
+		// volumes adopted from a previous process are older than their entry
+		createdAt, err := time.Parse(time.RFC3339, dv.CreatedAt)
+		if err == nil && createdAt.Before(v.createdAt) {
+			v.createdAt = createdAt
+		}
+	}
+}
+
+func (p *Pool) reserveRemovable(volumes []*pooledVolume) []*pooledVolume {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := p.now()
+
+	var removable []*pooledVolume
+	for _, v := range volumes {
+		if v.users > 0 || v.removing || (!v.expired(now) && !v.oversized()) {
+			continue
+		}
+
+		v.removing = true
+		removable = append(removable, v)
+	}
+
+	return removable
+}
+
+func (p *Pool) remove(ctx context.Context, client docker.Client, v *pooledVolume) {
+	err := client.VolumeRemove(ctx, v.name, false)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err != nil && !docker.IsErrNotFound(err) {
+		p.logger.WithError(err).WithField("volume", v.name).Warningln("Failed to remove build volume")
+		v.removing = false
+		return
+	}
+
+	p.logger.WithField("volume", v.name).Debugln("Removed build volume")
+	delete(p.volumes, v.name)
+}
@@ -0,0 +1,153 @@
+//go:build !integration
+
+package volumes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+)
+
+func newTestPool(t *testing.T, now *time.Time) (*Pool, *docker.MockClient) {
+	client := docker.NewMockClient(t)
+	logger, _ := test.NewNullLogger()
+
+	p := NewPool()
+	p.now = func() time.Time { return *now }
+	p.newClient = func(docker.Credentials) (docker.Client, error) { return client, nil }
+	p.logger = logger
+	p.interval = time.Hour
+
+	return p, client
+}
+
+func TestPool_Acquire(t *testing.T) {
+	now := time.Now()
+	p, _ := newTestPool(t, &now)
+
+	req := PoolRequest{Project: "project-1"}
+
+	name1, release1 := p.Acquire(req)
+	assert.Equal(t, "project-1-build-0", name1)
+
+	name2, release2 := p.Acquire(req)
+	assert.Equal(t, "project-1-build-1", name2)
+
+	other, releaseOther := p.Acquire(PoolRequest{Project: "project-2"})
+	assert.Equal(t, "project-2-build-0", other)
+	releaseOther()
+
+	now = now.Add(time.Minute)
+	release2()
+	release2()
+
+	now = now.Add(time.Minute)
+	release1()
+
+	name, release := p.Acquire(req)
+	assert.Equal(t, "project-1-build-0", name, "the most recently used idle volume is reused")
+
+	name2, release2 = p.Acquire(req)
+	assert.Equal(t, "project-1-build-1", name2, "a volume is used by one job at a time")
+	release()
+	release2()
+}
+
+func TestPool_AcquireSkipsExceedingVolumes(t *testing.T) {
+	now := time.Now()
+	p, _ := newTestPool(t, &now)
+
+	req := PoolRequest{
+		Project: "project",
+		Policy:  PoolPolicy{MaxAge: time.Hour, MaxSize: 100},
+	}
+
+	name, release := p.Acquire(req)
+	release()
+	assert.Equal(t, "project-build-0", name)
+
+	p.volumes[name].size = 200
+
+	name, release = p.Acquire(req)
+	release()
+	assert.Equal(t, "project-build-1", name, "oversized volumes aren't reused")
+
+	now = now.Add(time.Hour)
+
+	name, release = p.Acquire(req)
+	release()
+	assert.Equal(t, "project-build-2", name, "expired volumes aren't reused")
+}
+
+func TestPool_Cleanup(t *testing.T) {
+	now := time.Now()
+	p, client := newTestPool(t, &now)
+
+	policy := PoolPolicy{MaxAge: time.Hour, MaxSize: 100}
+
+	oversized, release := p.Acquire(PoolRequest{Project: "oversized", Policy: policy})
+	release()
+	expired, release := p.Acquire(PoolRequest{Project: "expired", Policy: policy})
+	release()
+	inUse, releaseInUse := p.Acquire(PoolRequest{Project: "in-use", Policy: policy})
+	kept, release := p.Acquire(PoolRequest{Project: "kept", Policy: policy})
+	release()
+	failing, release := p.Acquire(PoolRequest{Project: "failing", Policy: policy})
+	release()
+	unlimited, release := p.Acquire(PoolRequest{Project: "unlimited"})
+	release()
+
+	client.On("DiskUsage", mock.Anything, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}}).
+		Return(types.DiskUsage{Volumes: []*volume.Volume{
+			{Name: oversized, UsageData: &volume.UsageData{Size: 200}},
+			{Name: expired, CreatedAt: now.Add(-2 * time.Hour).Format(time.RFC3339)},
+			{Name: inUse, UsageData: &volume.UsageData{Size: 200}},
+			{Name: kept, UsageData: &volume.UsageData{Size: 50}},
+			{Name: failing, UsageData: &volume.UsageData{Size: 200}},
+		}}, nil).
+		Once()
+	client.On("VolumeRemove", mock.Anything, oversized, false).Return(nil).Once()
+	client.On("VolumeRemove", mock.Anything, expired, false).Return(fmt.Errorf("removing volume: %w", errdefs.NotFound(errors.New("not found")))).Once()
+	client.On("VolumeRemove", mock.Anything, failing, false).Return(errors.New("in use")).Once()
+	client.On("Close").Return(nil).Once()
+
+	p.cleanup(context.Background())
+
+	assert.NotContains(t, p.volumes, oversized)
+	assert.NotContains(t, p.volumes, expired)
+	assert.Contains(t, p.volumes, failing, "volumes failing to be removed are kept")
+	assert.False(t, p.volumes[failing].removing)
+	assert.Contains(t, p.volumes, inUse)
+	assert.Contains(t, p.volumes, kept)
+	assert.Contains(t, p.volumes, unlimited)
+
+	releaseInUse()
+}
+
+func TestPool_CleanupClientError(t *testing.T) {
+	now := time.Now()
+	p, _ := newTestPool(t, &now)
+	p.newClient = func(docker.Credentials) (docker.Client, error) {
+		return nil, errors.New("connection refused")
+	}
+
+	name, release := p.Acquire(PoolRequest{Project: "project", Policy: PoolPolicy{MaxAge: time.Minute}})
+	release()
+
+	now = now.Add(time.Hour)
+	p.cleanup(context.Background())
+
+	require.Contains(t, p.volumes, name)
+}
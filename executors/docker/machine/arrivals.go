@@ -0,0 +1,132 @@
+package machine
+
+import (
+	"sync"
+	"time"
+)
+
+// arrivalSlot is the time-of-day granularity of the recorded job arrivals
+const arrivalSlot = 15 * time.Minute
+
+const slotsPerDay = int(24 * time.Hour / arrivalSlot)
+
+// arrivalHistory records the job arrivals of a runner per time-of-day slot,
+// for the last days. A row holds the arrivals of one day and is reused once
+// the day is older than the history.
+type arrivalHistory struct {
+	lock sync.Mutex
+
+	days     int
+	rows     [][]int
+	rowDays  []int
+	firstDay int
+	recorded bool
+
+	location *time.Location
+}
+
+func newArrivalHistory(days int) *arrivalHistory {
+	h := &arrivalHistory{
+		days:     days,
+		rows:     make([][]int, days),
+		rowDays:  make([]int, days),
+		location: time.Local,
+	}
+
+	for i := range h.rows {
+		h.rows[i] = make([]int, slotsPerDay)
+	}
+
+	return h
+}
+
+// record adds a job arrival at the given time
+func (h *arrivalHistory) record(at time.Time) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	day, slot, _ := h.position(at)
+	if !h.recorded {
+		h.firstDay = day
+		h.recorded = true
+	}
+
+	row := day % h.days
+	if h.rowDays[row] != day {
+		h.rowDays[row] = day
+		for i := range h.rows[row] {
+			h.rows[row][i] = 0
+		}
+	}
+
+	h.rows[row][slot]++
+}
+
+// predict returns the number of jobs expected to arrive within the window
+// starting at the given time. It's the average of the arrivals of the past
+// days over the same time-of-day window; the current day isn't complete and
+// isn't part of the average.
+func (h *arrivalHistory) predict(from time.Time, window time.Duration) float64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if !h.recorded {
+		return 0
+	}
+
+	var expected float64
+	for at, remaining := from, window; remaining > 0; {
+		day, slot, intoSlot := h.position(at)
+
+		covered := arrivalSlot - intoSlot
+		if covered > remaining {
+			covered = remaining
+		}
+
+		expected += h.average(day, slot) * covered.Seconds() / arrivalSlot.Seconds()
+
+		at = at.Add(covered)
+		remaining -= covered
+	}
+
+	return expected
+}
+
+// average returns the average arrivals in the slot over the days preceding
+// the given day. The days without any arrival since the history started
+// count as zero.
+func (h *arrivalHistory) average(day int, slot int) float64 {
+	days := day - h.firstDay
+	if days > h.days {
+		days = h.days
+	}
+
+	if days <= 0 {
+		return 0
+	}
+
+	total := 0
+	for row, rowDay := range h.rowDays {
+		if rowDay >= day-days && rowDay < day {
+			total += h.rows[row][slot]
+		}
+	}
+
+	return float64(total) / float64(days)
+}
+
+// position returns the day number, the time-of-day slot and the time elapsed
+// in the slot at the given time
+func (h *arrivalHistory) position(at time.Time) (int, int, time.Duration) {
+	at = at.In(h.location)
+
+	date := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	day := int(date.Unix() / int64((24 * time.Hour).Seconds()))
+
+	sinceMidnight := time.Duration(at.Hour())*time.Hour +
+		time.Duration(at.Minute())*time.Minute +
+		time.Duration(at.Second())*time.Second +
+		time.Duration(at.Nanosecond())
+
+	return day, int(sinceMidnight / arrivalSlot), sinceMidnight % arrivalSlot
+}
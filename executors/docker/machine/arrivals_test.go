@@ -0,0 +1,108 @@
+//go:build !integration
+
+package machine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestArrivalHistory(days int) *arrivalHistory {
+	h := newArrivalHistory(days)
+	h.location = time.UTC
+
+	return h
+}
+
+func TestArrivalHistoryPredict(t *testing.T) {
+	day := func(d int, hour int, minute int) time.Time {
+		return time.Date(2023, time.March, d, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := map[string]struct {
+		days             int
+		arrivals         []time.Time
+		from             time.Time
+		window           time.Duration
+		expectedArrivals float64
+	}{
+		"no arrivals": {
+			days:             7,
+			from:             day(10, 9, 0),
+			window:           15 * time.Minute,
+			expectedArrivals: 0,
+		},
+		"arrivals of the current day only": {
+			days:             7,
+			arrivals:         []time.Time{day(10, 8, 0), day(10, 9, 5)},
+			from:             day(10, 9, 0),
+			window:           15 * time.Minute,
+			expectedArrivals: 0,
+		},
+		"arrivals of the previous day": {
+			days:             7,
+			arrivals:         []time.Time{day(9, 9, 1), day(9, 9, 2), day(9, 9, 20), day(10, 9, 3)},
+			from:             day(10, 9, 0),
+			window:           15 * time.Minute,
+			expectedArrivals: 2,
+		},
+		"averaged over the recorded days": {
+			days: 7,
+			arrivals: []time.Time{
+				day(7, 9, 1), day(7, 9, 2), day(7, 9, 3), day(7, 9, 4),
+				day(9, 9, 1), day(9, 9, 2),
+			},
+			from:             day(10, 9, 0),
+			window:           15 * time.Minute,
+			expectedArrivals: 2,
+		},
+		"days older than the history": {
+			days: 2,
+			arrivals: []time.Time{
+				day(7, 9, 1), day(7, 9, 2), day(7, 9, 3), day(7, 9, 4),
+				day(9, 9, 1), day(9, 9, 2),
+			},
+			from:             day(10, 9, 0),
+			window:           15 * time.Minute,
+			expectedArrivals: 1,
+		},
+		"window overlapping slots": {
+			days:             7,
+			arrivals:         []time.Time{day(9, 9, 1), day(9, 9, 2), day(9, 9, 20), day(9, 9, 21)},
+			from:             day(10, 9, 10),
+			window:           10 * time.Minute,
+			expectedArrivals: 5.0/15*2 + 5.0/15*2,
+		},
+		"window crossing midnight": {
+			days:             7,
+			arrivals:         []time.Time{day(8, 0, 5), day(8, 23, 55), day(9, 0, 5)},
+			from:             day(9, 23, 50),
+			window:           20 * time.Minute,
+			expectedArrivals: 10.0/15*1 + 10.0/15*2/2,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			h := newTestArrivalHistory(tt.days)
+			for _, at := range tt.arrivals {
+				h.record(at)
+			}
+
+			assert.InDelta(t, tt.expectedArrivals, h.predict(tt.from, tt.window), 0.0001)
+		})
+	}
+}
+
+func TestArrivalHistoryReusesRows(t *testing.T) {
+	h := newTestArrivalHistory(2)
+
+	h.record(time.Date(2023, time.March, 7, 9, 0, 0, 0, time.UTC))
+	h.record(time.Date(2023, time.March, 9, 10, 0, 0, 0, time.UTC))
+
+	// The arrivals of March 7th were overwritten by the ones of March 9th
+	assert.Zero(t, h.predict(time.Date(2023, time.March, 10, 9, 0, 0, 0, time.UTC), 15*time.Minute))
+	assert.Equal(t, 0.5, h.predict(time.Date(2023, time.March, 10, 10, 0, 0, 0, time.UTC), 15*time.Minute))
+}
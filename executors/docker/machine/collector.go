@@ -27,6 +27,8 @@ func (m *machineProvider) Describe(ch chan<- *prometheus.Desc) {
 	m.stoppingHistogram.Describe(ch)
 	m.removalHistogram.Describe(ch)
 	m.failedCreationHistogram.Describe(ch)
+	m.jobArrivals.Describe(ch)
+	m.predictedArrivals.Describe(ch)
 	ch <- m.currentStatesDesc
 }
 
@@ -75,4 +77,6 @@ func (m *machineProvider) Collect(ch chan<- prometheus.Metric) {
 	m.stoppingHistogram.Collect(ch)
 	m.removalHistogram.Collect(ch)
 	m.failedCreationHistogram.Collect(ch)
+	m.jobArrivals.Collect(ch)
+	m.predictedArrivals.Collect(ch)
 }
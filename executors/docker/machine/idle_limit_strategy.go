@@ -15,27 +15,34 @@ const (
 	removeIdleReasonTooManyIdleMachines removeIdleReason = "too many idle machines"
 )
 
-func canCreateIdle(config *common.RunnerConfig, data *machinesData) bool {
-	return canCreate(config, data, false)
+func canCreateIdle(config *common.RunnerConfig, data *machinesData, scaling scalingStrategy) bool {
+	return canCreate(config, data, scaling, false)
 }
 
-func canCreateOnDemand(config *common.RunnerConfig, data *machinesData) bool {
-	return canCreate(config, data, true)
+func canCreateOnDemand(config *common.RunnerConfig, data *machinesData, scaling scalingStrategy) bool {
+	return canCreate(config, data, scaling, true)
 }
 
-func canCreate(config *common.RunnerConfig, data *machinesData, onDemand bool) bool {
+func canCreate(config *common.RunnerConfig, data *machinesData, scaling scalingStrategy, onDemand bool) bool {
 	ils := &idleLimitStrategy{
-		config: config,
-		data:   data,
+		config:  config,
+		data:    data,
+		scaling: scaling,
 	}
 
 	return ils.canCreate(onDemand)
 }
 
-func shouldRemoveIdle(config *common.RunnerConfig, data *machinesData, details machineInfo) removeIdleReason {
+func shouldRemoveIdle(
+	config *common.RunnerConfig,
+	data *machinesData,
+	scaling scalingStrategy,
+	details machineInfo,
+) removeIdleReason {
 	ils := &idleLimitStrategy{
 		config:  config,
 		data:    data,
+		scaling: scaling,
 		details: details,
 	}
 
@@ -45,6 +52,7 @@ func shouldRemoveIdle(config *common.RunnerConfig, data *machinesData, details m
 type idleLimitStrategy struct {
 	config  *common.RunnerConfig
 	data    *machinesData
+	scaling scalingStrategy
 	details machineInfo
 }
 
@@ -63,7 +71,7 @@ func (ils *idleLimitStrategy) canCreate(onDemand bool) bool {
 		return true
 	}
 
-	return !ils.composedIdleMachinesExceeded()
+	return !ils.scaling.idleMachinesExceeded(ils.config, ils.data)
 }
 
 // shouldRemove checks if the machine is in Idle state
@@ -81,7 +89,7 @@ func (ils *idleLimitStrategy) shouldRemove() removeIdleReason {
 		return removeIdleReasonTooManyMachines
 	}
 
-	if ils.idleTimeExceeded() && ils.composedIdleMachinesExceeded() {
+	if ils.idleTimeExceeded() && ils.scaling.idleMachinesExceeded(ils.config, ils.data) {
 		return removeIdleReasonTooManyIdleMachines
 	}
 
@@ -112,59 +120,6 @@ func (ils *idleLimitStrategy) totalMachinesExceeded() bool {
 	return ils.data.Total() >= ils.config.Limit
 }
 
-// composedIdleMachinesExceeded checks several conditions that can evaluate
-// as "number of Idle Machines exceeded".
-func (ils *idleLimitStrategy) composedIdleMachinesExceeded() bool {
-	return ils.idleMachinesExceeded() ||
-		(ils.idleCountMinFulfilled() && ils.idleMachinesScaleFactorExceeded())
-}
-
-// idleMachinesExceeded checks whether runner reached the defined IdleCount
-// which is the maximum number of Idle machines that can exist.
-func (ils *idleLimitStrategy) idleMachinesExceeded() bool {
-	return ils.data.Available() >= ils.config.Machine.GetIdleCount()
-}
-
-// idleCountMinFulfilled checks if the IdleCountMin setting is fulfilled.
-// Should be used to ensure that the minimal number of Idle machines is created.
-func (ils *idleLimitStrategy) idleCountMinFulfilled() bool {
-	min := ils.config.Machine.GetIdleCountMin()
-
-	// When IdleScaleFactor is in use, there is a risk that with no executed jobs
-	// the desired number of Idle machines to maintain will also evaluate to 0.
-	// This could cause in removing all Idle machines. In that case Runner would
-	// stop asking for new jobs (with IdleCount > 0 Runner doesn't ask for jobs
-	// if there is no Idle machines awaiting to be used). And without new jobs using
-	// some machines, the IdleScaleFactor would be constantly evaluated to 0.
-	// This would lock the Runner in a state where no job can't be started because
-	// no machines are in Idle, and no machines are in Idle because no jobs are started.
-	//
-	// Therefore, in case when IdleScaleFactor is greater than 0 and IdleCountMin
-	// was not defined or intentionally set to 0, it will be forced to be at least
-	// 1. So that there is at least one Idle machine that can handle a job and allow
-	// the IdleScaleFactor to bring more of them later.
-	if ils.config.Machine.GetIdleScaleFactor() > 0 && min < 1 {
-		min = 1
-	}
-
-	return ils.data.Available() >= min
-}
-
-// idleMachinesScaleFactorExceeded checks whether runner reached the number
-// of machines defined as a factor of in-use ones.
-// This behavior is optional and depends on the IdleScaleFactor setting.
-// When it's set to 0 then it's ignored.
-func (ils *idleLimitStrategy) idleMachinesScaleFactorExceeded() bool {
-	idleScaleFactor := ils.config.Machine.GetIdleScaleFactor()
-	if idleScaleFactor <= 0 {
-		return false
-	}
-
-	desiredCapacity := int(float64(ils.data.InUse()) * idleScaleFactor)
-
-	return ils.data.Available() >= desiredCapacity
-}
-
 // machineUsageCountExceeded checks whether the machine was used more times than
 // the defined MaxBuilds setting.
 // MaxBuild=0 means that there is no limit how many subsequent jobs the machine
@@ -158,7 +158,7 @@ func TestCanCreateIdle(t *testing.T) {
 
 	for tn, tt := range tests {
 		t.Run(tn, func(t *testing.T) {
-			result := canCreateIdle(ilsNewRunnerConfig(tt.config), ilsNewMachinesData(tt.data))
+			result := canCreateIdle(ilsNewRunnerConfig(tt.config), ilsNewMachinesData(tt.data), classicScalingStrategy{})
 			assert.Equal(t, tt.expectedCanCreate, result)
 		})
 	}
@@ -272,7 +272,7 @@ func TestCanCreateOnDemand(t *testing.T) {
 
 	for tn, tt := range tests {
 		t.Run(tn, func(t *testing.T) {
-			result := canCreateOnDemand(ilsNewRunnerConfig(tt.config), ilsNewMachinesData(tt.data))
+			result := canCreateOnDemand(ilsNewRunnerConfig(tt.config), ilsNewMachinesData(tt.data), classicScalingStrategy{})
 			assert.Equal(t, tt.expectedCanCreate, result)
 		})
 	}
@@ -407,6 +407,7 @@ func TestShouldRemoveIdle(t *testing.T) {
 			result := shouldRemoveIdle(
 				ilsNewRunnerConfig(tt.config),
 				ilsNewMachinesData(tt.data),
+				classicScalingStrategy{},
 				ilsNewMachineDetails(tt.details).info(),
 			)
 			assert.Equal(t, tt.expectedReason, result)
@@ -119,6 +119,13 @@ func testMachineCredentialsUsage(t *testing.T, name string, runnerConfigSource f
 					},
 					[]string{"action"},
 				),
+				jobArrivals: prometheus.NewCounterVec(
+					prometheus.CounterOpts{
+						Name: "job_arrivals_total",
+						Help: "job_arrivals_total",
+					},
+					[]string{"runner"},
+				),
 			},
 		}
 		err := e.Prepare(options)
@@ -3,6 +3,7 @@ package machine
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,6 +26,10 @@ type machineProvider struct {
 
 	stuckRemoveLock sync.Mutex
 
+	// arrivals stores the job arrivals of the runners, used by the predictive scaling strategy
+	arrivals     map[string]*arrivalHistory
+	arrivalsLock sync.Mutex
+
 	// metrics
 	totalActions            *prometheus.CounterVec
 	currentStatesDesc       *prometheus.Desc
@@ -32,6 +37,8 @@ type machineProvider struct {
 	stoppingHistogram       prometheus.Histogram
 	removalHistogram        prometheus.Histogram
 	failedCreationHistogram prometheus.Histogram
+	jobArrivals             *prometheus.CounterVec
+	predictedArrivals       *prometheus.GaugeVec
 }
 
 func (m *machineProvider) machineDetails(name string, acquire bool) *machineDetails {
@@ -363,6 +370,7 @@ func (m *machineProvider) remove(machineName string, reason ...interface{}) erro
 func (m *machineProvider) updateMachines(
 	machines []string,
 	config *common.RunnerConfig,
+	scaling scalingStrategy,
 ) (data machinesData, validMachines []string) {
 	data.Runner = config.ShortDescription()
 	validMachines = make([]string, 0, len(machines))
@@ -375,7 +383,7 @@ func (m *machineProvider) updateMachines(
 		info := details.info()
 		details.Unlock()
 
-		reason := shouldRemoveIdle(config, &data, info)
+		reason := shouldRemoveIdle(config, &data, scaling, info)
 		if reason == dontRemoveIdleMachine {
 			validMachines = append(validMachines, name)
 		} else {
@@ -394,9 +402,9 @@ func (m *machineProvider) updateMachines(
 
 // createMachines starts goroutines that are creating the new machines.
 // Limiting strategy is used to ensure the autoscaling parameters are respected.
-func (m *machineProvider) createMachines(config *common.RunnerConfig, data *machinesData) {
+func (m *machineProvider) createMachines(config *common.RunnerConfig, data *machinesData, scaling scalingStrategy) {
 	for {
-		if !canCreateIdle(config, data) {
+		if !canCreateIdle(config, data, scaling) {
 			return
 		}
 
@@ -470,11 +478,13 @@ func (m *machineProvider) Acquire(config *common.RunnerConfig) (common.ExecutorD
 		return nil, err
 	}
 
+	scaling := m.scalingStrategy(config)
+
 	// Update a list of currently configured machines
-	machinesData, validMachines := m.updateMachines(machines, config)
+	machinesData, validMachines := m.updateMachines(machines, config, scaling)
 
 	// Pre-create machines
-	m.createMachines(config, &machinesData)
+	m.createMachines(config, &machinesData, scaling)
 
 	logger := logrus.WithFields(machinesData.Fields()).
 		WithField("runner", config.ShortDescription()).
@@ -482,7 +492,8 @@ func (m *machineProvider) Acquire(config *common.RunnerConfig) (common.ExecutorD
 		WithField("idleCount", config.Machine.GetIdleCount()).
 		WithField("idleScaleFactor", config.Machine.GetIdleScaleFactor()).
 		WithField("maxMachines", config.Limit).
-		WithField("maxMachineCreate", config.Machine.MaxGrowthRate)
+		WithField("maxMachineCreate", config.Machine.MaxGrowthRate).
+		WithField("scalingStrategy", config.Machine.ScalingStrategy)
 
 	logger.WithField("time", time.Now()).Debugln("Docker Machine Details")
 	machinesData.writeDebugInformation()
@@ -493,7 +504,7 @@ func (m *machineProvider) Acquire(config *common.RunnerConfig) (common.ExecutorD
 		return details, nil
 	}
 
-	if config.Machine.GetIdleCount() == 0 && canCreateOnDemand(config, &machinesData) {
+	if config.Machine.GetIdleCount() == 0 && canCreateOnDemand(config, &machinesData, scaling) {
 		logger.Debug("IdleCount is set to 0 so the machine will be created on demand in job context")
 	} else if machinesData.Idle == 0 {
 		return nil, &common.NoFreeExecutorError{Message: "no free machines that can process builds"}
@@ -553,9 +564,56 @@ func (m *machineProvider) Use(
 	details.Unlock()
 
 	m.totalActions.WithLabelValues("used").Inc()
+	m.recordArrival(config)
 	return
 }
 
+// scalingStrategy returns the strategy deciding the number of idle machines
+// of the runner
+func (m *machineProvider) scalingStrategy(config *common.RunnerConfig) scalingStrategy {
+	switch config.Machine.ScalingStrategy {
+	case "", scalingStrategyClassic:
+		return classicScalingStrategy{}
+	case scalingStrategyPredictive:
+		predicted := m.arrivalHistory(config).predict(time.Now(), config.Machine.GetPredictiveLeadTime())
+		m.predictedArrivals.WithLabelValues(config.ShortDescription()).Set(predicted)
+
+		return predictiveScalingStrategy{predictedArrivals: predicted}
+	default:
+		logrus.WithField("runner", config.ShortDescription()).
+			Warningln("Unknown scaling strategy", config.Machine.ScalingStrategy, "using", scalingStrategyClassic)
+
+		return classicScalingStrategy{}
+	}
+}
+
+// recordArrival records a job started on a machine of the runner. The
+// arrivals are recorded whatever the scaling strategy, so that the history
+// is available when the predictive strategy gets enabled.
+func (m *machineProvider) recordArrival(config *common.RunnerConfig) {
+	m.arrivalHistory(config).record(time.Now())
+	m.jobArrivals.WithLabelValues(config.ShortDescription()).Inc()
+}
+
+func (m *machineProvider) arrivalHistory(config *common.RunnerConfig) *arrivalHistory {
+	m.arrivalsLock.Lock()
+	defer m.arrivalsLock.Unlock()
+
+	if m.arrivals == nil {
+		m.arrivals = make(map[string]*arrivalHistory)
+	}
+
+	days := config.Machine.GetPredictiveHistoryDays()
+
+	history, ok := m.arrivals[config.GetToken()]
+	if !ok || history.days != days {
+		history = newArrivalHistory(days)
+		m.arrivals[config.GetToken()] = history
+	}
+
+	return history
+}
+
 func (m *machineProvider) Release(config *common.RunnerConfig, data common.ExecutorData) {
 	// Release machine
 	details, ok := data.(*machineDetails)
@@ -602,6 +660,76 @@ func (m *machineProvider) signalRelease(config *common.RunnerConfig) error {
 	return nil
 }
 
+// machineCreationSettings are the settings passed to docker-machine when the
+// machines are created
+var machineCreationSettings = []string{
+	"machine.MachineDriver",
+	"machine.MachineName",
+	"machine.MachineOptions",
+}
+
+// Reconfigure removes the idle machines of a removed runner, or of a runner
+// whose machines are now created differently, so that the runner doesn't
+// keep machines it won't use or which don't match its settings. The other
+// settings are read on each job request and don't need to be reconfigured.
+func (m *machineProvider) Reconfigure(change common.RunnerConfigChange) error {
+	previous, current := change.Previous, change.Current
+	if previous == nil || previous.Machine == nil || previous.Machine.MachineName == "" {
+		return nil
+	}
+
+	removed := current == nil || current.Machine == nil || current.GetToken() != previous.GetToken()
+	if removed {
+		m.lock.Lock()
+		delete(m.runners, previous.GetToken())
+		m.lock.Unlock()
+
+		m.arrivalsLock.Lock()
+		delete(m.arrivals, previous.GetToken())
+		m.arrivalsLock.Unlock()
+
+		go m.removeIdleMachines(previous, "Runner removed from the configuration")
+		return nil
+	}
+
+	for _, setting := range change.Settings {
+		for _, creationSetting := range machineCreationSettings {
+			if setting == creationSetting || strings.HasPrefix(setting, creationSetting+".") {
+				go m.removeIdleMachines(previous, "Machine creation settings changed")
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeIdleMachines removes the idle machines of the runner
+func (m *machineProvider) removeIdleMachines(config *common.RunnerConfig, reason string) {
+	m.acquireLock.Lock()
+	defer m.acquireLock.Unlock()
+
+	filter := machineFilter(config)
+
+	var machines []*machineDetails
+	m.lock.RLock()
+	for _, details := range m.details {
+		if details.match(filter) {
+			machines = append(machines, details)
+		}
+	}
+	m.lock.RUnlock()
+
+	for _, details := range machines {
+		// acquiring the machine makes sure no job starts using it
+		if m.tryAcquireMachineDetails(details) == nil {
+			continue
+		}
+
+		_ = m.remove(details.Name, reason)
+	}
+}
+
 func (m *machineProvider) CanCreate() bool {
 	return m.provider.CanCreate()
 }
@@ -636,6 +764,7 @@ func newMachineProvider() *machineProvider {
 		name:     name,
 		details:  make(machinesDetails),
 		runners:  make(runnersDetails),
+		arrivals: make(map[string]*arrivalHistory),
 		machine:  docker.NewMachineCommand(),
 		provider: provider,
 		totalActions: prometheus.NewCounterVec(
@@ -696,5 +825,25 @@ func newMachineProvider() *machineProvider {
 				},
 			},
 		),
+		jobArrivals: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gitlab_runner_autoscaling_job_arrivals_total",
+				Help: "The total number of jobs started on the machines of the runner.",
+				ConstLabels: prometheus.Labels{
+					"executor": name,
+				},
+			},
+			[]string{"runner"},
+		),
+		predictedArrivals: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gitlab_runner_autoscaling_predicted_job_arrivals",
+				Help: "The number of jobs the predictive scaling strategy expects to start within the lead time.",
+				ConstLabels: prometheus.Labels{
+					"executor": name,
+				},
+			},
+			[]string{"runner"},
+		),
 	}
 }
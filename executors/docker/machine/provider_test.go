@@ -795,3 +795,90 @@ func TestIntermediateMachineList(t *testing.T) {
 	intermediateMachine := p.intermediateMachineList([]string{"machine1", "machine2"})
 	assert.Equal(t, expectedIntermediateMachines, intermediateMachine)
 }
+
+func TestMachineReconfigure(t *testing.T) {
+	provisionRetryInterval = 0
+
+	newConfig := func(token string) *common.RunnerConfig {
+		config := createMachineConfig(t, 1, 5)
+		config.Token = token
+		return config
+	}
+
+	tests := map[string]struct {
+		change          func(previous *common.RunnerConfig) common.RunnerConfigChange
+		expectedRemoval bool
+	}{
+		"removed runner": {
+			change: func(previous *common.RunnerConfig) common.RunnerConfigChange {
+				return common.RunnerConfigChange{Previous: previous}
+			},
+			expectedRemoval: true,
+		},
+		"changed token": {
+			change: func(previous *common.RunnerConfig) common.RunnerConfigChange {
+				return common.RunnerConfigChange{
+					Previous: previous,
+					Current:  newConfig("glrt-newtoken1234"),
+					Settings: []string{"token"},
+				}
+			},
+			expectedRemoval: true,
+		},
+		"changed machine options": {
+			change: func(previous *common.RunnerConfig) common.RunnerConfigChange {
+				return common.RunnerConfigChange{
+					Previous: previous,
+					Current:  newConfig(previous.Token),
+					Settings: []string{"machine.MachineOptions"},
+				}
+			},
+			expectedRemoval: true,
+		},
+		"changed idle count": {
+			change: func(previous *common.RunnerConfig) common.RunnerConfigChange {
+				return common.RunnerConfigChange{
+					Previous: previous,
+					Current:  newConfig(previous.Token),
+					Settings: []string{"machine.IdleCount"},
+				}
+			},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			previous := newConfig("glrt-token123456")
+
+			p, _ := testMachineProvider()
+			idle, errCh := p.create(previous, machineStateIdle)
+			require.NoError(t, <-errCh)
+			used, errCh := p.create(previous, machineStateUsed)
+			require.NoError(t, <-errCh)
+			other, errCh := p.create(newConfig("glrt-other12345678"), machineStateIdle)
+			require.NoError(t, <-errCh)
+
+			require.NoError(t, p.Reconfigure(tt.change(previous)))
+
+			if tt.expectedRemoval {
+				assert.Eventually(t, func() bool {
+					idle.Lock()
+					defer idle.Unlock()
+					return idle.State == machineStateRemoving
+				}, time.Second, 10*time.Millisecond)
+			} else {
+				time.Sleep(50 * time.Millisecond)
+				idle.Lock()
+				assert.Equal(t, machineStateIdle, idle.State)
+				idle.Unlock()
+			}
+
+			used.Lock()
+			assert.Equal(t, machineStateUsed, used.State)
+			used.Unlock()
+			other.Lock()
+			assert.Equal(t, machineStateIdle, other.State)
+			other.Unlock()
+		})
+	}
+}
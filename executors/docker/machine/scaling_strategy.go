@@ -0,0 +1,115 @@
+package machine
+
+import (
+	"math"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+const (
+	scalingStrategyClassic    = "classic"
+	scalingStrategyPredictive = "predictive"
+)
+
+// scalingStrategy decides how many idle machines a runner maintains. The
+// limits on the total number of machines and on their growth rate are
+// enforced by the idleLimitStrategy, whatever the scaling strategy.
+type scalingStrategy interface {
+	// idleMachinesExceeded reports whether the runner has enough idle
+	// machines: no more are created and the ones idle for longer than
+	// IdleTime can be removed.
+	idleMachinesExceeded(config *common.RunnerConfig, data *machinesData) bool
+}
+
+// classicScalingStrategy maintains IdleCount idle machines or, when
+// IdleScaleFactor is set, a factor of the machines in use bounded by
+// IdleCountMin and IdleCount.
+type classicScalingStrategy struct{}
+
+// idleMachinesExceeded checks several conditions that can evaluate
+// as "number of Idle Machines exceeded".
+func (s classicScalingStrategy) idleMachinesExceeded(config *common.RunnerConfig, data *machinesData) bool {
+	return s.idleCountExceeded(config, data) ||
+		(s.idleCountMinFulfilled(config, data) && s.idleMachinesScaleFactorExceeded(config, data))
+}
+
+// idleCountExceeded checks whether runner reached the defined IdleCount
+// which is the maximum number of Idle machines that can exist.
+func (classicScalingStrategy) idleCountExceeded(config *common.RunnerConfig, data *machinesData) bool {
+	return data.Available() >= config.Machine.GetIdleCount()
+}
+
+// idleCountMinFulfilled checks if the IdleCountMin setting is fulfilled.
+// Should be used to ensure that the minimal number of Idle machines is created.
+func (classicScalingStrategy) idleCountMinFulfilled(config *common.RunnerConfig, data *machinesData) bool {
+	min := config.Machine.GetIdleCountMin()
+
+	// When IdleScaleFactor is in use, there is a risk that with no executed jobs
+	// the desired number of Idle machines to maintain will also evaluate to 0.
+	// This could cause in removing all Idle machines. In that case Runner would
+	// stop asking for new jobs (with IdleCount > 0 Runner doesn't ask for jobs
+	// if there is no Idle machines awaiting to be used). And without new jobs using
+	// some machines, the IdleScaleFactor would be constantly evaluated to 0.
+	// This would lock the Runner in a state where no job can't be started because
+	// no machines are in Idle, and no machines are in Idle because no jobs are started.
+	//
+	// Therefore, in case when IdleScaleFactor is greater than 0 and IdleCountMin
+	// was not defined or intentionally set to 0, it will be forced to be at least
+	// 1. So that there is at least one Idle machine that can handle a job and allow
+	// the IdleScaleFactor to bring more of them later.
+	if config.Machine.GetIdleScaleFactor() > 0 && min < 1 {
+		min = 1
+	}
+
+	return data.Available() >= min
+}
+
+// idleMachinesScaleFactorExceeded checks whether runner reached the number
+// of machines defined as a factor of in-use ones.
+// This behavior is optional and depends on the IdleScaleFactor setting.
+// When it's set to 0 then it's ignored.
+func (classicScalingStrategy) idleMachinesScaleFactorExceeded(config *common.RunnerConfig, data *machinesData) bool {
+	idleScaleFactor := config.Machine.GetIdleScaleFactor()
+	if idleScaleFactor <= 0 {
+		return false
+	}
+
+	desiredCapacity := int(float64(data.InUse()) * idleScaleFactor)
+
+	return data.Available() >= desiredCapacity
+}
+
+// predictiveScalingStrategy maintains as many idle machines as jobs are
+// expected to arrive within the lead time, according to the job arrivals
+// recorded at the same time of the past days. The number of idle machines
+// is bounded by IdleCountMin and IdleCount.
+type predictiveScalingStrategy struct {
+	// predictedArrivals is the number of jobs expected within the lead time
+	predictedArrivals float64
+}
+
+func (s predictiveScalingStrategy) idleMachinesExceeded(config *common.RunnerConfig, data *machinesData) bool {
+	return data.Available() >= s.desiredIdleCount(config)
+}
+
+func (s predictiveScalingStrategy) desiredIdleCount(config *common.RunnerConfig) int {
+	desired := int(math.Ceil(s.predictedArrivals))
+
+	// As with the IdleScaleFactor, at least one machine is kept idle: with
+	// IdleCount > 0 the runner doesn't ask for jobs when no machine is idle,
+	// so no arrival would ever be recorded again.
+	min := config.Machine.GetIdleCountMin()
+	if min < 1 {
+		min = 1
+	}
+
+	if desired < min {
+		desired = min
+	}
+
+	if max := config.Machine.GetIdleCount(); desired > max {
+		desired = max
+	}
+
+	return desired
+}
@@ -0,0 +1,67 @@
+//go:build !integration
+
+package machine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPredictiveScalingStrategy(t *testing.T) {
+	tests := map[string]struct {
+		config            ilsRunnerConfig
+		data              ilsMachinesData
+		predictedArrivals float64
+		expectedExceeded  bool
+	}{
+		"no arrivals expected keeps one idle machine": {
+			config:           ilsRunnerConfig{idleCount: 10},
+			data:             ilsMachinesData{},
+			expectedExceeded: false,
+		},
+		"one idle machine when no arrivals are expected": {
+			config:           ilsRunnerConfig{idleCount: 10},
+			data:             ilsMachinesData{idle: 1},
+			expectedExceeded: true,
+		},
+		"IdleCountMin not fulfilled": {
+			config:           ilsRunnerConfig{idleCount: 10, idleCountMin: 3},
+			data:             ilsMachinesData{idle: 2},
+			expectedExceeded: false,
+		},
+		"predicted arrivals not fulfilled": {
+			config:            ilsRunnerConfig{idleCount: 10},
+			data:              ilsMachinesData{idle: 2, creating: 1},
+			predictedArrivals: 3.2,
+			expectedExceeded:  false,
+		},
+		"predicted arrivals fulfilled": {
+			config:            ilsRunnerConfig{idleCount: 10},
+			data:              ilsMachinesData{idle: 3, creating: 1},
+			predictedArrivals: 3.2,
+			expectedExceeded:  true,
+		},
+		"predicted arrivals bounded by IdleCount": {
+			config:            ilsRunnerConfig{idleCount: 5},
+			data:              ilsMachinesData{idle: 5},
+			predictedArrivals: 12,
+			expectedExceeded:  true,
+		},
+		"IdleCount set to 0": {
+			config:            ilsRunnerConfig{idleCount: 0},
+			data:              ilsMachinesData{},
+			predictedArrivals: 12,
+			expectedExceeded:  true,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			strategy := predictiveScalingStrategy{predictedArrivals: tt.predictedArrivals}
+
+			result := strategy.idleMachinesExceeded(ilsNewRunnerConfig(tt.config), ilsNewMachinesData(tt.data))
+			assert.Equal(t, tt.expectedExceeded, result)
+		})
+	}
+}
@@ -5,7 +5,9 @@ import (
 )
 
 var createNetworksManager = func(e *executor) (networks.Manager, error) {
-	networksManager := networks.NewManager(&e.BuildLogger, e.client, e.Build, e.labeler)
+	// the egress policy is enforced on the per-build network
+	alwaysPerBuild := e.runtime.Podman || e.Config.Docker.EgressPolicy.IsEnabled()
+	networksManager := networks.NewManager(&e.BuildLogger, e.client, e.Build, e.labeler, alwaysPerBuild)
 
 	return networksManager, nil
 }
@@ -0,0 +1,222 @@
+package docker
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/docker/go-units"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+const (
+	// CPUSOverwriteVariableName is the key for the JobVariable containing user overwritten number of CPUs
+	CPUSOverwriteVariableName = "DOCKER_CPUS"
+	// MemoryOverwriteVariableName is the key for the JobVariable containing user overwritten memory limit
+	MemoryOverwriteVariableName = "DOCKER_MEMORY"
+	// ShmSizeOverwriteVariableName is the key for the JobVariable containing user overwritten shared memory size
+	ShmSizeOverwriteVariableName = "DOCKER_SHM_SIZE"
+)
+
+type overwriteTooHighError struct {
+	resource  string
+	max       string
+	overwrite string
+}
+
+func (o *overwriteTooHighError) Error() string {
+	return fmt.Sprintf("the resource %q requested %q is higher than limit allowed %q", o.resource, o.overwrite, o.max)
+}
+
+func (o *overwriteTooHighError) Is(err error) bool {
+	_, ok := err.(*overwriteTooHighError)
+	return ok
+}
+
+// overwriteConflictError is returned when a resource requested by the job
+// conflicts with another setting of the runner, that Docker would refuse
+type overwriteConflictError struct {
+	resource  string
+	overwrite string
+	setting   string
+	value     string
+	reason    string
+}
+
+func (o *overwriteConflictError) Error() string {
+	return fmt.Sprintf(
+		"the resource %q requested %q conflicts with the configured %s %q: %s",
+		o.resource, o.overwrite, o.setting, o.value, o.reason,
+	)
+}
+
+func (o *overwriteConflictError) Is(err error) bool {
+	_, ok := err.(*overwriteConflictError)
+	return ok
+}
+
+// resourceOverwrites are the resources requested by the job. A zero value
+// means that the resource isn't overwritten.
+type resourceOverwrites struct {
+	nanoCPUs int64
+	memory   int64
+	shmSize  int64
+}
+
+func createResourceOverwrites(
+	config *common.DockerConfig,
+	variables common.JobVariables,
+	logger common.BuildLogger,
+) (resourceOverwrites, error) {
+	var o resourceOverwrites
+	var err error
+
+	o.nanoCPUs, err = evaluateMaxResourceOverwrite(
+		"CPUS",
+		config.CPUSOverwriteMaxAllowed,
+		variables.Value(CPUSOverwriteVariableName),
+		parseNanoCPUs,
+		logger,
+	)
+	if err != nil {
+		return resourceOverwrites{}, err
+	}
+
+	o.memory, err = evaluateMaxResourceOverwrite(
+		"Memory",
+		config.MemoryOverwriteMaxAllowed,
+		variables.Value(MemoryOverwriteVariableName),
+		units.RAMInBytes,
+		logger,
+	)
+	if err != nil {
+		return resourceOverwrites{}, err
+	}
+
+	err = validateMemoryOverwrite(config, o.memory, variables.Value(MemoryOverwriteVariableName))
+	if err != nil {
+		return resourceOverwrites{}, err
+	}
+
+	o.shmSize, err = evaluateMaxResourceOverwrite(
+		"ShmSize",
+		config.ShmSizeOverwriteMaxAllowed,
+		variables.Value(ShmSizeOverwriteVariableName),
+		units.RAMInBytes,
+		logger,
+	)
+	if err != nil {
+		return resourceOverwrites{}, err
+	}
+
+	return o, nil
+}
+
+func evaluateMaxResourceOverwrite(
+	fieldName,
+	maxResource,
+	overwriteValue string,
+	parse func(string) (int64, error),
+	logger common.BuildLogger,
+) (int64, error) {
+	if maxResource == "" {
+		logger.Debugln("setting allowing overrides for", fieldName, "is empty, disabling override.")
+		return 0, nil
+	}
+
+	if overwriteValue == "" {
+		return 0, nil
+	}
+
+	max, err := parse(maxResource)
+	if err != nil {
+		return 0, fmt.Errorf("parsing max allowed %s %q: %w", fieldName, maxResource, err)
+	}
+
+	overwrite, err := parse(overwriteValue)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s overwrite %q: %w", fieldName, overwriteValue, err)
+	}
+
+	if overwrite <= 0 {
+		return 0, fmt.Errorf("the resource %q requested %q must be positive", fieldName, overwriteValue)
+	}
+
+	if overwrite > max {
+		return 0, &overwriteTooHighError{resource: fieldName, max: maxResource, overwrite: overwriteValue}
+	}
+
+	logger.Println(fmt.Sprintf("%q overwritten with %q", fieldName, overwriteValue))
+
+	return overwrite, nil
+}
+
+// validateMemoryOverwrite checks the memory requested by the job against the
+// configured memory_swap, the memory limit including the swap, and
+// memory_reservation, which Docker both requires to be consistent with the
+// memory limit
+func validateMemoryOverwrite(config *common.DockerConfig, memory int64, overwrite string) error {
+	if memory <= 0 {
+		return nil
+	}
+
+	if swap := config.GetMemorySwap(); swap > 0 && swap < memory {
+		return &overwriteConflictError{
+			resource:  "Memory",
+			overwrite: overwrite,
+			setting:   "memory_swap",
+			value:     config.MemorySwap,
+			reason:    "the memory limit including swap can't be lower than the memory limit",
+		}
+	}
+
+	if reservation := config.GetMemoryReservation(); reservation > memory {
+		return &overwriteConflictError{
+			resource:  "Memory",
+			overwrite: overwrite,
+			setting:   "memory_reservation",
+			value:     config.MemoryReservation,
+			reason:    "the memory reservation can't be higher than the memory limit",
+		}
+	}
+
+	return nil
+}
+
+func parseNanoCPUs(value string) (int64, error) {
+	cpu, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return 0, fmt.Errorf("failed to parse %v as a rational number", value)
+	}
+
+	nano, _ := cpu.Mul(cpu, big.NewRat(1e9, 1)).Float64()
+
+	return int64(nano), nil
+}
+
+// nanoCPUs returns the CPUs of the build container
+func (e *executor) nanoCPUs() (int64, error) {
+	if e.resourceOverwrites.nanoCPUs > 0 {
+		return e.resourceOverwrites.nanoCPUs, nil
+	}
+
+	return e.Config.Docker.GetNanoCPUs()
+}
+
+// memory returns the memory limit of the build container
+func (e *executor) memory() int64 {
+	if e.resourceOverwrites.memory > 0 {
+		return e.resourceOverwrites.memory
+	}
+
+	return e.Config.Docker.GetMemory()
+}
+
+// shmSize returns the shared memory size of the build and service containers
+func (e *executor) shmSize() int64 {
+	if e.resourceOverwrites.shmSize > 0 {
+		return e.resourceOverwrites.shmSize
+	}
+
+	return e.Config.Docker.ShmSize
+}
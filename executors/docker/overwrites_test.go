@@ -0,0 +1,227 @@
+//go:build !integration
+
+package docker
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/volumes"
+)
+
+func TestCreateResourceOverwrites(t *testing.T) {
+	tests := map[string]struct {
+		config             common.DockerConfig
+		variables          common.JobVariables
+		expectedOverwrites resourceOverwrites
+		expectedErr        error
+		expectedErrMsg     string
+	}{
+		"no overwrites": {
+			config: common.DockerConfig{
+				CPUSOverwriteMaxAllowed:    "4",
+				MemoryOverwriteMaxAllowed:  "2g",
+				ShmSizeOverwriteMaxAllowed: "1g",
+			},
+		},
+		"overwrites disabled": {
+			variables: common.JobVariables{
+				{Key: CPUSOverwriteVariableName, Value: "2"},
+				{Key: MemoryOverwriteVariableName, Value: "1g"},
+				{Key: ShmSizeOverwriteVariableName, Value: "512m"},
+			},
+		},
+		"overwrites within the limits": {
+			config: common.DockerConfig{
+				CPUSOverwriteMaxAllowed:    "4",
+				MemoryOverwriteMaxAllowed:  "2g",
+				ShmSizeOverwriteMaxAllowed: "1g",
+			},
+			variables: common.JobVariables{
+				{Key: CPUSOverwriteVariableName, Value: "1.5"},
+				{Key: MemoryOverwriteVariableName, Value: "1g"},
+				{Key: ShmSizeOverwriteVariableName, Value: "512m"},
+			},
+			expectedOverwrites: resourceOverwrites{
+				nanoCPUs: 1500000000,
+				memory:   1024 * 1024 * 1024,
+				shmSize:  512 * 1024 * 1024,
+			},
+		},
+		"overwrites equal to the limits": {
+			config: common.DockerConfig{
+				CPUSOverwriteMaxAllowed:   "2",
+				MemoryOverwriteMaxAllowed: "1g",
+			},
+			variables: common.JobVariables{
+				{Key: CPUSOverwriteVariableName, Value: "2"},
+				{Key: MemoryOverwriteVariableName, Value: "1024m"},
+			},
+			expectedOverwrites: resourceOverwrites{
+				nanoCPUs: 2000000000,
+				memory:   1024 * 1024 * 1024,
+			},
+		},
+		"CPUS overwrite too high": {
+			config: common.DockerConfig{CPUSOverwriteMaxAllowed: "2"},
+			variables: common.JobVariables{
+				{Key: CPUSOverwriteVariableName, Value: "2.5"},
+			},
+			expectedErr: new(overwriteTooHighError),
+		},
+		"memory overwrite too high": {
+			config: common.DockerConfig{MemoryOverwriteMaxAllowed: "1g"},
+			variables: common.JobVariables{
+				{Key: MemoryOverwriteVariableName, Value: "2g"},
+			},
+			expectedErr: new(overwriteTooHighError),
+		},
+		"memory overwrite within memory_swap and memory_reservation": {
+			config: common.DockerConfig{
+				MemoryOverwriteMaxAllowed: "4g",
+				MemorySwap:                "4g",
+				MemoryReservation:         "512m",
+			},
+			variables: common.JobVariables{
+				{Key: MemoryOverwriteVariableName, Value: "2g"},
+			},
+			expectedOverwrites: resourceOverwrites{
+				memory: 2 * 1024 * 1024 * 1024,
+			},
+		},
+		"memory overwrite higher than memory_swap": {
+			config: common.DockerConfig{
+				MemoryOverwriteMaxAllowed: "4g",
+				MemorySwap:                "2g",
+			},
+			variables: common.JobVariables{
+				{Key: MemoryOverwriteVariableName, Value: "3g"},
+			},
+			expectedErrMsg: `the resource "Memory" requested "3g" conflicts with the configured memory_swap "2g"`,
+		},
+		"memory overwrite lower than memory_reservation": {
+			config: common.DockerConfig{
+				MemoryOverwriteMaxAllowed: "4g",
+				MemoryReservation:         "1g",
+			},
+			variables: common.JobVariables{
+				{Key: MemoryOverwriteVariableName, Value: "512m"},
+			},
+			expectedErr: new(overwriteConflictError),
+		},
+		"shm size overwrite too high": {
+			config: common.DockerConfig{ShmSizeOverwriteMaxAllowed: "64m"},
+			variables: common.JobVariables{
+				{Key: ShmSizeOverwriteVariableName, Value: "128m"},
+			},
+			expectedErr: new(overwriteTooHighError),
+		},
+		"invalid overwrite": {
+			config: common.DockerConfig{MemoryOverwriteMaxAllowed: "1g"},
+			variables: common.JobVariables{
+				{Key: MemoryOverwriteVariableName, Value: "a lot"},
+			},
+			expectedErrMsg: `parsing Memory overwrite "a lot"`,
+		},
+		"invalid max allowed": {
+			config: common.DockerConfig{CPUSOverwriteMaxAllowed: "many"},
+			variables: common.JobVariables{
+				{Key: CPUSOverwriteVariableName, Value: "1"},
+			},
+			expectedErrMsg: `parsing max allowed CPUS "many"`,
+		},
+		"negative overwrite": {
+			config: common.DockerConfig{CPUSOverwriteMaxAllowed: "2"},
+			variables: common.JobVariables{
+				{Key: CPUSOverwriteVariableName, Value: "-1"},
+			},
+			expectedErrMsg: `the resource "CPUS" requested "-1" must be positive`,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			logger := common.NewBuildLogger(&common.Trace{Writer: &bytes.Buffer{}}, logrus.WithField("test", tn))
+
+			overwrites, err := createResourceOverwrites(&tt.config, tt.variables, logger)
+			switch {
+			case tt.expectedErr != nil:
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			case tt.expectedErrMsg != "":
+				assert.ErrorContains(t, err, tt.expectedErrMsg)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedOverwrites, overwrites)
+		})
+	}
+}
+
+func TestResourceOverwritesHostConfig(t *testing.T) {
+	tests := map[string]struct {
+		overwrites              resourceOverwrites
+		expectedBuildResources  container.Resources
+		expectedServiceResource container.Resources
+		expectedShmSize         int64
+	}{
+		"no overwrites": {
+			expectedBuildResources: container.Resources{
+				Memory:   512 * 1024 * 1024,
+				NanoCPUs: 1000000000,
+			},
+			expectedShmSize: 64 * 1024 * 1024,
+		},
+		"overwrites": {
+			overwrites: resourceOverwrites{
+				nanoCPUs: 2000000000,
+				memory:   1024 * 1024 * 1024,
+				shmSize:  128 * 1024 * 1024,
+			},
+			expectedBuildResources: container.Resources{
+				Memory:   1024 * 1024 * 1024,
+				NanoCPUs: 2000000000,
+			},
+			expectedServiceResource: container.Resources{
+				Memory:   1024 * 1024 * 1024,
+				NanoCPUs: 2000000000,
+			},
+			expectedShmSize: 128 * 1024 * 1024,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			volumesManager := volumes.NewMockManager(t)
+			volumesManager.On("Binds").Return(nil)
+
+			e := &executor{
+				volumesManager:     volumesManager,
+				resourceOverwrites: tt.overwrites,
+			}
+			e.Config.Docker = &common.DockerConfig{
+				CPUS:    "1",
+				Memory:  "512m",
+				ShmSize: 64 * 1024 * 1024,
+			}
+
+			hostConfig, err := e.createHostConfig()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedBuildResources.Memory, hostConfig.Memory)
+			assert.Equal(t, tt.expectedBuildResources.NanoCPUs, hostConfig.NanoCPUs)
+			assert.Equal(t, tt.expectedShmSize, hostConfig.ShmSize)
+
+			serviceHostConfig := e.createHostConfigForService()
+			assert.Equal(t, tt.expectedServiceResource.Memory, serviceHostConfig.Memory)
+			assert.Equal(t, tt.expectedServiceResource.NanoCPUs, serviceHostConfig.NanoCPUs)
+			assert.Equal(t, tt.expectedShmSize, serviceHostConfig.ShmSize)
+		})
+	}
+}
@@ -82,7 +82,7 @@ func TestPrepare(t *testing.T) {
 			p.taskscalerNew = mockTaskscalerNew(ts, false)
 			p.fleetingRunPlugin = mockFleetingRunPlugin(false)
 
-			p.scalers = map[string]scaler{
+			p.scalers = map[string]*scaler{
 				runnerToken: {internal: ts, shutdown: func(_ context.Context) {}},
 			}
 
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,10 +27,15 @@ import (
 )
 
 var (
-	_ prometheus.Collector           = &provider{}
-	_ common.ManagedExecutorProvider = &provider{}
+	_ prometheus.Collector                  = &provider{}
+	_ common.ManagedExecutorProvider        = &provider{}
+	_ common.ReconfigurableExecutorProvider = &provider{}
 )
 
+// retiredScalerShutdownTimeout is how long the taskscaler of a removed runner
+// is given to shut down once its jobs are released
+const retiredScalerShutdownTimeout = 5 * time.Minute
+
 type fleetingPlugin interface {
 	InstanceGroup() fleetingprovider.InstanceGroup
 	Kill()
@@ -40,7 +46,7 @@ type provider struct {
 	cfg Config
 
 	mu      sync.Mutex
-	scalers map[string]scaler
+	scalers map[string]*scaler
 
 	// Testing hooks
 	taskscalerNew     func(context.Context, fleetingprovider.InstanceGroup, ...taskscaler.Option) (taskscaler.Taskscaler, error)
@@ -52,6 +58,12 @@ type scaler struct {
 	internal taskscaler.Taskscaler
 	shutdown func(context.Context)
 	cancel   func()
+
+	// reservations is the number of capacity reservations not released yet
+	reservations int
+	// retired is set when the runner is removed or its token changed, the
+	// scaler being shut down once all its reservations are released
+	retired bool
 }
 
 type Config struct {
@@ -72,7 +84,7 @@ func New(ep common.ExecutorProvider, cfg Config) common.ExecutorProvider {
 	return &provider{
 		ExecutorProvider: ep,
 		cfg:              cfg,
-		scalers:          make(map[string]scaler),
+		scalers:          make(map[string]*scaler),
 		taskscalerNew:    taskscaler.New,
 		fleetingRunPlugin: func(name string, config []byte) (fleetingPlugin, error) {
 			return fleeting.RunPlugin(name, config)
@@ -92,7 +104,7 @@ func (p *provider) Shutdown(ctx context.Context) {
 	wg := new(sync.WaitGroup)
 	for key, s := range p.scalers {
 		wg.Add(1)
-		go func(sc scaler) {
+		go func(sc *scaler) {
 			defer wg.Done()
 			sc.shutdown(ctx)
 		}(s)
@@ -198,7 +210,7 @@ func (p *provider) init(config *common.RunnerConfig) (taskscaler.Taskscaler, boo
 		return nil, false, fmt.Errorf("creating taskscaler: %w", err)
 	}
 
-	s = scaler{
+	s = &scaler{
 		internal: ts,
 		shutdown: func(ctx context.Context) {
 			shutdownFn()
@@ -219,20 +231,10 @@ func (p *provider) Acquire(config *common.RunnerConfig) (common.ExecutorData, er
 		return nil, fmt.Errorf("initializing taskscaler: %w", err)
 	}
 
-	if fresh /* || todo: detect config updates - based on last modified timestamp? */ {
-		var schedules []taskscaler.Schedule
-		for _, schedule := range config.Autoscaler.Policy {
-			schedules = append(schedules, taskscaler.Schedule{
-				Periods:          schedule.Periods,
-				Timezone:         schedule.Timezone,
-				IdleCount:        schedule.IdleCount,
-				IdleTime:         schedule.IdleTime,
-				ScaleFactor:      schedule.ScaleFactor,
-				ScaleFactorLimit: schedule.ScaleFactorLimit,
-				PreemptiveMode:   schedule.IdleCount > 0,
-			})
-		}
-		if err := scaler.ConfigureSchedule(schedules...); err != nil {
+	// the changes of the schedules of an existing taskscaler are applied by
+	// Reconfigure
+	if fresh {
+		if err := scaler.ConfigureSchedule(taskscalerSchedules(config)...); err != nil {
 			return nil, fmt.Errorf("configuring taskscaler schedules: %w", err)
 		}
 	}
@@ -252,17 +254,156 @@ func (p *provider) Acquire(config *common.RunnerConfig) (common.ExecutorData, er
 		return nil, err
 	}
 
+	p.addReservation(config)
+
 	logrus.WithField("key", key).Trace("Reserved capacity...")
 
 	return newAcquisitionRef(key, p.cfg.MapJobImageToVMImage), nil
 }
 
+func taskscalerSchedules(config *common.RunnerConfig) []taskscaler.Schedule {
+	var schedules []taskscaler.Schedule
+	for _, schedule := range config.Autoscaler.Policy {
+		schedules = append(schedules, taskscaler.Schedule{
+			Periods:          schedule.Periods,
+			Timezone:         schedule.Timezone,
+			IdleCount:        schedule.IdleCount,
+			IdleTime:         schedule.IdleTime,
+			ScaleFactor:      schedule.ScaleFactor,
+			ScaleFactorLimit: schedule.ScaleFactorLimit,
+			PreemptiveMode:   schedule.IdleCount > 0,
+		})
+	}
+
+	return schedules
+}
+
+// Reconfigure applies the change of the configuration of a runner to its
+// taskscaler. The policies are applied live. The taskscaler of a removed
+// runner, or of a runner whose token changed, doesn't keep idle instances
+// anymore and is shut down with its plugin once the jobs it's running are
+// released. The other autoscaler settings are passed to the taskscaler and
+// the plugin when they're created, so their changes require a restart.
+func (p *provider) Reconfigure(change common.RunnerConfigChange) error {
+	previous, current := change.Previous, change.Current
+
+	if previous != nil && (current == nil || current.GetToken() != previous.GetToken()) {
+		if err := p.retireScaler(previous); err != nil {
+			return err
+		}
+	}
+
+	if current == nil || current.Autoscaler == nil {
+		return nil
+	}
+
+	// a taskscaler not created yet is created with the current configuration
+	scaler, ok := p.runnerScaler(current)
+	if !ok {
+		return nil
+	}
+
+	if err := scaler.internal.ConfigureSchedule(taskscalerSchedules(current)...); err != nil {
+		return fmt.Errorf("configuring taskscaler schedules: %w", err)
+	}
+
+	var restartRequired []string
+	for _, setting := range change.Settings {
+		if strings.HasPrefix(setting, "autoscaler.") && !strings.HasPrefix(setting, "autoscaler.policy") {
+			restartRequired = append(restartRequired, setting)
+		}
+	}
+
+	if len(restartRequired) > 0 {
+		return fmt.Errorf("changes of %s are applied on restart", strings.Join(restartRequired, ", "))
+	}
+
+	return nil
+}
+
+// retireScaler removes the idle instances of the taskscaler of the runner,
+// and shuts it down once its reservations are released. The runner being
+// back with the same token before that, the taskscaler is used again.
+func (p *provider) retireScaler(config *common.RunnerConfig) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.scalers[config.GetToken()]
+	if !ok {
+		return nil
+	}
+
+	if err := s.internal.ConfigureSchedule(); err != nil {
+		return fmt.Errorf("configuring taskscaler schedules: %w", err)
+	}
+
+	s.retired = true
+	p.shutdownRetiredScaler(config.GetToken(), s)
+
+	return nil
+}
+
+// shutdownRetiredScaler shuts down the scaler if it's retired and has no
+// reservations left. It must be called with the lock held.
+func (p *provider) shutdownRetiredScaler(token string, s *scaler) {
+	if !s.retired || s.reservations > 0 {
+		return
+	}
+
+	delete(p.scalers, token)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), retiredScalerShutdownTimeout)
+		defer cancel()
+
+		s.shutdown(ctx)
+	}()
+}
+
+// runnerScaler returns the scaler of the runner being configured, which isn't
+// retired anymore if it was
+func (p *provider) runnerScaler(config *common.RunnerConfig) (*scaler, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.scalers[config.GetToken()]
+	if ok {
+		s.retired = false
+	}
+
+	return s, ok
+}
+
+func (p *provider) addReservation(config *common.RunnerConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if s, ok := p.scalers[config.GetToken()]; ok {
+		s.reservations++
+	}
+}
+
+func (p *provider) removeReservation(config *common.RunnerConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.scalers[config.GetToken()]
+	if !ok {
+		return
+	}
+
+	s.reservations--
+	p.shutdownRetiredScaler(config.GetToken(), s)
+}
+
 func (p *provider) Release(config *common.RunnerConfig, data common.ExecutorData) {
 	acqRef, ok := data.(*acquisitionRef)
 	if !ok {
 		return
 	}
 
+	defer p.removeReservation(config)
+
 	if acqRef.acq != nil {
 		p.getRunnerTaskscaler(config).Release(acqRef.key)
 		logrus.WithField("key", acqRef.key).Trace("Released capacity...")
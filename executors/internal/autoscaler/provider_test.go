@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	fleetingprovider "gitlab.com/gitlab-org/fleeting/fleeting/provider"
 	"gitlab.com/gitlab-org/fleeting/taskscaler"
 	"gitlab.com/gitlab-org/fleeting/taskscaler/mocks"
@@ -94,7 +95,7 @@ func TestInit(t *testing.T) {
 			p.taskscalerNew = mockTaskscalerNew(tokenTaskscaler, tt.newTaskscalerErr)
 			p.fleetingRunPlugin = mockFleetingRunPlugin(tt.fleetingRunPluginErr)
 			for k, v := range tt.scalers {
-				p.scalers[k] = scaler{
+				p.scalers[k] = &scaler{
 					internal: v,
 					shutdown: func(_ context.Context) {},
 				}
@@ -217,6 +218,160 @@ func TestAcquire(t *testing.T) {
 	}
 }
 
+func TestReconfigure(t *testing.T) {
+	newConfig := func(token string, idleCount int) *common.RunnerConfig {
+		return common.NewTestRunnerConfig().
+			WithToken(token).
+			WithAutoscalerConfig(
+				common.NewTestAutoscalerConfig().
+					WithPolicies(common.AutoscalerPolicyConfig{IdleCount: idleCount}).
+					AutoscalerConfig,
+			).RunnerConfig
+	}
+
+	tests := map[string]struct {
+		reservations   int
+		change         func(previous *common.RunnerConfig) common.RunnerConfigChange
+		expectFn       func(ts *mocks.Taskscaler)
+		expectShutdown bool
+		expectedErr    string
+	}{
+		"modified policies": {
+			change: func(previous *common.RunnerConfig) common.RunnerConfigChange {
+				return common.RunnerConfigChange{
+					Previous: previous,
+					Current:  newConfig("token", 5),
+					Settings: []string{"autoscaler.policy.0.idle_count"},
+				}
+			},
+			expectFn: func(ts *mocks.Taskscaler) {
+				ts.EXPECT().ConfigureSchedule(taskscaler.Schedule{IdleCount: 5, PreemptiveMode: true}).Return(nil)
+			},
+		},
+		"modified settings requiring a restart": {
+			change: func(previous *common.RunnerConfig) common.RunnerConfigChange {
+				return common.RunnerConfigChange{
+					Previous: previous,
+					Current:  newConfig("token", 1),
+					Settings: []string{"autoscaler.max_instances", "docker.image", "autoscaler.plugin_config.name"},
+				}
+			},
+			expectFn: func(ts *mocks.Taskscaler) {
+				ts.EXPECT().ConfigureSchedule(taskscaler.Schedule{IdleCount: 1, PreemptiveMode: true}).Return(nil)
+			},
+			expectedErr: "changes of autoscaler.max_instances, autoscaler.plugin_config.name are applied on restart",
+		},
+		"failed configure schedule": {
+			change: func(previous *common.RunnerConfig) common.RunnerConfigChange {
+				return common.RunnerConfigChange{Previous: previous, Current: newConfig("token", 2)}
+			},
+			expectFn: func(ts *mocks.Taskscaler) {
+				ts.EXPECT().ConfigureSchedule(taskscaler.Schedule{IdleCount: 2, PreemptiveMode: true}).
+					Return(fmt.Errorf("test error"))
+			},
+			expectedErr: "configuring taskscaler schedules: test error",
+		},
+		"removed runner": {
+			change: func(previous *common.RunnerConfig) common.RunnerConfigChange {
+				return common.RunnerConfigChange{Previous: previous}
+			},
+			expectFn: func(ts *mocks.Taskscaler) {
+				ts.EXPECT().ConfigureSchedule().Return(nil)
+			},
+			expectShutdown: true,
+		},
+		"removed runner with jobs": {
+			reservations: 1,
+			change: func(previous *common.RunnerConfig) common.RunnerConfigChange {
+				return common.RunnerConfigChange{Previous: previous}
+			},
+			expectFn: func(ts *mocks.Taskscaler) {
+				ts.EXPECT().ConfigureSchedule().Return(nil)
+			},
+		},
+		"changed token": {
+			change: func(previous *common.RunnerConfig) common.RunnerConfigChange {
+				return common.RunnerConfigChange{
+					Previous: previous,
+					Current:  newConfig("new-token", 1),
+					Settings: []string{"token"},
+				}
+			},
+			expectFn: func(ts *mocks.Taskscaler) {
+				ts.EXPECT().ConfigureSchedule().Return(nil)
+			},
+			expectShutdown: true,
+		},
+		"runner without taskscaler": {
+			change: func(*common.RunnerConfig) common.RunnerConfigChange {
+				return common.RunnerConfigChange{Current: newConfig("other-token", 1)}
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			previous := newConfig("token", 1)
+
+			ts := mocks.NewTaskscaler(t)
+			if tt.expectFn != nil {
+				tt.expectFn(ts)
+			}
+
+			shutdown := make(chan struct{})
+			p := New(&common.MockExecutorProvider{}, Config{}).(*provider)
+			p.scalers[previous.GetToken()] = &scaler{
+				internal:     ts,
+				shutdown:     func(context.Context) { close(shutdown) },
+				reservations: tt.reservations,
+			}
+
+			err := p.Reconfigure(tt.change(previous))
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+
+			_, kept := p.scalers[previous.GetToken()]
+			assert.Equal(t, !tt.expectShutdown, kept)
+			if tt.expectShutdown {
+				<-shutdown
+			}
+		})
+	}
+}
+
+func TestRetiredScalerShutdownOnRelease(t *testing.T) {
+	config := common.NewTestRunnerConfig().
+		WithToken("token").
+		WithAutoscalerConfig(common.NewTestAutoscalerConfig().AutoscalerConfig).
+		RunnerConfig
+
+	ts := mocks.NewTaskscaler(t)
+	ts.EXPECT().ConfigureSchedule().Return(nil)
+	ts.EXPECT().Unreserve("first").Return()
+	ts.EXPECT().Unreserve("second").Return()
+
+	shutdown := make(chan struct{})
+	p := New(&common.MockExecutorProvider{}, Config{}).(*provider)
+	p.scalers[config.GetToken()] = &scaler{
+		internal:     ts,
+		shutdown:     func(context.Context) { close(shutdown) },
+		reservations: 2,
+	}
+
+	require.NoError(t, p.Reconfigure(common.RunnerConfigChange{Previous: config}))
+
+	p.Release(config, newAcquisitionRef("first", false))
+	assert.Contains(t, p.scalers, config.GetToken())
+
+	p.Release(config, newAcquisitionRef("second", false))
+	assert.NotContains(t, p.scalers, config.GetToken())
+	<-shutdown
+}
+
 func mockTaskscalerNew(
 	newTaskscaler taskscaler.Taskscaler,
 	newTaskscalerErr bool,
@@ -0,0 +1,124 @@
+package kubernetes
+
+import (
+	"errors"
+	"path"
+
+	api "k8s.io/api/core/v1"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/buildkit"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/helperimage"
+)
+
+const (
+	buildKitContainerName    = "buildkit"
+	buildKitVolumeName       = "buildkit-state"
+	buildKitSocketVolumeName = "buildkit-socket"
+
+	// buildKitAppArmorAnnotation runs the sidecar without AppArmor profile, the
+	// default one denying the mounts the rootless daemon needs
+	buildKitAppArmorAnnotation = "container.apparmor.security.beta.kubernetes.io/" + buildKitContainerName
+)
+
+var (
+	errBuildKitWindows     = errors.New("the BuildKit sidecar isn't supported on Windows nodes")
+	errBuildKitCacheVolume = errors.New("only one of the BuildKit cache_claim_name and cache_host_path can be set")
+)
+
+// prepareBuildKit returns the rootless BuildKit container requested by the job,
+// or nil when the job doesn't request one. The daemon doesn't authenticate its
+// clients, so rather than listening on the pod's network it exposes its socket
+// to the build container through an emptyDir volume.
+func (s *executor) prepareBuildKit() (*api.Container, error) {
+	config := s.Config.Kubernetes.BuildKit
+
+	sidecar, err := buildkit.Resolve(s.Build.GetAllVariables(), config, s.BuildLogger)
+	if err != nil || sidecar == nil {
+		return nil, err
+	}
+
+	if s.helperImageInfo.OSType == helperimage.OSTypeWindows {
+		return nil, errBuildKitWindows
+	}
+
+	if config.CacheClaimName != "" && config.CacheHostPath != "" {
+		return nil, errBuildKitCacheVolume
+	}
+
+	pullPolicy, err := s.pullManager.GetPullPolicyFor(sidecar.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Println("Adding BuildKit sidecar", sidecar.Image, "to the build pod")
+
+	s.Build.Variables = append(s.Build.Variables, common.JobVariable{
+		Key:    buildkit.HostVariable,
+		Value:  buildkit.Host(),
+		Public: true,
+	})
+	s.Build.RefreshAllVariables()
+
+	args := sidecar.Args()
+	// a persistent cache is shared by the jobs of all the projects, each of
+	// them gets its own state directory, created by the daemon
+	if buildKitCacheIsPersistent(config) {
+		args = append(args, "--root", path.Join(buildkit.StateDir, s.Build.ProjectUniqueName()))
+	}
+
+	uid := int64(buildkit.UserID)
+
+	return &api.Container{
+		Name:            buildKitContainerName,
+		Image:           sidecar.Image,
+		ImagePullPolicy: pullPolicy,
+		Args:            args,
+		Resources: api.ResourceRequirements{
+			Limits:   s.configurationOverwrites.serviceLimits,
+			Requests: s.configurationOverwrites.serviceRequests,
+		},
+		VolumeMounts: []api.VolumeMount{
+			{Name: buildKitVolumeName, MountPath: buildkit.StateDir},
+			{Name: buildKitSocketVolumeName, MountPath: buildkit.RuntimeDir},
+		},
+		SecurityContext: &api.SecurityContext{
+			RunAsUser:  &uid,
+			RunAsGroup: &uid,
+			SeccompProfile: &api.SeccompProfile{
+				Type: api.SeccompProfileTypeUnconfined,
+			},
+		},
+	}, nil
+}
+
+func buildKitCacheIsPersistent(config *common.BuildKitConfig) bool {
+	return config.CacheClaimName != "" || config.CacheHostPath != ""
+}
+
+// buildKitVolumes returns the volume keeping the layer cache of the sidecar,
+// an emptyDir removed with the pod unless a claim or a node directory is
+// configured, and the volume sharing its socket with the build container
+func buildKitVolumes(config *common.BuildKitConfig) []api.Volume {
+	cache := api.VolumeSource{EmptyDir: &api.EmptyDirVolumeSource{}}
+	switch {
+	case config.CacheClaimName != "":
+		cache = api.VolumeSource{
+			PersistentVolumeClaim: &api.PersistentVolumeClaimVolumeSource{ClaimName: config.CacheClaimName},
+		}
+	case config.CacheHostPath != "":
+		hostPathType := api.HostPathDirectoryOrCreate
+		cache = api.VolumeSource{
+			HostPath: &api.HostPathVolumeSource{Path: config.CacheHostPath, Type: &hostPathType},
+		}
+	}
+
+	return []api.Volume{
+		{Name: buildKitVolumeName, VolumeSource: cache},
+		{Name: buildKitSocketVolumeName, VolumeSource: api.VolumeSource{EmptyDir: &api.EmptyDirVolumeSource{}}},
+	}
+}
+
+func buildKitSocketVolumeMount() api.VolumeMount {
+	return api.VolumeMount{Name: buildKitSocketVolumeName, MountPath: buildkit.SocketDir}
+}
@@ -0,0 +1,163 @@
+//go:build !integration
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	api "k8s.io/api/core/v1"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/kubernetes/internal/pull"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/buildkit"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/helperimage"
+)
+
+func TestPrepareBuildKit(t *testing.T) {
+	requested := common.JobVariables{{Key: buildkit.EnableVariable, Value: "true"}}
+
+	tests := map[string]struct {
+		variables     common.JobVariables
+		config        *common.BuildKitConfig
+		osType        string
+		expectAdded   bool
+		expectedImage string
+		expectedRoot  string
+		expectedErr   error
+	}{
+		"not requested": {
+			config: &common.BuildKitConfig{Enabled: true},
+			osType: helperimage.OSTypeLinux,
+		},
+		"not enabled": {
+			variables:   requested,
+			osType:      helperimage.OSTypeLinux,
+			expectedErr: buildkit.ErrNotEnabled,
+		},
+		"windows": {
+			variables:   requested,
+			config:      &common.BuildKitConfig{Enabled: true},
+			osType:      helperimage.OSTypeWindows,
+			expectedErr: errBuildKitWindows,
+		},
+		"requested": {
+			variables:     requested,
+			config:        &common.BuildKitConfig{Enabled: true, Image: "registry.example.com/buildkit:rootless"},
+			osType:        helperimage.OSTypeLinux,
+			expectAdded:   true,
+			expectedImage: "registry.example.com/buildkit:rootless",
+		},
+		"requested with persistent cache": {
+			variables:     requested,
+			config:        &common.BuildKitConfig{Enabled: true, CacheClaimName: "buildkit-cache"},
+			osType:        helperimage.OSTypeLinux,
+			expectAdded:   true,
+			expectedImage: buildkit.DefaultImage,
+			expectedRoot:  buildkit.StateDir + "/runner--project-0-concurrent-0",
+		},
+		"both cache volumes": {
+			variables:   requested,
+			config:      &common.BuildKitConfig{Enabled: true, CacheClaimName: "buildkit-cache", CacheHostPath: "/var/cache/buildkit"},
+			osType:      helperimage.OSTypeLinux,
+			expectedErr: errBuildKitCacheVolume,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			mockPullManager := pull.NewMockManager(t)
+
+			e := &executor{
+				AbstractExecutor: executors.AbstractExecutor{
+					ExecutorOptions: executorOptions,
+					Build: &common.Build{
+						Runner: &common.RunnerConfig{},
+					},
+					Config: common.RunnerConfig{
+						RunnerSettings: common.RunnerSettings{
+							Kubernetes: &common.KubernetesConfig{BuildKit: tt.config},
+						},
+					},
+				},
+				pullManager:             mockPullManager,
+				helperImageInfo:         helperimage.Info{OSType: tt.osType},
+				configurationOverwrites: &overwrites{},
+			}
+			e.Build.Variables = tt.variables
+
+			if tt.expectAdded {
+				mockPullManager.On("GetPullPolicyFor", tt.expectedImage).
+					Return(api.PullIfNotPresent, nil).
+					Once()
+			}
+
+			container, err := e.prepareBuildKit()
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			if !tt.expectAdded {
+				assert.Nil(t, container)
+				assert.Empty(t, e.Build.GetAllVariables().Get(buildkit.HostVariable))
+				return
+			}
+
+			require.NotNil(t, container)
+			assert.Equal(t, buildKitContainerName, container.Name)
+			assert.Equal(t, tt.expectedImage, container.Image)
+			assert.Equal(t, api.PullIfNotPresent, container.ImagePullPolicy)
+			assert.Contains(t, container.Args, "--oci-worker-no-process-sandbox")
+			assert.Equal(t, api.SeccompProfileTypeUnconfined, container.SecurityContext.SeccompProfile.Type)
+			assert.Equal(t, int64(buildkit.UserID), *container.SecurityContext.RunAsUser)
+			assert.Nil(t, container.SecurityContext.Privileged)
+			assert.Contains(t, container.VolumeMounts, api.VolumeMount{Name: buildKitSocketVolumeName, MountPath: buildkit.RuntimeDir})
+			assert.Equal(t, "unix:///run/buildkit/buildkitd.sock", e.Build.GetAllVariables().Get(buildkit.HostVariable))
+
+			if tt.expectedRoot == "" {
+				assert.NotContains(t, container.Args, "--root")
+				return
+			}
+			assert.Equal(t, []string{"--root", tt.expectedRoot}, container.Args[len(container.Args)-2:])
+		})
+	}
+}
+
+func TestBuildKitVolumes(t *testing.T) {
+	directoryOrCreate := api.HostPathDirectoryOrCreate
+
+	tests := map[string]struct {
+		config        *common.BuildKitConfig
+		expectedCache api.VolumeSource
+	}{
+		"emptyDir": {
+			config:        &common.BuildKitConfig{Enabled: true},
+			expectedCache: api.VolumeSource{EmptyDir: &api.EmptyDirVolumeSource{}},
+		},
+		"claim": {
+			config: &common.BuildKitConfig{Enabled: true, CacheClaimName: "buildkit-cache"},
+			expectedCache: api.VolumeSource{
+				PersistentVolumeClaim: &api.PersistentVolumeClaimVolumeSource{ClaimName: "buildkit-cache"},
+			},
+		},
+		"host path": {
+			config: &common.BuildKitConfig{Enabled: true, CacheHostPath: "/var/cache/buildkit"},
+			expectedCache: api.VolumeSource{
+				HostPath: &api.HostPathVolumeSource{Path: "/var/cache/buildkit", Type: &directoryOrCreate},
+			},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, []api.Volume{
+				{Name: buildKitVolumeName, VolumeSource: tt.expectedCache},
+				{Name: buildKitSocketVolumeName, VolumeSource: api.VolumeSource{EmptyDir: &api.EmptyDirVolumeSource{}}},
+			}, buildKitVolumes(tt.config))
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors"
+)
+
+const (
+	clusterUnhealthyCooldown  = time.Minute
+	clusterHealthCheckTimeout = 10 * time.Second
+
+	clusterFailureHealthCheck = "health_check"
+	clusterFailureAPI         = "api_error"
+	clusterFailureCapacity    = "capacity"
+)
+
+var (
+	errNoHealthyCluster = errors.New("no healthy Kubernetes cluster available")
+
+	// defaultClusterPool is shared by all the executors created by the provider
+	defaultClusterPool = newClusterPool()
+)
+
+// executorProvider exposes the metrics of the cluster pool on top of
+// the default executor provider.
+type executorProvider struct {
+	executors.DefaultExecutorProvider
+	*clusterPool
+}
+
+type clusterKey struct {
+	runner  string
+	cluster string
+}
+
+type clusterState struct {
+	unhealthyUntil time.Time
+}
+
+// clusterPool tracks the health of the Kubernetes clusters configured for
+// the runners and orders them for selection. It's shared by all the jobs
+// handled by the executor provider.
+type clusterPool struct {
+	lock   sync.Mutex
+	states map[clusterKey]*clusterState
+
+	cooldown time.Duration
+	now      func() time.Time
+	random   func(n int) int
+
+	selectionsTotal *prometheus.CounterVec
+	failuresTotal   *prometheus.CounterVec
+	healthyDesc     *prometheus.Desc
+}
+
+func newClusterPool() *clusterPool {
+	return &clusterPool{
+		states:   make(map[clusterKey]*clusterState),
+		cooldown: clusterUnhealthyCooldown,
+		now:      time.Now,
+		random:   rand.Intn,
+		selectionsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gitlab_runner_kubernetes_cluster_selections_total",
+				Help: "The total number of jobs assigned to a Kubernetes cluster.",
+			},
+			[]string{"runner", "cluster"},
+		),
+		failuresTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gitlab_runner_kubernetes_cluster_failures_total",
+				Help: "The total number of failures that made the executor fall back from a Kubernetes cluster.",
+			},
+			[]string{"runner", "cluster", "reason"},
+		),
+		healthyDesc: prometheus.NewDesc(
+			"gitlab_runner_kubernetes_cluster_healthy",
+			"Whether the Kubernetes cluster is currently considered healthy.",
+			[]string{"runner", "cluster"},
+			nil,
+		),
+	}
+}
+
+func (p *clusterPool) isHealthy(key clusterKey) bool {
+	state, ok := p.states[key]
+	return !ok || !p.now().Before(state.unhealthyUntil)
+}
+
+// candidates returns the clusters in the order they should be tried. Healthy
+// clusters come first, ordered by priority and, within the same priority,
+// shuffled according to their weight. Clusters that recently failed are
+// kept as the last resort.
+func (p *clusterPool) candidates(runner string, clusters []common.KubernetesCluster) []common.KubernetesCluster {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var healthy, unhealthy []common.KubernetesCluster
+	for _, cluster := range clusters {
+		if p.isHealthy(clusterKey{runner: runner, cluster: cluster.Name}) {
+			healthy = append(healthy, cluster)
+		} else {
+			unhealthy = append(unhealthy, cluster)
+		}
+	}
+
+	return append(p.order(healthy), p.order(unhealthy)...)
+}
+
+func (p *clusterPool) order(clusters []common.KubernetesCluster) []common.KubernetesCluster {
+	byPriority := make(map[int][]common.KubernetesCluster)
+	var priorities []int
+	for _, cluster := range clusters {
+		if _, ok := byPriority[cluster.Priority]; !ok {
+			priorities = append(priorities, cluster.Priority)
+		}
+		byPriority[cluster.Priority] = append(byPriority[cluster.Priority], cluster)
+	}
+	sort.Ints(priorities)
+
+	ordered := make([]common.KubernetesCluster, 0, len(clusters))
+	for _, priority := range priorities {
+		ordered = append(ordered, p.weightedShuffle(byPriority[priority])...)
+	}
+
+	return ordered
+}
+
+func (p *clusterPool) weightedShuffle(clusters []common.KubernetesCluster) []common.KubernetesCluster {
+	remaining := append([]common.KubernetesCluster{}, clusters...)
+	shuffled := make([]common.KubernetesCluster, 0, len(clusters))
+
+	for len(remaining) > 0 {
+		total := 0
+		for _, cluster := range remaining {
+			total += cluster.GetWeight()
+		}
+
+		pick := p.random(total)
+		for i, cluster := range remaining {
+			pick -= cluster.GetWeight()
+			if pick < 0 {
+				shuffled = append(shuffled, cluster)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return shuffled
+}
+
+func (p *clusterPool) markSelected(runner string, cluster string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.states[clusterKey{runner: runner, cluster: cluster}] = &clusterState{}
+	p.selectionsTotal.WithLabelValues(runner, cluster).Inc()
+}
+
+func (p *clusterPool) markUnhealthy(runner string, cluster string, reason string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.states[clusterKey{runner: runner, cluster: cluster}] = &clusterState{
+		unhealthyUntil: p.now().Add(p.cooldown),
+	}
+	p.failuresTotal.WithLabelValues(runner, cluster, reason).Inc()
+}
+
+// Describe implements prometheus.Collector.
+func (p *clusterPool) Describe(ch chan<- *prometheus.Desc) {
+	p.selectionsTotal.Describe(ch)
+	p.failuresTotal.Describe(ch)
+	ch <- p.healthyDesc
+}
+
+// Collect implements prometheus.Collector.
+func (p *clusterPool) Collect(ch chan<- prometheus.Metric) {
+	p.lock.Lock()
+	keys := make([]clusterKey, 0, len(p.states))
+	healthy := make([]bool, 0, len(p.states))
+	for key := range p.states {
+		keys = append(keys, key)
+		healthy = append(healthy, p.isHealthy(key))
+	}
+	p.lock.Unlock()
+
+	for i, key := range keys {
+		value := 0.0
+		if healthy[i] {
+			value = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(p.healthyDesc, prometheus.GaugeValue, value, key.runner, key.cluster)
+	}
+
+	p.selectionsTotal.Collect(ch)
+	p.failuresTotal.Collect(ch)
+}
+
+// clusterFailure returns the reason for falling back to another cluster
+// after err, or an empty string if the error isn't related to the cluster.
+func clusterFailure(err error) string {
+	var buildErr *common.BuildError
+	if errors.As(err, &buildErr) {
+		switch buildErr.FailureReason {
+		case common.PodSchedulingFailure, common.ResourceQuotaExceeded:
+			return clusterFailureCapacity
+		}
+	}
+
+	if isNetworkError(err) ||
+		kubeerrors.IsServiceUnavailable(err) ||
+		kubeerrors.IsServerTimeout(err) ||
+		kubeerrors.IsTimeout(err) ||
+		kubeerrors.IsInternalError(err) ||
+		kubeerrors.IsTooManyRequests(err) {
+		return clusterFailureAPI
+	}
+
+	return ""
+}
+
+func checkClusterHealth(ctx context.Context, client *kubernetes.Clientset) error {
+	ctx, cancel := context.WithTimeout(ctx, clusterHealthCheckTimeout)
+	defer cancel()
+
+	return client.CoreV1().RESTClient().Get().AbsPath("/readyz").Do(ctx).Error()
+}
+
+func (s *executor) clusterRunner() string {
+	return s.Config.RunnerCredentials.ShortDescription()
+}
+
+// connect creates the Kubernetes client. When clusters are configured, the
+// first healthy cluster is selected.
+func (s *executor) connect(ctx context.Context) error {
+	if len(s.Config.Kubernetes.Clusters) == 0 {
+		return s.connectWithConfig(s.Config.Kubernetes)
+	}
+
+	s.clusterCandidates = s.clusters.candidates(s.clusterRunner(), s.Config.Kubernetes.Clusters)
+
+	return s.connectNextCluster(ctx)
+}
+
+func (s *executor) connectWithConfig(config *common.KubernetesConfig) error {
+	kubeConfig, err := getKubeClientConfig(config, s.configurationOverwrites)
+	if err != nil {
+		return fmt.Errorf("getting Kubernetes config: %w", err)
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("connecting to Kubernetes: %w", err)
+	}
+
+	s.kubeConfig = kubeConfig
+	s.kubeClient = kubeClient
+	s.featureChecker = &kubeClientFeatureChecker{kubeClient: kubeClient}
+
+	return nil
+}
+
+// connectNextCluster connects to the next healthy cluster from the candidates
+// selected for the job.
+func (s *executor) connectNextCluster(ctx context.Context) error {
+	var errs []error
+	for len(s.clusterCandidates) > 0 {
+		cluster := s.clusterCandidates[0]
+		s.clusterCandidates = s.clusterCandidates[1:]
+
+		err := s.connectWithConfig(cluster.KubernetesConfig(s.Config.Kubernetes))
+		if err == nil {
+			err = checkClusterHealth(ctx, s.kubeClient)
+		}
+
+		if err != nil {
+			s.Warningln(fmt.Sprintf("Kubernetes cluster %q is not available: %v", cluster.Name, err))
+			s.clusters.markUnhealthy(s.clusterRunner(), cluster.Name, clusterFailureHealthCheck)
+			errs = append(errs, fmt.Errorf("cluster %q: %w", cluster.Name, err))
+			continue
+		}
+
+		s.Println(fmt.Sprintf("Using Kubernetes cluster %q", cluster.Name))
+		s.clusters.markSelected(s.clusterRunner(), cluster.Name)
+		s.cluster = &cluster
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: %w", errNoHealthyCluster, errors.Join(errs...))
+}
+
+// failoverCluster moves the job to the next cluster when the build pod
+// couldn't be started on the current one because of an API or capacity failure.
+// It returns true if the job can be retried on another cluster.
+func (s *executor) failoverCluster(ctx context.Context, err error) bool {
+	if s.cluster == nil || s.podStarted {
+		return false
+	}
+
+	reason := clusterFailure(err)
+	if reason == "" {
+		return false
+	}
+
+	s.Warningln(fmt.Sprintf("Kubernetes cluster %q failed to start the build pod: %v", s.cluster.Name, err))
+	s.clusters.markUnhealthy(s.clusterRunner(), s.cluster.Name, reason)
+
+	if len(s.clusterCandidates) == 0 {
+		return false
+	}
+
+	s.cleanupResources()
+	s.resetPodResources()
+
+	if err := s.connectNextCluster(ctx); err != nil {
+		s.Warningln(err.Error())
+		return false
+	}
+
+	return true
+}
+
+func (s *executor) resetPodResources() {
+	if s.eventsStream != nil {
+		s.eventsStream.Stop()
+		s.eventsStream = nil
+	}
+
+	s.pod = nil
+	s.credentials = nil
+	s.services = nil
+}
@@ -0,0 +1,249 @@
+//go:build !integration
+
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors"
+)
+
+func clusterNames(clusters []common.KubernetesCluster) []string {
+	names := make([]string, len(clusters))
+	for i, cluster := range clusters {
+		names[i] = cluster.Name
+	}
+
+	return names
+}
+
+func TestClusterPool_Candidates(t *testing.T) {
+	clusters := []common.KubernetesCluster{
+		{Name: "backup", Priority: 10},
+		{Name: "primary-a", Priority: 1, Weight: 3},
+		{Name: "primary-b", Priority: 1},
+		{Name: "default"},
+	}
+
+	tests := map[string]struct {
+		random    func(n int) int
+		unhealthy []string
+		expected  []string
+	}{
+		"ordered by priority and weight": {
+			random:   func(n int) int { return 0 },
+			expected: []string{"default", "primary-a", "primary-b", "backup"},
+		},
+		"weight picks the last cluster of the same priority": {
+			random:   func(n int) int { return n - 1 },
+			expected: []string{"default", "primary-b", "primary-a", "backup"},
+		},
+		"unhealthy clusters are tried last": {
+			random:    func(n int) int { return 0 },
+			unhealthy: []string{"default", "primary-a"},
+			expected:  []string{"primary-b", "backup", "default", "primary-a"},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			p := newClusterPool()
+			p.random = tt.random
+
+			for _, name := range tt.unhealthy {
+				p.markUnhealthy("runner", name, clusterFailureAPI)
+			}
+
+			assert.Equal(t, tt.expected, clusterNames(p.candidates("runner", clusters)))
+		})
+	}
+}
+
+func TestClusterPool_UnhealthyCooldown(t *testing.T) {
+	now := time.Now()
+
+	p := newClusterPool()
+	p.now = func() time.Time { return now }
+	p.random = func(n int) int { return 0 }
+
+	clusters := []common.KubernetesCluster{{Name: "a"}, {Name: "b", Priority: 1}}
+
+	p.markUnhealthy("runner", "a", clusterFailureCapacity)
+	assert.Equal(t, []string{"b", "a"}, clusterNames(p.candidates("runner", clusters)))
+	assert.Equal(t, []string{"a", "b"}, clusterNames(p.candidates("other-runner", clusters)))
+
+	now = now.Add(clusterUnhealthyCooldown)
+	assert.Equal(t, []string{"a", "b"}, clusterNames(p.candidates("runner", clusters)))
+
+	assert.Equal(
+		t,
+		float64(1),
+		testutil.ToFloat64(p.failuresTotal.WithLabelValues("runner", "a", clusterFailureCapacity)),
+	)
+}
+
+func TestClusterFailure(t *testing.T) {
+	podsResource := schema.GroupResource{Resource: "pods"}
+
+	tests := map[string]struct {
+		err      error
+		expected string
+	}{
+		"no error": {},
+		"generic error": {
+			err: errors.New("generic"),
+		},
+		"script failure": {
+			err: &common.BuildError{FailureReason: common.ScriptFailure},
+		},
+		"scheduling failure": {
+			err:      fmt.Errorf("wrapped: %w", &common.BuildError{FailureReason: common.PodSchedulingFailure}),
+			expected: clusterFailureCapacity,
+		},
+		"quota exceeded": {
+			err:      &common.BuildError{FailureReason: common.ResourceQuotaExceeded},
+			expected: clusterFailureCapacity,
+		},
+		"network error": {
+			err:      fmt.Errorf("wrapped: %w", syscall.ECONNREFUSED),
+			expected: clusterFailureAPI,
+		},
+		"service unavailable": {
+			err:      kubeerrors.NewServiceUnavailable("unavailable"),
+			expected: clusterFailureAPI,
+		},
+		"internal error": {
+			err:      kubeerrors.NewInternalError(errors.New("internal")),
+			expected: clusterFailureAPI,
+		},
+		"not found": {
+			err: kubeerrors.NewNotFound(podsResource, "test-pod"),
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tt.expected, clusterFailure(tt.err))
+		})
+	}
+}
+
+func newClusterTestServer(t *testing.T, healthy bool) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/readyz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func newClusterTestExecutor(clusters []common.KubernetesCluster) *executor {
+	pool := newClusterPool()
+	pool.random = func(n int) int { return 0 }
+
+	return &executor{
+		AbstractExecutor: executors.AbstractExecutor{
+			Config: common.RunnerConfig{
+				RunnerCredentials: common.RunnerCredentials{Token: "runner-token"},
+				RunnerSettings: common.RunnerSettings{
+					Kubernetes: &common.KubernetesConfig{Clusters: clusters},
+				},
+			},
+			Build: &common.Build{},
+		},
+		configurationOverwrites: &overwrites{},
+		clusters:                pool,
+	}
+}
+
+func TestExecutor_ConnectCluster(t *testing.T) {
+	unhealthy := newClusterTestServer(t, false)
+	healthy := newClusterTestServer(t, true)
+
+	t.Run("selects first healthy cluster", func(t *testing.T) {
+		e := newClusterTestExecutor([]common.KubernetesCluster{
+			{Name: "unhealthy", Host: unhealthy.URL},
+			{Name: "healthy", Host: healthy.URL, Priority: 1},
+		})
+
+		require.NoError(t, e.connect(context.Background()))
+		require.NotNil(t, e.cluster)
+		assert.Equal(t, "healthy", e.cluster.Name)
+		assert.Equal(t, healthy.URL, e.kubeConfig.Host)
+		assert.NotNil(t, e.featureChecker)
+		assert.Empty(t, e.clusterCandidates)
+
+		runner := e.clusterRunner()
+		assert.Equal(
+			t,
+			float64(1),
+			testutil.ToFloat64(e.clusters.failuresTotal.WithLabelValues(runner, "unhealthy", clusterFailureHealthCheck)),
+		)
+		assert.Equal(t, float64(1), testutil.ToFloat64(e.clusters.selectionsTotal.WithLabelValues(runner, "healthy")))
+	})
+
+	t.Run("no healthy cluster", func(t *testing.T) {
+		e := newClusterTestExecutor([]common.KubernetesCluster{
+			{Name: "unhealthy", Host: unhealthy.URL},
+		})
+
+		err := e.connect(context.Background())
+		assert.ErrorIs(t, err, errNoHealthyCluster)
+		assert.Nil(t, e.cluster)
+	})
+
+	t.Run("fails over on capacity failure before the pod started", func(t *testing.T) {
+		e := newClusterTestExecutor([]common.KubernetesCluster{
+			{Name: "first", Host: healthy.URL},
+			{Name: "second", Host: healthy.URL, Priority: 1},
+		})
+
+		require.NoError(t, e.connect(context.Background()))
+		require.Equal(t, "first", e.cluster.Name)
+
+		capacityErr := &common.BuildError{FailureReason: common.PodSchedulingFailure}
+		assert.False(t, e.failoverCluster(context.Background(), errors.New("unrelated")))
+		assert.True(t, e.failoverCluster(context.Background(), capacityErr))
+		assert.Equal(t, "second", e.cluster.Name)
+
+		// no more candidates
+		assert.False(t, e.failoverCluster(context.Background(), capacityErr))
+	})
+
+	t.Run("doesn't fail over once the pod started", func(t *testing.T) {
+		e := newClusterTestExecutor([]common.KubernetesCluster{
+			{Name: "first", Host: healthy.URL},
+			{Name: "second", Host: healthy.URL, Priority: 1},
+		})
+
+		require.NoError(t, e.connect(context.Background()))
+		e.podStarted = true
+
+		assert.False(t, e.failoverCluster(context.Background(), syscall.ECONNRESET))
+		assert.Equal(t, "first", e.cluster.Name)
+	})
+}
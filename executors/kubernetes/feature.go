@@ -12,6 +12,7 @@ import (
 //go:generate mockery --name=featureChecker --inpackage
 type featureChecker interface {
 	IsHostAliasSupported() (bool, error)
+	IsNativeSidecarSupported() (bool, error)
 }
 
 type kubeClientFeatureChecker struct {
@@ -21,6 +22,10 @@ type kubeClientFeatureChecker struct {
 // https://kubernetes.io/docs/concepts/services-networking/add-entries-to-pod-etc-hosts-with-host-aliases/
 var minimumHostAliasesVersionRequired, _ = version.NewVersion("1.7")
 
+// https://kubernetes.io/docs/concepts/workloads/pods/sidecar-containers/
+// Native sidecars are available in alpha since 1.28, but enabled by default only since 1.29
+var minimumNativeSidecarVersionRequired, _ = version.NewVersion("1.29")
+
 type badVersionError struct {
 	major string
 	minor string
@@ -37,6 +42,14 @@ func (s *badVersionError) Is(err error) bool {
 }
 
 func (c *kubeClientFeatureChecker) IsHostAliasSupported() (bool, error) {
+	return c.isVersionAtLeast(minimumHostAliasesVersionRequired)
+}
+
+func (c *kubeClientFeatureChecker) IsNativeSidecarSupported() (bool, error) {
+	return c.isVersionAtLeast(minimumNativeSidecarVersionRequired)
+}
+
+func (c *kubeClientFeatureChecker) isVersionAtLeast(minimum *version.Version) (bool, error) {
 	verInfo, err := c.kubeClient.ServerVersion()
 	if err != nil {
 		return false, err
@@ -56,10 +69,7 @@ func (c *kubeClientFeatureChecker) IsHostAliasSupported() (bool, error) {
 		}
 	}
 
-	supportsHostAliases := ver.GreaterThan(minimumHostAliasesVersionRequired) ||
-		ver.Equal(minimumHostAliasesVersionRequired)
-
-	return supportsHostAliases, nil
+	return ver.GreaterThanOrEqual(minimum), nil
 }
 
 // Sometimes kubernetes returns a version which aren't valid semver versions
@@ -138,6 +138,51 @@ func TestKubeClientFeatureChecker(t *testing.T) {
 				assert.False(t, supported)
 			},
 		},
+		"native sidecars supported version 1.29": {
+			version: k8sversion.Info{
+				Major: "1",
+				Minor: "29",
+			},
+			fn: func(t *testing.T, fc featureChecker) {
+				supported, err := fc.IsNativeSidecarSupported()
+				require.NoError(t, err)
+				assert.True(t, supported)
+			},
+		},
+		"native sidecars supported version 1.30+": {
+			version: k8sversion.Info{
+				Major: "1",
+				Minor: "30+",
+			},
+			fn: func(t *testing.T, fc featureChecker) {
+				supported, err := fc.IsNativeSidecarSupported()
+				require.NoError(t, err)
+				assert.True(t, supported)
+			},
+		},
+		"native sidecars not supported version 1.28": {
+			version: k8sversion.Info{
+				Major: "1",
+				Minor: "28",
+			},
+			fn: func(t *testing.T, fc featureChecker) {
+				supported, err := fc.IsNativeSidecarSupported()
+				require.NoError(t, err)
+				assert.False(t, supported)
+			},
+		},
+		"native sidecars invalid version": {
+			version: k8sversion.Info{
+				Major: "aaa",
+				Minor: "bbb",
+			},
+			fn: func(t *testing.T, fc featureChecker) {
+				supported, err := fc.IsNativeSidecarSupported()
+				require.Error(t, err)
+				assert.False(t, supported)
+				assert.ErrorIs(t, err, &badVersionError{})
+			},
+		},
 	}
 
 	for tn, tt := range tests {
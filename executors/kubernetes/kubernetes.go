@@ -237,6 +237,9 @@ type podConfigPrepareOpts struct {
 	initContainers   []api.Container
 	imagePullSecrets []api.LocalObjectReference
 	hostAliases      []api.HostAlias
+	buildKit         *api.Container
+
+	nativeSidecarServices bool
 }
 
 type executor struct {
@@ -269,6 +272,25 @@ type executor struct {
 	remoteStageStatus      shells.StageCommandStatus
 
 	eventsStream watch.Interface
+
+	podFailureEventMutex sync.Mutex
+	podFailureEvent      *api.Event
+
+	clusters          *clusterPool
+	cluster           *common.KubernetesCluster
+	clusterCandidates []common.KubernetesCluster
+
+	// podStarted is set once the build pod is running, after which the job
+	// can't be moved to another cluster anymore
+	podStarted bool
+
+	// localSourcesCopied is set once the local sources of a build run by
+	// exec are copied to the build pod
+	localSourcesCopied bool
+
+	// remoteExecutor runs the commands copying the local sources to the build
+	// pod, DefaultRemoteExecutor when nil
+	remoteExecutor RemoteExecutor
 }
 
 type serviceCreateResponse struct {
@@ -299,14 +321,8 @@ func (s *executor) Prepare(options common.ExecutorPrepareOptions) (err error) {
 		return fmt.Errorf("check defaults error: %w", err)
 	}
 
-	s.kubeConfig, err = getKubeClientConfig(s.Config.Kubernetes, s.configurationOverwrites)
-	if err != nil {
-		return fmt.Errorf("getting Kubernetes config: %w", err)
-	}
-
-	s.kubeClient, err = kubernetes.NewForConfig(s.kubeConfig)
-	if err != nil {
-		return fmt.Errorf("connecting to Kubernetes: %w", err)
+	if err = s.connect(options.Context); err != nil {
+		return err
 	}
 
 	s.helperImageInfo, err = s.prepareHelperImage()
@@ -317,8 +333,6 @@ func (s *executor) Prepare(options common.ExecutorPrepareOptions) (err error) {
 	// setup default executor options based on OS type
 	s.setupDefaultExecutorOptions(s.helperImageInfo.OSType)
 
-	s.featureChecker = &kubeClientFeatureChecker{kubeClient: s.kubeClient}
-
 	imageName := s.options.Image.Name
 
 	s.Println("Using Kubernetes executor with image", imageName, "...")
@@ -488,6 +502,11 @@ func (s *executor) Run(cmd common.ExecutorCommand) error {
 				continue
 			}
 		}
+
+		if s.failoverCluster(cmd.Context, err) {
+			continue
+		}
+
 		return err
 	}
 }
@@ -538,6 +557,8 @@ func (s *executor) printPodEvents() {
 			continue
 		}
 
+		s.recordPodFailureEvent(ev)
+
 		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", ev.Type, ev.Reason, ev.Message)
 		_ = w.Flush()
 	}
@@ -574,6 +595,12 @@ func (s *executor) runWithExecLegacy(cmd common.ExecutorCommand) error {
 		return err
 	}
 
+	if cmd.Stage == common.BuildStageGetSources {
+		if err := s.copyLocalSources(ctx); err != nil {
+			return fmt.Errorf("copying the local sources: %w", err)
+		}
+	}
+
 	containerName := buildContainerName
 	containerCommand := s.BuildShell.DockerCommand
 	if cmd.Predefined {
@@ -637,6 +664,12 @@ func (s *executor) runWithAttach(cmd common.ExecutorCommand) error {
 		return err
 	}
 
+	if cmd.Stage == common.BuildStageGetSources {
+		if err := s.copyLocalSources(ctx); err != nil {
+			return fmt.Errorf("copying the local sources: %w", err)
+		}
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -666,7 +699,8 @@ func (s *executor) runWithAttach(cmd common.ExecutorCommand) error {
 
 		return err
 	case err := <-podStatusCh:
-		if IsKubernetesPodNotFoundError(err) {
+		var buildErr *common.BuildError
+		if IsKubernetesPodNotFoundError(err) || errors.As(err, &buildErr) {
 			return err
 		}
 
@@ -708,13 +742,15 @@ func (s *executor) ensurePodsConfigured(ctx context.Context) error {
 
 	status, err := waitForPodRunning(ctx, s.kubeClient, s.pod, out, s.Config.Kubernetes)
 	if err != nil {
-		return fmt.Errorf("waiting for pod running: %w", err)
+		return fmt.Errorf("waiting for pod running: %w", s.withPodFailureEvent(err))
 	}
 
 	if status != api.PodRunning {
 		return fmt.Errorf("pod failed to enter running state: %s", status)
 	}
 
+	s.podStarted = true
+
 	err = s.setupTrappingScripts(ctx)
 	if err != nil {
 		return fmt.Errorf("setting up trapping scripts on emptyDir: %w", err)
@@ -722,7 +758,8 @@ func (s *executor) ensurePodsConfigured(ctx context.Context) error {
 
 	go s.processLogs(ctx)
 
-	s.captureContainersLogs(ctx, s.pod.Spec.Containers)
+	// Services may run as native sidecars, which are defined as init containers
+	s.captureContainersLogs(ctx, lo.Flatten([][]api.Container{s.pod.Spec.InitContainers, s.pod.Spec.Containers}))
 
 	return nil
 }
@@ -1638,7 +1675,7 @@ func (s *executor) setupBuildPod(ctx context.Context, initContainers []api.Conta
 	})
 	s.pod, err = kubeRequest.RunValue()
 	if err != nil {
-		return err
+		return classifyPodCreationError(err)
 	}
 
 	ownerReferences := s.buildPodReferences()
@@ -1652,8 +1689,14 @@ func (s *executor) setupBuildPod(ctx context.Context, initContainers []api.Conta
 }
 
 func (s *executor) requestPodCreation(ctx context.Context, pod *api.Pod, namespace string) (*api.Pod, error) {
-	p, err := s.kubeClient.CoreV1().
-		Pods(namespace).Create(ctx, pod, metav1.CreateOptions{})
+	var p *api.Pod
+	var err error
+	if hasNativeSidecars(pod) {
+		p, err = s.requestNativeSidecarPodCreation(ctx, pod, namespace)
+	} else {
+		p, err = s.kubeClient.CoreV1().
+			Pods(namespace).Create(ctx, pod, metav1.CreateOptions{})
+	}
 	if isConflict(err) {
 		s.Debugln(
 			fmt.Sprintf(
@@ -1735,13 +1778,28 @@ func (s *executor) createPodConfigPrepareOpts(initContainers []api.Container) (p
 		return podConfigPrepareOpts{}, err
 	}
 
+	nativeSidecarServices, err := s.useNativeSidecarServices()
+	if err != nil {
+		return podConfigPrepareOpts{}, err
+	}
+
+	buildKit, err := s.prepareBuildKit()
+	if err != nil {
+		return podConfigPrepareOpts{}, err
+	}
+	if buildKit != nil {
+		annotations[buildKitAppArmorAnnotation] = "unconfined"
+	}
+
 	return podConfigPrepareOpts{
-		labels:           labels,
-		annotations:      annotations,
-		services:         podServices,
-		imagePullSecrets: imagePullSecrets,
-		hostAliases:      hostAliases,
-		initContainers:   initContainers,
+		labels:                labels,
+		annotations:           annotations,
+		services:              podServices,
+		imagePullSecrets:      imagePullSecrets,
+		hostAliases:           hostAliases,
+		initContainers:        initContainers,
+		buildKit:              buildKit,
+		nativeSidecarServices: nativeSidecarServices,
 	}, nil
 }
 
@@ -1803,6 +1861,26 @@ func (s *executor) preparePodConfig(opts podConfigPrepareOpts) (api.Pod, error)
 		return api.Pod{}, err
 	}
 
+	initContainers := opts.initContainers
+	containers := append([]api.Container{
+		buildContainer,
+		helperContainer,
+	}, opts.services...)
+
+	// Native sidecars are started in order, after the other init containers
+	// and before the build and helper containers
+	if opts.nativeSidecarServices {
+		initContainers = append(initContainers, s.asNativeSidecars(opts.services)...)
+		containers = []api.Container{buildContainer, helperContainer}
+	}
+
+	volumes := s.getVolumes()
+	if opts.buildKit != nil {
+		containers[0].VolumeMounts = append(containers[0].VolumeMounts, buildKitSocketVolumeMount())
+		containers = append(containers, *opts.buildKit)
+		volumes = append(volumes, buildKitVolumes(s.Config.Kubernetes.BuildKit)...)
+	}
+
 	pod := api.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        generateNameForK8sResources(s.Build.ProjectUniqueName()),
@@ -1811,17 +1889,14 @@ func (s *executor) preparePodConfig(opts podConfigPrepareOpts) (api.Pod, error)
 			Annotations: opts.annotations,
 		},
 		Spec: api.PodSpec{
-			Volumes:            s.getVolumes(),
-			SchedulerName:      s.Config.Kubernetes.SchedulerName,
-			ServiceAccountName: s.configurationOverwrites.serviceAccount,
-			RestartPolicy:      api.RestartPolicyNever,
-			NodeSelector:       s.configurationOverwrites.nodeSelector,
-			Tolerations:        s.Config.Kubernetes.GetNodeTolerations(),
-			InitContainers:     opts.initContainers,
-			Containers: append([]api.Container{
-				buildContainer,
-				helperContainer,
-			}, opts.services...),
+			Volumes:                       volumes,
+			SchedulerName:                 s.Config.Kubernetes.SchedulerName,
+			ServiceAccountName:            s.configurationOverwrites.serviceAccount,
+			RestartPolicy:                 api.RestartPolicyNever,
+			NodeSelector:                  s.configurationOverwrites.nodeSelector,
+			Tolerations:                   s.Config.Kubernetes.GetNodeTolerations(),
+			InitContainers:                initContainers,
+			Containers:                    containers,
 			TerminationGracePeriodSeconds: s.Config.Kubernetes.GetPodTerminationGracePeriodSeconds(),
 			ActiveDeadlineSeconds:         s.getPodActiveDeadlineSeconds(),
 			ImagePullSecrets:              opts.imagePullSecrets,
@@ -2235,6 +2310,10 @@ func (s *executor) checkPodStatus(ctx context.Context) error {
 		return nil
 	}
 
+	if err := classifyPodFailure(pod); err != nil {
+		return err
+	}
+
 	if pod.Status.Phase != api.PodRunning {
 		return &podPhaseError{
 			name:  s.pod.Name,
@@ -2310,7 +2389,7 @@ func (s *executor) runInContainerWithExec(
 
 		status, err := waitForPodRunning(ctx, s.kubeClient, s.pod, out, s.Config.Kubernetes)
 		if err != nil {
-			errCh <- err
+			errCh <- s.withPodFailureEvent(err)
 			return
 		}
 
@@ -2319,6 +2398,8 @@ func (s *executor) runInContainerWithExec(
 			return
 		}
 
+		s.podStarted = true
+
 		exec := ExecOptions{
 			PodName:       s.pod.Name,
 			Namespace:     s.pod.Namespace,
@@ -2560,6 +2641,7 @@ func newExecutor() *executor {
 			ExecutorOptions: executorOptions,
 		},
 		remoteProcessTerminated: make(chan shells.StageCommandStatus),
+		clusters:                defaultClusterPool,
 	}
 
 	e.newLogProcessor = func() logProcessor {
@@ -2596,11 +2678,14 @@ func featuresFn(features *common.FeaturesInfo) {
 }
 
 func init() {
-	common.RegisterExecutorProvider(common.ExecutorKubernetes, executors.DefaultExecutorProvider{
-		Creator: func() common.Executor {
-			return newExecutor()
+	common.RegisterExecutorProvider(common.ExecutorKubernetes, &executorProvider{
+		DefaultExecutorProvider: executors.DefaultExecutorProvider{
+			Creator: func() common.Executor {
+				return newExecutor()
+			},
+			FeaturesUpdater:  featuresFn,
+			DefaultShellName: executorOptions.Shell.Shell,
 		},
-		FeaturesUpdater:  featuresFn,
-		DefaultShellName: executorOptions.Shell.Shell,
+		clusterPool: defaultClusterPool,
 	})
 }
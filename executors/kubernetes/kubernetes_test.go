@@ -3912,6 +3912,50 @@ func TestSetupBuildPod(t *testing.T) {
 				assert.Equal(t, 80, port.Number)
 			},
 		},
+		"runs services as native sidecars": {
+			RunnerConfig: common.RunnerConfig{
+				RunnerSettings: common.RunnerSettings{
+					Kubernetes: &common.KubernetesConfig{
+						NativeSidecarServices: true,
+						PollInterval:          1,
+						PollTimeout:           30,
+					},
+				},
+			},
+			Options: &kubernetesOptions{
+				Image: common.Image{
+					Name: "test-image",
+				},
+				Services: common.Services{
+					{
+						Name:  "test-service-0",
+						Ports: []common.Port{{Number: 5432}},
+					},
+					{
+						Name: "test-service-1",
+					},
+				},
+			},
+			InitContainers: []api.Container{{Name: "init-permissions"}},
+			VerifyFn: func(t *testing.T, test setupBuildPodTestDef, pod *api.Pod) {
+				require.Len(t, pod.Spec.Containers, 2)
+				assert.Equal(t, buildContainerName, pod.Spec.Containers[0].Name)
+				assert.Equal(t, helperContainerName, pod.Spec.Containers[1].Name)
+
+				require.Len(t, pod.Spec.InitContainers, 3)
+				assert.Equal(t, "init-permissions", pod.Spec.InitContainers[0].Name)
+				assert.Equal(t, "svc-0", pod.Spec.InitContainers[1].Name)
+				assert.Equal(t, "svc-1", pod.Spec.InitContainers[2].Name)
+
+				probe := pod.Spec.InitContainers[1].StartupProbe
+				require.NotNil(t, probe)
+				require.NotNil(t, probe.TCPSocket)
+				assert.Equal(t, 5432, probe.TCPSocket.Port.IntValue())
+				assert.Equal(t, int32(30), probe.FailureThreshold)
+
+				assert.Nil(t, pod.Spec.InitContainers[2].StartupProbe)
+			},
+		},
 		"makes service name compatible with RFC1123": {
 			RunnerConfig: common.RunnerConfig{
 				RunnerSettings: common.RunnerSettings{
@@ -5149,6 +5193,7 @@ containers:
 
 			mockFc := &mockFeatureChecker{}
 			mockFc.On("IsHostAliasSupported").Return(true, nil)
+			mockFc.On("IsNativeSidecarSupported").Return(true, nil).Maybe()
 
 			mockPullManager := &pull.MockManager{}
 			defer mockPullManager.AssertExpectations(t)
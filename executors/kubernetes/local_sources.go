@@ -0,0 +1,65 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/archives"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/helperimage"
+)
+
+// extractLocalSourcesScript extracts the archive read on the standard input
+// to the directory given as first argument
+const extractLocalSourcesScript = `mkdir -p "$0" && tar -xzf - -C "$0"`
+
+// copyLocalSources copies the local working tree of a build run by exec to
+// the project directory, on the builds emptyDir, in place of fetching the
+// sources
+func (s *executor) copyLocalSources(ctx context.Context) error {
+	if s.Build.LocalSources == "" || s.localSourcesCopied {
+		return nil
+	}
+
+	if s.helperImageInfo.OSType == helperimage.OSTypeWindows {
+		return errors.New("copying the local sources isn't supported on Windows")
+	}
+
+	s.Println("Copying the local sources to the build pod...")
+
+	content, writer := io.Pipe()
+	go func() {
+		_ = writer.CloseWithError(archives.CreateTarGzArchive(writer, s.Build.LocalSources))
+	}()
+	defer func() { _ = content.Close() }()
+
+	var remoteExecutor RemoteExecutor = &DefaultRemoteExecutor{}
+	if s.remoteExecutor != nil {
+		remoteExecutor = s.remoteExecutor
+	}
+
+	exec := ExecOptions{
+		PodName:       s.pod.Name,
+		Namespace:     s.pod.Namespace,
+		ContainerName: helperContainerName,
+		Command:       []string{"sh", "-c", extractLocalSourcesScript, s.Build.FullProjectDir()},
+		In:            content,
+		Out:           s.Trace,
+		Err:           s.Trace,
+		Stdin:         true,
+		Config:        s.kubeConfig,
+		Client:        s.kubeClient,
+		Executor:      remoteExecutor,
+
+		Context: ctx,
+	}
+
+	// the archive is streamed, the request can't be retried
+	if err := exec.Run(); err != nil {
+		return err
+	}
+
+	s.localSourcesCopied = true
+
+	return nil
+}
@@ -0,0 +1,133 @@
+//go:build !integration
+
+package kubernetes
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest/fake"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/helperimage"
+)
+
+func newLocalSourcesTestExecutor(t *testing.T, remoteExecutor RemoteExecutor) *executor {
+	version, codec := testVersionAndCodec()
+
+	pod := &api.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"},
+		Status:     api.PodStatus{Phase: api.PodRunning},
+	}
+
+	sources := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sources, "main.go"), []byte("package main"), 0o600))
+
+	s := &executor{
+		pod:            pod,
+		remoteExecutor: remoteExecutor,
+		kubeClient: testKubernetesClient(version, fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       objBody(codec, pod),
+				Header:     map[string][]string{common.ContentType: {"application/json"}},
+			}, nil
+		})),
+		helperImageInfo: helperimage.Info{OSType: helperimage.OSTypeLinux},
+	}
+	s.Build = &common.Build{Runner: &common.RunnerConfig{}, LocalSources: sources}
+	s.Build.BuildDir = "/builds/group/project"
+	s.Trace = &common.Trace{Writer: io.Discard}
+	s.BuildLogger = common.NewBuildLogger(s.Trace, logrus.WithField("test", t.Name()))
+
+	return s
+}
+
+func readTarGz(t *testing.T, r io.Reader) map[string]string {
+	gz, err := gzip.NewReader(r)
+	require.NoError(t, err)
+
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files
+		}
+		require.NoError(t, err)
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+}
+
+func TestCopyLocalSources(t *testing.T) {
+	remoteExecutor := NewMockRemoteExecutor(t)
+	s := newLocalSourcesTestExecutor(t, remoteExecutor)
+
+	var files map[string]string
+	remoteExecutor.
+		On("Execute", mock.Anything, http.MethodPost, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, false).
+		Run(func(args mock.Arguments) {
+			query := args.Get(2).(*url.URL).Query()
+			assert.Equal(t, []string{"sh", "-c", extractLocalSourcesScript, "/builds/group/project"}, query["command"])
+			assert.Contains(t, query["container"], helperContainerName)
+
+			files = readTarGz(t, args.Get(4).(io.Reader))
+		}).
+		Return(nil).
+		Once()
+
+	require.NoError(t, s.copyLocalSources(context.Background()))
+	assert.Equal(t, map[string]string{"main.go": "package main"}, files)
+
+	// a retried get_sources stage doesn't copy the sources again
+	require.NoError(t, s.copyLocalSources(context.Background()))
+}
+
+func TestCopyLocalSourcesFailure(t *testing.T) {
+	remoteExecutor := NewMockRemoteExecutor(t)
+	s := newLocalSourcesTestExecutor(t, remoteExecutor)
+
+	remoteExecutor.
+		On("Execute", mock.Anything, http.MethodPost, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, false).
+		Run(func(args mock.Arguments) {
+			_, _ = io.Copy(io.Discard, args.Get(4).(io.Reader))
+		}).
+		Return(errors.New("exec failed")).
+		Twice()
+
+	assert.Error(t, s.copyLocalSources(context.Background()))
+	assert.Error(t, s.copyLocalSources(context.Background()), "the sources are copied again after a failure")
+}
+
+func TestCopyLocalSourcesSkipped(t *testing.T) {
+	s := newLocalSourcesTestExecutor(t, NewMockRemoteExecutor(t))
+
+	s.Build.LocalSources = ""
+	assert.NoError(t, s.copyLocalSources(context.Background()))
+
+	s.Build.LocalSources = t.TempDir()
+	s.helperImageInfo.OSType = helperimage.OSTypeWindows
+	assert.Error(t, s.copyLocalSources(context.Background()))
+
+}
@@ -33,6 +33,30 @@ func (_m *mockFeatureChecker) IsHostAliasSupported() (bool, error) {
 	return r0, r1
 }
 
+// IsNativeSidecarSupported provides a mock function with given fields:
+func (_m *mockFeatureChecker) IsNativeSidecarSupported() (bool, error) {
+	ret := _m.Called()
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func() (bool, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type mockConstructorTestingTnewMockFeatureChecker interface {
 	mock.TestingT
 	Cleanup(func())
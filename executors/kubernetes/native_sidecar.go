@@ -0,0 +1,148 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// containerRestartPolicyAlways marks an init container as a native sidecar.
+	// The field isn't available in the version of the Kubernetes API types we use,
+	// so it's set directly in the JSON sent to the cluster.
+	containerRestartPolicyAlways = "Always"
+
+	sidecarStartupProbePeriodSeconds = 1
+)
+
+// useNativeSidecarServices returns true when services should be added to the
+// build pod as native sidecar init containers. When the cluster doesn't support
+// them, services fall back to be regular containers of the pod.
+func (s *executor) useNativeSidecarServices() (bool, error) {
+	if !s.Config.Kubernetes.NativeSidecarServices || len(s.options.Services) == 0 {
+		return false, nil
+	}
+
+	supported, err := s.featureChecker.IsNativeSidecarSupported()
+	switch {
+	case errors.Is(err, &badVersionError{}):
+		s.Warningln("Checking for native sidecar support. Services will be started as regular containers.", err)
+		return false, nil
+	case err != nil:
+		return false, err
+	case !supported:
+		s.Warningln(fmt.Sprintf(
+			"Native sidecar services require Kubernetes %s or later. Services will be started as regular containers.",
+			minimumNativeSidecarVersionRequired,
+		))
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// asNativeSidecars configures the service containers to be started as native sidecars.
+// Services exposing ports get a startup probe for the first port, so that the next
+// sidecar, and finally the build container, are started only once the service
+// accepts connections.
+func (s *executor) asNativeSidecars(services []api.Container) []api.Container {
+	sidecars := make([]api.Container, len(services))
+	for i, service := range services {
+		sidecars[i] = service
+		sidecars[i].StartupProbe = s.sidecarStartupProbe(service)
+	}
+
+	return sidecars
+}
+
+func (s *executor) sidecarStartupProbe(container api.Container) *api.Probe {
+	if len(container.Ports) == 0 {
+		return nil
+	}
+
+	pollTimeout := s.Config.Kubernetes.GetPollAttempts() * s.Config.Kubernetes.GetPollInterval()
+	failureThreshold := int32(pollTimeout / sidecarStartupProbePeriodSeconds)
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+
+	return &api.Probe{
+		ProbeHandler: api.ProbeHandler{
+			TCPSocket: &api.TCPSocketAction{
+				Port: intstr.FromInt(int(container.Ports[0].ContainerPort)),
+			},
+		},
+		PeriodSeconds:    sidecarStartupProbePeriodSeconds,
+		FailureThreshold: failureThreshold,
+	}
+}
+
+func isNativeSidecar(container api.Container) bool {
+	return strings.HasPrefix(container.Name, serviceContainerPrefix)
+}
+
+func hasNativeSidecars(pod *api.Pod) bool {
+	for _, container := range pod.Spec.InitContainers {
+		if isNativeSidecar(container) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nativeSidecarPodBody returns the JSON encoded pod, with the restartPolicy of
+// service init containers set to Always.
+func nativeSidecarPodBody(pod *api.Pod) ([]byte, error) {
+	data, err := json.Marshal(pod)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	spec, _ := raw["spec"].(map[string]interface{})
+	initContainers, _ := spec["initContainers"].([]interface{})
+	for i, container := range pod.Spec.InitContainers {
+		if !isNativeSidecar(container) || i >= len(initContainers) {
+			continue
+		}
+
+		if c, ok := initContainers[i].(map[string]interface{}); ok {
+			c["restartPolicy"] = containerRestartPolicyAlways
+		}
+	}
+
+	return json.Marshal(raw)
+}
+
+func (s *executor) requestNativeSidecarPodCreation(
+	ctx context.Context,
+	pod *api.Pod,
+	namespace string,
+) (*api.Pod, error) {
+	body, err := nativeSidecarPodBody(pod)
+	if err != nil {
+		return nil, fmt.Errorf("encoding pod with native sidecars: %w", err)
+	}
+
+	result := &api.Pod{}
+	err = s.kubeClient.CoreV1().RESTClient().
+		Post().
+		Namespace(namespace).
+		Resource("pods").
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		Do(ctx).
+		Into(result)
+
+	return result, err
+}
@@ -0,0 +1,129 @@
+//go:build !integration
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	api "k8s.io/api/core/v1"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors"
+)
+
+func TestUseNativeSidecarServices(t *testing.T) {
+	checkErr := errors.New("check error")
+
+	tests := map[string]struct {
+		enabled       bool
+		services      common.Services
+		supported     bool
+		supportedErr  error
+		expectedUse   bool
+		expectedErr   error
+		expectedCheck bool
+	}{
+		"disabled": {
+			services: common.Services{{Name: "postgres"}},
+		},
+		"enabled without services": {
+			enabled: true,
+		},
+		"enabled and supported": {
+			enabled:       true,
+			services:      common.Services{{Name: "postgres"}},
+			supported:     true,
+			expectedUse:   true,
+			expectedCheck: true,
+		},
+		"enabled and not supported": {
+			enabled:       true,
+			services:      common.Services{{Name: "postgres"}},
+			expectedCheck: true,
+		},
+		"enabled with unparsable version": {
+			enabled:       true,
+			services:      common.Services{{Name: "postgres"}},
+			supportedErr:  &badVersionError{major: "a", minor: "b"},
+			expectedCheck: true,
+		},
+		"enabled with check error": {
+			enabled:       true,
+			services:      common.Services{{Name: "postgres"}},
+			supportedErr:  checkErr,
+			expectedErr:   checkErr,
+			expectedCheck: true,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			fc := newMockFeatureChecker(t)
+			if tt.expectedCheck {
+				fc.On("IsNativeSidecarSupported").Return(tt.supported, tt.supportedErr).Once()
+			}
+
+			e := &executor{
+				AbstractExecutor: executors.AbstractExecutor{
+					Config: common.RunnerConfig{
+						RunnerSettings: common.RunnerSettings{
+							Kubernetes: &common.KubernetesConfig{
+								NativeSidecarServices: tt.enabled,
+							},
+						},
+					},
+					Build: &common.Build{},
+				},
+				options:        &kubernetesOptions{Services: tt.services},
+				featureChecker: fc,
+			}
+
+			use, err := e.useNativeSidecarServices()
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expectedUse, use)
+		})
+	}
+}
+
+func TestNativeSidecarPodBody(t *testing.T) {
+	pod := &api.Pod{
+		Spec: api.PodSpec{
+			InitContainers: []api.Container{
+				{Name: "init-permissions"},
+				{Name: "svc-0"},
+				{Name: "svc-1"},
+			},
+			Containers: []api.Container{
+				{Name: "build"},
+				{Name: "helper"},
+			},
+		},
+	}
+
+	body, err := nativeSidecarPodBody(pod)
+	require.NoError(t, err)
+
+	var raw struct {
+		Spec struct {
+			InitContainers []map[string]interface{} `json:"initContainers"`
+			Containers     []map[string]interface{} `json:"containers"`
+		} `json:"spec"`
+	}
+	require.NoError(t, json.Unmarshal(body, &raw))
+
+	require.Len(t, raw.Spec.InitContainers, 3)
+	assert.NotContains(t, raw.Spec.InitContainers[0], "restartPolicy")
+	assert.Equal(t, "Always", raw.Spec.InitContainers[1]["restartPolicy"])
+	assert.Equal(t, "Always", raw.Spec.InitContainers[2]["restartPolicy"])
+
+	for _, c := range raw.Spec.Containers {
+		assert.NotContains(t, c, "restartPolicy")
+	}
+
+	assert.True(t, hasNativeSidecars(pod))
+	assert.False(t, hasNativeSidecars(&api.Pod{}))
+}
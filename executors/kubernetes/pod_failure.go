@@ -0,0 +1,290 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+const (
+	podReasonEvicted          = "Evicted"
+	podReasonUnschedulable    = api.PodReasonUnschedulable
+	containerReasonOOMKilled  = "OOMKilled"
+	eventReasonFailedSchedule = "FailedScheduling"
+	eventReasonOOMKilling     = "OOMKilling"
+	eventReasonFailed         = "Failed"
+
+	// the events the cluster autoscaler reports for the pods it scales up, or
+	// decides not to scale up, for
+	eventReasonTriggeredScaleUp  = "TriggeredScaleUp"
+	eventReasonNotTriggerScaleUp = "NotTriggerScaleUp"
+
+	quotaExceededMessage = "exceeded quota"
+)
+
+// newPodFailure returns the error describing a pod condition that prevents
+// the job from running, classified into the failure reason reported to GitLab.
+func newPodFailure(reason common.JobFailureReason, format string, args ...interface{}) *common.BuildError {
+	return &common.BuildError{
+		Inner:         fmt.Errorf(format, args...),
+		FailureReason: reason,
+	}
+}
+
+// classifyPodFailure inspects the status of the pod and returns an error
+// for conditions the pod is not going to recover from, such as eviction or
+// a container being killed because of running out of memory.
+// It returns nil when no terminal condition was detected.
+func classifyPodFailure(pod *api.Pod) error {
+	if pod == nil {
+		return nil
+	}
+
+	if pod.Status.Phase == api.PodFailed && pod.Status.Reason == podReasonEvicted {
+		return newPodFailure(
+			common.PodEvictedFailure,
+			"pod %s/%s was evicted: %s",
+			pod.Namespace,
+			pod.Name,
+			pod.Status.Message,
+		)
+	}
+
+	statuses := append(
+		append([]api.ContainerStatus{}, pod.Status.InitContainerStatuses...),
+		pod.Status.ContainerStatuses...,
+	)
+	for _, container := range statuses {
+		terminated := container.State.Terminated
+		if terminated == nil || terminated.Reason != containerReasonOOMKilled {
+			continue
+		}
+
+		return newPodFailure(
+			common.OutOfMemoryFailure,
+			"container %q in pod %s/%s was OOMKilled, consider increasing its memory limit",
+			container.Name,
+			pod.Namespace,
+			pod.Name,
+		)
+	}
+
+	return nil
+}
+
+// classifyPodPending returns the error describing why the pod is still waiting
+// to be started. It's not terminal by itself, as e.g. cluster autoscaling can
+// make an unschedulable pod schedulable: isPodPendingTerminal tells whether
+// waiting for the pod should stop.
+func classifyPodPending(pod *api.Pod) error {
+	if pod == nil {
+		return nil
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type != api.PodScheduled ||
+			condition.Status != api.ConditionFalse ||
+			condition.Reason != podReasonUnschedulable {
+			continue
+		}
+
+		if strings.Contains(condition.Message, quotaExceededMessage) {
+			return newPodFailure(
+				common.ResourceQuotaExceeded,
+				"pod %s/%s exceeded resource quota: %s",
+				pod.Namespace,
+				pod.Name,
+				condition.Message,
+			)
+		}
+
+		return newPodFailure(
+			common.PodSchedulingFailure,
+			"pod %s/%s could not be scheduled: %s",
+			pod.Namespace,
+			pod.Name,
+			condition.Message,
+		)
+	}
+
+	return nil
+}
+
+// isPodPendingTerminal reports whether the pod, still waiting to be started
+// because of pendingErr, isn't going to start, so the job can fail without
+// waiting for the poll timeout. A pod exceeding the resource quota never
+// starts. An unschedulable pod doesn't start when the cluster autoscaler
+// reported it won't scale up for it, or when no scale up was triggered for it
+// within the grace period. The grace period defaults to the poll timeout as
+// node provisioners other than the cluster autoscaler don't report scaling up.
+func isPodPendingTerminal(
+	pod *api.Pod,
+	pendingErr error,
+	events []api.Event,
+	gracePeriod time.Duration,
+	now time.Time,
+) bool {
+	var buildErr *common.BuildError
+	if !errors.As(pendingErr, &buildErr) {
+		return false
+	}
+
+	switch buildErr.FailureReason {
+	case common.ResourceQuotaExceeded:
+		return true
+	case common.PodSchedulingFailure:
+	default:
+		return false
+	}
+
+	var scaleUpEvent *api.Event
+	for i := range events {
+		ev := &events[i]
+		if ev.Reason != eventReasonTriggeredScaleUp && ev.Reason != eventReasonNotTriggerScaleUp {
+			continue
+		}
+
+		if scaleUpEvent == nil || !eventTime(ev).Before(eventTime(scaleUpEvent)) {
+			scaleUpEvent = ev
+		}
+	}
+
+	if scaleUpEvent != nil {
+		return scaleUpEvent.Reason == eventReasonNotTriggerScaleUp
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type != api.PodScheduled || condition.LastTransitionTime.IsZero() {
+			continue
+		}
+
+		return now.Sub(condition.LastTransitionTime.Time) >= gracePeriod
+	}
+
+	return false
+}
+
+func eventTime(ev *api.Event) time.Time {
+	switch {
+	case !ev.LastTimestamp.IsZero():
+		return ev.LastTimestamp.Time
+	case !ev.EventTime.IsZero():
+		return ev.EventTime.Time
+	}
+
+	return ev.FirstTimestamp.Time
+}
+
+// classifyPodEvent maps a Kubernetes event related to the build pod to
+// the matching job failure reason. An empty reason is returned for events
+// that don't indicate a failure.
+func classifyPodEvent(ev *api.Event) common.JobFailureReason {
+	if ev == nil || ev.Type != k8sEventWarningType {
+		return ""
+	}
+
+	switch {
+	case strings.Contains(ev.Message, quotaExceededMessage):
+		return common.ResourceQuotaExceeded
+	case ev.Reason == eventReasonFailedSchedule:
+		return common.PodSchedulingFailure
+	case ev.Reason == podReasonEvicted:
+		return common.PodEvictedFailure
+	case ev.Reason == eventReasonOOMKilling:
+		return common.OutOfMemoryFailure
+	case ev.Reason == eventReasonFailed && strings.Contains(strings.ToLower(ev.Message), "pull"):
+		return common.ImagePullFailure
+	}
+
+	return ""
+}
+
+// classifyPodCreationError wraps errors returned when creating the build pod,
+// recognizing requests rejected by a ResourceQuota admission.
+func classifyPodCreationError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if kubeerrors.IsForbidden(err) && strings.Contains(err.Error(), quotaExceededMessage) {
+		return &common.BuildError{
+			Inner:         fmt.Errorf("creating build pod: %w", err),
+			FailureReason: common.ResourceQuotaExceeded,
+		}
+	}
+
+	return err
+}
+
+// recordPodFailureEvent keeps the latest event indicating why the build pod
+// failed, so it can be used to classify errors which by themselves carry
+// no information about the failure, like timeouts.
+func (s *executor) recordPodFailureEvent(ev *api.Event) {
+	if classifyPodEvent(ev) == "" {
+		return
+	}
+
+	s.podFailureEventMutex.Lock()
+	defer s.podFailureEventMutex.Unlock()
+
+	s.podFailureEvent = ev
+}
+
+// withPodFailureEvent enriches an unclassified error with the failure reason
+// and message of the latest pod failure event. The events are recorded only
+// while they're printed, so they're listed when none was recorded.
+func (s *executor) withPodFailureEvent(err error) error {
+	var buildErr *common.BuildError
+	if err == nil || errors.As(err, &buildErr) {
+		return err
+	}
+
+	s.podFailureEventMutex.Lock()
+	ev := s.podFailureEvent
+	s.podFailureEventMutex.Unlock()
+
+	if ev == nil {
+		ev = s.listPodFailureEvent()
+	}
+
+	if ev == nil {
+		return err
+	}
+
+	return &common.BuildError{
+		Inner:         fmt.Errorf("%w (%s: %s)", err, ev.Reason, ev.Message),
+		FailureReason: classifyPodEvent(ev),
+	}
+}
+
+// listPodFailureEvent returns the latest event of the build pod indicating
+// why it failed, or nil when there's none or the events can't be listed
+func (s *executor) listPodFailureEvent() *api.Event {
+	if s.kubeClient == nil || s.pod == nil {
+		return nil
+	}
+
+	// TODO: handle the context properly with https://gitlab.com/gitlab-org/gitlab-runner/-/issues/27932
+	events := listPodEvents(context.Background(), s.kubeClient, s.pod)
+
+	var failureEvent *api.Event
+	for i := range events {
+		ev := &events[i]
+		if classifyPodEvent(ev) == "" {
+			continue
+		}
+
+		if failureEvent == nil || !eventTime(ev).Before(eventTime(failureEvent)) {
+			failureEvent = ev
+		}
+	}
+
+	return failureEvent
+}
@@ -0,0 +1,417 @@
+//go:build !integration
+
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	api "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest/fake"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+func newFailureTestPod(status api.PodStatus) *api.Pod {
+	return &api.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "test-ns",
+		},
+		Status: status,
+	}
+}
+
+func requireFailureReason(t *testing.T, expected common.JobFailureReason, err error) {
+	if expected == "" {
+		assert.NoError(t, err)
+		return
+	}
+
+	var buildErr *common.BuildError
+	require.ErrorAs(t, err, &buildErr)
+	assert.Equal(t, expected, buildErr.FailureReason)
+}
+
+func TestClassifyPodFailure(t *testing.T) {
+	tests := map[string]struct {
+		pod            *api.Pod
+		expectedReason common.JobFailureReason
+	}{
+		"nil pod": {},
+		"running pod": {
+			pod: newFailureTestPod(api.PodStatus{Phase: api.PodRunning}),
+		},
+		"evicted pod": {
+			pod: newFailureTestPod(api.PodStatus{
+				Phase:   api.PodFailed,
+				Reason:  "Evicted",
+				Message: "The node was low on resource: memory.",
+			}),
+			expectedReason: common.PodEvictedFailure,
+		},
+		"failed but not evicted pod": {
+			pod: newFailureTestPod(api.PodStatus{Phase: api.PodFailed}),
+		},
+		"OOMKilled container": {
+			pod: newFailureTestPod(api.PodStatus{
+				Phase: api.PodRunning,
+				ContainerStatuses: []api.ContainerStatus{
+					{Name: "helper", State: api.ContainerState{Running: &api.ContainerStateRunning{}}},
+					{Name: "build", State: api.ContainerState{
+						Terminated: &api.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+					}},
+				},
+			}),
+			expectedReason: common.OutOfMemoryFailure,
+		},
+		"OOMKilled init container": {
+			pod: newFailureTestPod(api.PodStatus{
+				Phase: api.PodPending,
+				InitContainerStatuses: []api.ContainerStatus{
+					{Name: "init-permissions", State: api.ContainerState{
+						Terminated: &api.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+					}},
+				},
+			}),
+			expectedReason: common.OutOfMemoryFailure,
+		},
+		"container terminated with an error": {
+			pod: newFailureTestPod(api.PodStatus{
+				Phase: api.PodRunning,
+				ContainerStatuses: []api.ContainerStatus{
+					{Name: "build", State: api.ContainerState{
+						Terminated: &api.ContainerStateTerminated{Reason: "Error", ExitCode: 1},
+					}},
+				},
+			}),
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			requireFailureReason(t, tt.expectedReason, classifyPodFailure(tt.pod))
+		})
+	}
+}
+
+func TestClassifyPodPending(t *testing.T) {
+	unschedulable := func(message string) *api.Pod {
+		return newFailureTestPod(api.PodStatus{
+			Phase: api.PodPending,
+			Conditions: []api.PodCondition{
+				{
+					Type:    api.PodScheduled,
+					Status:  api.ConditionFalse,
+					Reason:  api.PodReasonUnschedulable,
+					Message: message,
+				},
+			},
+		})
+	}
+
+	tests := map[string]struct {
+		pod            *api.Pod
+		expectedReason common.JobFailureReason
+	}{
+		"nil pod": {},
+		"pending pod without conditions": {
+			pod: newFailureTestPod(api.PodStatus{Phase: api.PodPending}),
+		},
+		"scheduled pod": {
+			pod: newFailureTestPod(api.PodStatus{
+				Phase: api.PodPending,
+				Conditions: []api.PodCondition{
+					{Type: api.PodScheduled, Status: api.ConditionTrue},
+				},
+			}),
+		},
+		"unschedulable pod": {
+			pod:            unschedulable("0/3 nodes are available: 3 Insufficient cpu."),
+			expectedReason: common.PodSchedulingFailure,
+		},
+		"unschedulable pod because of quota": {
+			pod:            unschedulable(`pods "test-pod" is forbidden: exceeded quota: compute-resources`),
+			expectedReason: common.ResourceQuotaExceeded,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			requireFailureReason(t, tt.expectedReason, classifyPodPending(tt.pod))
+		})
+	}
+}
+
+func TestIsPodPendingTerminal(t *testing.T) {
+	now := time.Now()
+
+	unschedulableSince := func(since time.Duration) *api.Pod {
+		return newFailureTestPod(api.PodStatus{
+			Phase: api.PodPending,
+			Conditions: []api.PodCondition{
+				{
+					Type:               api.PodScheduled,
+					Status:             api.ConditionFalse,
+					Reason:             api.PodReasonUnschedulable,
+					Message:            "0/3 nodes are available: 3 Insufficient cpu.",
+					LastTransitionTime: metav1.NewTime(now.Add(-since)),
+				},
+			},
+		})
+	}
+
+	scaleUpEvent := func(reason string, ago time.Duration) api.Event {
+		return api.Event{Reason: reason, LastTimestamp: metav1.NewTime(now.Add(-ago))}
+	}
+
+	tests := map[string]struct {
+		pod              *api.Pod
+		events           []api.Event
+		gracePeriod      time.Duration
+		expectedTerminal bool
+	}{
+		"pending pod": {
+			pod: newFailureTestPod(api.PodStatus{Phase: api.PodPending}),
+		},
+		"quota exceeded": {
+			pod: newFailureTestPod(api.PodStatus{
+				Phase: api.PodPending,
+				Conditions: []api.PodCondition{
+					{
+						Type:    api.PodScheduled,
+						Status:  api.ConditionFalse,
+						Reason:  api.PodReasonUnschedulable,
+						Message: `pods "test-pod" is forbidden: exceeded quota: compute-resources`,
+					},
+				},
+			}),
+			expectedTerminal: true,
+		},
+		"unschedulable within the grace period": {
+			pod:         unschedulableSince(time.Second),
+			gracePeriod: 30 * time.Second,
+		},
+		"unschedulable past the grace period": {
+			pod:              unschedulableSince(time.Minute),
+			gracePeriod:      30 * time.Second,
+			expectedTerminal: true,
+		},
+		"unschedulable within the default grace period": {
+			pod:         unschedulableSince(time.Minute),
+			gracePeriod: (&common.KubernetesConfig{}).GetUnschedulablePodGracePeriod(),
+		},
+		"unschedulable with scale up triggered": {
+			pod:    unschedulableSince(time.Minute),
+			events: []api.Event{scaleUpEvent(eventReasonTriggeredScaleUp, time.Second)},
+		},
+		"unschedulable with scale up not triggered": {
+			pod:              unschedulableSince(time.Second),
+			events:           []api.Event{scaleUpEvent(eventReasonNotTriggerScaleUp, time.Second)},
+			expectedTerminal: true,
+		},
+		"unschedulable with scale up triggered after not being triggered": {
+			pod: unschedulableSince(time.Minute),
+			events: []api.Event{
+				scaleUpEvent(eventReasonTriggeredScaleUp, time.Second),
+				scaleUpEvent(eventReasonNotTriggerScaleUp, 10*time.Second),
+			},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			pendingErr := classifyPodPending(tt.pod)
+			assert.Equal(t, tt.expectedTerminal, isPodPendingTerminal(tt.pod, pendingErr, tt.events, tt.gracePeriod, now))
+		})
+	}
+}
+
+func TestClassifyPodEvent(t *testing.T) {
+	tests := map[string]struct {
+		event          *api.Event
+		expectedReason common.JobFailureReason
+	}{
+		"nil event": {},
+		"normal event": {
+			event: &api.Event{Type: "Normal", Reason: "Scheduled"},
+		},
+		"failed scheduling": {
+			event:          &api.Event{Type: k8sEventWarningType, Reason: "FailedScheduling"},
+			expectedReason: common.PodSchedulingFailure,
+		},
+		"quota exceeded": {
+			event: &api.Event{
+				Type:    k8sEventWarningType,
+				Reason:  "FailedScheduling",
+				Message: "exceeded quota: compute-resources",
+			},
+			expectedReason: common.ResourceQuotaExceeded,
+		},
+		"evicted": {
+			event:          &api.Event{Type: k8sEventWarningType, Reason: "Evicted"},
+			expectedReason: common.PodEvictedFailure,
+		},
+		"OOM killing": {
+			event:          &api.Event{Type: k8sEventWarningType, Reason: "OOMKilling"},
+			expectedReason: common.OutOfMemoryFailure,
+		},
+		"image pull failed": {
+			event: &api.Event{
+				Type:    k8sEventWarningType,
+				Reason:  "Failed",
+				Message: `Failed to pull image "alpine:unknown": not found`,
+			},
+			expectedReason: common.ImagePullFailure,
+		},
+		"unrelated warning": {
+			event: &api.Event{Type: k8sEventWarningType, Reason: "BackOff"},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tt.expectedReason, classifyPodEvent(tt.event))
+		})
+	}
+}
+
+func TestClassifyPodCreationError(t *testing.T) {
+	podsResource := schema.GroupResource{Resource: "pods"}
+
+	tests := map[string]struct {
+		err            error
+		expectedReason common.JobFailureReason
+		expectedSame   bool
+	}{
+		"no error": {},
+		"generic error": {
+			err:          errors.New("generic error"),
+			expectedSame: true,
+		},
+		"forbidden without quota": {
+			err:          kubeerrors.NewForbidden(podsResource, "test-pod", errors.New("denied")),
+			expectedSame: true,
+		},
+		"quota exceeded": {
+			err: kubeerrors.NewForbidden(
+				podsResource,
+				"test-pod",
+				errors.New("exceeded quota: compute-resources, requested: cpu=2, used: cpu=7, limited: cpu=8"),
+			),
+			expectedReason: common.ResourceQuotaExceeded,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			err := classifyPodCreationError(tt.err)
+			if tt.expectedSame {
+				assert.Equal(t, tt.err, err)
+				return
+			}
+
+			requireFailureReason(t, tt.expectedReason, err)
+		})
+	}
+}
+
+func TestExecutor_WithPodFailureEvent(t *testing.T) {
+	timeoutErr := errors.New("timed out waiting for pod to start")
+
+	tests := map[string]struct {
+		events         []*api.Event
+		err            error
+		expectedReason common.JobFailureReason
+		expectedSame   bool
+	}{
+		"no error": {
+			events: []*api.Event{{Type: k8sEventWarningType, Reason: "FailedScheduling"}},
+		},
+		"no recorded events": {
+			err:          timeoutErr,
+			expectedSame: true,
+		},
+		"only non-failure events": {
+			events:       []*api.Event{{Type: "Normal", Reason: "Scheduled"}},
+			err:          timeoutErr,
+			expectedSame: true,
+		},
+		"already classified error": {
+			events:       []*api.Event{{Type: k8sEventWarningType, Reason: "FailedScheduling"}},
+			err:          fmt.Errorf("wrapped: %w", &common.BuildError{FailureReason: common.ImagePullFailure}),
+			expectedSame: true,
+		},
+		"latest failure event is used": {
+			events: []*api.Event{
+				{Type: k8sEventWarningType, Reason: "FailedScheduling"},
+				{Type: k8sEventWarningType, Reason: "Evicted"},
+				{Type: "Normal", Reason: "Killing"},
+			},
+			err:            timeoutErr,
+			expectedReason: common.PodEvictedFailure,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			e := &executor{}
+			for _, ev := range tt.events {
+				e.recordPodFailureEvent(ev)
+			}
+
+			err := e.withPodFailureEvent(tt.err)
+			if tt.expectedSame {
+				assert.Equal(t, tt.err, err)
+				return
+			}
+
+			requireFailureReason(t, tt.expectedReason, err)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestExecutor_WithPodFailureEventListsEvents(t *testing.T) {
+	version, _ := testVersionAndCodec()
+	now := time.Now()
+
+	events, err := json.Marshal(&api.EventList{
+		TypeMeta: metav1.TypeMeta{Kind: "EventList", APIVersion: "v1"},
+		Items: []api.Event{
+			{Type: k8sEventWarningType, Reason: "Evicted", LastTimestamp: metav1.NewTime(now.Add(-time.Minute))},
+			{Type: k8sEventWarningType, Reason: "FailedScheduling", LastTimestamp: metav1.NewTime(now)},
+			{Type: "Normal", Reason: "Scheduled", LastTimestamp: metav1.NewTime(now)},
+		},
+	})
+	require.NoError(t, err)
+
+	e := &executor{
+		pod: newFailureTestPod(api.PodStatus{}),
+		kubeClient: testKubernetesClient(version, fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, "/api/v1/namespaces/test-ns/events", req.URL.Path)
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader(events)),
+				Header:     map[string][]string{common.ContentType: {"application/json"}},
+			}, nil
+		})),
+	}
+
+	err = e.withPodFailureEvent(errors.New("timed out waiting for pod to start"))
+	requireFailureReason(t, common.PodSchedulingFailure, err)
+}
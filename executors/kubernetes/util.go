@@ -140,17 +140,31 @@ type podPhaseResponse struct {
 	done  bool
 	phase api.PodPhase
 	err   error
+
+	// pendingErr describes why the pod is not yet running. It's reported
+	// only if the pod doesn't reach the running state in time.
+	pendingErr error
 }
 
-func getPodPhase(ctx context.Context, c *kubernetes.Clientset, pod *api.Pod, out io.Writer) podPhaseResponse {
+func getPodPhase(
+	ctx context.Context,
+	c *kubernetes.Clientset,
+	pod *api.Pod,
+	out io.Writer,
+	config *common.KubernetesConfig,
+) podPhaseResponse {
 	pod, err := c.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
 	if err != nil {
-		return podPhaseResponse{true, api.PodUnknown, err}
+		return podPhaseResponse{done: true, phase: api.PodUnknown, err: err}
+	}
+
+	if err := classifyPodFailure(pod); err != nil {
+		return podPhaseResponse{done: true, phase: pod.Status.Phase, err: err}
 	}
 
 	ready, err := isRunning(pod)
 	if err != nil || ready {
-		return podPhaseResponse{true, pod.Status.Phase, err}
+		return podPhaseResponse{done: true, phase: pod.Status.Phase, err: err}
 	}
 
 	// check status of containers
@@ -166,14 +180,14 @@ func getPodPhase(ctx context.Context, c *kubernetes.Clientset, pod *api.Pod, out
 		switch waiting.Reason {
 		case "InvalidImageName":
 			err = &common.BuildError{Inner: fmt.Errorf("image pull failed: %s", waiting.Message)}
-			return podPhaseResponse{true, api.PodUnknown, err}
+			return podPhaseResponse{done: true, phase: api.PodUnknown, err: err}
 		case "ErrImagePull", "ImagePullBackOff":
 			msg := fmt.Sprintf("image pull failed: %s", waiting.Message)
 			imagePullErr := &pull.ImagePullError{Message: msg, Image: container.Image}
 			return podPhaseResponse{
-				true,
-				api.PodUnknown,
-				&common.BuildError{Inner: imagePullErr, FailureReason: common.ImagePullFailure},
+				done:  true,
+				phase: api.PodUnknown,
+				err:   &common.BuildError{Inner: imagePullErr, FailureReason: common.ImagePullFailure},
 			}
 		}
 	}
@@ -200,14 +214,43 @@ func getPodPhase(ctx context.Context, c *kubernetes.Clientset, pod *api.Pod, out
 		)
 	}
 
-	return podPhaseResponse{false, pod.Status.Phase, nil}
+	pendingErr := classifyPodPending(pod)
+	if pendingErr == nil {
+		return podPhaseResponse{done: false, phase: pod.Status.Phase}
+	}
+
+	gracePeriod := config.GetUnschedulablePodGracePeriod()
+	if isPodPendingTerminal(pod, pendingErr, listPodEvents(ctx, c, pod), gracePeriod, time.Now()) {
+		return podPhaseResponse{done: true, phase: pod.Status.Phase, err: pendingErr}
+	}
+
+	return podPhaseResponse{done: false, phase: pod.Status.Phase, pendingErr: pendingErr}
+}
+
+// listPodEvents returns the events of the pod, or none when they can't be
+// listed, as they're only used to stop waiting for the pod earlier
+func listPodEvents(ctx context.Context, c *kubernetes.Clientset, pod *api.Pod) []api.Event {
+	events, err := c.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.name=%s", pod.Name),
+	})
+	if err != nil {
+		return nil
+	}
+
+	return events.Items
 }
 
-func triggerPodPhaseCheck(ctx context.Context, c *kubernetes.Clientset, pod *api.Pod, out io.Writer) <-chan podPhaseResponse {
+func triggerPodPhaseCheck(
+	ctx context.Context,
+	c *kubernetes.Clientset,
+	pod *api.Pod,
+	out io.Writer,
+	config *common.KubernetesConfig,
+) <-chan podPhaseResponse {
 	errc := make(chan podPhaseResponse)
 	go func() {
 		defer close(errc)
-		errc <- getPodPhase(ctx, c, pod, out)
+		errc <- getPodPhase(ctx, c, pod, out, config)
 	}()
 	return errc
 }
@@ -216,8 +259,10 @@ func triggerPodPhaseCheck(ctx context.Context, c *kubernetes.Clientset, pod *api
 // state. It returns the final PodPhase once either PodRunning, PodSucceeded or
 // PodFailed has been reached. In the case of PodRunning, it will also wait until
 // all containers within the pod are also Ready.
-// It returns error if the call to retrieve pod details fails or the timeout is
-// reached.
+// It returns error if the call to retrieve pod details fails, the pod hits
+// a terminal condition (eviction, OOM kill, image pull failure, exceeded quota,
+// unschedulable pod no autoscaler scales up for) or the timeout is reached.
+// On timeout, the reason the pod couldn't be started is included when known.
 // The timeout and polling values are configurable through KubernetesConfig
 // parameters.
 func waitForPodRunning(
@@ -227,12 +272,15 @@ func waitForPodRunning(
 	out io.Writer,
 	config *common.KubernetesConfig,
 ) (api.PodPhase, error) {
+	var pendingErr error
+
 	pollInterval := config.GetPollInterval()
 	pollAttempts := config.GetPollAttempts()
 	for i := 0; i <= pollAttempts; i++ {
 		select {
-		case r := <-triggerPodPhaseCheck(ctx, c, pod, out):
+		case r := <-triggerPodPhaseCheck(ctx, c, pod, out, config):
 			if !r.done {
+				pendingErr = r.pendingErr
 				time.Sleep(time.Duration(pollInterval) * time.Second)
 				continue
 			}
@@ -241,6 +289,11 @@ func waitForPodRunning(
 			return api.PodUnknown, ctx.Err()
 		}
 	}
+
+	if pendingErr != nil {
+		return api.PodUnknown, fmt.Errorf("timed out waiting for pod to start: %w", pendingErr)
+	}
+
 	return api.PodUnknown, errors.New("timed out waiting for pod to start")
 }
 
@@ -173,14 +173,15 @@ func TestWaitForPodRunning(t *testing.T) {
 	retries := 0
 
 	tests := []struct {
-		Name         string
-		Pod          *api.Pod
-		Config       *common.KubernetesConfig
-		ClientFunc   func(*http.Request) (*http.Response, error)
-		PodEndPhase  api.PodPhase
-		Retries      int
-		Error        bool
-		ExactRetries bool
+		Name          string
+		Pod           *api.Pod
+		Config        *common.KubernetesConfig
+		ClientFunc    func(*http.Request) (*http.Response, error)
+		PodEndPhase   api.PodPhase
+		Retries       int
+		Error         bool
+		ExactRetries  bool
+		FailureReason common.JobFailureReason
 	}{
 		{
 			Name: "ensure function retries until ready",
@@ -328,6 +329,129 @@ func TestWaitForPodRunning(t *testing.T) {
 			Error:        true,
 			ExactRetries: true,
 		},
+		{
+			Name: "ensure function fails fast when pod is evicted",
+			Pod: &api.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "test-ns",
+				},
+			},
+			Config: &common.KubernetesConfig{},
+			ClientFunc: func(req *http.Request) (*http.Response, error) {
+				pod := &api.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-pod",
+						Namespace: "test-ns",
+					},
+					Status: api.PodStatus{
+						Phase:  api.PodFailed,
+						Reason: "Evicted",
+					},
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       objBody(codec, pod),
+					Header:     map[string][]string{common.ContentType: {"application/json"}},
+				}, nil
+			},
+			PodEndPhase:   api.PodFailed,
+			Error:         true,
+			FailureReason: common.PodEvictedFailure,
+		},
+		{
+			Name: "ensure unschedulable pod is reported on timeout",
+			Pod: &api.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "test-ns",
+				},
+			},
+			Config: &common.KubernetesConfig{
+				PollInterval: 1,
+				PollTimeout:  1,
+			},
+			ClientFunc: func(req *http.Request) (*http.Response, error) {
+				pod := &api.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-pod",
+						Namespace: "test-ns",
+					},
+					Status: api.PodStatus{
+						Phase: api.PodPending,
+						Conditions: []api.PodCondition{
+							{
+								Type:    api.PodScheduled,
+								Status:  api.ConditionFalse,
+								Reason:  api.PodReasonUnschedulable,
+								Message: "0/3 nodes are available: 3 Insufficient memory.",
+							},
+						},
+					},
+				}
+				retries++
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       objBody(codec, pod),
+					Header:     map[string][]string{common.ContentType: {"application/json"}},
+				}, nil
+			},
+			PodEndPhase:   api.PodUnknown,
+			Error:         true,
+			FailureReason: common.PodSchedulingFailure,
+		},
+		{
+			Name: "ensure function fails fast when pod exceeds quota",
+			Pod: &api.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "test-ns",
+				},
+			},
+			Config: &common.KubernetesConfig{
+				PollInterval: 1,
+				PollTimeout:  60,
+			},
+			ClientFunc: func(req *http.Request) (*http.Response, error) {
+				if strings.HasSuffix(req.URL.Path, "/events") {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(strings.NewReader(`{"kind": "EventList", "apiVersion": "v1", "items": []}`)),
+						Header:     map[string][]string{common.ContentType: {"application/json"}},
+					}, nil
+				}
+
+				pod := &api.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-pod",
+						Namespace: "test-ns",
+					},
+					Status: api.PodStatus{
+						Phase: api.PodPending,
+						Conditions: []api.PodCondition{
+							{
+								Type:    api.PodScheduled,
+								Status:  api.ConditionFalse,
+								Reason:  api.PodReasonUnschedulable,
+								Message: `pods "test-pod" is forbidden: exceeded quota: compute-resources`,
+							},
+						},
+					},
+				}
+				retries++
+				if retries > 1 {
+					t.Errorf("Expected no retry for a pod exceeding its quota")
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       objBody(codec, pod),
+					Header:     map[string][]string{common.ContentType: {"application/json"}},
+				}, nil
+			},
+			PodEndPhase:   api.PodPending,
+			Error:         true,
+			FailureReason: common.ResourceQuotaExceeded,
+		},
 	}
 
 	for _, test := range tests {
@@ -361,6 +485,12 @@ func TestWaitForPodRunning(t *testing.T) {
 				t.Errorf("[%s] Not enough retries. Expected: %d, got: %d", test.Name, test.Retries, retries)
 				return
 			}
+
+			if test.FailureReason != "" {
+				var buildErr *common.BuildError
+				require.ErrorAs(t, err, &buildErr)
+				assert.Equal(t, test.FailureReason, buildErr.FailureReason)
+			}
 		})
 	}
 }
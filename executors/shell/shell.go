@@ -54,6 +54,8 @@ func (s *executor) Prepare(options common.ExecutorPrepareOptions) error {
 		return err
 	}
 
+	s.Build.GitMirrorDir = s.RefreshGitMirror()
+
 	s.Println("Using Shell (" + s.Shell().Shell + ") executor...")
 	return nil
 }
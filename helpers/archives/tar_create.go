@@ -0,0 +1,87 @@
+package archives
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	gzip "github.com/klauspost/pgzip"
+	"github.com/sirupsen/logrus"
+)
+
+func createTarEntry(archive *tar.Writer, dir string, fileName string, fi fs.FileInfo) error {
+	link := ""
+	if fi.Mode()&fs.ModeSymlink != 0 {
+		var err error
+		link, err = os.Readlink(fileName)
+		if err != nil {
+			return err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return err
+	}
+
+	name, err := filepath.Rel(dir, fileName)
+	if err != nil {
+		return err
+	}
+
+	header.Name = filepath.ToSlash(name)
+	if fi.IsDir() {
+		header.Name += "/"
+	}
+
+	err = archive.WriteHeader(header)
+	if err != nil || !fi.Mode().IsRegular() {
+		return err
+	}
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	_, err = io.Copy(archive, file)
+	return err
+}
+
+// CreateTarGzArchive writes the content of the directory to w as a gzip
+// compressed tar archive, with the paths relative to the directory. The
+// files which aren't regular files, directories or symlinks are ignored.
+func CreateTarGzArchive(w io.Writer, dir string) error {
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+
+	err := filepath.Walk(dir, func(fileName string, fi fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if fileName == dir {
+			return nil
+		}
+
+		mode := fi.Mode()
+		if !mode.IsRegular() && !mode.IsDir() && mode&fs.ModeSymlink == 0 {
+			logrus.Warningln("File ignored:", fileName, "isn't a regular file, a directory or a symlink")
+			return nil
+		}
+
+		return createTarEntry(archive, dir, fileName, fi)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
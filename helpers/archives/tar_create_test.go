@@ -0,0 +1,64 @@
+//go:build !integration
+
+package archives
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	gzip "github.com/klauspost/pgzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateTarGzArchive(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "src", "pkg"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src", "pkg", "main.go"), []byte("package main"), 0o644))
+
+	expected := map[string]string{
+		"README.md":       "readme",
+		"src/":            "",
+		"src/pkg/":        "",
+		"src/pkg/main.go": "package main",
+	}
+
+	if runtime.GOOS != "windows" {
+		require.NoError(t, os.Symlink("README.md", filepath.Join(dir, "link")))
+		expected["link"] = "-> README.md"
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, CreateTarGzArchive(buf, dir))
+
+	gz, err := gzip.NewReader(buf)
+	require.NoError(t, err)
+
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		if header.Typeflag == tar.TypeSymlink {
+			files[header.Name] = "-> " + header.Linkname
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+
+	assert.Equal(t, expected, files)
+}
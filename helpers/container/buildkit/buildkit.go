@@ -0,0 +1,104 @@
+// Package buildkit holds the executor independent parts of the rootless
+// BuildKit sidecar, which lets jobs build images without a privileged
+// Docker-in-Docker service.
+package buildkit
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+const (
+	DefaultImage = "moby/buildkit:rootless"
+
+	// RuntimeDir is the runtime directory of the daemon in the rootless image,
+	// shared with the build container to expose the daemon's socket
+	RuntimeDir = "/run/user/1000"
+	// SocketDir is where the build container finds the daemon's socket
+	SocketDir  = "/run/buildkit"
+	socketName = "buildkitd.sock"
+
+	// StateDir is where the rootless image keeps its layer cache
+	StateDir = "/home/user/.local/share/buildkit"
+	// UserID is the user running the daemon in the rootless image
+	UserID = 1000
+
+	// EnableVariable is the job variable requesting the sidecar
+	EnableVariable = "BUILDKIT_SIDECAR"
+	// ImageVariable is the job variable selecting another image for the sidecar
+	ImageVariable = "BUILDKIT_SIDECAR_IMAGE"
+	// HostVariable is the variable telling buildctl and docker buildx where the
+	// daemon listens
+	HostVariable = "BUILDKIT_HOST"
+)
+
+var ErrNotEnabled = errors.New("the BuildKit sidecar isn't enabled for this runner")
+
+// Sidecar describes the BuildKit daemon to start for a job
+type Sidecar struct {
+	Image string
+}
+
+// Args returns the arguments of the rootless daemon. The process sandbox is
+// disabled, as it requires the daemon to create PID namespaces. The daemon
+// doesn't authenticate its clients, so it only listens on a unix socket in
+// the volume shared with the build container, never on the network.
+func (s *Sidecar) Args() []string {
+	return []string{
+		"--oci-worker-no-process-sandbox",
+		"--addr", "unix://" + path.Join(RuntimeDir, socketName),
+	}
+}
+
+// Host returns the address of the daemon in the build container
+func Host() string {
+	return "unix://" + path.Join(SocketDir, socketName)
+}
+
+// Resolve returns the sidecar requested by the job, or nil when the job
+// doesn't request one. The image selected by the job must be in the configured
+// allowed images, the configured image being always allowed.
+func Resolve(
+	variables common.JobVariables,
+	config *common.BuildKitConfig,
+	logger common.BuildLogger,
+) (*Sidecar, error) {
+	requested, _ := strconv.ParseBool(variables.Value(EnableVariable))
+	if !requested {
+		return nil, nil
+	}
+
+	if !config.IsEnabled() {
+		return nil, ErrNotEnabled
+	}
+
+	image := config.Image
+	if image == "" {
+		image = DefaultImage
+	}
+
+	if requestedImage := variables.Value(ImageVariable); requestedImage != "" && requestedImage != image {
+		// unlike other images, only the configured one can be used when
+		// there's no allowlist, as the sidecar runs unconfined
+		if len(config.AllowedImages) == 0 {
+			return nil, fmt.Errorf("%w: %s (no BuildKit allowed_images configured)", common.ErrDisallowedImage, requestedImage)
+		}
+
+		err := common.VerifyAllowedImage(common.VerifyAllowedImageOptions{
+			Image:         requestedImage,
+			OptionName:    "BuildKit images",
+			AllowedImages: config.AllowedImages,
+		}, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		image = requestedImage
+	}
+
+	return &Sidecar{Image: image}, nil
+}
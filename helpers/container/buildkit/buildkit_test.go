@@ -0,0 +1,108 @@
+//go:build !integration
+
+package buildkit
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+func TestResolve(t *testing.T) {
+	requested := common.JobVariables{{Key: EnableVariable, Value: "true"}}
+	withImage := func(image string) common.JobVariables {
+		return append(requested, common.JobVariable{Key: ImageVariable, Value: image})
+	}
+
+	tests := map[string]struct {
+		variables       common.JobVariables
+		config          *common.BuildKitConfig
+		expectedSidecar *Sidecar
+		expectedErr     error
+	}{
+		"not requested": {
+			config: &common.BuildKitConfig{Enabled: true},
+		},
+		"explicitly not requested": {
+			variables: common.JobVariables{{Key: EnableVariable, Value: "false"}},
+			config:    &common.BuildKitConfig{Enabled: true},
+		},
+		"requested without config": {
+			variables:   requested,
+			expectedErr: ErrNotEnabled,
+		},
+		"requested but disabled": {
+			variables:   requested,
+			config:      &common.BuildKitConfig{},
+			expectedErr: ErrNotEnabled,
+		},
+		"default image": {
+			variables:       requested,
+			config:          &common.BuildKitConfig{Enabled: true},
+			expectedSidecar: &Sidecar{Image: DefaultImage},
+		},
+		"configured image": {
+			variables:       requested,
+			config:          &common.BuildKitConfig{Enabled: true, Image: "registry.example.com/buildkit:rootless"},
+			expectedSidecar: &Sidecar{Image: "registry.example.com/buildkit:rootless"},
+		},
+		"job image without allowlist": {
+			variables:   withImage("moby/buildkit:latest"),
+			config:      &common.BuildKitConfig{Enabled: true},
+			expectedErr: common.ErrDisallowedImage,
+		},
+		"job image matching the configured one": {
+			variables:       withImage(DefaultImage),
+			config:          &common.BuildKitConfig{Enabled: true},
+			expectedSidecar: &Sidecar{Image: DefaultImage},
+		},
+		"allowed job image": {
+			variables: withImage("moby/buildkit:v0.12.5-rootless"),
+			config: &common.BuildKitConfig{
+				Enabled:       true,
+				AllowedImages: []string{"moby/buildkit:*-rootless"},
+			},
+			expectedSidecar: &Sidecar{Image: "moby/buildkit:v0.12.5-rootless"},
+		},
+		"disallowed job image": {
+			variables: withImage("moby/buildkit:latest"),
+			config: &common.BuildKitConfig{
+				Enabled:       true,
+				AllowedImages: []string{"moby/buildkit:*-rootless"},
+			},
+			expectedErr: common.ErrDisallowedImage,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			logger := common.NewBuildLogger(nil, logrus.WithField("test", tn))
+
+			sidecar, err := Resolve(tt.variables, tt.config, logger)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSidecar, sidecar)
+		})
+	}
+}
+
+func TestHost(t *testing.T) {
+	assert.Equal(t, "unix:///run/buildkit/buildkitd.sock", Host())
+}
+
+func TestSidecarArgs(t *testing.T) {
+	args := (&Sidecar{Image: DefaultImage}).Args()
+
+	assert.Contains(t, args, "unix:///run/user/1000/buildkitd.sock")
+	for _, arg := range args {
+		assert.NotContains(t, arg, "tcp://")
+	}
+}
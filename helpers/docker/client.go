@@ -24,6 +24,7 @@ type Client interface {
 		ref string,
 		options types.ImageImportOptions,
 	) error
+	ImageRemove(ctx context.Context, imageID string, options types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error)
 
 	ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
 	ContainerCreate(
@@ -51,6 +52,17 @@ type Client interface {
 	ContainerLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error)
 	ContainerExecCreate(ctx context.Context, container string, config types.ExecConfig) (types.IDResponse, error)
 	ContainerExecAttach(ctx context.Context, execID string, config types.ExecStartCheck) (types.HijackedResponse, error)
+	ContainerDiff(ctx context.Context, containerID string) ([]container.FilesystemChange, error)
+	ContainerExport(ctx context.Context, containerID string) (io.ReadCloser, error)
+	ContainerCommit(ctx context.Context, containerID string, options types.ContainerCommitOptions) (types.IDResponse, error)
+
+	CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, types.ContainerPathStat, error)
+	CopyToContainer(
+		ctx context.Context,
+		containerID, dstPath string,
+		content io.Reader,
+		options types.CopyToContainerOptions,
+	) error
 
 	NetworkCreate(
 		ctx context.Context,
@@ -58,6 +70,7 @@ type Client interface {
 		options types.NetworkCreate,
 	) (types.NetworkCreateResponse, error)
 	NetworkRemove(ctx context.Context, networkID string) error
+	NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error
 	NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error
 	NetworkList(ctx context.Context, options types.NetworkListOptions) ([]types.NetworkResource, error)
 	NetworkInspect(ctx context.Context, networkID string) (types.NetworkResource, error)
@@ -66,7 +79,10 @@ type Client interface {
 	VolumeRemove(ctx context.Context, volumeID string, force bool) error
 	VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error)
 
+	DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error)
+
 	Info(ctx context.Context) (types.Info, error)
+	ServerVersion(ctx context.Context) (types.Version, error)
 
 	Close() error
 }
@@ -77,6 +77,30 @@ func (_m *MockClient) ContainerAttach(ctx context.Context, _a1 string, options t
 	return r0, r1
 }
 
+// ContainerCommit provides a mock function with given fields: ctx, containerID, options
+func (_m *MockClient) ContainerCommit(ctx context.Context, containerID string, options types.ContainerCommitOptions) (types.IDResponse, error) {
+	ret := _m.Called(ctx, containerID, options)
+
+	var r0 types.IDResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, types.ContainerCommitOptions) (types.IDResponse, error)); ok {
+		return rf(ctx, containerID, options)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, types.ContainerCommitOptions) types.IDResponse); ok {
+		r0 = rf(ctx, containerID, options)
+	} else {
+		r0 = ret.Get(0).(types.IDResponse)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, types.ContainerCommitOptions) error); ok {
+		r1 = rf(ctx, containerID, options)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ContainerCreate provides a mock function with given fields: ctx, config, hostConfig, networkingConfig, platform, containerName
 func (_m *MockClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *v1.Platform, containerName string) (container.CreateResponse, error) {
 	ret := _m.Called(ctx, config, hostConfig, networkingConfig, platform, containerName)
@@ -101,6 +125,32 @@ func (_m *MockClient) ContainerCreate(ctx context.Context, config *container.Con
 	return r0, r1
 }
 
+// ContainerDiff provides a mock function with given fields: ctx, containerID
+func (_m *MockClient) ContainerDiff(ctx context.Context, containerID string) ([]container.FilesystemChange, error) {
+	ret := _m.Called(ctx, containerID)
+
+	var r0 []container.FilesystemChange
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]container.FilesystemChange, error)); ok {
+		return rf(ctx, containerID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []container.FilesystemChange); ok {
+		r0 = rf(ctx, containerID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]container.FilesystemChange)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, containerID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ContainerExecAttach provides a mock function with given fields: ctx, execID, config
 func (_m *MockClient) ContainerExecAttach(ctx context.Context, execID string, config types.ExecStartCheck) (types.HijackedResponse, error) {
 	ret := _m.Called(ctx, execID, config)
@@ -149,6 +199,32 @@ func (_m *MockClient) ContainerExecCreate(ctx context.Context, _a1 string, confi
 	return r0, r1
 }
 
+// ContainerExport provides a mock function with given fields: ctx, containerID
+func (_m *MockClient) ContainerExport(ctx context.Context, containerID string) (io.ReadCloser, error) {
+	ret := _m.Called(ctx, containerID)
+
+	var r0 io.ReadCloser
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (io.ReadCloser, error)); ok {
+		return rf(ctx, containerID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) io.ReadCloser); ok {
+		r0 = rf(ctx, containerID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, containerID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ContainerInspect provides a mock function with given fields: ctx, containerID
 func (_m *MockClient) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
 	ret := _m.Called(ctx, containerID)
@@ -309,6 +385,77 @@ func (_m *MockClient) ContainerWait(ctx context.Context, containerID string, con
 	return r0, r1
 }
 
+// CopyFromContainer provides a mock function with given fields: ctx, containerID, srcPath
+func (_m *MockClient) CopyFromContainer(ctx context.Context, containerID string, srcPath string) (io.ReadCloser, types.ContainerPathStat, error) {
+	ret := _m.Called(ctx, containerID, srcPath)
+
+	var r0 io.ReadCloser
+	var r1 types.ContainerPathStat
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (io.ReadCloser, types.ContainerPathStat, error)); ok {
+		return rf(ctx, containerID, srcPath)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) io.ReadCloser); ok {
+		r0 = rf(ctx, containerID, srcPath)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) types.ContainerPathStat); ok {
+		r1 = rf(ctx, containerID, srcPath)
+	} else {
+		r1 = ret.Get(1).(types.ContainerPathStat)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, string) error); ok {
+		r2 = rf(ctx, containerID, srcPath)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// CopyToContainer provides a mock function with given fields: ctx, containerID, dstPath, content, options
+func (_m *MockClient) CopyToContainer(ctx context.Context, containerID string, dstPath string, content io.Reader, options types.CopyToContainerOptions) error {
+	ret := _m.Called(ctx, containerID, dstPath, content, options)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, io.Reader, types.CopyToContainerOptions) error); ok {
+		r0 = rf(ctx, containerID, dstPath, content, options)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DiskUsage provides a mock function with given fields: ctx, options
+func (_m *MockClient) DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error) {
+	ret := _m.Called(ctx, options)
+
+	var r0 types.DiskUsage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, types.DiskUsageOptions) (types.DiskUsage, error)); ok {
+		return rf(ctx, options)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, types.DiskUsageOptions) types.DiskUsage); ok {
+		r0 = rf(ctx, options)
+	} else {
+		r0 = ret.Get(0).(types.DiskUsage)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, types.DiskUsageOptions) error); ok {
+		r1 = rf(ctx, options)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ImageImportBlocking provides a mock function with given fields: ctx, source, ref, options
 func (_m *MockClient) ImageImportBlocking(ctx context.Context, source types.ImageImportSource, ref string, options types.ImageImportOptions) error {
 	ret := _m.Called(ctx, source, ref, options)
@@ -370,6 +517,32 @@ func (_m *MockClient) ImagePullBlocking(ctx context.Context, ref string, options
 	return r0
 }
 
+// ImageRemove provides a mock function with given fields: ctx, imageID, options
+func (_m *MockClient) ImageRemove(ctx context.Context, imageID string, options types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error) {
+	ret := _m.Called(ctx, imageID, options)
+
+	var r0 []types.ImageDeleteResponseItem
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error)); ok {
+		return rf(ctx, imageID, options)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, types.ImageRemoveOptions) []types.ImageDeleteResponseItem); ok {
+		r0 = rf(ctx, imageID, options)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]types.ImageDeleteResponseItem)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, types.ImageRemoveOptions) error); ok {
+		r1 = rf(ctx, imageID, options)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Info provides a mock function with given fields: ctx
 func (_m *MockClient) Info(ctx context.Context) (types.Info, error) {
 	ret := _m.Called(ctx)
@@ -418,6 +591,20 @@ func (_m *MockClient) NetworkCreate(ctx context.Context, networkName string, opt
 	return r0, r1
 }
 
+// NetworkConnect provides a mock function with given fields: ctx, networkID, containerID, config
+func (_m *MockClient) NetworkConnect(ctx context.Context, networkID string, containerID string, config *network.EndpointSettings) error {
+	ret := _m.Called(ctx, networkID, containerID, config)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *network.EndpointSettings) error); ok {
+		r0 = rf(ctx, networkID, containerID, config)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NetworkDisconnect provides a mock function with given fields: ctx, networkID, containerID, force
 func (_m *MockClient) NetworkDisconnect(ctx context.Context, networkID string, containerID string, force bool) error {
 	ret := _m.Called(ctx, networkID, containerID, force)
@@ -496,6 +683,30 @@ func (_m *MockClient) NetworkRemove(ctx context.Context, networkID string) error
 	return r0
 }
 
+// ServerVersion provides a mock function with given fields: ctx
+func (_m *MockClient) ServerVersion(ctx context.Context) (types.Version, error) {
+	ret := _m.Called(ctx)
+
+	var r0 types.Version
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (types.Version, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) types.Version); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(types.Version)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // VolumeCreate provides a mock function with given fields: ctx, options
 func (_m *MockClient) VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error) {
 	ret := _m.Called(ctx, options)
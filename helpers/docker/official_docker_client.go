@@ -187,6 +187,61 @@ func (c *officialDockerClient) ContainerLogs(
 	return rc, wrapError("ContainerLogs", err, started)
 }
 
+func (c *officialDockerClient) ContainerDiff(
+	ctx context.Context,
+	containerID string,
+) ([]container.FilesystemChange, error) {
+	started := time.Now()
+	changes, err := c.client.ContainerDiff(ctx, containerID)
+	return changes, wrapError("ContainerDiff", err, started)
+}
+
+func (c *officialDockerClient) ContainerExport(ctx context.Context, containerID string) (io.ReadCloser, error) {
+	started := time.Now()
+	rc, err := c.client.ContainerExport(ctx, containerID)
+	return rc, wrapError("ContainerExport", err, started)
+}
+
+func (c *officialDockerClient) ContainerCommit(
+	ctx context.Context,
+	containerID string,
+	options types.ContainerCommitOptions,
+) (types.IDResponse, error) {
+	started := time.Now()
+	resp, err := c.client.ContainerCommit(ctx, containerID, options)
+	return resp, wrapError("ContainerCommit", err, started)
+}
+
+func (c *officialDockerClient) ImageRemove(
+	ctx context.Context,
+	imageID string,
+	options types.ImageRemoveOptions,
+) ([]types.ImageDeleteResponseItem, error) {
+	started := time.Now()
+	items, err := c.client.ImageRemove(ctx, imageID, options)
+	return items, wrapError("ImageRemove", err, started)
+}
+
+func (c *officialDockerClient) CopyFromContainer(
+	ctx context.Context,
+	containerID, srcPath string,
+) (io.ReadCloser, types.ContainerPathStat, error) {
+	started := time.Now()
+	rc, stat, err := c.client.CopyFromContainer(ctx, containerID, srcPath)
+	return rc, stat, wrapError("CopyFromContainer", err, started)
+}
+
+func (c *officialDockerClient) CopyToContainer(
+	ctx context.Context,
+	containerID, dstPath string,
+	content io.Reader,
+	options types.CopyToContainerOptions,
+) error {
+	started := time.Now()
+	err := c.client.CopyToContainer(ctx, containerID, dstPath, content, options)
+	return wrapError("CopyToContainer", err, started)
+}
+
 func (c *officialDockerClient) ContainerExecCreate(
 	ctx context.Context,
 	container string,
@@ -223,6 +278,16 @@ func (c *officialDockerClient) NetworkRemove(ctx context.Context, networkID stri
 	return wrapError("NetworkRemove", err, started)
 }
 
+func (c *officialDockerClient) NetworkConnect(
+	ctx context.Context,
+	networkID, containerID string,
+	config *network.EndpointSettings,
+) error {
+	started := time.Now()
+	err := c.client.NetworkConnect(ctx, networkID, containerID, config)
+	return wrapError("NetworkConnect", err, started)
+}
+
 func (c *officialDockerClient) NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error {
 	started := time.Now()
 	err := c.client.NetworkDisconnect(ctx, networkID, containerID, force)
@@ -265,12 +330,24 @@ func (c *officialDockerClient) VolumeInspect(ctx context.Context, volumeID strin
 	return v, wrapError("VolumeInspect", err, started)
 }
 
+func (c *officialDockerClient) DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error) {
+	started := time.Now()
+	du, err := c.client.DiskUsage(ctx, options)
+	return du, wrapError("DiskUsage", err, started)
+}
+
 func (c *officialDockerClient) Info(ctx context.Context) (types.Info, error) {
 	started := time.Now()
 	info, err := c.client.Info(ctx)
 	return info, wrapError("Info", err, started)
 }
 
+func (c *officialDockerClient) ServerVersion(ctx context.Context) (types.Version, error) {
+	started := time.Now()
+	version, err := c.client.ServerVersion(ctx)
+	return version, wrapError("ServerVersion", err, started)
+}
+
 func (c *officialDockerClient) ImageImportBlocking(
 	ctx context.Context,
 	source types.ImageImportSource,
@@ -0,0 +1,150 @@
+// Package egress implements the proxy through which the jobs reach the
+// destinations allowed by the runner's egress policy.
+package egress
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+var (
+	ErrMetadataEndpoint = errors.New("cloud metadata endpoints are blocked")
+	ErrNotAllowed       = errors.New("destination isn't in the allow list")
+)
+
+// metadataNetworks are the addresses of the instance metadata services of
+// the cloud providers, which could leak the credentials of the runner host
+var metadataNetworks = mustParseCIDRs(
+	"169.254.169.254/32",
+	"169.254.170.2/32",
+	"100.100.100.200/32",
+	"fd00:ec2::254/128",
+)
+
+var metadataHosts = []string{
+	"metadata",
+	"metadata.google.internal",
+}
+
+// Policy decides whether a destination can be reached
+type Policy struct {
+	networks      []*net.IPNet
+	hosts         []string
+	blockMetadata bool
+}
+
+// NewPolicy parses the allow list, made of CIDRs, IP addresses and hostnames.
+// Hostnames can start with a `*.` wildcard matching any of their subdomains.
+func NewPolicy(allow []string, blockMetadata bool) (*Policy, error) {
+	p := &Policy{blockMetadata: blockMetadata}
+
+	for _, entry := range allow {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+
+		switch {
+		case entry == "":
+			continue
+		case strings.Contains(entry, "/"):
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("parsing egress allow list entry %q: %w", entry, err)
+			}
+			p.networks = append(p.networks, network)
+		case net.ParseIP(entry) != nil:
+			p.networks = append(p.networks, singleIPNetwork(net.ParseIP(entry)))
+		default:
+			p.hosts = append(p.hosts, strings.TrimSuffix(entry, "."))
+		}
+	}
+
+	return p, nil
+}
+
+// Check returns an error when the host, resolved to the given addresses, can't
+// be reached. Allowed hostnames are still checked against the metadata
+// endpoints, so that they can't be resolved to them.
+func (p *Policy) Check(host string, ips []net.IP) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	if p.blockMetadata {
+		if matchesAnyHost(host, metadataHosts) || anyIPInNetworks(ips, metadataNetworks) {
+			return ErrMetadataEndpoint
+		}
+	}
+
+	if matchesAnyHost(host, p.hosts) {
+		return nil
+	}
+
+	if len(ips) == 0 {
+		return ErrNotAllowed
+	}
+
+	for _, ip := range ips {
+		if !ipInNetworks(ip, p.networks) {
+			return ErrNotAllowed
+		}
+	}
+
+	return nil
+}
+
+func matchesAnyHost(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+
+		if host == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+func anyIPInNetworks(ips []net.IP, networks []*net.IPNet) bool {
+	for _, ip := range ips {
+		if ipInNetworks(ip, networks) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ipInNetworks(ip net.IP, networks []*net.IPNet) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func singleIPNetwork(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks
+}
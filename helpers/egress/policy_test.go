@@ -0,0 +1,107 @@
+//go:build !integration
+
+package egress
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPolicy(t *testing.T) {
+	p, err := NewPolicy([]string{" 10.0.0.0/8", "192.0.2.10", "2001:db8::1", "", "GitLab.example.com.", "*.example.org"}, true)
+	require.NoError(t, err)
+
+	assert.Len(t, p.networks, 3)
+	assert.Equal(t, []string{"gitlab.example.com", "*.example.org"}, p.hosts)
+	assert.True(t, p.blockMetadata)
+
+	_, err = NewPolicy([]string{"10.0.0.0/33"}, true)
+	assert.Error(t, err)
+}
+
+func TestPolicy_Check(t *testing.T) {
+	allow := []string{"10.0.0.0/8", "192.0.2.10", "gitlab.example.com", "*.example.org"}
+
+	tests := map[string]struct {
+		host          string
+		ips           []string
+		blockMetadata bool
+		expectedErr   error
+	}{
+		"allowed hostname": {
+			host: "GitLab.example.com",
+			ips:  []string{"203.0.113.1"},
+		},
+		"allowed hostname with trailing dot": {
+			host: "gitlab.example.com.",
+			ips:  []string{"203.0.113.1"},
+		},
+		"allowed subdomain": {
+			host: "registry.example.org",
+			ips:  []string{"203.0.113.2"},
+		},
+		"wildcard doesn't match the domain itself": {
+			host:        "example.org",
+			ips:         []string{"203.0.113.2"},
+			expectedErr: ErrNotAllowed,
+		},
+		"allowed network": {
+			host: "internal.example.net",
+			ips:  []string{"10.1.2.3", "192.0.2.10"},
+		},
+		"partially allowed addresses": {
+			host:        "internal.example.net",
+			ips:         []string{"10.1.2.3", "203.0.113.3"},
+			expectedErr: ErrNotAllowed,
+		},
+		"unresolved host": {
+			host:        "unknown.example.net",
+			expectedErr: ErrNotAllowed,
+		},
+		"metadata address": {
+			host:          "169.254.169.254",
+			ips:           []string{"169.254.169.254"},
+			blockMetadata: true,
+			expectedErr:   ErrMetadataEndpoint,
+		},
+		"allowed hostname resolved to a metadata address": {
+			host:          "gitlab.example.com",
+			ips:           []string{"169.254.169.254"},
+			blockMetadata: true,
+			expectedErr:   ErrMetadataEndpoint,
+		},
+		"metadata hostname": {
+			host:          "metadata.google.internal",
+			blockMetadata: true,
+			expectedErr:   ErrMetadataEndpoint,
+		},
+		"IPv6 metadata address": {
+			host:          "fd00:ec2::254",
+			ips:           []string{"fd00:ec2::254"},
+			blockMetadata: true,
+			expectedErr:   ErrMetadataEndpoint,
+		},
+		"metadata address not blocked": {
+			host:        "169.254.169.254",
+			ips:         []string{"169.254.169.254"},
+			expectedErr: ErrNotAllowed,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			p, err := NewPolicy(allow, tt.blockMetadata)
+			require.NoError(t, err)
+
+			var ips []net.IP
+			for _, ip := range tt.ips {
+				ips = append(ips, net.ParseIP(ip))
+			}
+
+			assert.ErrorIs(t, p.Check(tt.host, ips), tt.expectedErr)
+		})
+	}
+}
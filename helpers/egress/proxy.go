@@ -0,0 +1,239 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const dialTimeout = 30 * time.Second
+
+// hopByHopHeaders aren't forwarded to the destination
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+type resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+type dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DeniedError is returned when the policy denies the connection to a destination
+type DeniedError struct {
+	Address string
+	Inner   error
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("egress to %s denied: %v", e.Address, e.Inner)
+}
+
+func (e *DeniedError) Unwrap() error {
+	return e.Inner
+}
+
+// Proxy is an HTTP proxy, supporting the CONNECT method, which connects only
+// to the destinations allowed by its policy. The destinations are resolved
+// once, and the checked addresses are the ones dialed.
+type Proxy struct {
+	policy   *Policy
+	resolver resolver
+	dialer   dialer
+	logger   logrus.FieldLogger
+
+	transport *http.Transport
+}
+
+func NewProxy(policy *Policy, logger logrus.FieldLogger) *Proxy {
+	p := &Proxy{
+		policy:   policy,
+		resolver: net.DefaultResolver,
+		dialer:   &net.Dialer{Timeout: dialTimeout},
+		logger:   logger,
+	}
+
+	p.transport = &http.Transport{
+		DialContext:           p.dial,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return p
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.serveConnect(w, r)
+		return
+	}
+
+	if !r.URL.IsAbs() {
+		http.Error(w, "this is a proxy, requests must use absolute URLs", http.StatusBadRequest)
+		return
+	}
+
+	p.serveForward(w, r)
+}
+
+func (p *Proxy) serveConnect(w http.ResponseWriter, r *http.Request) {
+	upstream, err := p.dial(r.Context(), "tcp", r.Host)
+	if err != nil {
+		p.fail(w, r, err)
+		return
+	}
+	defer upstream.Close()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection hijacking isn't supported", http.StatusInternalServerError)
+		return
+	}
+
+	client, buf, err := hijacker.Hijack()
+	if err != nil {
+		p.logger.WithError(err).Warningln("Hijacking the client connection")
+		return
+	}
+	defer client.Close()
+
+	_, err = client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	if err != nil {
+		return
+	}
+
+	// bytes sent by the client after the request are already buffered
+	if n := buf.Reader.Buffered(); n > 0 {
+		pending, _ := buf.Reader.Peek(n)
+		if _, err := upstream.Write(pending); err != nil {
+			return
+		}
+	}
+
+	pipe(client, upstream)
+}
+
+func (p *Proxy) serveForward(w http.ResponseWriter, r *http.Request) {
+	outgoing := r.Clone(r.Context())
+	outgoing.RequestURI = ""
+	removeHopByHopHeaders(outgoing.Header)
+
+	resp, err := p.transport.RoundTrip(outgoing)
+	if err != nil {
+		p.fail(w, r, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	removeHopByHopHeaders(resp.Header)
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func (p *Proxy) fail(w http.ResponseWriter, r *http.Request, err error) {
+	var denied *DeniedError
+	if errors.As(err, &denied) {
+		p.logger.Warningln(fmt.Sprintf("Egress policy violation: %s %s: %v", r.Method, denied.Address, denied.Inner))
+		http.Error(w, denied.Error(), http.StatusForbidden)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
+
+// dial resolves the address, checks it against the policy, and connects to
+// the first allowed address that accepts the connection
+func (p *Proxy) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := p.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %s: %w", host, err)
+		}
+
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	if err := p.policy.Check(host, ips); err != nil {
+		return nil, &DeniedError{Address: address, Inner: err}
+	}
+
+	dialErr := fmt.Errorf("no addresses found for %s", host)
+	for _, ip := range ips {
+		conn, err := p.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		dialErr = err
+	}
+
+	return nil, dialErr
+}
+
+func removeHopByHopHeaders(header http.Header) {
+	for _, field := range strings.Split(header.Get("Connection"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			header.Del(field)
+		}
+	}
+
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+}
+
+func pipe(a, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	copyAndClose := func(dst, src net.Conn) {
+		defer wg.Done()
+
+		_, _ = io.Copy(dst, src)
+		if c, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = c.CloseWrite()
+		} else {
+			_ = dst.Close()
+		}
+	}
+
+	go copyAndClose(a, b)
+	go copyAndClose(b, a)
+
+	wg.Wait()
+}
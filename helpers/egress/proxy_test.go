@@ -0,0 +1,151 @@
+//go:build !integration
+
+package egress
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResolver map[string][]net.IPAddr
+
+func (r fakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return addrs, nil
+}
+
+func newTestProxy(t *testing.T) (*http.Client, *test.Hook) {
+	policy, err := NewPolicy([]string{"allowed.test"}, true)
+	require.NoError(t, err)
+
+	logger, hook := test.NewNullLogger()
+
+	p := NewProxy(policy, logger)
+	loopback := []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}
+	p.resolver = fakeResolver{
+		"allowed.test":  loopback,
+		"denied.test":   loopback,
+		"metadata.test": {{IP: net.ParseIP("169.254.169.254")}},
+	}
+
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+
+	proxyURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(proxyURL),
+			//nolint:gosec
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	t.Cleanup(client.CloseIdleConnections)
+
+	return client, hook
+}
+
+func TestProxy(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Proxy-Connection"))
+		_, _ = fmt.Fprint(w, "upstream")
+	})
+
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	tlsServer := httptest.NewTLSServer(handler)
+	defer tlsServer.Close()
+
+	port := func(server *httptest.Server) string {
+		_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+		require.NoError(t, err)
+		return port
+	}
+
+	tests := map[string]struct {
+		url                string
+		expectedStatus     int
+		expectedBody       string
+		expectedErr        bool
+		expectedViolation  string
+		expectedViolations int
+	}{
+		"allowed HTTP destination": {
+			url:            "http://allowed.test:" + port(httpServer),
+			expectedStatus: http.StatusOK,
+			expectedBody:   "upstream",
+		},
+		"allowed HTTPS destination": {
+			url:            "https://allowed.test:" + port(tlsServer),
+			expectedStatus: http.StatusOK,
+			expectedBody:   "upstream",
+		},
+		"denied HTTP destination": {
+			url:                "http://denied.test:" + port(httpServer),
+			expectedStatus:     http.StatusForbidden,
+			expectedViolation:  "Egress policy violation: GET denied.test:" + port(httpServer) + ": destination isn't in the allow list",
+			expectedViolations: 1,
+		},
+		"denied HTTPS destination": {
+			url:                "https://denied.test:" + port(tlsServer),
+			expectedErr:        true,
+			expectedViolation:  "Egress policy violation: CONNECT denied.test:" + port(tlsServer) + ": destination isn't in the allow list",
+			expectedViolations: 1,
+		},
+		"metadata endpoint": {
+			url:                "http://169.254.169.254/latest/meta-data/",
+			expectedStatus:     http.StatusForbidden,
+			expectedViolation:  "Egress policy violation: GET 169.254.169.254:80: cloud metadata endpoints are blocked",
+			expectedViolations: 1,
+		},
+		"unknown host": {
+			url:            "http://unknown.test:" + port(httpServer),
+			expectedStatus: http.StatusBadGateway,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			client, hook := newTestProxy(t)
+
+			resp, err := client.Get(tt.url)
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+				if tt.expectedBody != "" {
+					body, err := io.ReadAll(resp.Body)
+					require.NoError(t, err)
+					assert.Equal(t, tt.expectedBody, string(body))
+				}
+			}
+
+			require.Len(t, hook.AllEntries(), tt.expectedViolations)
+			if tt.expectedViolations > 0 {
+				assert.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+				assert.Equal(t, tt.expectedViolation, hook.LastEntry().Message)
+			}
+		})
+	}
+}
@@ -105,20 +105,42 @@ func (m *DataBag) Sanitize() (err error) {
 	return
 }
 
-func getOptionsMap(optionKey string, primary, secondary DataBag) (value DataBag) {
-	value, ok := primary.GetSubOptions(optionKey)
-	if !ok {
-		value, _ = secondary.GetSubOptions(optionKey)
+// mergeDataBags returns the deep merge of override into base: the hashes are
+// merged, the other values of override replace the ones of base
+func mergeDataBags(base, override DataBag) DataBag {
+	result := make(DataBag, len(base)+len(override))
+	for key, value := range base {
+		result[key] = value
 	}
 
-	return
-}
+	for key, value := range override {
+		baseMap, baseIsMap := toDataBag(result[key])
+		overrideMap, overrideIsMap := toDataBag(value)
+		if baseIsMap && overrideIsMap {
+			result[key] = map[string]interface{}(mergeDataBags(baseMap, overrideMap))
+			continue
+		}
 
-func getOptions(optionKey string, primary, secondary DataBag) (value []interface{}, ok bool) {
-	value, ok = primary.GetSlice(optionKey)
-	if !ok {
-		value, ok = secondary.GetSlice(optionKey)
+		result[key] = value
 	}
 
-	return
+	return result
+}
+
+// toDataBag returns the value as a DataBag if it's a map, sanitized or not
+func toDataBag(value interface{}) (DataBag, bool) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m, true
+	case DataBag:
+		return m, true
+	case map[interface{}]interface{}:
+		converted, err := convertMapToStringMap(m)
+		if err != nil {
+			return nil, false
+		}
+		return converted.(map[string]interface{}), true
+	default:
+		return nil, false
+	}
 }
@@ -0,0 +1,357 @@
+package gitlab_ci_yaml_parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+type expressionTokenType int
+
+const (
+	expressionVariable expressionTokenType = iota
+	expressionString
+	expressionRegex
+	expressionNull
+	expressionOperator
+	expressionOpenParen
+	expressionCloseParen
+)
+
+type expressionToken struct {
+	kind  expressionTokenType
+	value string
+}
+
+// expressionValue is an operand of an expression. Undefined variables and
+// null are represented by a value which isn't defined.
+type expressionValue struct {
+	value   string
+	defined bool
+	regex   bool
+}
+
+func (v expressionValue) truthy() bool {
+	return v.defined && v.value != ""
+}
+
+// expression evaluates the `if` clauses of rules, with the subset of the
+// GitLab syntax which can be evaluated locally: variables, string, null and
+// regex literals, the ==, !=, =~ and !~ comparisons, && and || and
+// parentheses
+type expression struct {
+	tokens    []expressionToken
+	pos       int
+	variables common.JobVariables
+}
+
+func evaluateExpression(text string, variables common.JobVariables) (bool, error) {
+	tokens, err := tokenizeExpression(text)
+	if err != nil {
+		return false, err
+	}
+
+	e := &expression{tokens: tokens, variables: variables}
+
+	result, err := e.or()
+	if err != nil {
+		return false, err
+	}
+
+	if e.pos < len(e.tokens) {
+		return false, fmt.Errorf("unexpected %q", e.tokens[e.pos].value)
+	}
+
+	return result, nil
+}
+
+func tokenizeExpression(text string) ([]expressionToken, error) {
+	var tokens []expressionToken
+
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, expressionToken{kind: expressionOpenParen, value: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, expressionToken{kind: expressionCloseParen, value: ")"})
+			i++
+		case c == '$':
+			name, n := readVariableName(text[i+1:])
+			if name == "" {
+				return nil, fmt.Errorf("invalid variable at %d", i)
+			}
+			tokens = append(tokens, expressionToken{kind: expressionVariable, value: name})
+			i += n + 1
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(text[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, expressionToken{kind: expressionString, value: text[i+1 : i+1+end]})
+			i += end + 2
+		case c == '/':
+			pattern, n, err := readRegex(text[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%w at %d", err, i)
+			}
+			tokens = append(tokens, expressionToken{kind: expressionRegex, value: pattern})
+			i += n
+		case strings.HasPrefix(text[i:], "null"):
+			tokens = append(tokens, expressionToken{kind: expressionNull, value: "null"})
+			i += len("null")
+		default:
+			operator := ""
+			for _, op := range []string{"==", "!=", "=~", "!~", "&&", "||"} {
+				if strings.HasPrefix(text[i:], op) {
+					operator = op
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected %q at %d", text[i], i)
+			}
+			tokens = append(tokens, expressionToken{kind: expressionOperator, value: operator})
+			i += len(operator)
+		}
+	}
+
+	return tokens, nil
+}
+
+// readVariableName reads the name of a $NAME or ${NAME} variable and returns
+// it with the number of bytes read
+func readVariableName(text string) (string, int) {
+	if strings.HasPrefix(text, "{") {
+		end := strings.IndexByte(text, '}')
+		if end < 0 {
+			return "", 0
+		}
+		return text[1:end], end + 1
+	}
+
+	n := 0
+	for n < len(text) && isVariableNameChar(text[n]) {
+		n++
+	}
+
+	return text[:n], n
+}
+
+func isVariableNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// readRegex reads a /pattern/flags regex literal and returns it with the
+// number of bytes read
+func readRegex(text string) (string, int, error) {
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '/':
+			end := i + 1
+			for end < len(text) && text[end] >= 'a' && text[end] <= 'z' {
+				end++
+			}
+			return text[:end], end, nil
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated regex")
+}
+
+// compileRegex compiles a /pattern/flags regex. Only the i flag is supported.
+func compileRegex(literal string) (*regexp.Regexp, error) {
+	end := strings.LastIndexByte(literal, '/')
+	if !strings.HasPrefix(literal, "/") || end < 1 {
+		return nil, fmt.Errorf("invalid regex %q", literal)
+	}
+
+	pattern := literal[1:end]
+	for _, flag := range literal[end+1:] {
+		if flag != 'i' {
+			return nil, fmt.Errorf("unsupported regex flag %q", flag)
+		}
+		pattern = "(?i)" + pattern
+	}
+
+	return regexp.Compile(pattern)
+}
+
+func (e *expression) peek() *expressionToken {
+	if e.pos >= len(e.tokens) {
+		return nil
+	}
+
+	return &e.tokens[e.pos]
+}
+
+func (e *expression) peekOperator(operators ...string) string {
+	token := e.peek()
+	if token == nil || token.kind != expressionOperator {
+		return ""
+	}
+
+	for _, op := range operators {
+		if token.value == op {
+			return op
+		}
+	}
+
+	return ""
+}
+
+func (e *expression) or() (bool, error) {
+	result, err := e.and()
+	if err != nil {
+		return false, err
+	}
+
+	for e.peekOperator("||") != "" {
+		e.pos++
+
+		right, err := e.and()
+		if err != nil {
+			return false, err
+		}
+		result = result || right
+	}
+
+	return result, nil
+}
+
+func (e *expression) and() (bool, error) {
+	result, err := e.primary()
+	if err != nil {
+		return false, err
+	}
+
+	for e.peekOperator("&&") != "" {
+		e.pos++
+
+		right, err := e.primary()
+		if err != nil {
+			return false, err
+		}
+		result = result && right
+	}
+
+	return result, nil
+}
+
+func (e *expression) primary() (bool, error) {
+	token := e.peek()
+	if token == nil {
+		return false, fmt.Errorf("unexpected end of expression")
+	}
+
+	if token.kind != expressionOpenParen {
+		return e.comparison()
+	}
+
+	e.pos++
+	result, err := e.or()
+	if err != nil {
+		return false, err
+	}
+
+	if token := e.peek(); token == nil || token.kind != expressionCloseParen {
+		return false, fmt.Errorf("missing closing parenthesis")
+	}
+	e.pos++
+
+	return result, nil
+}
+
+func (e *expression) comparison() (bool, error) {
+	left, err := e.operand()
+	if err != nil {
+		return false, err
+	}
+
+	operator := e.peekOperator("==", "!=", "=~", "!~")
+	if operator == "" {
+		return left.truthy(), nil
+	}
+	e.pos++
+
+	right, err := e.operand()
+	if err != nil {
+		return false, err
+	}
+
+	switch operator {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "=~":
+		return match(left, right)
+	default:
+		matched, err := match(left, right)
+		return !matched, err
+	}
+}
+
+func (e *expression) operand() (expressionValue, error) {
+	token := e.peek()
+	if token == nil {
+		return expressionValue{}, fmt.Errorf("unexpected end of expression")
+	}
+	e.pos++
+
+	switch token.kind {
+	case expressionVariable:
+		return e.variable(token.value), nil
+	case expressionString:
+		return expressionValue{value: token.value, defined: true}, nil
+	case expressionRegex:
+		return expressionValue{value: token.value, defined: true, regex: true}, nil
+	case expressionNull:
+		return expressionValue{}, nil
+	default:
+		return expressionValue{}, fmt.Errorf("unexpected %q", token.value)
+	}
+}
+
+func (e *expression) variable(name string) expressionValue {
+	for i := len(e.variables) - 1; i >= 0; i-- {
+		if e.variables[i].Key == name {
+			return expressionValue{value: e.variables[i].Value, defined: true}
+		}
+	}
+
+	return expressionValue{}
+}
+
+func equal(left, right expressionValue) bool {
+	if !left.defined || !right.defined {
+		return left.defined == right.defined
+	}
+
+	return left.value == right.value
+}
+
+// match matches the left value against the right regex, either a regex
+// literal or a variable containing one
+func match(left, right expressionValue) (bool, error) {
+	if !right.defined {
+		return false, fmt.Errorf("the right side of a regex comparison must be a regex")
+	}
+
+	re, err := compileRegex(right.value)
+	if err != nil {
+		return false, err
+	}
+
+	if !left.defined {
+		return false, nil
+	}
+
+	return re.MatchString(left.value), nil
+}
@@ -0,0 +1,74 @@
+//go:build !integration
+
+package gitlab_ci_yaml_parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+func TestEvaluateExpression(t *testing.T) {
+	variables := common.JobVariables{
+		{Key: "CI_COMMIT_BRANCH", Value: "main"},
+		{Key: "EMPTY", Value: ""},
+		{Key: "RELEASE", Value: "release-1.2"},
+		{Key: "PATTERN", Value: "/^RELEASE-/i"},
+	}
+
+	tests := map[string]struct {
+		expression     string
+		expectedResult bool
+		expectedError  string
+	}{
+		"defined variable":             {expression: "$CI_COMMIT_BRANCH", expectedResult: true},
+		"empty variable":               {expression: "$EMPTY", expectedResult: false},
+		"undefined variable":           {expression: "$UNDEFINED", expectedResult: false},
+		"braces variable":              {expression: `${CI_COMMIT_BRANCH} == "main"`, expectedResult: true},
+		"equal":                        {expression: `$CI_COMMIT_BRANCH == "main"`, expectedResult: true},
+		"single quotes":                {expression: `$CI_COMMIT_BRANCH == 'main'`, expectedResult: true},
+		"not equal":                    {expression: `$CI_COMMIT_BRANCH != "main"`, expectedResult: false},
+		"variables equal":              {expression: `$CI_COMMIT_BRANCH == $RELEASE`, expectedResult: false},
+		"undefined equals null":        {expression: "$UNDEFINED == null", expectedResult: true},
+		"empty doesn't equal null":     {expression: "$EMPTY == null", expectedResult: false},
+		"undefined doesn't equal \"\"": {expression: `$UNDEFINED == ""`, expectedResult: false},
+		"regex match":                  {expression: "$RELEASE =~ /^release-[0-9.]+$/", expectedResult: true},
+		"regex no match":               {expression: "$CI_COMMIT_BRANCH =~ /^release-/", expectedResult: false},
+		"regex with slash":             {expression: `"a/b" =~ /a\/b/`, expectedResult: true},
+		"regex not match":              {expression: "$CI_COMMIT_BRANCH !~ /^release-/", expectedResult: true},
+		"regex flag":                   {expression: "$RELEASE =~ /^RELEASE-/i", expectedResult: true},
+		"regex in variable":            {expression: "$RELEASE =~ $PATTERN", expectedResult: true},
+		"regex on undefined":           {expression: "$UNDEFINED =~ /.*/", expectedResult: false},
+		"and":                          {expression: `$CI_COMMIT_BRANCH == "main" && $RELEASE`, expectedResult: true},
+		"or":                           {expression: `$UNDEFINED || $RELEASE`, expectedResult: true},
+		"precedence": {
+			expression:     `$CI_COMMIT_BRANCH == "main" || $UNDEFINED && $EMPTY`,
+			expectedResult: true,
+		},
+		"parentheses": {
+			expression:     `($CI_COMMIT_BRANCH == "main" || $UNDEFINED) && $EMPTY`,
+			expectedResult: false,
+		},
+		"missing operand":        {expression: "$CI_COMMIT_BRANCH ==", expectedError: "unexpected end of expression"},
+		"missing parenthesis":    {expression: "($CI_COMMIT_BRANCH", expectedError: "missing closing parenthesis"},
+		"unterminated string":    {expression: `$CI_COMMIT_BRANCH == "main`, expectedError: "unterminated string at 21"},
+		"unexpected character":   {expression: "$CI_COMMIT_BRANCH = 1", expectedError: `unexpected '=' at 18`},
+		"regex without regex":    {expression: `$CI_COMMIT_BRANCH =~ "main"`, expectedError: `invalid regex "main"`},
+		"unsupported regex flag": {expression: "$RELEASE =~ /a/m", expectedError: `unsupported regex flag 'm'`},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			result, err := evaluateExpression(tt.expression, variables)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedResult, result)
+		})
+	}
+}
@@ -4,34 +4,76 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/sirupsen/logrus"
+
 	"gitlab.com/gitlab-org/gitlab-runner/common"
 
 	"gopkg.in/yaml.v2"
 )
 
+// maxExtendsDepth is the maximum nesting of extends, as enforced by GitLab
+const maxExtendsDepth = 11
+
+// ErrJobExcludedByRules is returned when the rules of the job don't add it to
+// the pipeline
+var ErrJobExcludedByRules = errors.New("excluded by its rules")
+
+// globalDefaultKeys are the legacy global keywords, which are defaults for
+// the jobs like the ones of the default section
+var globalDefaultKeys = []string{"image", "services", "before_script", "after_script", "cache"}
+
+// defaultKeys are the keywords of the default section
+var defaultKeys = append([]string{"artifacts", "interruptible", "retry", "tags", "timeout"}, globalDefaultKeys...)
+
+// reservedKeys are the global keywords, which can't be job names
+var reservedKeys = append([]string{"default", "include", "stages", "types", "variables", "workflow"}, globalDefaultKeys...)
+
+// defaultStages are the stages of a pipeline which doesn't define them
+var defaultStages = []string{"build", "test", "deploy"}
+
 type GitLabCiYamlParser struct {
 	filename  string
 	jobName   string
+	rootDir   string
 	config    DataBag
 	jobConfig DataBag
 }
 
-func (c *GitLabCiYamlParser) parseFile() (err error) {
-	data, err := os.ReadFile(c.filename)
+// need is an entry of the needs of a job
+type need struct {
+	job       string
+	artifacts bool
+	optional  bool
+}
+
+func readConfigFile(filename string) (DataBag, error) {
+	data, err := os.ReadFile(filename)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	config := make(DataBag)
 	err = yaml.Unmarshal(data, config)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("%s: %w", filename, err)
 	}
 
 	err = config.Sanitize()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return config, nil
+}
+
+func (c *GitLabCiYamlParser) parseFile() (err error) {
+	config, err := c.loadFile(c.filename, nil)
 	if err != nil {
 		return err
 	}
@@ -41,10 +83,87 @@ func (c *GitLabCiYamlParser) parseFile() (err error) {
 	return
 }
 
+// loadFile reads the configuration file and deep merges it over the local
+// files it includes, in order
+func (c *GitLabCiYamlParser) loadFile(filename string, includedBy []string) (DataBag, error) {
+	for _, parent := range includedBy {
+		if parent == filename {
+			return nil, fmt.Errorf("include loop: %s", strings.Join(append(includedBy, filename), " -> "))
+		}
+	}
+
+	config, err := readConfigFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	includes, err := c.localIncludes(config["include"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	delete(config, "include")
+
+	// copy the parents, the slice is shared by the included files
+	parents := append(append([]string{}, includedBy...), filename)
+
+	merged := make(DataBag)
+	for _, include := range includes {
+		includedConfig, err := c.loadFile(include, parents)
+		if err != nil {
+			return nil, err
+		}
+
+		merged = mergeDataBags(merged, includedConfig)
+	}
+
+	return mergeDataBags(merged, config), nil
+}
+
+// localIncludes returns the files matching the local includes. The other
+// includes need a GitLab instance and are skipped.
+func (c *GitLabCiYamlParser) localIncludes(include interface{}) ([]string, error) {
+	var entries []interface{}
+	switch value := include.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		entries = value
+	default:
+		entries = []interface{}{value}
+	}
+
+	var files []string
+	for _, entry := range entries {
+		local, ok := entry.(string)
+		if entryMap, isMap := toDataBag(entry); isMap {
+			local, ok = entryMap.GetString("local")
+		}
+
+		if !ok || strings.Contains(local, "://") {
+			logrus.Warningf("Skipping include %v: only local includes are supported", entry)
+			continue
+		}
+
+		pattern := filepath.Join(c.rootDir, filepath.FromSlash(strings.TrimPrefix(local, "/")))
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("include %q: %w", local, err)
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("include %q: no such file", local)
+		}
+
+		files = append(files, matches...)
+	}
+
+	return files, nil
+}
+
 func (c *GitLabCiYamlParser) loadJob() (err error) {
-	jobConfig, ok := c.config.GetSubOptions(c.jobName)
-	if !ok {
-		return fmt.Errorf("no job named %q", c.jobName)
+	jobConfig, err := c.resolveJob(c.jobName)
+	if err != nil {
+		return err
 	}
 
 	c.jobConfig = jobConfig
@@ -52,6 +171,126 @@ func (c *GitLabCiYamlParser) loadJob() (err error) {
 	return
 }
 
+// resolveJob returns the configuration of the job with its extends resolved
+// and its defaults applied
+func (c *GitLabCiYamlParser) resolveJob(name string) (DataBag, error) {
+	if _, ok := c.config.GetSubOptions(name); !ok {
+		return nil, fmt.Errorf("no job named %q", name)
+	}
+
+	jobConfig, err := c.resolveExtends(name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.applyDefaults(jobConfig)
+
+	return jobConfig, nil
+}
+
+// resolveExtends deep merges the configuration of the job over the ones it
+// extends, in order
+func (c *GitLabCiYamlParser) resolveExtends(name string, extendedBy []string) (DataBag, error) {
+	jobConfig, ok := c.config.GetSubOptions(name)
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown key in extends: %q", extendedBy[len(extendedBy)-1], name)
+	}
+
+	for _, child := range extendedBy {
+		if child == name {
+			return nil, fmt.Errorf("%s: circular dependency detected in extends", extendedBy[0])
+		}
+	}
+
+	if len(extendedBy) >= maxExtendsDepth {
+		return nil, fmt.Errorf("%s: nesting too deep in extends", extendedBy[0])
+	}
+
+	var bases []string
+	switch extends := jobConfig["extends"].(type) {
+	case nil:
+	case string:
+		bases = []string{extends}
+	case []interface{}:
+		for _, base := range extends {
+			baseName, ok := base.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: invalid extends", name)
+			}
+			bases = append(bases, baseName)
+		}
+	default:
+		return nil, fmt.Errorf("%s: invalid extends", name)
+	}
+
+	children := append(append([]string{}, extendedBy...), name)
+
+	resolved := make(DataBag)
+	for _, base := range bases {
+		baseConfig, err := c.resolveExtends(base, children)
+		if err != nil {
+			return nil, err
+		}
+
+		resolved = mergeDataBags(resolved, baseConfig)
+	}
+
+	resolved = mergeDataBags(resolved, jobConfig)
+	delete(resolved, "extends")
+
+	return resolved, nil
+}
+
+// applyDefaults sets the keywords the job doesn't define to their default,
+// unless the job doesn't inherit them
+func (c *GitLabCiYamlParser) applyDefaults(jobConfig DataBag) {
+	defaults := make(DataBag)
+	for _, key := range globalDefaultKeys {
+		if value, ok := c.config[key]; ok {
+			defaults[key] = value
+		}
+	}
+
+	if defaultConfig, ok := c.config.GetSubOptions("default"); ok {
+		for _, key := range defaultKeys {
+			if value, ok := defaultConfig[key]; ok {
+				defaults[key] = value
+			}
+		}
+	}
+
+	for key, value := range defaults {
+		if _, ok := jobConfig[key]; ok || !inherits(jobConfig, "default", key) {
+			continue
+		}
+
+		jobConfig[key] = value
+	}
+}
+
+// inherits returns whether the job inherits the key of the default section
+// or of the global variables, according to its inherit keyword
+func inherits(jobConfig DataBag, section string, key string) bool {
+	inherit, ok := jobConfig.Get("inherit", section)
+	if !ok {
+		return true
+	}
+
+	switch value := inherit.(type) {
+	case bool:
+		return value
+	case []interface{}:
+		for _, inherited := range value {
+			if inherited == key {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
 func (c *GitLabCiYamlParser) prepareJobInfo(job *common.JobResponse) (err error) {
 	job.JobInfo = common.JobInfo{
 		Name: c.jobName,
@@ -96,24 +335,12 @@ func (c *GitLabCiYamlParser) prepareSteps(job *common.JobResponse) error {
 
 	var scriptCommands, afterScriptCommands common.StepScript
 
-	// get before_script
-	beforeScript, err := c.getCommands(c.config["before_script"])
+	// get before_script, the default one is already applied
+	scriptCommands, err := c.getCommands(c.jobConfig["before_script"])
 	if err != nil {
 		return err
 	}
 
-	// get job before_script
-	jobBeforeScript, err := c.getCommands(c.jobConfig["before_script"])
-	if err != nil {
-		return err
-	}
-
-	if len(jobBeforeScript) < 1 {
-		scriptCommands = beforeScript
-	} else {
-		scriptCommands = jobBeforeScript
-	}
-
 	// get script
 	script, err := c.getCommands(c.jobConfig["script"])
 	if err != nil {
@@ -162,6 +389,8 @@ func (c *GitLabCiYamlParser) buildDefaultVariables(job *common.JobResponse) comm
 		{Key: "CI_COMMIT_SHA", Value: job.GitInfo.Sha, Public: true, Internal: true, File: false},
 		{Key: "CI_COMMIT_BEFORE_SHA", Value: job.GitInfo.BeforeSha, Public: true, Internal: true, File: false},
 		{Key: "CI_COMMIT_REF_NAME", Value: job.GitInfo.Ref, Public: true, Internal: true, File: false},
+		{Key: "CI_COMMIT_BRANCH", Value: job.GitInfo.Ref, Public: true, Internal: true, File: false},
+		{Key: "CI_PIPELINE_SOURCE", Value: "push", Public: true, Internal: true, File: false},
 	}
 }
 
@@ -198,7 +427,11 @@ func (c *GitLabCiYamlParser) prepareVariables(job *common.JobResponse) error {
 		return err
 	}
 
-	job.Variables = append(job.Variables, globalVariables...)
+	for _, variable := range globalVariables {
+		if inherits(c.jobConfig, "variables", variable.Key) {
+			job.Variables = append(job.Variables, variable)
+		}
+	}
 
 	jobVariables, err := c.buildVariables(c.jobConfig["variables"])
 	if err != nil {
@@ -224,26 +457,10 @@ func (c *GitLabCiYamlParser) prepareImage(job *common.JobResponse) error {
 		return nil
 	}
 
-	if imageName, ok := c.config.GetString("image"); ok {
-		job.Image.Name = imageName
-		return nil
-	}
-
-	if imageDefinition, ok := c.config.GetSubOptions("image"); ok {
-		job.Image.Name, _ = imageDefinition.GetString("name")
-		job.Image.Entrypoint, _ = imageDefinition.GetStringSlice("entrypoint")
-		return nil
-	}
-
 	return nil
 }
 
-func parseExtendedServiceDefinitionMap(serviceDefinition map[interface{}]interface{}) (image common.Image) {
-	service := make(DataBag)
-	for key, value := range serviceDefinition {
-		service[key.(string)] = value
-	}
-
+func parseExtendedServiceDefinitionMap(service DataBag) (image common.Image) {
 	image.Name, _ = service.GetString("name")
 	image.Alias, _ = service.GetString("alias")
 	image.Command, _ = service.GetStringSlice("command")
@@ -254,7 +471,7 @@ func parseExtendedServiceDefinitionMap(serviceDefinition map[interface{}]interfa
 func (c *GitLabCiYamlParser) prepareServices(job *common.JobResponse) (err error) {
 	job.Services = common.Services{}
 
-	if servicesMap, ok := getOptions("services", c.jobConfig, c.config); ok {
+	if servicesMap, ok := c.jobConfig.GetSlice("services"); ok {
 		for _, service := range servicesMap {
 			if serviceName, ok := service.(string); ok {
 				job.Services = append(job.Services, common.Image{
@@ -263,7 +480,7 @@ func (c *GitLabCiYamlParser) prepareServices(job *common.JobResponse) (err error
 				continue
 			}
 
-			if serviceDefinition, ok := service.(map[interface{}]interface{}); ok {
+			if serviceDefinition, ok := toDataBag(service); ok {
 				job.Services = append(job.Services, parseExtendedServiceDefinitionMap(serviceDefinition))
 			}
 		}
@@ -275,7 +492,7 @@ func (c *GitLabCiYamlParser) prepareServices(job *common.JobResponse) (err error
 func (c *GitLabCiYamlParser) prepareArtifacts(job *common.JobResponse) error {
 	var ok bool
 
-	artifactsMap := getOptionsMap("artifacts", c.jobConfig, c.config)
+	artifactsMap, _ := c.jobConfig.GetSubOptions("artifacts")
 
 	artifactsPaths, _ := artifactsMap.GetSlice("paths")
 	paths := common.ArtifactPaths{}
@@ -318,7 +535,7 @@ func (c *GitLabCiYamlParser) prepareArtifacts(job *common.JobResponse) error {
 func (c *GitLabCiYamlParser) prepareCache(job *common.JobResponse) error {
 	var ok bool
 
-	cacheMap := getOptionsMap("cache", c.jobConfig, c.config)
+	cacheMap, _ := c.jobConfig.GetSubOptions("cache")
 
 	cachePaths, _ := cacheMap.GetSlice("paths")
 	paths := common.ArtifactPaths{}
@@ -346,6 +563,306 @@ func (c *GitLabCiYamlParser) prepareCache(job *common.JobResponse) error {
 	return nil
 }
 
+// prepareRules evaluates the rules of the job. Only the if and exists clauses
+// are evaluated, the changes clauses always match. The variables of the
+// matching rule are added to the job.
+func (c *GitLabCiYamlParser) prepareRules(job *common.JobResponse) error {
+	rules, ok := c.jobConfig["rules"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	for _, rawRule := range rules {
+		rule, ok := toDataBag(rawRule)
+		if !ok {
+			return errors.New("unsupported rules")
+		}
+
+		matched, err := c.ruleMatches(rule, job.Variables)
+		if err != nil {
+			return err
+		}
+
+		if !matched {
+			continue
+		}
+
+		if when, _ := rule.GetString("when"); when == "never" {
+			break
+		}
+
+		ruleVariables, err := c.buildVariables(rule["variables"])
+		if err != nil {
+			return err
+		}
+
+		job.Variables = append(job.Variables, ruleVariables...)
+
+		return nil
+	}
+
+	return fmt.Errorf("job %q %w", c.jobName, ErrJobExcludedByRules)
+}
+
+func (c *GitLabCiYamlParser) ruleMatches(rule DataBag, variables common.JobVariables) (bool, error) {
+	if expression, ok := rule.GetString("if"); ok {
+		matched, err := evaluateExpression(expression, variables)
+		if err != nil {
+			return false, fmt.Errorf("rules:if %q: %w", expression, err)
+		}
+
+		if !matched {
+			return false, nil
+		}
+	}
+
+	if _, ok := rule["exists"]; ok {
+		patterns, _ := rule.GetStringSlice("exists")
+		for _, pattern := range patterns {
+			matches, err := filepath.Glob(filepath.Join(c.rootDir, filepath.FromSlash(pattern)))
+			if err != nil {
+				return false, fmt.Errorf("rules:exists %q: %w", pattern, err)
+			}
+
+			if len(matches) > 0 {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// prepareDependencies sets the jobs whose artifacts the job downloads: the
+// jobs it needs with their artifacts or, without needs, the jobs of the
+// previous stages, restricted to the ones listed by dependencies
+func (c *GitLabCiYamlParser) prepareDependencies(job *common.JobResponse) error {
+	var candidates []string
+	if _, ok := c.jobConfig["needs"]; ok {
+		needs, err := jobNeeds(c.jobName, c.jobConfig)
+		if err != nil {
+			return err
+		}
+
+		for _, need := range needs {
+			if _, ok := c.config.GetSubOptions(need.job); !ok && need.optional {
+				continue
+			}
+
+			if need.artifacts {
+				candidates = append(candidates, need.job)
+			}
+		}
+	} else {
+		candidates = c.previousStagesJobs(job.JobInfo.Stage)
+	}
+
+	if _, ok := c.jobConfig["dependencies"]; ok {
+		dependencies, ok := c.jobConfig.GetStringSlice("dependencies")
+		if !ok && c.jobConfig["dependencies"] != nil {
+			return fmt.Errorf("%s: unsupported dependencies", c.jobName)
+		}
+
+		candidates = filterJobs(candidates, dependencies)
+	}
+
+	job.Dependencies = common.Dependencies{}
+	for _, name := range candidates {
+		job.Dependencies = append(job.Dependencies, common.Dependency{Name: name})
+	}
+
+	return nil
+}
+
+// stages returns the stages of the pipeline, with the .pre and .post ones
+func (c *GitLabCiYamlParser) stages() []string {
+	stages, ok := c.config.GetStringSlice("stages")
+	if !ok {
+		stages, ok = c.config.GetStringSlice("types")
+	}
+	if !ok {
+		stages = defaultStages
+	}
+
+	return append(append([]string{".pre"}, stages...), ".post")
+}
+
+// previousStagesJobs returns the names of the jobs of the stages before the
+// stage, sorted. The jobs which can't be resolved are skipped.
+func (c *GitLabCiYamlParser) previousStagesJobs(stage string) []string {
+	stageIndex := make(map[string]int)
+	for i, name := range c.stages() {
+		stageIndex[name] = i
+	}
+
+	current, ok := stageIndex[stage]
+	if !ok {
+		return nil
+	}
+
+	var jobs []string
+	for name := range c.config {
+		if isReservedKey(name) || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		jobConfig, err := c.resolveJob(name)
+		if err != nil {
+			continue
+		}
+
+		jobStage, ok := jobConfig.GetString("stage")
+		if !ok {
+			jobStage = "test"
+		}
+
+		if index, ok := stageIndex[jobStage]; ok && index < current {
+			jobs = append(jobs, name)
+		}
+	}
+
+	sort.Strings(jobs)
+
+	return jobs
+}
+
+func isReservedKey(key string) bool {
+	for _, reserved := range reservedKeys {
+		if key == reserved {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterJobs returns the jobs which are in the list, in order
+func filterJobs(jobs, list []string) []string {
+	filtered := []string{}
+	for _, job := range jobs {
+		for _, name := range list {
+			if job == name {
+				filtered = append(filtered, job)
+				break
+			}
+		}
+	}
+
+	return filtered
+}
+
+// jobNeeds returns the needs of the job on the other jobs of the pipeline
+func jobNeeds(name string, jobConfig DataBag) ([]need, error) {
+	rawNeeds, ok := jobConfig["needs"].([]interface{})
+	if !ok {
+		if jobConfig["needs"] != nil {
+			return nil, fmt.Errorf("%s: unsupported needs", name)
+		}
+		return nil, nil
+	}
+
+	needs := make([]need, 0, len(rawNeeds))
+	for _, rawNeed := range rawNeeds {
+		if job, ok := rawNeed.(string); ok {
+			needs = append(needs, need{job: job, artifacts: true})
+			continue
+		}
+
+		needConfig, ok := toDataBag(rawNeed)
+		if !ok {
+			return nil, fmt.Errorf("%s: unsupported needs", name)
+		}
+
+		// needs on the jobs of other pipelines can't be run locally
+		if _, ok := needConfig["pipeline"]; ok {
+			continue
+		}
+		if _, ok := needConfig["project"]; ok {
+			continue
+		}
+
+		job, ok := needConfig.GetString("job")
+		if !ok {
+			return nil, fmt.Errorf("%s: unsupported needs", name)
+		}
+
+		n := need{job: job, artifacts: true}
+		if artifacts, ok := needConfig["artifacts"].(bool); ok {
+			n.artifacts = artifacts
+		}
+		if optional, ok := needConfig["optional"].(bool); ok {
+			n.optional = optional
+		}
+
+		needs = append(needs, n)
+	}
+
+	return needs, nil
+}
+
+// NeedsChain returns the names of the jobs to run for the job: the jobs it
+// needs, recursively, ordered so that a job comes after the jobs it needs,
+// and the job itself, last. The optional needs on jobs missing from the
+// configuration are skipped.
+func (c *GitLabCiYamlParser) NeedsChain() ([]string, error) {
+	if c.config == nil {
+		if err := c.parseFile(); err != nil {
+			return nil, err
+		}
+	}
+
+	var chain []string
+	visited := make(map[string]bool)
+
+	var visit func(name string, neededBy []string) error
+	visit = func(name string, neededBy []string) error {
+		for _, job := range neededBy {
+			if job == name {
+				return fmt.Errorf("circular dependency detected in needs: %s",
+					strings.Join(append(neededBy, name), " -> "))
+			}
+		}
+
+		if visited[name] {
+			return nil
+		}
+
+		jobConfig, err := c.resolveJob(name)
+		if err != nil {
+			return err
+		}
+
+		needs, err := jobNeeds(name, jobConfig)
+		if err != nil {
+			return err
+		}
+
+		for _, n := range needs {
+			if _, ok := c.config.GetSubOptions(n.job); !ok && n.optional {
+				continue
+			}
+
+			err := visit(n.job, append(append([]string{}, neededBy...), name))
+			if err != nil {
+				return err
+			}
+		}
+
+		visited[name] = true
+		chain = append(chain, name)
+
+		return nil
+	}
+
+	if err := visit(c.jobName, nil); err != nil {
+		return nil, err
+	}
+
+	return chain, nil
+}
+
 func (c *GitLabCiYamlParser) ParseYaml(job *common.JobResponse) (err error) {
 	err = c.parseFile()
 	if err != nil {
@@ -363,10 +880,12 @@ func (c *GitLabCiYamlParser) ParseYaml(job *common.JobResponse) (err error) {
 		{c.prepareJobInfo},
 		{c.prepareSteps},
 		{c.prepareVariables},
+		{c.prepareRules},
 		{c.prepareImage},
 		{c.prepareServices},
 		{c.prepareArtifacts},
 		{c.prepareCache},
+		{c.prepareDependencies},
 	}
 
 	for _, parser := range parsers {
@@ -383,5 +902,23 @@ func NewGitLabCiYamlParser(configFile, jobName string) *GitLabCiYamlParser {
 	return &GitLabCiYamlParser{
 		filename: configFile,
 		jobName:  jobName,
+		rootDir:  projectRootDir(configFile),
+	}
+}
+
+// projectRootDir returns the directory the local includes and the rules:exists
+// patterns are relative to: the top level of the Git repository holding the
+// config file or, outside of a repository, the directory of the config file
+func projectRootDir(configFile string) string {
+	dir := filepath.Dir(configFile)
+	if absDir, err := filepath.Abs(dir); err == nil {
+		dir = absDir
 	}
+
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return dir
+	}
+
+	return filepath.FromSlash(strings.TrimSpace(string(out)))
 }
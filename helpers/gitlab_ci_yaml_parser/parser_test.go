@@ -4,6 +4,8 @@ package gitlab_ci_yaml_parser
 
 import (
 	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -210,3 +212,405 @@ func TestFileParsing(t *testing.T) {
 	assert.Equal(t, "service-1 42", jobResponse.Services[0].Alias)
 	assert.Equal(t, []string{"service-1", "42"}, jobResponse.Services[0].Aliases())
 }
+
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+	}
+
+	return dir
+}
+
+func parseTestJob(t *testing.T, dir, jobName string) (*common.JobResponse, error) {
+	parser := &GitLabCiYamlParser{
+		filename: filepath.Join(dir, ".gitlab-ci.yml"),
+		jobName:  jobName,
+		rootDir:  dir,
+	}
+
+	jobResponse := &common.JobResponse{GitInfo: common.GitInfo{Ref: "main"}}
+	err := parser.ParseYaml(jobResponse)
+
+	return jobResponse, err
+}
+
+func TestIncludesParsing(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		".gitlab-ci.yml": `
+include:
+- local: /ci/templates.yml
+- ci/jobs/*.yml
+
+variables:
+  GLOBAL: main
+
+lint:
+  image: golang:main
+`,
+		"ci/templates.yml": `
+include: ci/base.yml
+
+variables:
+  GLOBAL: templates
+  TEMPLATES: "true"
+
+.test:
+  script: make test
+`,
+		"ci/base.yml": `
+image: base:image
+`,
+		"ci/jobs/lint.yml": `
+lint:
+  image: golang:included
+  script: make lint
+`,
+	})
+
+	jobResponse, err := parseTestJob(t, dir, "lint")
+	require.NoError(t, err)
+
+	assert.Equal(t, "golang:main", jobResponse.Image.Name)
+	assert.Equal(t, common.StepScript{"make lint"}, jobResponse.Steps[0].Script)
+	assert.Equal(t, "main", jobResponse.Variables.Get("GLOBAL"))
+	assert.Equal(t, "true", jobResponse.Variables.Get("TEMPLATES"))
+}
+
+func TestProjectRootDir(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"repo/ci/.gitlab-ci.yml": "",
+		"plain/.gitlab-ci.yml":   "",
+	})
+	dir, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+
+	repo := filepath.Join(dir, "repo")
+	require.NoError(t, exec.Command("git", "init", "-q", repo).Run())
+
+	assert.Equal(t, repo, projectRootDir(filepath.Join(repo, "ci", ".gitlab-ci.yml")))
+	assert.Equal(t, filepath.Join(dir, "plain"), projectRootDir(filepath.Join(dir, "plain", ".gitlab-ci.yml")))
+}
+
+func TestIncludesLoop(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		".gitlab-ci.yml": "include: a.yml\njob:\n  script: test\n",
+		"a.yml":          "include: b.yml\n",
+		"b.yml":          "include: a.yml\n",
+	})
+
+	_, err := parseTestJob(t, dir, "job")
+	assert.ErrorContains(t, err, "include loop")
+}
+
+func TestExtendsAndDefaultParsing(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		".gitlab-ci.yml": `
+image: global:image
+
+default:
+  image: default:image
+  before_script:
+  - setup
+  after_script:
+  - cleanup
+  cache:
+    key: default
+    paths: [vendor/]
+
+variables:
+  GLOBAL: "true"
+
+.base:
+  variables:
+    BASE: base
+    OVERRIDDEN: base
+  services:
+  - postgres:latest
+  script: base script
+
+.artifacts:
+  artifacts:
+    paths: [bin/]
+
+job:
+  extends: [.base, .artifacts]
+  variables:
+    OVERRIDDEN: job
+  script: job script
+
+no-defaults:
+  inherit:
+    default: false
+    variables: false
+  script: test
+
+some-defaults:
+  inherit:
+    default: [image]
+  script: test
+
+loop:
+  extends: .loop
+  script: test
+
+.loop:
+  extends: loop
+`,
+	})
+
+	jobResponse, err := parseTestJob(t, dir, "job")
+	require.NoError(t, err)
+	assert.Equal(t, "default:image", jobResponse.Image.Name)
+	assert.Equal(t, common.StepScript{"setup", "job script"}, jobResponse.Steps[0].Script)
+	assert.Equal(t, common.StepScript{"cleanup"}, jobResponse.Steps[1].Script)
+	require.Len(t, jobResponse.Services, 1)
+	assert.Equal(t, "postgres:latest", jobResponse.Services[0].Name)
+	assert.Equal(t, "base", jobResponse.Variables.Get("BASE"))
+	assert.Equal(t, "job", jobResponse.Variables.Get("OVERRIDDEN"))
+	assert.Equal(t, "true", jobResponse.Variables.Get("GLOBAL"))
+	assert.Equal(t, common.ArtifactPaths{"bin/"}, jobResponse.Artifacts[0].Paths)
+	assert.Equal(t, "default", jobResponse.Cache[0].Key)
+
+	jobResponse, err = parseTestJob(t, dir, "no-defaults")
+	require.NoError(t, err)
+	assert.Empty(t, jobResponse.Image.Name)
+	assert.Equal(t, common.StepScript{"test"}, jobResponse.Steps[0].Script)
+	assert.Empty(t, jobResponse.Variables.Get("GLOBAL"))
+
+	jobResponse, err = parseTestJob(t, dir, "some-defaults")
+	require.NoError(t, err)
+	assert.Equal(t, "default:image", jobResponse.Image.Name)
+	assert.Equal(t, common.StepScript{"test"}, jobResponse.Steps[0].Script)
+
+	_, err = parseTestJob(t, dir, "loop")
+	assert.ErrorContains(t, err, "circular dependency detected in extends")
+}
+
+func TestRulesParsing(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		".gitlab-ci.yml": `
+variables:
+  DEPLOY: "false"
+
+deploy:
+  rules:
+  - if: $DEPLOY == "true"
+  script: deploy
+
+build:
+  rules:
+  - if: $CI_COMMIT_BRANCH =~ /^release-/
+    when: never
+  - if: $CI_COMMIT_BRANCH == "main" && $CI_PIPELINE_SOURCE == "push"
+    variables:
+      TARGET: production
+  script: build
+
+docs:
+  rules:
+  - exists:
+    - docs/*.md
+  script: docs
+
+invalid:
+  rules:
+  - if: $CI_COMMIT_BRANCH ==
+  script: test
+`,
+	})
+
+	_, err := parseTestJob(t, dir, "deploy")
+	assert.ErrorIs(t, err, ErrJobExcludedByRules)
+
+	jobResponse, err := parseTestJob(t, dir, "build")
+	require.NoError(t, err)
+	assert.Equal(t, "production", jobResponse.Variables.Get("TARGET"))
+
+	_, err = parseTestJob(t, dir, "docs")
+	assert.ErrorIs(t, err, ErrJobExcludedByRules)
+
+	_, err = parseTestJob(t, dir, "invalid")
+	assert.ErrorContains(t, err, "rules:if")
+}
+
+func TestNeedsParsing(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		".gitlab-ci.yml": `
+compile:
+  script: compile
+  artifacts:
+    paths: [bin/]
+
+generate:
+  script: generate
+
+lint:
+  needs: []
+  script: lint
+
+test:
+  needs:
+  - compile
+  - job: generate
+    artifacts: false
+  - job: optional
+    optional: true
+  - pipeline: $PARENT_PIPELINE_ID
+    job: upstream
+  script: test
+
+package:
+  needs: [test, compile]
+  script: package
+
+missing:
+  needs: [unknown]
+  script: test
+
+a:
+  needs: [b]
+  script: test
+
+b:
+  needs: [a]
+  script: test
+`,
+	})
+
+	tests := map[string]struct {
+		jobName              string
+		expectedChain        []string
+		expectedDependencies common.Dependencies
+		expectedError        string
+	}{
+		"no needs": {
+			jobName:              "lint",
+			expectedChain:        []string{"lint"},
+			expectedDependencies: common.Dependencies{},
+		},
+		"needs": {
+			jobName:              "test",
+			expectedChain:        []string{"compile", "generate", "test"},
+			expectedDependencies: common.Dependencies{{Name: "compile"}},
+		},
+		"needs chain": {
+			jobName:              "package",
+			expectedChain:        []string{"compile", "generate", "test", "package"},
+			expectedDependencies: common.Dependencies{{Name: "test"}, {Name: "compile"}},
+		},
+		"missing need": {
+			jobName:       "missing",
+			expectedError: `no job named "unknown"`,
+		},
+		"needs loop": {
+			jobName:       "a",
+			expectedError: "circular dependency detected in needs: a -> b -> a",
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			parser := &GitLabCiYamlParser{
+				filename: filepath.Join(dir, ".gitlab-ci.yml"),
+				jobName:  tt.jobName,
+				rootDir:  dir,
+			}
+
+			chain, err := parser.NeedsChain()
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedChain, chain)
+
+			jobResponse, err := parseTestJob(t, dir, tt.jobName)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedDependencies, jobResponse.Dependencies)
+		})
+	}
+}
+
+func TestDependenciesParsing(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		".gitlab-ci.yml": `
+stages: [build, test, deploy]
+
+.template:
+  stage: build
+
+compile:
+  extends: .template
+  script: compile
+
+generate:
+  stage: build
+  script: generate
+
+prepare:
+  stage: .pre
+  script: prepare
+
+lint:
+  script: lint
+
+test:
+  dependencies: [compile]
+  script: test
+
+deploy:
+  stage: deploy
+  script: deploy
+
+release:
+  stage: deploy
+  dependencies: []
+  script: release
+
+package:
+  stage: deploy
+  needs: [compile, lint]
+  dependencies: [lint]
+  script: package
+`,
+	})
+
+	tests := map[string]struct {
+		jobName              string
+		expectedDependencies common.Dependencies
+	}{
+		"first stage": {
+			jobName:              "prepare",
+			expectedDependencies: common.Dependencies{},
+		},
+		"previous stages": {
+			jobName: "deploy",
+			expectedDependencies: common.Dependencies{
+				{Name: "compile"}, {Name: "generate"}, {Name: "lint"}, {Name: "prepare"}, {Name: "test"},
+			},
+		},
+		"dependencies": {
+			jobName:              "test",
+			expectedDependencies: common.Dependencies{{Name: "compile"}},
+		},
+		"no dependencies": {
+			jobName:              "release",
+			expectedDependencies: common.Dependencies{},
+		},
+		"dependencies with needs": {
+			jobName:              "package",
+			expectedDependencies: common.Dependencies{{Name: "lint"}},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			jobResponse, err := parseTestJob(t, dir, tt.jobName)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedDependencies, jobResponse.Dependencies)
+		})
+	}
+}
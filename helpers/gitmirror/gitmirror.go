@@ -0,0 +1,252 @@
+// Package gitmirror maintains bare reference repositories on the runner host.
+// The repositories are shared by the jobs of the same project and used as the
+// source of `git clone --reference --dissociate`, so that only the missing
+// objects have to be fetched from the GitLab instance.
+package gitmirror
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// gcMarkerFile is the file of the reference repository whose modification time
+// is the time of its latest garbage collection
+const gcMarkerFile = "gitlab-runner-gc"
+
+// defaultGCInterval is the interval at which the reference repositories are
+// garbage collected, as they're fetched into without ever being pruned
+const defaultGCInterval = 24 * time.Hour
+
+var (
+	ErrInvalidRemoteURL = errors.New("invalid remote URL")
+
+	unsafeKeyChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+	managers   = make(map[string]*Manager)
+	managersMu sync.Mutex
+)
+
+var fetchRefspecs = []string{
+	"+refs/heads/*:refs/heads/*",
+	"+refs/tags/*:refs/tags/*",
+}
+
+// Manager refreshes the reference repositories stored in a directory.
+// Refreshes of the same repository are serialized.
+type Manager struct {
+	dir        string
+	git        string
+	gcInterval time.Duration
+
+	lock  sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// Get returns the manager of the directory, shared by all the jobs handled
+// by the process
+func Get(dir string) *Manager {
+	managersMu.Lock()
+	defer managersMu.Unlock()
+
+	dir = filepath.Clean(dir)
+	if m, ok := managers[dir]; ok {
+		return m
+	}
+
+	m := New(dir)
+	managers[dir] = m
+
+	return m
+}
+
+func New(dir string) *Manager {
+	return &Manager{
+		dir:        dir,
+		git:        "git",
+		gcInterval: defaultGCInterval,
+		locks:      make(map[string]*sync.Mutex),
+	}
+}
+
+// Key returns the name of the reference repository of the project, built
+// from the host and path of its remote URL
+func Key(remoteURL string) (string, error) {
+	u, err := url.Parse(remoteURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidRemoteURL, err)
+	}
+
+	projectPath := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if u.Host == "" || projectPath == "" {
+		return "", ErrInvalidRemoteURL
+	}
+
+	parts := []string{sanitize(u.Host)}
+	for _, part := range strings.Split(projectPath, "/") {
+		if part == "" || part == "." || part == ".." {
+			continue
+		}
+
+		parts = append(parts, sanitize(part))
+	}
+
+	return filepath.Join(parts...) + ".git", nil
+}
+
+func sanitize(part string) string {
+	return unsafeKeyChars.ReplaceAllString(part, "_")
+}
+
+// Path returns the location of the reference repository of the project
+func (m *Manager) Path(remoteURL string) (string, error) {
+	key, err := Key(remoteURL)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(m.dir, key), nil
+}
+
+// RefreshOptions configures how the reference repository is fetched
+type RefreshOptions struct {
+	// CAFile is the file with the certificates used to verify the remote
+	CAFile string
+}
+
+// Refresh creates the reference repository of the project if needed and
+// fetches its branches and tags. The remote URL, which may hold credentials,
+// is never stored in the repository configuration. The repository is garbage
+// collected in the background when it's due.
+func (m *Manager) Refresh(ctx context.Context, remoteURL string, opts RefreshOptions) (string, error) {
+	repoPath, err := m.Path(remoteURL)
+	if err != nil {
+		return "", err
+	}
+
+	lock := m.repoLock(repoPath)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := os.Stat(filepath.Join(repoPath, "HEAD")); err != nil {
+		err = m.initRepo(ctx, repoPath)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	args := []string{"fetch", "--prune", "--no-tags", "--quiet"}
+	if opts.CAFile != "" {
+		args = append([]string{"-c", "http.sslCAInfo=" + opts.CAFile}, args...)
+	}
+	args = append(args, remoteURL)
+	args = append(args, fetchRefspecs...)
+
+	err = m.run(ctx, repoPath, args...)
+	if err != nil {
+		return "", fmt.Errorf("fetching reference repository: %w", err)
+	}
+
+	if m.isGCDue(repoPath) {
+		go m.collectGarbage(repoPath)
+	}
+
+	return repoPath, nil
+}
+
+func (m *Manager) isGCDue(repoPath string) bool {
+	info, err := os.Stat(filepath.Join(repoPath, gcMarkerFile))
+
+	return err != nil || time.Since(info.ModTime()) >= m.gcInterval
+}
+
+// collectGarbage repacks the reference repository and prunes its unreachable
+// objects. The clones don't use its objects through alternates, so they're
+// not affected.
+func (m *Manager) collectGarbage(repoPath string) {
+	lock := m.repoLock(repoPath)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// the marker is updated first, so that a failing garbage collection
+	// isn't retried by every job
+	err := m.touchGCMarker(repoPath)
+	if err == nil {
+		err = m.run(context.Background(), repoPath, "gc", "--quiet")
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("repository", repoPath).
+			Warningln("Failed to garbage collect the reference repository")
+	}
+}
+
+func (m *Manager) touchGCMarker(repoPath string) error {
+	err := os.WriteFile(filepath.Join(repoPath, gcMarkerFile), nil, 0o644)
+	if err != nil {
+		return fmt.Errorf("updating garbage collection marker: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Manager) initRepo(ctx context.Context, repoPath string) error {
+	err := os.MkdirAll(repoPath, 0o755)
+	if err != nil {
+		return fmt.Errorf("creating reference repository directory: %w", err)
+	}
+
+	err = m.run(ctx, repoPath, "init", "--quiet", "--bare")
+	if err != nil {
+		return fmt.Errorf("initializing reference repository: %w", err)
+	}
+
+	// the reference repository is garbage collected periodically instead of
+	// by the fetches, which would make the jobs wait for it
+	err = m.run(ctx, repoPath, "config", "gc.auto", "0")
+	if err != nil {
+		return err
+	}
+
+	return m.touchGCMarker(repoPath)
+}
+
+func (m *Manager) repoLock(repoPath string) *sync.Mutex {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	lock, ok := m.locks[repoPath]
+	if !ok {
+		lock = new(sync.Mutex)
+		m.locks[repoPath] = lock
+	}
+
+	return lock
+}
+
+func (m *Manager) run(ctx context.Context, dir string, args ...string) error {
+	var stderr bytes.Buffer
+
+	// #nosec G204
+	cmd := exec.CommandContext(ctx, m.git, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
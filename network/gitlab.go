@@ -96,7 +96,7 @@ func (n *GitLabClient) getRunnerVersion(config common.RunnerConfig) common.Versi
 	n.getFeatures(&info.Features)
 
 	if executorProvider := common.GetExecutorProvider(config.Executor); executorProvider != nil {
-		_ = executorProvider.GetFeatures(&info.Features)
+		_ = common.GetRunnerFeatures(executorProvider, &config, &info.Features)
 
 		if info.Shell == "" {
 			info.Shell = executorProvider.GetDefaultShell()
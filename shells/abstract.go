@@ -19,6 +19,8 @@ import (
 
 var errUnknownGitStrategy = errors.New("unknown GIT_STRATEGY")
 
+const sparseCheckoutFile = ".git/info/sparse-checkout"
+
 type stringQuoter func(string) string
 
 func singleQuote(s string) string {
@@ -244,7 +246,7 @@ func (b *AbstractShell) writePrepareScript(ctx context.Context, w ShellWriter, i
 	return nil
 }
 
-func (b *AbstractShell) writeGetSourcesScript(_ context.Context, w ShellWriter, info common.ShellScriptInfo) error {
+func (b *AbstractShell) writeGetSourcesScript(ctx context.Context, w ShellWriter, info common.ShellScriptInfo) error {
 	b.writeExports(w, info)
 
 	if !info.Build.IsSharedEnv() {
@@ -266,7 +268,7 @@ func (b *AbstractShell) writeGetSourcesScript(_ context.Context, w ShellWriter,
 		return s
 	})
 
-	if err := b.writeCloneFetchCmds(w, info); err != nil {
+	if err := b.writeCloneFetchCmds(ctx, w, info); err != nil {
 		return err
 	}
 
@@ -348,7 +350,7 @@ func (b *AbstractShell) writeGitSSLConfig(w ShellWriter, build *common.Build, wh
 	}
 }
 
-func (b *AbstractShell) writeCloneFetchCmds(w ShellWriter, info common.ShellScriptInfo) error {
+func (b *AbstractShell) writeCloneFetchCmds(ctx context.Context, w ShellWriter, info common.ShellScriptInfo) error {
 	build := info.Build
 
 	// If LFS smudging was disabled by the user (by setting the GIT_LFS_SKIP_SMUDGE variable
@@ -363,12 +365,13 @@ func (b *AbstractShell) writeCloneFetchCmds(w ShellWriter, info common.ShellScri
 		w.Variable(common.JobVariable{Key: "GIT_LFS_SKIP_SMUDGE", Value: "1"})
 	}
 
-	err := b.handleGetSourcesStrategy(w, build)
+	err := b.handleGetSourcesStrategy(ctx, w, info)
 	if err != nil {
 		return err
 	}
 
 	if build.GetGitCheckout() {
+		b.writeSparseCheckoutCmd(w, build)
 		b.writeCheckoutCmd(w, build)
 
 		// If LFS smudging was disabled by the user (by setting the GIT_LFS_SKIP_SMUDGE variable
@@ -396,15 +399,16 @@ func (b *AbstractShell) writeCloneFetchCmds(w ShellWriter, info common.ShellScri
 	return nil
 }
 
-func (b *AbstractShell) handleGetSourcesStrategy(w ShellWriter, build *common.Build) error {
+func (b *AbstractShell) handleGetSourcesStrategy(ctx context.Context, w ShellWriter, info common.ShellScriptInfo) error {
+	build := info.Build
 	projectDir := build.FullProjectDir()
 
 	switch build.GetGitStrategy() {
 	case common.GitFetch:
-		b.writeRefspecFetchCmd(w, build, projectDir)
+		return b.writeRefspecFetchCmd(ctx, w, info, projectDir)
 	case common.GitClone:
 		w.RmDir(projectDir)
-		b.writeRefspecFetchCmd(w, build, projectDir)
+		return b.writeRefspecFetchCmd(ctx, w, info, projectDir)
 	case common.GitNone:
 		w.Noticef("Skipping Git repository setup")
 		w.MkDir(projectDir)
@@ -416,9 +420,20 @@ func (b *AbstractShell) handleGetSourcesStrategy(w ShellWriter, build *common.Bu
 }
 
 //nolint:funlen
-func (b *AbstractShell) writeRefspecFetchCmd(w ShellWriter, build *common.Build, projectDir string) {
+func (b *AbstractShell) writeRefspecFetchCmd(
+	ctx context.Context,
+	w ShellWriter,
+	info common.ShellScriptInfo,
+	projectDir string,
+) error {
+	build := info.Build
 	depth := build.GitInfo.Depth
 
+	filter, err := build.GetGitCloneFilter()
+	if err != nil {
+		return err
+	}
+
 	if depth > 0 {
 		w.Noticef("Fetching changes with git depth set to %d...", depth)
 	} else {
@@ -450,12 +465,17 @@ func (b *AbstractShell) writeRefspecFetchCmd(w ShellWriter, build *common.Build,
 
 	b.writeGitCleanup(w, build.GetSubmoduleStrategy(), projectDir)
 
+	if build.GitMirrorDir != "" {
+		b.writeGitMirrorCloneCmd(w, build, projectDir, templateDir)
+	}
+
 	w.Command("git", "init", projectDir, "--template", templateDir)
 	w.Cd(projectDir)
 
 	// Add `git remote` or update existing
 	w.IfCmd("git", "remote", "add", "origin", build.GetRemoteURL())
 	w.Noticef("Created fresh repository.")
+	b.writeGitBundleSeedCmd(ctx, w, info)
 	w.Else()
 	w.Command("git", "remote", "set-url", "origin", build.GetRemoteURL())
 	w.EndIf()
@@ -469,6 +489,12 @@ func (b *AbstractShell) writeRefspecFetchCmd(w ShellWriter, build *common.Build,
 		fetchArgs = append(fetchArgs, "--depth", strconv.Itoa(depth))
 	}
 
+	if filter != "" {
+		// the fetched objects are filtered, the missing ones are
+		// fetched on demand by the checkout
+		fetchArgs = append(fetchArgs, "--filter="+filter)
+	}
+
 	fetchArgs = append(fetchArgs, build.GetGitFetchFlags()...)
 
 	if depth <= 0 {
@@ -482,6 +508,36 @@ func (b *AbstractShell) writeRefspecFetchCmd(w ShellWriter, build *common.Build,
 	} else {
 		w.Command("git", fetchArgs...)
 	}
+
+	return nil
+}
+
+// writeGitMirrorCloneCmd creates the repository from the reference repository
+// shared by the jobs of the project, so that only the missing objects are
+// fetched from the remote. The objects are copied, so that the repository
+// doesn't depend on the reference repository once cloned. Existing
+// repositories cloned with alternates to the reference repository are
+// dissociated from it.
+func (b *AbstractShell) writeGitMirrorCloneCmd(w ShellWriter, build *common.Build, projectDir, templateDir string) {
+	if build.SafeDirectoryCheckout {
+		w.Command("git", "config", "--global", "--add", "safe.directory", build.GitMirrorDir)
+	}
+
+	alternatesFile := path.Join(projectDir, ".git", "objects", "info", "alternates")
+
+	w.IfDirectory(path.Join(projectDir, ".git"))
+	w.Noticef("Reusing existing Git repository...")
+	w.IfFile(alternatesFile)
+	w.Command("git", "-C", projectDir, "repack", "-a", "-d", "-q")
+	w.RmFile(alternatesFile)
+	w.EndIf()
+	w.Else()
+	w.Noticef("Cloning from the reference repository...")
+	w.Command(
+		"git", "clone", "--reference", build.GitMirrorDir, "--dissociate", "--no-checkout", "--quiet",
+		"--template", templateDir, build.GitMirrorDir, projectDir,
+	)
+	w.EndIf()
 }
 
 func (b *AbstractShell) writeGitCleanup(w ShellWriter, submoduleStrategy common.SubmoduleStrategy, projectDir string) {
@@ -507,6 +563,23 @@ func (b *AbstractShell) writeGitCleanup(w ShellWriter, submoduleStrategy common.
 	}
 }
 
+// writeSparseCheckoutCmd limits the checkout to the directories listed in
+// GIT_SPARSE_CHECKOUT_PATHS, or restores the full checkout of a repository
+// left sparse by a previous job
+func (b *AbstractShell) writeSparseCheckoutCmd(w ShellWriter, build *common.Build) {
+	paths := build.GetGitSparseCheckoutPaths()
+	if len(paths) == 0 {
+		w.IfFile(sparseCheckoutFile)
+		w.Command("git", "sparse-checkout", "disable")
+		w.RmFile(sparseCheckoutFile)
+		w.EndIf()
+		return
+	}
+
+	w.Noticef("Setting sparse checkout to %s...", strings.Join(paths, " "))
+	w.Command("git", append([]string{"sparse-checkout", "set", "--cone", "--"}, paths...)...)
+}
+
 func (b *AbstractShell) writeCheckoutCmd(w ShellWriter, build *common.Build) {
 	w.Noticef("Checking out %s as detached HEAD (ref is %s)...", build.GitInfo.Sha[0:8], build.GitInfo.Ref)
 	w.Command("git", "checkout", "-f", "-q", build.GitInfo.Sha)
@@ -551,6 +624,16 @@ func (b *AbstractShell) writeSubmoduleUpdateCmd(w ShellWriter, build *common.Bui
 		return err
 	}
 
+	filter, err := build.GetGitCloneFilter()
+	if err != nil {
+		return err
+	}
+
+	// submodules outside of the sparse checkout aren't needed
+	if len(submodulePaths) == 0 && build.GetGitCheckout() {
+		submodulePaths = build.GetGitSparseCheckoutPaths()
+	}
+
 	if len(submodulePaths) != 0 {
 		pathArgs = append(pathArgs, "--")
 		pathArgs = append(pathArgs, submodulePaths...)
@@ -577,6 +660,9 @@ func (b *AbstractShell) writeSubmoduleUpdateCmd(w ShellWriter, build *common.Bui
 	if depth > 0 {
 		updateArgs = append(updateArgs, "--depth", strconv.Itoa(depth))
 	}
+	if filter != "" {
+		updateArgs = append(updateArgs, "--filter="+filter)
+	}
 	updateArgs = append(updateArgs, build.GetGitSubmoduleUpdateFlags()...)
 	updateArgs = append(updateArgs, pathArgs...)
 
@@ -814,6 +900,32 @@ func (b *AbstractShell) addCacheUploadCommand(
 	archiverArgs []string,
 	cacheKey string,
 ) {
+	args := cacheArchiverArgs(ctx, info, cacheFile, archiverArgs, cacheKey)
+	env := cache.GetCacheUploadEnv(info.Build, cacheKey)
+
+	// Execute cache-archiver command. Failure is not fatal.
+	b.guardRunnerCommand(w, info.RunnerCommand, "Creating cache", func() {
+		w.Noticef("Creating cache %s...", cacheKey)
+
+		for key, value := range env {
+			w.Variable(common.JobVariable{Key: key, Value: value})
+		}
+
+		w.IfCmdWithOutput(info.RunnerCommand, args...)
+		w.Noticef("Created cache")
+		w.Else()
+		w.Warningf("Failed to create cache")
+		w.EndIf()
+	})
+}
+
+func cacheArchiverArgs(
+	ctx context.Context,
+	info common.ShellScriptInfo,
+	cacheFile string,
+	archiverArgs []string,
+	cacheKey string,
+) []string {
 	args := []string{
 		"cache-archiver",
 		"--file", cacheFile,
@@ -831,24 +943,7 @@ func (b *AbstractShell) addCacheUploadCommand(
 	args = append(args, archiverArgs...)
 
 	// Generate cache upload address
-	args = append(args, getCacheUploadURL(ctx, info.Build, cacheKey)...)
-
-	env := cache.GetCacheUploadEnv(info.Build, cacheKey)
-
-	// Execute cache-archiver command. Failure is not fatal.
-	b.guardRunnerCommand(w, info.RunnerCommand, "Creating cache", func() {
-		w.Noticef("Creating cache %s...", cacheKey)
-
-		for key, value := range env {
-			w.Variable(common.JobVariable{Key: key, Value: value})
-		}
-
-		w.IfCmdWithOutput(info.RunnerCommand, args...)
-		w.Noticef("Created cache")
-		w.Else()
-		w.Warningf("Failed to create cache")
-		w.EndIf()
-	})
+	return append(args, getCacheUploadURL(ctx, info.Build, cacheKey)...)
 }
 
 // getCacheUploadURL will first try to generate the GoCloud URL if it's
@@ -1146,6 +1241,7 @@ func (b *AbstractShell) writeScript(
 		common.BuildStageArchiveOnFailureCache:    b.writeArchiveCacheOnFailureScript,
 		common.BuildStageUploadOnSuccessArtifacts: b.writeUploadArtifactsOnSuccessScript,
 		common.BuildStageUploadOnFailureArtifacts: b.writeUploadArtifactsOnFailureScript,
+		common.BuildStageCreateGitBundle:          b.writeCreateGitBundleScript,
 		common.BuildStageCleanup:                  b.writeCleanupScript,
 	}
 
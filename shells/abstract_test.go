@@ -830,7 +830,141 @@ func TestGitFetchFlags(t *testing.T) {
 				mockWriter.EXPECT().Command("git", command...).Once()
 			}
 
-			shell.writeRefspecFetchCmd(mockWriter, build, dummyProjectDir)
+			err := shell.writeRefspecFetchCmd(
+				context.Background(),
+				mockWriter,
+				common.ShellScriptInfo{Build: build},
+				dummyProjectDir,
+			)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestAbstractShell_writeGitMirrorCloneCmd(t *testing.T) {
+	const (
+		mirrorDir   = "/gitlab-runner-git-mirror"
+		projectDir  = "/builds/group/project"
+		templateDir = "/tmp/git-template"
+	)
+
+	for _, safeDirectoryCheckout := range []bool{true, false} {
+		t.Run(fmt.Sprintf("safe directory checkout %v", safeDirectoryCheckout), func(t *testing.T) {
+			shell := AbstractShell{}
+			build := &common.Build{
+				Runner:                &common.RunnerConfig{},
+				SafeDirectoryCheckout: safeDirectoryCheckout,
+				GitMirrorDir:          mirrorDir,
+			}
+
+			mockWriter := NewMockShellWriter(t)
+			if safeDirectoryCheckout {
+				mockWriter.EXPECT().Command("git", "config", "--global", "--add", "safe.directory", mirrorDir).Once()
+			}
+			mockWriter.EXPECT().IfDirectory(projectDir + "/.git").Once()
+			mockWriter.EXPECT().Noticef("Reusing existing Git repository...").Once()
+			mockWriter.EXPECT().IfFile(projectDir + "/.git/objects/info/alternates").Once()
+			mockWriter.EXPECT().Command("git", "-C", projectDir, "repack", "-a", "-d", "-q").Once()
+			mockWriter.EXPECT().RmFile(projectDir + "/.git/objects/info/alternates").Once()
+			mockWriter.EXPECT().Else().Once()
+			mockWriter.EXPECT().Noticef("Cloning from the reference repository...").Once()
+			mockWriter.EXPECT().Command(
+				"git", "clone", "--reference", mirrorDir, "--dissociate", "--no-checkout", "--quiet",
+				"--template", templateDir, mirrorDir, projectDir,
+			).Once()
+			mockWriter.EXPECT().EndIf().Twice()
+
+			shell.writeGitMirrorCloneCmd(mockWriter, build, projectDir, templateDir)
+		})
+	}
+}
+
+func TestAbstractShell_writeSparseCheckoutCmd(t *testing.T) {
+	tests := map[string]struct {
+		paths         string
+		setupExpected func(w *MockShellWriter)
+	}{
+		"no sparse checkout paths": {
+			setupExpected: func(w *MockShellWriter) {
+				w.EXPECT().IfFile(".git/info/sparse-checkout").Once()
+				w.EXPECT().Command("git", "sparse-checkout", "disable").Once()
+				w.EXPECT().RmFile(".git/info/sparse-checkout").Once()
+				w.EXPECT().EndIf().Once()
+			},
+		},
+		"sparse checkout paths": {
+			paths: "services/api libs",
+			setupExpected: func(w *MockShellWriter) {
+				w.EXPECT().Noticef("Setting sparse checkout to %s...", "services/api libs").Once()
+				w.EXPECT().Command("git", "sparse-checkout", "set", "--cone", "--", "services/api", "libs").Once()
+			},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			build := &common.Build{
+				Runner: &common.RunnerConfig{},
+				JobResponse: common.JobResponse{
+					Variables: common.JobVariables{
+						{Key: "GIT_SPARSE_CHECKOUT_PATHS", Value: tt.paths},
+					},
+				},
+			}
+
+			mockWriter := NewMockShellWriter(t)
+			tt.setupExpected(mockWriter)
+
+			shell := AbstractShell{}
+			shell.writeSparseCheckoutCmd(mockWriter, build)
+		})
+	}
+}
+
+func TestAbstractShell_writeCloneFetchCmdsPartialClone(t *testing.T) {
+	writers := map[string]func() ShellWriter{
+		"bash":       func() ShellWriter { return &BashWriter{TemporaryPath: "/tmp"} },
+		"powershell": func() ShellWriter { return &PsWriter{Shell: SNPowershell, EOL: "\r\n", TemporaryPath: "/tmp"} },
+		"cmd":        func() ShellWriter { return &CmdWriter{TemporaryPath: "/tmp"} },
+	}
+
+	newBuild := func(filter string) *common.Build {
+		return &common.Build{
+			Runner: &common.RunnerConfig{},
+			JobResponse: common.JobResponse{
+				GitInfo: common.GitInfo{
+					RepoURL: "https://gitlab.example.com/group/project.git",
+					Sha:     "01234567abcdef",
+					Ref:     "main",
+				},
+				Variables: common.JobVariables{
+					{Key: "GIT_STRATEGY", Value: "fetch"},
+					{Key: "GIT_CLONE_FILTER", Value: filter},
+					{Key: "GIT_SPARSE_CHECKOUT_PATHS", Value: "services/api libs"},
+					{Key: "GIT_SUBMODULE_STRATEGY", Value: "normal"},
+				},
+			},
+			BuildDir: "/builds/group/project",
+		}
+	}
+
+	for name, newWriter := range writers {
+		t.Run(name, func(t *testing.T) {
+			shell := AbstractShell{}
+			w := newWriter()
+			build := newBuild(common.GitCloneFilterBlobNone)
+			info := common.ShellScriptInfo{Build: build}
+
+			require.NoError(t, shell.writeCloneFetchCmds(context.Background(), w, info))
+			require.NoError(t, shell.writeSubmoduleUpdateCmds(w, info))
+
+			script := w.Finish(false)
+			assert.Contains(t, script, "--filter=blob:none")
+			assert.Contains(t, script, "sparse-checkout")
+			assert.Regexp(t, `submodule.+update.+--filter=blob:none.+--.+services/api.+libs`, script)
+
+			err := shell.writeCloneFetchCmds(context.Background(), newWriter(), common.ShellScriptInfo{Build: newBuild("sparse:oid=main")})
+			assert.ErrorContains(t, err, "invalid GIT_CLONE_FILTER")
 		})
 	}
 }
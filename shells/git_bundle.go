@@ -0,0 +1,94 @@
+package shells
+
+import (
+	"context"
+	"strconv"
+
+	"gitlab.com/gitlab-org/gitlab-runner/cache"
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+const (
+	gitBundleCacheKey = "gitlab-runner-git-bundle"
+	gitBundleFile     = ".git/gitlab-runner.bundle"
+)
+
+// writeGitBundleSeedCmd fetches the objects of the git bundle stored in the
+// cache into a fresh repository, so that only the newer objects are fetched
+// from the remote. Failures aren't fatal.
+func (b *AbstractShell) writeGitBundleSeedCmd(ctx context.Context, w ShellWriter, info common.ShellScriptInfo) {
+	build := info.Build
+	if build.Runner.Cache.GetGitBundleInterval() <= 0 {
+		return
+	}
+
+	_, archiveFile, err := b.cacheFile(build, gitBundleCacheKey)
+	if err != nil {
+		w.Noticef("Skipping git bundle extraction due to %v", err)
+		return
+	}
+
+	args := []string{
+		"cache-extractor",
+		"--file", archiveFile,
+		"--timeout", strconv.Itoa(build.GetCacheRequestTimeout()),
+	}
+
+	if url := cache.GetCacheDownloadURL(ctx, build, gitBundleCacheKey); url != nil {
+		args = append(args, "--url", url.String())
+	}
+
+	b.guardRunnerCommand(w, info.RunnerCommand, "Extracting git bundle", func() {
+		w.Noticef("Checking git bundle...")
+		w.IfCmdWithOutput(info.RunnerCommand, args...)
+		w.IfCmd("git", "fetch", "--quiet", gitBundleFile, "+refs/remotes/origin/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*")
+		w.Noticef("Seeded repository from git bundle")
+		w.Else()
+		w.Warningf("Failed to seed repository from git bundle")
+		w.EndIf()
+		w.RmFile(gitBundleFile)
+		w.Else()
+		w.Noticef("Git bundle is not available")
+		w.EndIf()
+	})
+}
+
+// writeCreateGitBundleScript produces the git bundle of the project from the
+// repository fetched by the job and stores it in the cache. Unlike the other
+// cache uploads, a failure fails the stage, so that the next job of the
+// project produces the bundle instead.
+func (b *AbstractShell) writeCreateGitBundleScript(
+	ctx context.Context,
+	w ShellWriter,
+	info common.ShellScriptInfo,
+) error {
+	build := info.Build
+	if !build.CreatesGitBundle() {
+		return common.ErrSkipBuildStage
+	}
+
+	_, archiveFile, err := b.cacheFile(build, gitBundleCacheKey)
+	if err != nil {
+		return common.ErrSkipBuildStage
+	}
+
+	b.writeExports(w, info)
+	b.writeCdBuildDir(w, info)
+
+	b.guardRunnerCommand(w, info.RunnerCommand, "Creating git bundle", func() {
+		for key, value := range cache.GetCacheUploadEnv(build, gitBundleCacheKey) {
+			w.Variable(common.JobVariable{Key: key, Value: value})
+		}
+
+		w.Noticef("Creating git bundle...")
+		w.RmFile(gitBundleFile)
+		w.Command("git", "bundle", "create", gitBundleFile, "--remotes=origin", "--tags")
+		w.Command(
+			info.RunnerCommand,
+			cacheArchiverArgs(ctx, info, archiveFile, []string{"--path", gitBundleFile}, gitBundleCacheKey)...,
+		)
+		w.RmFile(gitBundleFile)
+	})
+
+	return nil
+}
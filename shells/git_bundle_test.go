@@ -0,0 +1,124 @@
+//go:build !integration
+
+package shells
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+func newGitBundleTestBuild(interval time.Duration, variables ...common.JobVariable) *common.Build {
+	return &common.Build{
+		Runner: &common.RunnerConfig{
+			RunnerCredentials: common.RunnerCredentials{Token: "runner-token"},
+			RunnerSettings: common.RunnerSettings{
+				Cache: &common.CacheConfig{GitBundleInterval: &interval},
+			},
+		},
+		JobResponse: common.JobResponse{
+			JobInfo:   common.JobInfo{ProjectID: 1},
+			GitInfo:   common.GitInfo{RepoURL: "https://gitlab.example.com/group/project.git"},
+			Variables: variables,
+		},
+		BuildDir: "/builds/group/project",
+		CacheDir: "/cache/group/project",
+	}
+}
+
+func TestAbstractShell_writeGitBundleSeedCmd(t *testing.T) {
+	tests := map[string]struct {
+		interval         time.Duration
+		expectedContains []string
+	}{
+		"git bundles disabled": {},
+		"git bundles enabled": {
+			interval: time.Hour,
+			expectedContains: []string{
+				"cache-extractor --file ../../../cache/group/project/gitlab-runner-git-bundle/cache.zip",
+				"fetch --quiet .git/gitlab-runner.bundle",
+				"Git bundle is not available",
+			},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			w := &BashWriter{TemporaryPath: "/tmp"}
+			info := common.ShellScriptInfo{
+				Build:         newGitBundleTestBuild(tt.interval),
+				RunnerCommand: "gitlab-runner-helper",
+			}
+
+			shell := AbstractShell{}
+			shell.writeGitBundleSeedCmd(context.Background(), w, info)
+
+			script := w.Finish(false)
+			if len(tt.expectedContains) == 0 {
+				assert.NotContains(t, script, "bundle")
+				return
+			}
+
+			for _, expected := range tt.expectedContains {
+				assert.Contains(t, script, expected)
+			}
+		})
+	}
+}
+
+func TestAbstractShell_writeCreateGitBundleScript(t *testing.T) {
+	tests := map[string]struct {
+		interval       time.Duration
+		depth          int
+		variables      []common.JobVariable
+		expectedCreate bool
+	}{
+		"git bundles disabled": {},
+		"shallow clone": {
+			interval: time.Hour,
+			depth:    20,
+		},
+		"partial clone": {
+			interval:  time.Hour,
+			variables: []common.JobVariable{{Key: "GIT_CLONE_FILTER", Value: "blob:none"}},
+		},
+		"git strategy none": {
+			interval:  time.Hour,
+			variables: []common.JobVariable{{Key: "GIT_STRATEGY", Value: "none"}},
+		},
+		"git bundles enabled": {
+			interval:       time.Hour,
+			expectedCreate: true,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			build := newGitBundleTestBuild(tt.interval, tt.variables...)
+			build.GitInfo.Depth = tt.depth
+
+			w := &BashWriter{TemporaryPath: "/tmp"}
+			info := common.ShellScriptInfo{Build: build, RunnerCommand: "gitlab-runner-helper"}
+
+			shell := AbstractShell{}
+			err := shell.writeCreateGitBundleScript(context.Background(), w, info)
+			if !tt.expectedCreate {
+				assert.ErrorIs(t, err, common.ErrSkipBuildStage)
+				return
+			}
+
+			require.NoError(t, err)
+
+			script := w.Finish(false)
+			assert.Contains(t, script, "bundle create .git/gitlab-runner.bundle")
+			assert.Contains(t, script, "cache-archiver --file ../../../cache/group/project/gitlab-runner-git-bundle/cache.zip")
+			assert.Contains(t, script, "--path .git/gitlab-runner.bundle")
+			assert.NotContains(t, script, "Failed to create cache", "a failing upload fails the stage")
+		})
+	}
+}